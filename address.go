@@ -0,0 +1,112 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// address.go
+//
+// The address block on the back cover of mailed calendars, placed
+// where the window of the envelope shows it.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"strconv"
+	"strings"
+)
+
+// ADDRESSFONTSIZE is the font size of the address in points.
+const ADDRESSFONTSIZE = 11.0
+
+// RETURNADDRESSFONTSIZE is the font size of the return address.
+const RETURNADDRESSFONTSIZE = 7.0
+
+// addressWindows are the address fields of windowed envelopes, from
+// the top left corner of the page in mm.
+var addressWindows = map[string]rect{
+	"din":  {20, 45, 85, 45},  // DIN 5008 form B, DL and C5/6 envelopes
+	"dina": {20, 27, 85, 45},  // DIN 5008 form A
+	"us10": {22, 51, 102, 29}, // #10 envelope, letter folded in three
+}
+
+// parseAddressWindow returns the address field of one of the
+// addressWindows, or of "x,y" or "x,y,w,h" in mm.
+func parseAddressWindow(spec string) (rect, error) {
+	if r, ok := addressWindows[spec]; ok {
+		return r, nil
+	}
+	parts := strings.Split(spec, ",")
+	if len(parts) != 2 && len(parts) != 4 {
+		return rect{}, fmt.Errorf("invalid address block '%s', use din, dina, us10, x,y or x,y,w,h", spec)
+	}
+	v := []float64{0, 0, 85, 45}
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || f < 0 {
+			return rect{}, fmt.Errorf("invalid number '%s' in address block", p)
+		}
+		v[i] = f
+	}
+	return rect{v[0], v[1], v[2], v[3]}, nil
+}
+
+// addressLines returns the lines of the address of the recipient:
+// the name and the address column, with lines separated by newlines
+// or semicolons.
+func (g *Calendar) addressLines() (lines []string) {
+	if name := strings.TrimSpace(g.recipient["name"]); name != "" {
+		lines = append(lines, name)
+	}
+	address := strings.Replace(g.recipient["address"], ";", "\n", -1)
+	for _, l := range strings.Split(address, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// addBackCover adds the back cover with the address of the recipient
+// in the address field, below the return address.
+func (g *Calendar) addBackCover(pdf *gofpdf.Fpdf, fonts elementFonts, fontScale float64, width, height float64) {
+	r, err := parseAddressWindow(g.OptAddressBlock)
+	if err != nil {
+		fmt.Printf("# Error in address block: %v\n", err)
+		return
+	}
+	pdf.AddPage()
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+	y := r.y
+	if g.OptReturnAddress != "" {
+		fonts.set(pdf, "footer", RETURNADDRESSFONTSIZE)
+		y += pdf.PointConvert(RETURNADDRESSFONTSIZE) * 1.2
+		ret := convertCP(g.recipient.Expand(g.OptReturnAddress))
+		pdf.Text(r.x, y, ret)
+		lw := pdf.GetLineWidth()
+		pdf.SetLineWidth(0.1)
+		pdf.Line(r.x, y+0.8, r.x+pdf.GetStringWidth(ret), y+0.8)
+		pdf.SetLineWidth(lw)
+		y += 2
+	}
+	lines := g.addressLines()
+	if len(lines) == 0 {
+		g.warnf("field", "No address for the address block of '%s'", g.recipient["name"])
+	}
+	fonts.set(pdf, "footer", ADDRESSFONTSIZE)
+	lh := pdf.PointConvert(ADDRESSFONTSIZE) * 1.2
+	for i, l := range lines {
+		if y+lh > r.y+r.h {
+			g.warnf("field", "The address of '%s' has %d lines more than the window shows", g.recipient["name"], len(lines)-i)
+			break
+		}
+		y += lh
+		pdf.Text(r.x, y, convertCP(l))
+	}
+	g.setFooterColor(pdf)
+	fonts.set(pdf, "footer", FOOTERFONTSIZE*fontScale)
+	pdf.Text(0.50*width-pdf.GetStringWidth(g.footer())*0.5, 0.95*height, g.footer())
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+}
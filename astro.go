@@ -0,0 +1,193 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// astro.go
+//
+// Sun related computations for a location on earth.
+//
+
+import (
+	"fmt"
+	"github.com/soniakeys/meeus/v3/deltat"
+	"github.com/soniakeys/meeus/v3/globe"
+	"github.com/soniakeys/meeus/v3/julian"
+	"github.com/soniakeys/meeus/v3/rise"
+	"github.com/soniakeys/meeus/v3/sidereal"
+	"github.com/soniakeys/meeus/v3/solar"
+	"github.com/soniakeys/unit"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// J2000 is the Julian date of the epoch J2000.0.
+	J2000 = 2451545.0
+	// CANDLELIGHTING is the default number of minutes before sunset
+	// on Friday.
+	CANDLELIGHTING = 18
+	// HAVDALAH is the default number of minutes after sunset on
+	// Saturday.
+	HAVDALAH = 42
+)
+
+func sinDeg(deg float64) float64 { return math.Sin(deg * math.Pi / 180) }
+func cosDeg(deg float64) float64 { return math.Cos(deg * math.Pi / 180) }
+
+// getLocation returns the time zone by IANA name, the local
+// time zone if the name is empty.
+func getLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("# Error loading time zone '%s', using local time: %v\n", name, err)
+		return time.Local
+	}
+	return loc
+}
+
+// jdToTime converts a Julian date into a time in UTC.
+func jdToTime(jd float64) time.Time {
+	sec := (jd - 2440587.5) * 86400
+	return time.Unix(int64(math.Floor(sec)), 0).UTC()
+}
+
+// parseLocation parses a location given as "latitude,longitude" in
+// decimal degrees, north and east positive.
+func parseLocation(in string) (lat, lon float64, err error) {
+	parts := strings.Split(in, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid location '%s', expected latitude,longitude", in)
+	}
+	lat, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("invalid latitude in '%s'", in)
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid longitude in '%s'", in)
+	}
+	return lat, lon, nil
+}
+
+// deltaT returns the difference between dynamical and universal time
+// in the year, with the table of Meeus where it applies.
+func deltaT(jde float64, year int) unit.Time {
+	switch {
+	case year < 948:
+		return deltat.PolyBefore948(float64(year))
+	case year < 1620:
+		return deltat.Poly948to1600(float64(year))
+	case year > 2010:
+		return deltat.PolyAfter2000(float64(year))
+	}
+	return deltat.Interp10A(jde)
+}
+
+// sunCrossings computes the transit of the sun on the day at the
+// location and the times up and down at which its center is at the
+// altitude h0 in the morning and in the evening,
+// following chapter 15 of Meeus, Astronomical Algorithms. ok is false
+// if the sun doesn't reach the altitude on that day (polar day or
+// night), transit is set anyway.
+func sunCrossings(day time.Time, lat, lon float64, h0 unit.Angle) (up, transit, down time.Time, ok bool) {
+	// The UT day of the local noon, as Meeus works with UT days
+	noon := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, time.UTC).
+		Add(-time.Duration(lon / 15 * float64(time.Hour)))
+	d0 := time.Date(noon.Year(), noon.Month(), noon.Day(), 0, 0, 0, 0, time.UTC)
+	jd := julian.TimeToJD(d0)
+
+	var α3 [3]unit.RA
+	var δ3 [3]unit.Angle
+	for i := range α3 {
+		α3[i], δ3[i] = solar.ApparentEquatorial(jd + float64(i-1))
+		// Keep the right ascension continuous around the equinox
+		if i > 0 && α3[i] < α3[i-1]-math.Pi {
+			α3[i] += 2 * math.Pi
+		}
+	}
+	// Globe longitudes are positive west
+	p := globe.Coord{Lat: unit.AngleFromDeg(lat), Lon: unit.AngleFromDeg(-lon)}
+	Th0 := sidereal.Apparent0UT(jd)
+	tRise, tTransit, tSet, err := rise.Times(p, deltaT(jd, d0.Year()), h0, Th0, α3[:], δ3[:])
+	if err != nil {
+		// The sun transits anyway
+		mt := unit.TimeFromRad(α3[1].Rad()+p.Lon.Rad()) - Th0
+		tTransit = mt.Mod1()
+	}
+
+	// Meeus returns times of the UT day, put them around the local noon
+	sec := tTransit.Sec()
+	mid := noon.Sub(d0).Seconds()
+	for sec < mid-43200 {
+		sec += 86400
+	}
+	for sec > mid+43200 {
+		sec -= 86400
+	}
+	transit = d0.Add(time.Duration(sec * float64(time.Second))).Truncate(time.Second)
+	if err != nil {
+		return time.Time{}, transit, time.Time{}, false
+	}
+	r, s := tRise.Sec(), tSet.Sec()
+	for r > sec {
+		r -= 86400
+	}
+	for r < sec-86400 {
+		r += 86400
+	}
+	for s < sec {
+		s += 86400
+	}
+	for s > sec+86400 {
+		s -= 86400
+	}
+	up = d0.Add(time.Duration(r * float64(time.Second))).Truncate(time.Second)
+	down = d0.Add(time.Duration(s * float64(time.Second))).Truncate(time.Second)
+	return up, transit, down, true
+}
+
+// sunDeclination returns the apparent declination of the sun at t.
+func sunDeclination(t time.Time) unit.Angle {
+	_, δ := solar.ApparentEquatorial(julian.TimeToJD(t))
+	return δ
+}
+
+// sunTimes computes sunrise and sunset of the day at the location.
+func sunTimes(day time.Time, lat, lon float64) (sunrise, sunset time.Time, ok bool) {
+	sunrise, _, sunset, ok = sunCrossings(day, lat, lon, rise.Stdh0Solar)
+	return sunrise, sunset, ok
+}
+
+// shabbatEvents returns the candle-lighting times on Fridays and
+// the Havdalah times on Saturdays from the day from to the day to at
+// the location, candles minutes before and havdalah minutes after
+// sunset.
+func shabbatEvents(a Astronomy, from, to time.Time, lat, lon float64, candles, havdalah int, loc *time.Location) (eL []gDate) {
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		var text string
+		var offset time.Duration
+		switch d.Weekday() {
+		case time.Friday:
+			text, offset = "Candles", -time.Duration(candles)*time.Minute
+		case time.Saturday:
+			text, offset = "Havdalah", time.Duration(havdalah)*time.Minute
+		default:
+			continue
+		}
+		_, set, ok := a.SunTimes(d, lat, lon)
+		if !ok {
+			continue
+		}
+		text += " " + set.Add(offset).In(loc).Format("15:04")
+		eL = append(eL, gDate{d.Month(), d.Day(), text, "", "", "shabbat", "", "", "", "", 0, ""})
+	}
+	return eL
+}
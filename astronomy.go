@@ -0,0 +1,239 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// astronomy.go
+//
+// The astronomy behind the calendar, behind an interface so that
+// other engines or precomputed tables can be used.
+//
+
+import (
+	"fmt"
+	"github.com/soniakeys/meeus/v3/moonphase"
+	"github.com/soniakeys/meeus/v3/solstice"
+	"math"
+	"time"
+)
+
+// Astronomy computes the astronomical data of the calendar.
+// The default uses the algorithms of Meeus.
+type Astronomy interface {
+	// MoonPhases returns the moon phases of the year, keyed by
+	// date as "2006-01-02", with the values New, First, Full, Last.
+	MoonPhases(year int) map[string]string
+	// SunTimes returns sunrise and sunset of the day at the
+	// location, ok is false during polar day or night.
+	SunTimes(day time.Time, lat, lon float64) (rise, set time.Time, ok bool)
+	// Seasons returns the March equinox, June solstice, September
+	// equinox and December solstice of the year.
+	Seasons(year int) [4]time.Time
+	// Eclipses returns the solar and lunar eclipses of the year.
+	Eclipses(year int) []Eclipse
+	// Longitudes returns the geocentric ecliptic longitudes of the
+	// sun and the moon at t in degrees, for the Panchang.
+	Longitudes(t time.Time) (sun, moon float64)
+}
+
+// Eclipse is a solar or lunar eclipse at its maximum.
+type Eclipse struct {
+	Time  time.Time
+	Solar bool
+	// Type is partial, annular or total for solar eclipses and
+	// penumbral, partial or total for lunar eclipses.
+	Type string
+}
+
+// RangeAstronomy is an Astronomy that also computes the moon phases of
+// a range of days, so a calendar of a month does not need the year.
+type RangeAstronomy interface {
+	Astronomy
+	// MoonPhasesBetween returns the moon phases from the day from to
+	// the day to, both included, keyed like MoonPhases.
+	MoonPhasesBetween(from, to time.Time) map[string]string
+}
+
+// meeusAstronomy is the default Astronomy.
+type meeusAstronomy struct{}
+
+func (meeusAstronomy) MoonPhases(year int) map[string]string {
+	moon := make(map[string]string)
+	computeMoonphasesJ(moon, year)
+	return moon
+}
+
+func (meeusAstronomy) MoonPhasesBetween(from, to time.Time) map[string]string {
+	moon := make(map[string]string)
+	computeMoonphasesRange(moon, from, to)
+	return moon
+}
+
+func (meeusAstronomy) SunTimes(day time.Time, lat, lon float64) (rise, set time.Time, ok bool) {
+	return sunTimes(day, lat, lon)
+}
+
+func (meeusAstronomy) Seasons(year int) [4]time.Time {
+	return [4]time.Time{
+		jdToTime(solstice.March(year)),
+		jdToTime(solstice.June(year)),
+		jdToTime(solstice.September(year)),
+		jdToTime(solstice.December(year)),
+	}
+}
+
+func (meeusAstronomy) Longitudes(t time.Time) (sun, moon float64) {
+	return sunMoonLongitudes(t)
+}
+
+// Eclipses checks the new and full moons of the year, following
+// chapter 54 of Meeus, Astronomical Algorithms.
+func (meeusAstronomy) Eclipses(year int) (eclipses []Eclipse) {
+	first := int(math.Floor((float64(year)-2000)*lunationsPerYear)) - 1
+	last := int(math.Ceil((float64(year+1)-2000)*lunationsPerYear)) + 1
+	for n := first; n <= last; n++ {
+		for _, q := range []float64{0, 0.5} {
+			k := float64(n) + q
+			var jde float64
+			if q == 0 {
+				jde = moonphase.New(2000 + k/lunationsPerYear)
+			} else {
+				jde = moonphase.Full(2000 + k/lunationsPerYear)
+			}
+			t := jdToTime(jde)
+			if t.Year() != year {
+				continue
+			}
+			if typ := eclipseType(k); typ != "" {
+				eclipses = append(eclipses, Eclipse{t, q == 0, typ})
+			}
+		}
+	}
+	return eclipses
+}
+
+// eclipseType returns the type of the eclipse at lunation k, a
+// new moon for whole k and a full moon for k+0.5, or "" if there
+// is none.
+func eclipseType(k float64) string {
+	T := k / 1236.85
+	E := 1 - 0.002516*T
+	M := 2.5534 + 29.10535670*k - 0.0000014*T*T
+	Mm := 201.5643 + 385.81693528*k + 0.0107582*T*T
+	F := 160.7108 + 390.67050284*k - 0.0016118*T*T
+	Omega := 124.7746 - 1.56375588*k + 0.0020672*T*T
+	if math.Abs(sinDeg(F)) > 0.36 {
+		return ""
+	}
+	F1 := F - 0.02665*sinDeg(Omega)
+	P := 0.2070*E*sinDeg(M) + 0.0024*E*sinDeg(2*M) - 0.0392*sinDeg(Mm) +
+		0.0116*sinDeg(2*Mm) - 0.0073*E*sinDeg(Mm+M) + 0.0067*E*sinDeg(Mm-M) +
+		0.0118*sinDeg(2*F1)
+	Q := 5.2207 - 0.0048*E*cosDeg(M) + 0.0020*E*cosDeg(2*M) - 0.3299*cosDeg(Mm) -
+		0.0060*E*cosDeg(Mm+M) + 0.0041*E*cosDeg(Mm-M)
+	W := math.Abs(cosDeg(F1))
+	gamma := math.Abs((P*cosDeg(F1) + Q*sinDeg(F1)) * (1 - 0.0048*W))
+	u := 0.0059 + 0.0046*E*cosDeg(M) - 0.0182*cosDeg(Mm) + 0.0004*cosDeg(2*Mm) -
+		0.0005*cosDeg(M+Mm)
+
+	if k == math.Floor(k) { // solar
+		switch {
+		case gamma > 1.5433+u:
+			return ""
+		case gamma > 0.9972:
+			return "partial"
+		case u < 0:
+			return "total"
+		default:
+			return "annular"
+		}
+	}
+	penumbral := (1.5573 + u - gamma) / 0.5450
+	umbral := (1.0128 - u - gamma) / 0.5450
+	switch {
+	case penumbral < 0:
+		return ""
+	case umbral < 0:
+		return "penumbral"
+	case umbral < 1:
+		return "partial"
+	default:
+		return "total"
+	}
+}
+
+// astronomy returns the Astronomy of the calendar.
+func (g *Calendar) astronomy() Astronomy {
+	if g.Astronomy != nil {
+		return g.Astronomy
+	}
+	return meeusAstronomy{}
+}
+
+// SetAstronomy sets another engine for the astronomical data.
+func (g *Calendar) SetAstronomy(a Astronomy) {
+	g.Astronomy = a
+}
+
+// moonPhasesBetween returns the moon phases of the days from to to. An
+// Astronomy that is no RangeAstronomy computes the whole years.
+func moonPhasesBetween(a Astronomy, from, to time.Time) map[string]string {
+	if r, ok := a.(RangeAstronomy); ok {
+		return r.MoonPhasesBetween(from, to)
+	}
+	moon := make(map[string]string)
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	for year := from.Year(); year <= to.Year(); year++ {
+		for k, v := range a.MoonPhases(year) {
+			if k >= first && k <= last {
+				moon[k] = v
+			}
+		}
+	}
+	return moon
+}
+
+// astroRange returns the first and the last day of the year in the
+// grids of the months of the calendar, the days that need astronomy.
+func (g *Calendar) astroRange() (from, to time.Time) {
+	begin := time.Date(g.WantYear, time.Month(g.WantBeginMonth), 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(g.WantYear, time.Month(g.WantEndMonth), 1, 0, 0, 0, 0, time.UTC)
+	from = begin.AddDate(0, 0, firstCellOffset(g.WantYear, g.WantBeginMonth))
+	to = end.AddDate(0, 0, firstCellOffset(g.WantYear, g.WantEndMonth)+LINES*COLUMNS-1)
+	if from.Year() < g.WantYear {
+		from = time.Date(g.WantYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if to.Year() > g.WantYear {
+		to = time.Date(g.WantYear, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return from, to
+}
+
+// seasonEvents returns the equinoxes and solstices of the year as
+// events.
+func seasonEvents(a Astronomy, year int, loc *time.Location) (eL []gDate) {
+	names := []string{"March equinox", "June solstice", "September equinox", "December solstice"}
+	for i, t := range a.Seasons(year) {
+		t = t.In(loc)
+		eL = append(eL, gDate{t.Month(), t.Day(), names[i], "", "", "astronomy", "", "", "", "", 0, ""})
+	}
+	return eL
+}
+
+// astroEvents returns the equinoxes, solstices and eclipses
+// of the year as events.
+func astroEvents(a Astronomy, year int, loc *time.Location) (eL []gDate) {
+	eL = seasonEvents(a, year, loc)
+	for _, e := range a.Eclipses(year) {
+		t := e.Time.In(loc)
+		kind := "Lunar"
+		if e.Solar {
+			kind = "Solar"
+		}
+		text := fmt.Sprintf("%s eclipse (%s)", kind, e.Type)
+		eL = append(eL, gDate{t.Month(), t.Day(), text, "", "", "astronomy", "", "", "", "", 0, ""})
+	}
+	return eL
+}
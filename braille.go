@@ -0,0 +1,74 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// braille.go
+//
+// Day numbers in Braille, drawn as dots of the standard size, so
+// that they can be embossed or raised on swell paper.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"strconv"
+)
+
+// Size of Braille in mm: the dot diameter, the distance of the dots
+// in a cell and of the cells.
+const (
+	BRAILLEDOT         = 1.5
+	BRAILLEDOTSPACING  = 2.5
+	BRAILLECELLSPACING = 6.0
+)
+
+// brailleDigits are the cells of the digits 0-9, the letters j and
+// a-i. Bit k is dot k+1; dots 1-3 are the left column from the top,
+// dots 4-6 the right one, like in the Unicode Braille patterns.
+var brailleDigits = [10]uint8{26, 1, 3, 9, 25, 17, 11, 27, 19, 10}
+
+// brailleNumberSign is the cell before the digits, dots 3456.
+const brailleNumberSign = 60
+
+// brailleNumber returns the cells of the number.
+func brailleNumber(n int) []uint8 {
+	cells := []uint8{brailleNumberSign}
+	for _, d := range strconv.Itoa(n) {
+		cells = append(cells, brailleDigits[d-'0'])
+	}
+	return cells
+}
+
+// brailleString returns the cells as Unicode Braille patterns.
+func brailleString(cells []uint8) string {
+	var rs []rune
+	for _, c := range cells {
+		rs = append(rs, rune(0x2800+int(c)))
+	}
+	return string(rs)
+}
+
+// brailleSize returns the size of the cells on paper.
+func brailleSize(cells []uint8) (w, h float64) {
+	w = float64(len(cells)-1)*BRAILLECELLSPACING + BRAILLEDOTSPACING + BRAILLEDOT
+	h = 2*BRAILLEDOTSPACING + BRAILLEDOT
+	return w, h
+}
+
+// drawBraille draws the dots of the cells with the fill color,
+// x and y are the top left corner.
+func drawBraille(pdf *gofpdf.Fpdf, x, y float64, cells []uint8) {
+	r := BRAILLEDOT / 2
+	for i, c := range cells {
+		for dot := 0; dot < 6; dot++ {
+			if c&(1<<uint(dot)) == 0 {
+				continue
+			}
+			cx := x + r + float64(i)*BRAILLECELLSPACING + float64(dot/3)*BRAILLEDOTSPACING
+			cy := y + r + float64(dot%3)*BRAILLEDOTSPACING
+			pdf.Circle(cx, cy, r, "F")
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// brand.go
+//
+// The branding of a company on the calendars it gives away: the logo
+// in the headers, the colors of titles and footers, the font, and a
+// cover page.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"math"
+	"strconv"
+)
+
+// brand returns the branding of the brand file or, if it has none,
+// of the first configuration file with a Gocalbrand entry. Invalid
+// colors are reported once and dropped.
+func (g *Calendar) brand() Gocalbrand {
+	if g.branding != nil {
+		return *g.branding
+	}
+	g.branding = &Gocalbrand{}
+	for _, cfg := range append([]string{g.OptBrand, g.OptConfig}, g.OptConfigs...) {
+		if cfg == "" {
+			continue
+		}
+		if v := g.loadConfigurationfile(cfg); len(v.Gocalbrand) > 0 {
+			*g.branding = v.Gocalbrand[0]
+			break
+		}
+	}
+	b := g.branding
+	b.Logo, _ = g.rootedFile(b.Logo)
+	for _, c := range []*string{&b.Primary, &b.Secondary} {
+		if _, _, _, err := parseColor(*c); *c != "" && err != nil {
+			fmt.Printf("# Error in brand color: %v\n", err)
+			*c = ""
+		}
+	}
+	return *b
+}
+
+// hasBrand reports whether a brand is set.
+func (b Gocalbrand) hasBrand() bool {
+	return b != Gocalbrand{}
+}
+
+// setBrandColor sets the text color c of the brand, or else the gray.
+func setBrandColor(pdf *gofpdf.Fpdf, c string, gray int) {
+	if c == "" {
+		pdf.SetTextColor(gray, gray, gray)
+		return
+	}
+	r, gr, b, _ := parseColor(c)
+	pdf.SetTextColor(r, gr, b)
+}
+
+// setTitleColor sets the color of the titles: the primary color of
+// the brand or black.
+func (g *Calendar) setTitleColor(pdf *gofpdf.Fpdf) {
+	setBrandColor(pdf, g.brand().Primary, BLACK)
+}
+
+// setFooterColor sets the color of the footers: the secondary color
+// of the brand or dark grey.
+func (g *Calendar) setFooterColor(pdf *gofpdf.Fpdf) {
+	setBrandColor(pdf, g.brand().Secondary, DARKGREY)
+}
+
+// footer returns the text of the footers, that of the brand unless
+// one is set, with the variables of the recipient.
+func (g *Calendar) footer() string {
+	if g.OptFooter == "" && g.brand().Footer != "" {
+		return convertCP(g.recipient.Expand(g.brand().Footer))
+	}
+	return g.recipient.Expand(g.OptFooter)
+}
+
+// drawLogo draws the image file as large as fits into r with its
+// aspect ratio, at the horizontal position align from 0 (left) to 1
+// (right).
+func (g *Calendar) drawLogo(pdf *gofpdf.Fpdf, file string, r rect, align float64) {
+	var iw, ih float64
+	var svg *svgImage
+	if isSVG(file) {
+		if svg = loadSVG(file); svg == nil {
+			return
+		}
+		iw, ih = svg.w, svg.h
+	} else {
+		if !g.registerImage(pdf, file) {
+			return
+		}
+		info := pdf.RegisterImageOptions(file, gofpdf.ImageOptions{ReadDpi: true})
+		if info == nil {
+			return
+		}
+		iw, ih = info.Width(), info.Height()
+	}
+	if iw <= 0 || ih <= 0 {
+		return
+	}
+	s := math.Min(r.w/iw, r.h/ih)
+	p := rect{r.x + align*(r.w-iw*s), r.y + (r.h-ih*s)/2, iw * s, ih * s}
+	if svg != nil {
+		drawSVG(pdf, svg, p, false)
+		return
+	}
+	pdf.Image(file, p.x, p.y, p.w, p.h, false, "", 0, "")
+}
+
+// addCoverPage adds the cover page of the brand: the logo on a band
+// of the primary color, the name of the company, the year and the
+// tagline, which may address the recipient.
+func (g *Calendar) addCoverPage(pdf *gofpdf.Fpdf, fonts elementFonts, fontScale float64, width, height float64) {
+	b := g.brand()
+	pdf.AddPage()
+	if b.Primary != "" {
+		r, gr, bl, _ := parseColor(b.Primary)
+		fr, fg, fb := pdf.GetFillColor()
+		pdf.SetFillColor(r, gr, bl)
+		pdf.Rect(0, 0, width, height*0.4, "F")
+		pdf.SetFillColor(fr, fg, fb)
+	}
+	if b.Logo != "" {
+		g.drawLogo(pdf, b.Logo, rect{width * 0.25, height * 0.08, width * 0.5, height * 0.24}, 0.5)
+	}
+	center := func(y float64, text string) {
+		pdf.Text((width-pdf.GetStringWidth(text))/2, y, text)
+	}
+	g.setTitleColor(pdf)
+	fonts.set(pdf, "title", HEADERFONTSIZE*1.25*fontScale)
+	center(height*0.5, convertCP(b.Name))
+	setBrandColor(pdf, b.Secondary, DARKGREY)
+	fonts.set(pdf, "title", HEADERFONTSIZE*2*fontScale)
+	center(height*0.62, strconv.Itoa(g.WantYear))
+	pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+	fonts.set(pdf, "footer", QUOTEFONTSIZE*fontScale)
+	center(height*0.7, convertCP(g.recipient.Expand(b.Tagline)))
+	g.setFooterColor(pdf)
+	fonts.set(pdf, "footer", FOOTERFONTSIZE*fontScale)
+	center(0.95*height, g.footer())
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+}
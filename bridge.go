@@ -0,0 +1,87 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// bridge.go
+//
+// Bridge days: single workdays between a public holiday and a weekend
+// or another holiday, which give a long weekend for one day off.
+//
+
+import (
+	"strings"
+	"time"
+)
+
+// SetBridgeDays marks the bridge days with an event of the label, e.g.
+// "Bridge day" or "Brückentag". The public holidays are those of
+// SetHoliday and the events of the category holiday.
+func (g *Calendar) SetBridgeDays(label string) {
+	g.OptBridgeDays = label
+}
+
+// SetBridgeFill paints the cells of the bridge days with the fill, a
+// color or a fill of SetFillStyle.
+func (g *Calendar) SetBridgeFill(fill string) {
+	g.OptBridgeFill = fill
+}
+
+// isPublicHoliday reports whether the event is a public holiday: one
+// of the holiday service, not a school holiday, or of the category
+// holiday.
+func isPublicHoliday(ev gDate) bool {
+	return strings.Contains(ev.Source, "PublicHolidays?") || strings.EqualFold(ev.Category, "holiday")
+}
+
+// bridgeDays returns the workdays of the year whose neighbors are both
+// weekend days or public holidays of the events, at least one of them
+// a holiday.
+func bridgeDays(eL []gDate, year int) (days []time.Time) {
+	holidays := make(map[string]bool)
+	for _, ev := range eL {
+		if ev.Weekday == "" && ev.Day > 0 && isPublicHoliday(ev) {
+			holidays[time.Date(year, ev.Month, ev.Day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")] = true
+		}
+	}
+	if len(holidays) == 0 {
+		return nil
+	}
+	holiday := func(d time.Time) bool { return holidays[d.Format("2006-01-02")] }
+	weekend := func(d time.Time) bool { return d.Weekday() == time.Saturday || d.Weekday() == time.Sunday }
+	for d := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		if weekend(d) || holiday(d) {
+			continue
+		}
+		prev, next := d.AddDate(0, 0, -1), d.AddDate(0, 0, 1)
+		if (holiday(prev) || weekend(prev)) && (holiday(next) || weekend(next)) && (holiday(prev) || holiday(next)) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// bridgeEvents returns the bridge days of the events as events of the
+// category bridge.
+func (g *Calendar) bridgeEvents(eL []gDate) (out []gDate) {
+	if g.OptBridgeDays == "" {
+		return nil
+	}
+	for _, d := range bridgeDays(eL, g.WantYear) {
+		out = append(out, gDate{d.Month(), d.Day(), convertCP(g.OptBridgeDays), "", "", "bridge", "", "bridge", "", "", 0, ""})
+	}
+	return out
+}
+
+// hasBridgeDay reports whether one of the events of a day marks it as
+// a bridge day.
+func hasBridgeDay(eL []gDate) bool {
+	for _, ev := range eL {
+		if ev.Source == "bridge" {
+			return true
+		}
+	}
+	return false
+}
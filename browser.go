@@ -0,0 +1,36 @@
+//go:build js
+// +build js
+
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// browser.go
+//
+// The builtin fonts in the browser, which has no temporary files to
+// convert them. Their definitions are converted beforehand into
+// fonts/wasm, which isn't committed, by go generate; see the wasm
+// target of the Makefile.
+//
+
+import (
+	"embed"
+	"io"
+)
+
+//go:embed fonts/wasm/*.json fonts/wasm/*.z
+var fontDefinitions embed.FS
+
+// memoryFontLoader loads the font definitions from fontDefinitions.
+type memoryFontLoader struct{}
+
+func (memoryFontLoader) Open(name string) (io.Reader, error) {
+	return fontDefinitions.Open("fonts/wasm/" + name)
+}
+
+func init() {
+	fontLoader = memoryFontLoader{}
+}
@@ -0,0 +1,153 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// caption.go
+//
+// Captions of the photos of the months, e.g. the place where a photo
+// was taken and the name of the photographer.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// CAPTIONINSET is the distance of a caption to the edges of its photo.
+const CAPTIONINSET = 3.0
+
+// CAPTIONCREDITSIZE is the size of the credit line relative to the
+// caption.
+const CAPTIONCREDITSIZE = 0.75
+
+// captionPositions are the places of a caption in its photo, the
+// horizontal and the vertical position as fractions.
+var captionPositions = map[string][2]float64{
+	"topleft":     {0, 0},
+	"top":         {0.5, 0},
+	"topright":    {1, 0},
+	"bottomleft":  {0, 1},
+	"bottom":      {0.5, 1},
+	"bottomright": {1, 1},
+}
+
+// captionStyles are the looks of a caption: white text with a
+// shadow, black text, or white text on a dark band.
+var captionStyles = []string{"light", "dark", "band"}
+
+// isCaptionStyle reports whether s is one of the captionStyles.
+func isCaptionStyle(s string) bool {
+	for _, c := range captionStyles {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+// captionData are the variables of a caption template, e.g.
+// "{{.Month}} {{.Year}}".
+type captionData struct {
+	Month       string
+	MonthNumber int
+	Year        int
+	File        string
+	Credit      string
+}
+
+// captionText returns the caption of the template with the data.
+func captionText(tmpl string, data captionData) (string, error) {
+	t, err := template.New("caption").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// captionPlace returns the top left corner of a caption of size w x h
+// at the position pos in the photo r, and the horizontal position of
+// its lines. Unknown positions are bottomright.
+func captionPlace(pos string, r rect, w, h float64) (x, y, align float64) {
+	p, ok := captionPositions[pos]
+	if !ok {
+		p = captionPositions["bottomright"]
+	}
+	x = r.x + CAPTIONINSET + p[0]*(r.w-2*CAPTIONINSET-w)
+	y = r.y + CAPTIONINSET + p[1]*(r.h-2*CAPTIONINSET-h)
+	return x, y, p[0]
+}
+
+// drawCaption prints the caption and the credit line of the photo of
+// the month mo in the area r of the photo. The caption of its
+// Gocalimage entry overrides the one of the calendar.
+func (g *Calendar) drawCaption(pdf *gofpdf.Fpdf, fonts elementFonts, fontScale float64, photo string, r rect, monthName string, mo int) {
+	fr, _ := g.frame(photo)
+	tmpl := g.OptCaption
+	if fr.caption != "" {
+		tmpl = fr.caption
+	}
+	if tmpl == "" && fr.credit == "" {
+		return
+	}
+	data := captionData{convertFromCP(monthName), mo, g.WantYear, filepath.Base(photo), fr.credit}
+	caption, err := captionText(tmpl, data)
+	if err != nil {
+		g.warnf("field", "Ignoring invalid caption '%s': %v", tmpl, err)
+		caption = ""
+	}
+	type line struct {
+		text string
+		size float64
+	}
+	var lines []line
+	if caption != "" {
+		lines = append(lines, line{convertCP(caption), FOOTERFONTSIZE * fontScale})
+	}
+	if fr.credit != "" && !strings.Contains(tmpl, ".Credit") {
+		lines = append(lines, line{convertCP(fr.credit), FOOTERFONTSIZE * CAPTIONCREDITSIZE * fontScale})
+	}
+	if len(lines) == 0 {
+		return
+	}
+	w, h := 0.0, 0.0
+	for _, l := range lines {
+		fonts.set(pdf, "footer", l.size)
+		if lw := pdf.GetStringWidth(l.text); lw > w {
+			w = lw
+		}
+		h += pdf.PointConvert(l.size) * 1.2
+	}
+	x, y, align := captionPlace(g.OptCaptionPos, r, w, h)
+
+	var effect textEffect
+	switch g.OptCaptionStyle {
+	case "dark":
+		pdf.SetTextColor(BLACK, BLACK, BLACK)
+	case "band":
+		pdf.SetAlpha(0.5, "Normal")
+		pdf.SetFillColor(BLACK, BLACK, BLACK)
+		pdf.Rect(r.x, y-CAPTIONINSET/2, r.w, h+CAPTIONINSET, "F")
+		pdf.SetAlpha(1, "Normal")
+		pdf.SetTextColor(255, 255, 255)
+	default:
+		pdf.SetTextColor(255, 255, 255)
+		effect = textEffect{shadow: true, shadowOffset: 0.3}
+	}
+	for _, l := range lines {
+		fonts.set(pdf, "footer", l.size)
+		lh := pdf.PointConvert(l.size) * 1.2
+		// The baseline is about a fifth of the line above its bottom.
+		myPdf{pdf, 0}.effectText(x+align*(w-pdf.GetStringWidth(l.text)), y+lh*0.8, l.text, effect)
+		y += lh
+	}
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+}
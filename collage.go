@@ -0,0 +1,116 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// collage.go
+//
+// Several images in a day cell, e.g. the photos of two birthdays,
+// arranged as a collage of tiles.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"math"
+	"strings"
+)
+
+// DAYIMAGEGAP is the gap between the tiles of a collage in mm.
+const DAYIMAGEGAP = 0.5
+
+// DAYIMAGES is the default maximum number of images in a cell.
+const DAYIMAGES = 4
+
+// gridTiles divides the cell into rows and columns of tiles for n
+// images, the last row may have fewer and wider tiles.
+func gridTiles(n, cols, rows int, c rect, gap float64) (tiles []rect) {
+	h := (c.h - float64(rows-1)*gap) / float64(rows)
+	for row := 0; row < rows; row++ {
+		k := cols
+		if rest := n - row*cols; rest < cols {
+			k = rest
+		}
+		w := (c.w - float64(k-1)*gap) / float64(k)
+		for col := 0; col < k; col++ {
+			tiles = append(tiles, rect{c.x + float64(col)*(w+gap), c.y + float64(row)*(h+gap), w, h})
+		}
+	}
+	return tiles
+}
+
+// collageTiles returns the tiles of n images in the cell: one fills
+// it, two are side by side or, in a tall cell, on top of each other.
+// Of three, the first is large and the others share the rest. More
+// are arranged in a grid with the longer side along the cell's.
+func collageTiles(n int, c rect, gap float64) []rect {
+	wide := c.w >= c.h
+	switch {
+	case n <= 0:
+		return nil
+	case n == 1:
+		return []rect{c}
+	case n == 2 && wide:
+		return gridTiles(2, 2, 1, c, gap)
+	case n == 2:
+		return gridTiles(2, 1, 2, c, gap)
+	case n == 3 && wide:
+		w := (c.w - gap) / 2
+		right := gridTiles(2, 1, 2, rect{c.x + w + gap, c.y, w, c.h}, gap)
+		return append([]rect{{c.x, c.y, w, c.h}}, right...)
+	case n == 3:
+		h := (c.h - gap) / 2
+		bottom := gridTiles(2, 2, 1, rect{c.x, c.y + h + gap, c.w, h}, gap)
+		return append([]rect{{c.x, c.y, c.w, h}}, bottom...)
+	}
+	long := int(math.Ceil(math.Sqrt(float64(n))))
+	short := (n + long - 1) / long
+	if wide {
+		return gridTiles(n, long, short, c, gap)
+	}
+	return gridTiles(n, short, long, c, gap)
+}
+
+// dayImages returns the images of the events, an event may have
+// several separated by commas. Images used twice are drawn once.
+func dayImages(events []gDate) (images []string) {
+	for _, ev := range events {
+		for _, f := range strings.Split(ev.Image, ",") {
+			if f = strings.TrimSpace(f); f != "" && !stringInSlice(f, images) {
+				images = append(images, f)
+			}
+		}
+	}
+	return images
+}
+
+// imageCover draws the image so that it covers the area, cut at the
+// sides or at the top and bottom, around its focus.
+func (g *Calendar) imageCover(pdf *gofpdf.Fpdf, file string, r rect) {
+	if isSVG(file) {
+		if img := loadSVG(file); img != nil {
+			drawSVG(pdf, img, r, true)
+		}
+		return
+	}
+	g.drawFramed(pdf, file, r, true)
+}
+
+// drawDayImages draws the images of the events of a day behind the
+// content of the cell. A single image fills the cell, several ones
+// up to OptDayImages form a collage.
+func (g *Calendar) drawDayImages(pdf *gofpdf.Fpdf, events []gDate, cell rect) {
+	images := dayImages(events)
+	if len(images) == 1 {
+		g.image(pdf, images[0], cell.x, cell.y, cell.w, cell.h)
+		return
+	}
+	if max := g.OptDayImages; max > 0 && len(images) > max {
+		g.warnf("field", "Only %d of %d images fit into a day cell: %s", max, len(images), strings.Join(images[max:], ", "))
+		images = images[:max]
+	}
+	for i, r := range collageTiles(len(images), cell, DAYIMAGEGAP) {
+		g.imageCover(pdf, images[i], r)
+	}
+}
@@ -0,0 +1,93 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// companion.go
+//
+// The companion ICS file of a calendar: written next to the PDF with
+// the events, moon phases and seasons of the printed pages, and links
+// to the PDF and the images of the events.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// SetCompanionICS also writes an ICS file next to the PDF, e.g.
+// 2025.ics for 2025.pdf, with the events and moon phases of the pages
+// and the seasons.
+func (g *Calendar) SetCompanionICS(b bool) {
+	g.OptCompanionICS = b
+}
+
+// SetArtworkURL sets the address where the PDF and the images of the
+// calendar are published, e.g. "https://example.org/cal/". The
+// companion ICS links them there instead of as local files.
+func (g *Calendar) SetArtworkURL(u string) {
+	g.OptArtworkURL = u
+}
+
+// companionFilename returns the name of the companion ICS file of the
+// PDF file fn.
+func companionFilename(fn string) string {
+	return strings.TrimSuffix(fn, filepath.Ext(fn)) + ".ics"
+}
+
+// artworkLink returns the link to a file of the calendar: an address
+// as it is, or under the artwork URL, or else as a local file.
+func (g *Calendar) artworkLink(file string) string {
+	if strings.Contains(file, "://") {
+		return file
+	}
+	if g.OptArtworkURL != "" {
+		return strings.TrimSuffix(g.OptArtworkURL, "/") + "/" + url.PathEscape(filepath.Base(file))
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+// companionEvents returns the events of the companion ICS file: the
+// printed events and the moon phases of printedEvents, and the seasons
+// even if they are not printed.
+func (g *Calendar) companionEvents() []gDate {
+	eL := g.printedEvents()
+	if g.OptAstroEvents {
+		return eL
+	}
+	for _, ev := range seasonEvents(g.astronomy(), g.WantYear, getLocation(g.OptTimezone)) {
+		if int(ev.Month) >= g.WantBeginMonth && int(ev.Month) <= g.WantEndMonth {
+			eL = append(eL, ev)
+		}
+	}
+	sortByDate(eL)
+	return eL
+}
+
+// writeCompanionICS writes the companion ICS file of the PDF file fn,
+// none for a PDF on standard output.
+func (g *Calendar) writeCompanionICS(fn string) {
+	if fn == STDIN {
+		return
+	}
+	var b bytes.Buffer
+	g.writeICS(&b, g.companionEvents(), fn)
+	ics := companionFilename(fn)
+	if err := ioutil.WriteFile(ics, b.Bytes(), 0644); err != nil {
+		fmt.Printf("# Error writing '%s': %v\n", ics, err)
+		countError("render")
+		g.exitCode = ExitRender
+		return
+	}
+	fmt.Printf("Generated '%v'.\n", ics)
+}
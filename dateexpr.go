@@ -0,0 +1,155 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// dateexpr.go
+//
+// Relative dates and date ranges of the configuration file, e.g.
+// "3rd Sunday of 6", "last weekday of 12", "easter-2" or "7/15-7/30".
+//
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDateExpr computes the date of an expression in the year:
+//
+//	easter, easter+N, easter-N     days relative to Easter Sunday
+//	<nth> <weekday> of <month>     e.g. 3rd Sunday of 6, last Friday of June
+//	<nth> weekday of <month>       nth working day (Monday-Friday)
+//
+// nth is first..fifth, 1st..5th or last. The month is 1-12 or an
+// English month name. Weekday names may be in lang.
+func parseDateExpr(expr string, lang string, year int) (time.Time, bool) {
+	fields := strings.Fields(strings.ToLower(expr))
+	if len(fields) == 0 {
+		return time.Time{}, false
+	}
+	if joined := strings.Join(fields, ""); strings.HasPrefix(joined, "easter") {
+		offset := 0
+		if rest := strings.TrimPrefix(joined, "easter"); rest != "" {
+			n, err := strconv.Atoi(rest)
+			if err != nil || (rest[0] != '+' && rest[0] != '-') {
+				return time.Time{}, false
+			}
+			offset = n
+		}
+		return easterSunday(year).AddDate(0, 0, offset), true
+	}
+	if len(fields) != 4 || fields[2] != "of" {
+		return time.Time{}, false
+	}
+	nth, ok := weekdayOrdinals[fields[0]]
+	if !ok {
+		return time.Time{}, false
+	}
+	month, ok := parseMonth(fields[3])
+	if !ok {
+		return time.Time{}, false
+	}
+	var match func(time.Time) bool
+	if fields[1] == "weekday" {
+		match = func(d time.Time) bool {
+			return d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+		}
+	} else {
+		wd, ok := lookupWeekday(strings.TrimSuffix(fields[1], "."), lang)
+		if !ok {
+			return time.Time{}, false
+		}
+		match = func(d time.Time) bool { return d.Weekday() == wd }
+	}
+	return nthDayOfMonth(year, month, nth, match)
+}
+
+// parseMonth reads a month number or English month name.
+func parseMonth(s string) (time.Month, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Month(n), n >= 1 && n <= 12
+	}
+	for m := time.January; m <= time.December; m++ {
+		if strings.ToLower(m.String()) == s {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// nthDayOfMonth returns the nth day of the month that matches,
+// counted from the end if nth is negative.
+func nthDayOfMonth(year int, month time.Month, nth int, match func(time.Time) bool) (time.Time, bool) {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+	step, d := 1, first
+	if nth < 0 {
+		step, d, nth = -1, last, -nth
+	}
+	for ; d.Month() == month; d = d.AddDate(0, 0, step) {
+		if match(d) {
+			nth--
+			if nth == 0 {
+				return d, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseDateRange returns the days of a range in the year, like
+// "7/15-7/30" or "12/20-1/6" (wrapping into January). With "*/1-*/3"
+// the range repeats in every month, shortened to the month's length.
+func parseDateRange(expr string, year int) (days []time.Time, ok bool) {
+	ends := strings.Split(strings.Replace(expr, " ", "", -1), "-")
+	if len(ends) != 2 {
+		return nil, false
+	}
+	from := strings.Split(ends[0], "/")
+	to := strings.Split(ends[1], "/")
+	if len(from) != 2 || len(to) != 2 {
+		return nil, false
+	}
+	d1, err1 := strconv.Atoi(from[1])
+	d2, err2 := strconv.Atoi(to[1])
+	if err1 != nil || err2 != nil || d1 < 1 || d2 < 1 || d1 > 31 || d2 > 31 {
+		return nil, false
+	}
+	if from[0] == "*" && to[0] == "*" {
+		if d1 > d2 {
+			return nil, false
+		}
+		for m := time.January; m <= time.December; m++ {
+			for d := d1; d <= d2; d++ {
+				t := time.Date(year, m, d, 0, 0, 0, 0, time.UTC)
+				if t.Month() == m {
+					days = append(days, t)
+				}
+			}
+		}
+		return days, true
+	}
+	m1, err1 := strconv.Atoi(from[0])
+	m2, err2 := strconv.Atoi(to[0])
+	if err1 != nil || err2 != nil || m1 < 1 || m2 < 1 || m1 > 12 || m2 > 12 {
+		return nil, false
+	}
+	// The end is in the next year if it is before the start, and
+	// days that the month doesn't have, like 2/30, are errors.
+	endYear := year
+	if m2 < m1 || m2 == m1 && d2 < d1 {
+		endYear++
+	}
+	start := time.Date(year, time.Month(m1), d1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(endYear, time.Month(m2), d2, 0, 0, 0, 0, time.UTC)
+	if start.Day() != d1 || end.Day() != d2 {
+		return nil, false
+	}
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		days = append(days, t)
+	}
+	return days, true
+}
@@ -0,0 +1,147 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// diff.go
+//
+// The differences of the events of two releases of a configuration
+// file, e.g. of a community calendar that is reprinted every year.
+//
+
+import (
+	"fmt"
+	"sort"
+)
+
+// EventChange is a difference between the events of two
+// configuration files. Dates are like "3/15" or "Monday".
+type EventChange struct {
+	Kind    string `json:"kind"` // added, removed, moved or changed
+	Date    string `json:"date"`
+	Text    string `json:"text"`
+	OldDate string `json:"old_date,omitempty"`
+	OldText string `json:"old_text,omitempty"`
+}
+
+// String formats the change as a line of the report.
+func (c EventChange) String() string {
+	switch c.Kind {
+	case "added":
+		return fmt.Sprintf("+ %s %s", c.Date, c.Text)
+	case "removed":
+		return fmt.Sprintf("- %s %s", c.Date, c.Text)
+	case "moved":
+		return fmt.Sprintf("> %s -> %s %s", c.OldDate, c.Date, c.Text)
+	}
+	return fmt.Sprintf("~ %s %s -> %s", c.Date, c.OldText, c.Text)
+}
+
+// diffMarks are the prefixes and colors of the changed events
+// in the annotated calendar.
+var diffMarks = map[string][2]string{
+	"added":   {"+ ", "green"},
+	"removed": {"- ", "red"},
+	"moved":   {"> ", "orange"},
+	"changed": {"~ ", "orange"},
+}
+
+// eventDate formats the date of an event for the report.
+func eventDate(ev gDate) string {
+	if ev.Weekday != "" {
+		return ev.Weekday
+	}
+	return fmt.Sprintf("%d/%d", ev.Month, ev.Day)
+}
+
+// diffEvents compares the events: an event with the same date and
+// summary is unchanged, one with the same summary on another date
+// moved, one with another summary on the same date changed. The
+// rest was added or removed. marked are the new events and the
+// removed ones, the changes marked by prefix and color.
+func diffEvents(oldList, newList []gDate) (changes []EventChange, marked []gDate) {
+	usedOld := make([]bool, len(oldList))
+	pairs := make([]int, len(newList)) // index+1 in oldList
+	same := []func(o, n gDate) bool{
+		func(o, n gDate) bool {
+			return eventDate(o) == eventDate(n) && normalizeSummary(o.Text) == normalizeSummary(n.Text)
+		},
+		func(o, n gDate) bool { return normalizeSummary(o.Text) == normalizeSummary(n.Text) },
+		func(o, n gDate) bool { return eventDate(o) == eventDate(n) },
+	}
+	for _, match := range same {
+		for i, n := range newList {
+			if pairs[i] != 0 {
+				continue
+			}
+			for j, o := range oldList {
+				if !usedOld[j] && match(o, n) {
+					usedOld[j], pairs[i] = true, j+1
+					break
+				}
+			}
+		}
+	}
+	var kinds []string
+	for i, n := range newList {
+		c := EventChange{Date: eventDate(n), Text: convertFromCP(n.Text)}
+		if pairs[i] == 0 {
+			c.Kind = "added"
+		} else {
+			o := oldList[pairs[i]-1]
+			switch {
+			case eventDate(o) != eventDate(n):
+				c.Kind, c.OldDate = "moved", eventDate(o)
+			case normalizeSummary(o.Text) != normalizeSummary(n.Text):
+				c.Kind, c.OldText = "changed", convertFromCP(o.Text)
+			}
+		}
+		kinds = append(kinds, c.Kind)
+		if c.Kind != "" {
+			changes = append(changes, c)
+		}
+	}
+	for j, o := range oldList {
+		if !usedOld[j] {
+			changes = append(changes, EventChange{Kind: "removed", Date: eventDate(o), Text: convertFromCP(o.Text)})
+			newList = append(newList, o)
+			kinds = append(kinds, "removed")
+		}
+	}
+	for i, ev := range newList {
+		if m, ok := diffMarks[kinds[i]]; ok {
+			ev.Text = m[0] + ev.Text
+			ev.Color = m[1]
+		}
+		marked = append(marked, ev)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return dateOrder(changes[i].Date) < dateOrder(changes[j].Date)
+	})
+	return changes, marked
+}
+
+// dateOrder sorts the dates of the report, weekdays last.
+func dateOrder(date string) int {
+	var m, d int
+	if n, _ := fmt.Sscanf(date, "%d/%d", &m, &d); n == 2 {
+		return m*32 + d
+	}
+	return 13 * 32
+}
+
+// AddDiff compares the events of the configuration files in the
+// year of the calendar and returns the changes. The events of
+// newFile are added to the calendar with the changes marked: added
+// ones with "+ " in green, moved and changed ones with "> " and "~ "
+// in orange, and the removed ones of oldFile with "- " in red.
+func (g *Calendar) AddDiff(oldFile string, newFile string) []EventChange {
+	lang := getLanguage(g.OptLocale)
+	oldList := g.readConfigurationfile(oldFile, lang, g.WantYear)
+	newList := g.readConfigurationfile(newFile, lang, g.WantYear)
+	changes, marked := diffEvents(oldList, newList)
+	g.EventList = append(g.EventList, marked...)
+	return changes
+}
@@ -0,0 +1,138 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// enhance.go
+//
+// Automatic enhancement of the photos of the months, which often
+// print dull: levels and white balance, then a little sharpening.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+)
+
+// ENHANCECLIP is the part of the darkest and the brightest pixels
+// of a channel that become black and white by the levels.
+const ENHANCECLIP = 0.005
+
+// ENHANCESHARPEN is the amount of the unsharp mask.
+const ENHANCESHARPEN = 0.5
+
+// channelLevels returns the values of the histogram at which the
+// part clip of the pixels is darker or brighter.
+func channelLevels(hist *[256]int, total int, clip float64) (low, high int) {
+	n := int(float64(total) * clip)
+	for sum := 0; low < 255 && sum+hist[low] <= n; low++ {
+		sum += hist[low]
+	}
+	high = 255
+	for sum := 0; high > 0 && sum+hist[high] <= n; high-- {
+		sum += hist[high]
+	}
+	return low, high
+}
+
+// enhanceImage returns the image with the levels of every channel
+// stretched to the full range, which also removes a color cast, and
+// sharpened with an unsharp mask.
+func enhanceImage(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	var hist [3][256]int
+	for i := 0; i < len(out.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			hist[c][out.Pix[i+c]]++
+		}
+	}
+	var lut [3][256]uint8
+	for c := 0; c < 3; c++ {
+		low, high := channelLevels(&hist[c], b.Dx()*b.Dy(), ENHANCECLIP)
+		for v := 0; v < 256; v++ {
+			lut[c][v] = uint8(v)
+			if high-low >= 16 { // leave flat channels alone
+				lut[c][v] = uint8(math.Max(0, math.Min(255, math.Round(float64(v-low)*255/float64(high-low)))))
+			}
+		}
+	}
+	for i := 0; i < len(out.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			out.Pix[i+c] = lut[c][out.Pix[i+c]]
+		}
+	}
+	return sharpen(out, ENHANCESHARPEN)
+}
+
+// sharpen adds amount times the difference of every pixel to the
+// mean of its 3x3 neighbourhood.
+func sharpen(img *image.NRGBA, amount float64) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	copy(out.Pix, img.Pix)
+	for y := b.Min.Y + 1; y < b.Max.Y-1; y++ {
+		for x := b.Min.X + 1; x < b.Max.X-1; x++ {
+			i := img.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				sum := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						sum += int(img.Pix[i+dy*img.Stride+dx*4+c])
+					}
+				}
+				v := float64(img.Pix[i+c])
+				v += amount * (v - float64(sum)/9)
+				out.Pix[i+c] = uint8(math.Max(0, math.Min(255, math.Round(v))))
+			}
+		}
+	}
+	return out
+}
+
+// registerEnhanced adds the enhanced photo to the PDF under its own
+// name, in grayscale with the contrast if it is set.
+func registerEnhanced(pdf *gofpdf.Fpdf, file string, contrast float64) {
+	if pdf.GetImageInfo(file) != nil {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		return // pdf.Image reports it
+	}
+	img, err := decodeImage(file)
+	if err != nil {
+		fmt.Printf("# Error enhancing %v: %v\n", file, err)
+		return
+	}
+	var out image.Image = enhanceImage(img)
+	if contrast > 0 {
+		out = grayImage(out, contrast)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		fmt.Printf("# Error enhancing %v: %v\n", file, err)
+		return
+	}
+	pdf.RegisterImageOptionsReader(file, gofpdf.ImageOptions{ImageType: "PNG"}, &buf)
+}
+
+// enhancePhoto prepares the photo of a month for pdf.Image, enhanced
+// unless its Gocalimage entry says enhance="no".
+func (g *Calendar) enhancePhoto(pdf *gofpdf.Fpdf, file string) {
+	if !g.OptEnhance || isSVG(file) {
+		return
+	}
+	if fr, _ := g.frame(file); fr.noEnhance {
+		return
+	}
+	registerEnhanced(pdf, file, g.OptGrayscale)
+}
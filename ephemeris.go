@@ -0,0 +1,233 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// ephemeris.go
+//
+// Astronomical events from ephemeris files, like meteor showers,
+// planet oppositions or ISS passes: they are added as events of the
+// category astronomy and mark their days with an icon of their kind.
+//
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/phpdave11/gofpdf"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ephemerisEvent is an event of an ephemeris file. at is empty for an
+// event without time.
+type ephemerisEvent struct {
+	day  time.Time
+	at   string
+	kind string
+	text string
+}
+
+// ephemerisEntry is a line of an ephemeris file, with the fields of
+// the JSON files.
+type ephemerisEntry struct {
+	Date string `json:"date"`
+	Time string `json:"time"`
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// AddEphemeris adds the astronomical events of an ephemeris file, a
+// CSV file with the columns date, kind, text and the optional time,
+// or a JSON file (.json) with an array of objects with these fields.
+// Dates are like 2006-01-02, times like 15:04. The kinds meteor,
+// opposition, conjunction, iss and comet have icons of their own,
+// other kinds get a star.
+func (g *Calendar) AddEphemeris(f string) {
+	g.OptEphemeris = append(g.OptEphemeris, f)
+}
+
+// SetAstroIcons replaces the icons of kinds of ephemeris events,
+// comma separated, e.g. "meteor=meteor.png,iss=S": an image file or a
+// single character like the icons of the rules.
+func (g *Calendar) SetAstroIcons(s string) {
+	g.OptAstroIcons = s
+}
+
+// parseEphemeris reads the events of an ephemeris file of the year.
+// The entries it ignores go to warn.
+func parseEphemeris(data []byte, filename string, year int, warn func(kind string, format string, v ...interface{})) (eL []ephemerisEvent, err error) {
+	var entries []ephemerisEntry
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+	} else {
+		r := csv.NewReader(strings.NewReader(string(data)))
+		r.Comment = '#'
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for n, rec := range records {
+			if n == 0 && strings.EqualFold(rec[0], "date") {
+				continue // header
+			}
+			if len(rec) < 3 {
+				warn("field", "Ignoring line %d of %v, expected date,kind,text[,time]", n+1, filename)
+				continue
+			}
+			e := ephemerisEntry{Date: rec[0], Kind: rec[1], Text: rec[2]}
+			if len(rec) > 3 {
+				e.Time = rec[3]
+			}
+			entries = append(entries, e)
+		}
+	}
+	for _, e := range entries {
+		day, err := time.Parse("2006-01-02", strings.TrimSpace(e.Date))
+		if err != nil {
+			warn("date", "Ignoring '%s' of %v, invalid date '%s'", e.Text, filename, e.Date)
+			continue
+		}
+		at := strings.TrimSpace(e.Time)
+		if at != "" {
+			if _, err := time.Parse("15:04", at); err != nil {
+				warn("date", "Ignoring '%s' of %v, invalid time '%s'", e.Text, filename, e.Time)
+				continue
+			}
+		}
+		if day.Year() != year {
+			continue
+		}
+		eL = append(eL, ephemerisEvent{day, at, strings.ToLower(strings.TrimSpace(e.Kind)), strings.TrimSpace(e.Text)})
+	}
+	return eL, nil
+}
+
+// ephemerisEvents reads the ephemeris files and returns their events
+// as events of the category astronomy. The events are kept for their
+// icons.
+func (g *Calendar) ephemerisEvents() (out []gDate) {
+	g.ephemeris = nil
+	for _, f := range g.OptEphemeris {
+		data, err := g.readFile(f)
+		if err != nil {
+			g.warnf("field", "Ignoring ephemeris %v: %v", f, err)
+			continue
+		}
+		eL, err := parseEphemeris(data, f, g.WantYear, g.warnf)
+		if err != nil {
+			g.warnf("field", "Ignoring ephemeris %v: %v", f, err)
+			continue
+		}
+		for _, e := range eL {
+			text := e.text
+			if e.at != "" {
+				text += " " + e.at
+			}
+			out = append(out, gDate{e.day.Month(), e.day.Day(), convertCP(text), "", "", f, "", "astronomy", "", "", 0, e.at})
+		}
+		g.ephemeris = append(g.ephemeris, eL...)
+	}
+	return out
+}
+
+// ephemerisKinds returns the kinds of the ephemeris events of the day,
+// each once.
+func (g *Calendar) ephemerisKinds(day time.Time) (kinds []string) {
+	seen := make(map[string]bool)
+	for _, e := range g.ephemeris {
+		if e.day.Month() == day.Month() && e.day.Day() == day.Day() && !seen[e.kind] {
+			seen[e.kind] = true
+			kinds = append(kinds, e.kind)
+		}
+	}
+	return kinds
+}
+
+// astroIcon returns the icon of SetAstroIcons for the kind, or "".
+func (g *Calendar) astroIcon(kind string) string {
+	for _, p := range strings.Split(g.OptAstroIcons, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], kind) {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// drawAstroIcon draws the icon of the kind of size s on the right of
+// the cell, if there is room.
+func (g *Calendar) drawAstroIcon(pdf *gofpdf.Fpdf, layout *cellLayout, fonts elementFonts, kind string, s float64) {
+	if icon := g.astroIcon(kind); icon != "" {
+		g.drawIcon(pdf, layout, fonts, icon, s)
+		return
+	}
+	r, ok := layout.placeRight(s, s)
+	if !ok {
+		return
+	}
+	drawAstroSymbol(pdf, kind, r)
+}
+
+// drawAstroSymbol draws the symbol of the kind into the square r.
+func drawAstroSymbol(pdf *gofpdf.Fpdf, kind string, r rect) {
+	dr, dg, db := pdf.GetDrawColor()
+	defer pdf.SetDrawColor(dr, dg, db)
+	fr, fg, fb := pdf.GetFillColor()
+	defer pdf.SetFillColor(fr, fg, fb)
+	lw := pdf.GetLineWidth()
+	defer pdf.SetLineWidth(lw)
+	pdf.SetDrawColor(DARKGREY, DARKGREY, DARKGREY)
+	pdf.SetFillColor(DARKGREY, DARKGREY, DARKGREY)
+	pdf.SetLineWidth(r.w / 16)
+	pdf.SetDashPattern([]float64{}, 0)
+	cx, cy, s := r.x+r.w/2, r.y+r.h/2, r.w
+	switch kind {
+	case "meteor":
+		// A head with a tail to the upper right
+		pdf.Circle(r.x+0.25*s, r.y+0.75*s, 0.15*s, "F")
+		pdf.Line(r.x+0.35*s, r.y+0.65*s, r.x+0.95*s, r.y+0.05*s)
+		pdf.Line(r.x+0.25*s, r.y+0.55*s, r.x+0.6*s, r.y+0.2*s)
+		pdf.Line(r.x+0.45*s, r.y+0.75*s, r.x+0.8*s, r.y+0.4*s)
+	case "opposition":
+		// Two bodies on opposite sides
+		pdf.Circle(r.x+0.2*s, r.y+0.8*s, 0.15*s, "D")
+		pdf.Circle(r.x+0.8*s, r.y+0.2*s, 0.15*s, "F")
+		pdf.Line(r.x+0.3*s, r.y+0.7*s, r.x+0.7*s, r.y+0.3*s)
+	case "conjunction":
+		// A body with a line from it
+		pdf.Circle(r.x+0.35*s, r.y+0.65*s, 0.25*s, "D")
+		pdf.Line(r.x+0.53*s, r.y+0.47*s, r.x+0.95*s, r.y+0.05*s)
+	case "iss":
+		// The body of the station between its solar panels
+		pdf.Rect(cx-0.12*s, cy-0.12*s, 0.24*s, 0.24*s, "F")
+		pdf.Line(r.x+0.1*s, cy, r.x+0.9*s, cy)
+		pdf.Rect(r.x, r.y+0.3*s, 0.25*s, 0.4*s, "D")
+		pdf.Rect(r.x+0.75*s, r.y+0.3*s, 0.25*s, 0.4*s, "D")
+	case "comet":
+		// A nucleus with a fanned tail
+		pdf.Circle(r.x+0.75*s, r.y+0.25*s, 0.15*s, "F")
+		for _, a := range []float64{200, 225, 250} {
+			pdf.Line(r.x+0.75*s, r.y+0.25*s, r.x+0.75*s+0.8*s*math.Cos(a*math.Pi/180), r.y+0.25*s-0.8*s*math.Sin(a*math.Pi/180))
+		}
+	default:
+		// A five-pointed star
+		var points []gofpdf.PointType
+		for i := 0; i < 10; i++ {
+			rad := 0.5 * s
+			if i%2 == 1 {
+				rad = 0.2 * s
+			}
+			a := (90 + float64(i)*36) * math.Pi / 180
+			points = append(points, gofpdf.PointType{X: cx + rad*math.Cos(a), Y: cy - rad*math.Sin(a)})
+		}
+		pdf.Polygon(points, "F")
+	}
+}
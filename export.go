@@ -0,0 +1,204 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// export.go
+//
+// The events of the calendar for other programs: all sources merged
+// like on the printed pages, written as an iCalendar, JSON or CSV
+// file.
+//
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// datedEvents returns the events on the days of the calendar's
+// months, sorted by date. Weekly events become events on every
+// matching day.
+func (g *Calendar) datedEvents() (eL []gDate) {
+	for _, ev := range g.normalizeEvents(g.collectEvents()) {
+		if ev.Weekday == "" {
+			if int(ev.Month) >= g.WantBeginMonth && int(ev.Month) <= g.WantEndMonth {
+				eL = append(eL, ev)
+			}
+			continue
+		}
+		d := time.Date(g.WantYear, time.Month(g.WantBeginMonth), 1, 0, 0, 0, 0, time.UTC)
+		for ; d.Year() == g.WantYear && int(d.Month()) <= g.WantEndMonth; d = d.AddDate(0, 0, 1) {
+			if weekdayMatches(ev.Weekday, d) {
+				day := ev
+				day.Month, day.Day, day.Weekday = d.Month(), d.Day(), ""
+				eL = append(eL, day)
+			}
+		}
+	}
+	sortByDate(eL)
+	return eL
+}
+
+// sortByDate sorts the events by date, keeping the order of a day.
+func sortByDate(eL []gDate) {
+	sort.SliceStable(eL, func(i, j int) bool {
+		return eL[i].Month < eL[j].Month || eL[i].Month == eL[j].Month && eL[i].Day < eL[j].Day
+	})
+}
+
+// moonEvents returns the moon phases of the calendar's months as events
+// of the category moon, with the names of the moon labels.
+func (g *Calendar) moonEvents() (eL []gDate) {
+	if g.OptHideMoon {
+		return nil
+	}
+	labels := g.moonPhaseLabels(getLanguage(g.OptLocale))
+	from := time.Date(g.WantYear, time.Month(g.WantBeginMonth), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(g.WantYear, time.Month(g.WantEndMonth)+1, 0, 0, 0, 0, 0, time.UTC)
+	phases := moonPhasesBetween(g.astronomy(), from, to)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if p, ok := phases[d.Format("2006-01-02")]; ok {
+			eL = append(eL, gDate{d.Month(), d.Day(), labels[p], "", "", "moon", "", "moon", "", "", 0, ""})
+		}
+	}
+	return eL
+}
+
+// printedEvents returns what the pages show: the events of
+// datedEvents and the moon phases, sorted by date.
+func (g *Calendar) printedEvents() []gDate {
+	eL := append(g.datedEvents(), g.moonEvents()...)
+	sortByDate(eL)
+	return eL
+}
+
+// icsEscaper escapes the texts of ICS properties.
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// writeICSLine writes a content line, folded after 75 octets
+// without splitting UTF-8 characters.
+func writeICSLine(b *bytes.Buffer, line string) {
+	max := 75
+	for len(line) > max {
+		n := max
+		for n > 0 && line[n]&0xc0 == 0x80 {
+			n--
+		}
+		b.WriteString(line[:n] + "\r\n ")
+		line = line[n:]
+		max = 74 // after the space
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// WriteICS writes the events of the calendar as an iCalendar file:
+// the configuration files, ICS files, holidays and computed events,
+// with the leap day policy and deduplication of the calendar, and the
+// moon phases unless they are hidden. Every event is an all-day event,
+// weekly ones are repeated on their days.
+func (g *Calendar) WriteICS(w io.Writer) error {
+	var b bytes.Buffer
+	g.writeICS(&b, g.printedEvents(), "")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeICS writes the events as an iCalendar. With the PDF file pdf,
+// the calendar is named after it and the events link to it and to
+// their images.
+func (g *Calendar) writeICS(b *bytes.Buffer, eL []gDate, pdf string) {
+	for _, l := range []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//Gocal//Gocal//EN", "CALSCALE:GREGORIAN", "METHOD:PUBLISH"} {
+		writeICSLine(b, l)
+	}
+	if pdf != "" {
+		name := strings.TrimSuffix(filepath.Base(pdf), filepath.Ext(pdf))
+		writeICSLine(b, "X-WR-CALNAME:"+icsEscaper.Replace(name))
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	uids := make(map[string]int)
+	for _, ev := range eL {
+		day := time.Date(g.WantYear, ev.Month, ev.Day, 0, 0, 0, 0, time.UTC)
+		summary := plainText(convertFromCP(ev.Text))
+		uid := fmt.Sprintf("%s-%08x", day.Format("20060102"), crc32.ChecksumIEEE([]byte(ev.Source+"\n"+summary)))
+		if n := uids[uid]; n > 0 {
+			uids[uid]++
+			uid = fmt.Sprintf("%s-%d", uid, n)
+		} else {
+			uids[uid] = 1
+		}
+		writeICSLine(b, "BEGIN:VEVENT")
+		writeICSLine(b, "UID:"+uid+"@gocal")
+		writeICSLine(b, "DTSTAMP:"+stamp)
+		writeICSLine(b, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+		writeICSLine(b, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(b, "SUMMARY:"+icsEscaper.Replace(summary))
+		if ev.Description != "" {
+			writeICSLine(b, "DESCRIPTION:"+icsEscaper.Replace(ev.Description))
+		}
+		if ev.Category != "" {
+			writeICSLine(b, "CATEGORIES:"+icsEscaper.Replace(ev.Category))
+		}
+		if pdf != "" {
+			writeICSLine(b, "URL:"+g.artworkLink(pdf))
+			if ev.Image != "" {
+				writeICSLine(b, "IMAGE;VALUE=URI;DISPLAY=BADGE:"+g.artworkLink(ev.Image))
+			}
+		}
+		writeICSLine(b, "TRANSP:TRANSPARENT")
+		writeICSLine(b, "END:VEVENT")
+	}
+	writeICSLine(b, "END:VCALENDAR")
+}
+
+// exportedEvent is an event of the JSON export: the event with its
+// date like 2025-03-14 and the text in UTF-8 without markup.
+type exportedEvent struct {
+	Date string `json:"date"`
+	gDate
+}
+
+// exportedEvents returns the events of the calendar for the JSON
+// and CSV export.
+func (g *Calendar) exportedEvents() (eL []exportedEvent) {
+	for _, ev := range g.datedEvents() {
+		ev.Text = plainText(convertFromCP(ev.Text))
+		day := time.Date(g.WantYear, ev.Month, ev.Day, 0, 0, 0, 0, time.UTC)
+		eL = append(eL, exportedEvent{day.Format("2006-01-02"), ev})
+	}
+	return eL
+}
+
+// WriteJSON writes the events of the calendar like WriteICS, without
+// the moon phases, as a JSON array of objects with date, month, day,
+// text, source and, if set, image, color, category and description.
+func (g *Calendar) WriteJSON(w io.Writer) error {
+	eL := g.exportedEvents()
+	if eL == nil {
+		eL = []exportedEvent{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(eL)
+}
+
+// WriteCSV writes the events of the calendar like WriteICS, without
+// the moon phases, as CSV with a header line.
+func (g *Calendar) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "text", "category", "description", "color", "image", "source"})
+	for _, ev := range g.exportedEvents() {
+		cw.Write([]string{ev.Date, ev.Text, ev.Category, ev.Description, ev.Color, ev.Image, ev.Source})
+	}
+	cw.Flush()
+	return cw.Error()
+}
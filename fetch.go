@@ -0,0 +1,263 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// fetch.go
+//
+// The HTTP client shared by all remote sources: images,
+// ICS calendars and holidays, and the policy of what may be
+// downloaded.
+//
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// USERAGENT is sent with every request.
+const USERAGENT = "Gocal (https://github.com/StefanSchroeder/Gocal)"
+
+var (
+	httpTimeout = 10 * time.Second
+	httpRetries = 3
+	httpMaxSize = int64(50 << 20)
+	// httpBackoff is the wait before the first retry, doubled
+	// for every further retry.
+	httpBackoff = 500 * time.Millisecond
+	// httpTLS is the TLS configuration, nil for the defaults.
+	httpTLS *tls.Config
+)
+
+// SetHTTPOptions sets the timeout of a request, the number of
+// retries and the maximum size of a download in bytes.
+func SetHTTPOptions(timeout time.Duration, retries int, maxSize int64) {
+	httpTimeout = timeout
+	httpRetries = retries
+	httpMaxSize = maxSize
+}
+
+// SetTLSOptions trusts the CA certificates of the PEM file in
+// addition to the system certificates, e.g. of a TLS-intercepting
+// proxy. With insecure, certificates are not verified at all.
+func SetTLSOptions(caFile string, insecure bool) {
+	if caFile == "" && !insecure {
+		httpTLS = nil
+		return
+	}
+	httpTLS = &tls.Config{InsecureSkipVerify: insecure}
+	if caFile == "" {
+		return
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		fatalf(ExitFetch, "# Error reading CA certificates: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		fatalf(ExitFetch, "# Error: no certificates found in %v", caFile)
+	}
+	httpTLS.RootCAs = pool
+}
+
+// RemotePolicy controls what may be downloaded, for services that
+// create calendars of configurations they don't trust.
+type RemotePolicy struct {
+	// Deny refuses all downloads.
+	Deny bool
+	// Hosts may be downloaded from, all hosts if empty; *.example.com
+	// allows the subdomains of example.com.
+	Hosts []string
+	// MaxImageSize and MaxICSSize are the largest images and ICS
+	// calendars in bytes, 0 for the maximum size of SetHTTPOptions.
+	MaxImageSize int64
+	MaxICSSize   int64
+}
+
+var remotePolicy RemotePolicy
+
+// SetRemotePolicy sets what may be downloaded.
+func SetRemotePolicy(p RemotePolicy) {
+	remotePolicy = p
+}
+
+// policyError is a download the remote policy refuses; it isn't
+// retried.
+type policyError struct {
+	msg string
+}
+
+func (e *policyError) Error() string {
+	return e.msg
+}
+
+// check returns an error if the policy refuses the URL.
+func (p RemotePolicy) check(rawurl string) error {
+	if p.Deny {
+		return &policyError{"remote content is denied"}
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &policyError{fmt.Sprintf("the scheme %s is not allowed", u.Scheme)}
+	}
+	if len(p.Hosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range p.Hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if host == h || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return nil
+		}
+	}
+	return &policyError{fmt.Sprintf("the host %s is not allowed", host)}
+}
+
+// maxSize returns the largest download of the content type accept.
+func (p RemotePolicy) maxSize(accept string) int64 {
+	switch {
+	case strings.HasPrefix(accept, "image/") && p.MaxImageSize > 0:
+		return p.MaxImageSize
+	case accept == "text/calendar" && p.MaxICSSize > 0:
+		return p.MaxICSSize
+	}
+	return httpMaxSize
+}
+
+// newHTTPClient returns a client that honors the proxy settings of
+// the environment (HTTP_PROXY, HTTPS_PROXY, NO_PROXY).
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if httpTLS != nil {
+		transport.TLSClientConfig = httpTLS
+	}
+	// Redirects must be allowed as well.
+	checkRedirect := func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return remotePolicy.check(req.URL.String())
+	}
+	return &http.Client{Timeout: httpTimeout, Transport: transport, CheckRedirect: checkRedirect}
+}
+
+// fetchURL downloads the URL. Network errors, rate limits and
+// server errors are retried with backoff.
+func fetchURL(url string, accept string) (data []byte, err error) {
+	if err := remotePolicy.check(url); err != nil {
+		recordDownload(url, nil, err)
+		return nil, err
+	}
+	client := newHTTPClient()
+	wait := httpBackoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		data, retry, err = fetchOnce(client, url, accept)
+		if err == nil || !retry || attempt >= httpRetries {
+			recordDownload(url, data, err)
+			return data, err
+		}
+		fmt.Printf("# Retrying %v in %v: %v\n", url, wait, err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// fetchOnce does a single request and reports whether
+// a failure is worth a retry.
+func fetchOnce(client *http.Client, url string, accept string) (data []byte, retry bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("User-Agent", USERAGENT)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	res, err := client.Do(req)
+	var denied *policyError
+	if errors.As(err, &denied) {
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		return nil, true, fmt.Errorf("%s", res.Status)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("%s", res.Status)
+	}
+	maxSize := remotePolicy.maxSize(accept)
+	if res.ContentLength > maxSize {
+		return nil, false, fmt.Errorf("%d bytes exceed the maximum size of %d bytes", res.ContentLength, maxSize)
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, true, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, false, fmt.Errorf("download exceeds the maximum size of %d bytes", maxSize)
+	}
+	return data, false, nil
+}
+
+// flightGroup runs one download per key at a time: the callers of a
+// key that is being downloaded wait for its result instead of
+// downloading it again.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a download in flight.
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// do runs fn for the key, or waits for the call of the key that is
+// already running, and returns its result.
+func (f *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	if f.calls == nil {
+		f.calls = make(map[string]*flightCall)
+	}
+	c := &flightCall{done: make(chan struct{})}
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err
+}
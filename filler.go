@@ -0,0 +1,175 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// filler.go
+//
+// Filler pages between the months of a booklet.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"math/rand"
+)
+
+const (
+	// RULEDLINESPACING is the distance of the lines on ruled pages.
+	RULEDLINESPACING = 8.0
+	// DOTSPACING is the distance of the dots on dot grid pages.
+	DOTSPACING = 5.0
+	// SUDOKUCLUES is the minimum number of given digits in a sudoku.
+	SUDOKUCLUES = 28
+)
+
+// sudoku is a 9x9 grid, 0 is an empty cell.
+type sudoku [9][9]int
+
+// addFillerPages adds the configured number of filler pages.
+// The seed makes generated puzzles reproducible.
+func (g *Calendar) addFillerPages(pdf *gofpdf.Fpdf, calFont string, fontScale float64, PAGEWIDTH float64, PAGEHEIGHT float64, seed int64) {
+	if g.OptFiller == "" {
+		return
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	for i := 0; i < g.OptFillerCount; i++ {
+		pdf.AddPage()
+		switch g.OptFiller {
+		case "sudoku":
+			pdf.SetDrawColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
+			pdf.SetLineWidth(0.2)
+			pdf.SetDashPattern([]float64{}, 0)
+			puzzle, solution := newSudoku(rnd)
+			drawSudoku(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, puzzle, solution)
+		default:
+			r, err := parseRuling(g.OptFiller)
+			if err != nil {
+				fmt.Printf("# Unknown filler page type '%s'\n", g.OptFiller)
+				break
+			}
+			drawRuling(pdf, rect{MARGIN, 2 * MARGIN, PAGEWIDTH - 2*MARGIN, PAGEHEIGHT - 3*MARGIN}, r)
+		}
+		g.setGridStyle(pdf)
+	}
+}
+
+// drawSudoku prints the puzzle centered on the page and the
+// solution in small print upside down at the bottom.
+func drawSudoku(pdf *gofpdf.Fpdf, calFont string, fontScale float64, PAGEWIDTH float64, PAGEHEIGHT float64, puzzle, solution sudoku) {
+	size := PAGEWIDTH - 4*MARGIN
+	if PAGEHEIGHT-6*MARGIN < size {
+		size = PAGEHEIGHT - 6*MARGIN
+	}
+	x0 := (PAGEWIDTH - size) / 2
+	y0 := 2 * MARGIN
+	drawSudokuGrid(pdf, calFont, size/9*2.0*fontScale, x0, y0, size, puzzle)
+
+	small := 3 * MARGIN
+	pdf.TransformBegin()
+	pdf.TransformRotate(180, PAGEWIDTH/2, PAGEHEIGHT-MARGIN-small/2)
+	drawSudokuGrid(pdf, calFont, small/9*2.0, (PAGEWIDTH-small)/2, PAGEHEIGHT-MARGIN-small, small, solution)
+	pdf.TransformEnd()
+}
+
+func drawSudokuGrid(pdf *gofpdf.Fpdf, calFont string, fontSize float64, x0, y0, size float64, s sudoku) {
+	cell := size / 9
+	pdf.SetDrawColor(BLACK, BLACK, BLACK)
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+	for i := 0; i <= 9; i++ {
+		pdf.SetLineWidth(size / 900)
+		if i%3 == 0 {
+			pdf.SetLineWidth(size / 300)
+		}
+		pdf.Line(x0, y0+float64(i)*cell, x0+size, y0+float64(i)*cell)
+		pdf.Line(x0+float64(i)*cell, y0, x0+float64(i)*cell, y0+size)
+	}
+	pdf.SetFont(calFont, "", fontSize)
+	for r := 0; r < 9; r++ {
+		for c := 0; c < 9; c++ {
+			if s[r][c] == 0 {
+				continue
+			}
+			d := fmt.Sprintf("%d", s[r][c])
+			pdf.Text(x0+(float64(c)+0.5)*cell-pdf.GetStringWidth(d)/2, y0+(float64(r)+0.5)*cell+fontSize*0.12, d)
+		}
+	}
+}
+
+// newSudoku generates a puzzle with a unique solution.
+func newSudoku(rnd *rand.Rand) (puzzle, solution sudoku) {
+	// Shuffle a valid base pattern: digits, rows within bands,
+	// columns within stacks, bands and stacks.
+	digits := rnd.Perm(9)
+	var rows, cols []int
+	for _, band := range rnd.Perm(3) {
+		for _, r := range rnd.Perm(3) {
+			rows = append(rows, band*3+r)
+		}
+	}
+	for _, stack := range rnd.Perm(3) {
+		for _, c := range rnd.Perm(3) {
+			cols = append(cols, stack*3+c)
+		}
+	}
+	for r := 0; r < 9; r++ {
+		for c := 0; c < 9; c++ {
+			rr, cc := rows[r], cols[c]
+			solution[r][c] = digits[(rr*3+rr/3+cc)%9] + 1
+		}
+	}
+
+	// Remove digits as long as the solution stays unique.
+	puzzle = solution
+	clues := 81
+	for _, p := range rnd.Perm(81) {
+		if clues <= SUDOKUCLUES {
+			break
+		}
+		r, c := p/9, p%9
+		v := puzzle[r][c]
+		puzzle[r][c] = 0
+		test := puzzle
+		if test.countSolutions(2) != 1 {
+			puzzle[r][c] = v
+			continue
+		}
+		clues--
+	}
+	return puzzle, solution
+}
+
+// valid reports whether v can be placed at r, c.
+func (s *sudoku) valid(r, c, v int) bool {
+	br, bc := r/3*3, c/3*3
+	for i := 0; i < 9; i++ {
+		if s[r][i] == v || s[i][c] == v || s[br+i/3][bc+i%3] == v {
+			return false
+		}
+	}
+	return true
+}
+
+// countSolutions counts the solutions up to limit by backtracking.
+// The grid is modified while solving.
+func (s *sudoku) countSolutions(limit int) int {
+	for r := 0; r < 9; r++ {
+		for c := 0; c < 9; c++ {
+			if s[r][c] != 0 {
+				continue
+			}
+			n := 0
+			for v := 1; v <= 9 && n < limit; v++ {
+				if s.valid(r, c, v) {
+					s[r][c] = v
+					n += s.countSolutions(limit - n)
+					s[r][c] = 0
+				}
+			}
+			return n
+		}
+	}
+	return 1
+}
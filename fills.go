@@ -0,0 +1,108 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// fills.go
+//
+// Gradient and pattern fills of areas.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"strings"
+)
+
+// PATTERNSPACING is the distance of the stripes, dots and checks.
+const PATTERNSPACING = 2.5
+
+// paintFill paints the area with the fill spec, one of
+//
+//	color               flat color
+//	gradient:c1:c2      from c1 on the left to c2 on the right
+//	vgradient:c1:c2     from c1 at the top to c2 at the bottom
+//	stripes:c           diagonal stripes
+//	dots:c              dots
+//	checks:c            checkerboard
+//
+// With a radius the area has rounded corners.
+func paintFill(pdf *gofpdf.Fpdf, spec string, x, y, w, h, radius float64) error {
+	parts := strings.Split(spec, ":")
+	var colors [][3]int
+	for _, p := range parts[1:] {
+		var c [3]int
+		var err error
+		c[0], c[1], c[2], err = parseColor(p)
+		if err != nil {
+			return err
+		}
+		colors = append(colors, c)
+	}
+	kind := parts[0]
+	switch kind {
+	case "gradient", "vgradient":
+		if len(colors) != 2 {
+			return fmt.Errorf("%s needs two colors, e.g. %s:#ffffff:#8080ff", kind, kind)
+		}
+	case "stripes", "dots", "checks":
+		if len(colors) != 1 {
+			return fmt.Errorf("%s needs one color, e.g. %s:#c0c0c0", kind, kind)
+		}
+	default:
+		if len(parts) != 1 {
+			return fmt.Errorf("unknown fill '%s'", kind)
+		}
+		var c [3]int
+		var err error
+		c[0], c[1], c[2], err = parseColor(kind)
+		if err != nil {
+			return err
+		}
+		colors = append(colors, c)
+		kind = "color"
+	}
+
+	if radius > 0 {
+		pdf.ClipRoundedRect(x, y, w, h, radius, false)
+	} else {
+		pdf.ClipRect(x, y, w, h, false)
+	}
+	fr, fg, fb := pdf.GetFillColor()
+	c := colors[0]
+	pdf.SetFillColor(c[0], c[1], c[2])
+	switch kind {
+	case "color":
+		pdf.Rect(x, y, w, h, "F")
+	case "gradient":
+		pdf.LinearGradient(x, y, w, h, c[0], c[1], c[2], colors[1][0], colors[1][1], colors[1][2], 0, 0, 1, 0)
+	case "vgradient":
+		pdf.LinearGradient(x, y, w, h, c[0], c[1], c[2], colors[1][0], colors[1][1], colors[1][2], 0, 1, 0, 0)
+	case "stripes":
+		for d := 0.0; d < w+h; d += 2 * PATTERNSPACING {
+			pdf.Polygon([]gofpdf.PointType{
+				{X: x + d, Y: y}, {X: x + d + PATTERNSPACING, Y: y},
+				{X: x + d + PATTERNSPACING - h, Y: y + h}, {X: x + d - h, Y: y + h},
+			}, "F")
+		}
+	case "dots":
+		for dy := PATTERNSPACING / 2; dy < h; dy += PATTERNSPACING {
+			for dx := PATTERNSPACING / 2; dx < w; dx += PATTERNSPACING {
+				pdf.Circle(x+dx, y+dy, PATTERNSPACING/6, "F")
+			}
+		}
+	case "checks":
+		for row, dy := 0, 0.0; dy < h; row, dy = row+1, dy+PATTERNSPACING {
+			for col, dx := 0, 0.0; dx < w; col, dx = col+1, dx+PATTERNSPACING {
+				if (row+col)%2 == 0 {
+					pdf.Rect(x+dx, y+dy, PATTERNSPACING, PATTERNSPACING, "F")
+				}
+			}
+		}
+	}
+	pdf.SetFillColor(fr, fg, fb)
+	pdf.ClipEnd()
+	return nil
+}
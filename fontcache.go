@@ -0,0 +1,137 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// fontcache.go
+//
+// The converted fonts are kept in the user cache directory,
+// so that repeated runs skip the conversion.
+//
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var (
+	useFontCache = true
+	// fontCacheRoot is the cache directory, empty for the
+	// default user cache directory.
+	fontCacheRoot = ""
+)
+
+// SetFontCache enables or disables the font cache.
+func SetFontCache(enabled bool) {
+	useFontCache = enabled
+}
+
+// fontCacheKey returns the path of a font in the cache without the
+// extension, named by the hash of the font file and the code page, so
+// the same font under another name is found too. It returns "" if
+// there is no cache.
+func fontCacheKey(fontFile string) string {
+	if !useFontCache {
+		return ""
+	}
+	root := fontCacheRoot
+	if root == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		root = filepath.Join(base, "gocal", "fonts")
+	}
+	data, err := ioutil.ReadFile(fontFile)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte(codepageCP1252))
+	return filepath.Join(root, hex.EncodeToString(h.Sum(nil))[:32])
+}
+
+// loadCachedFont copies the cached font into the directory under the
+// name of the font, with the definition pointing to its .z file.
+func loadCachedFont(cacheKey, fontName, dir string) error {
+	def, err := ioutil.ReadFile(cacheKey + ".json")
+	if err != nil {
+		return err
+	}
+	z, err := ioutil.ReadFile(cacheKey + ".z")
+	if err != nil {
+		return err
+	}
+	if def, err = setFontFile(def, fontName+".z"); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, fontName+".z"), z, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fontName+".json"), def, 0600)
+}
+
+// setFontFile sets the name of the compressed font in the definition.
+func setFontFile(def []byte, file string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(def, &fields); err != nil {
+		return nil, err
+	}
+	name, _ := json.Marshal(file)
+	fields["File"] = name
+	return json.Marshal(fields)
+}
+
+// storeCachedFont copies the converted font into the cache. The files
+// are renamed into place, the definition last, so that concurrent runs
+// never see half-written files. Errors only mean a slower next run.
+func storeCachedFont(cacheKey, fontName, dir string) {
+	if err := os.MkdirAll(filepath.Dir(cacheKey), 0700); err != nil {
+		return
+	}
+	for _, ext := range []string{".z", ".json"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, fontName+ext))
+		if err != nil {
+			return
+		}
+		tmp, err := ioutil.TempFile(filepath.Dir(cacheKey), "tmp-")
+		if err != nil {
+			return
+		}
+		_, err = tmp.Write(data)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil || os.Rename(tmp.Name(), cacheKey+ext) != nil {
+			os.Remove(tmp.Name())
+			return
+		}
+	}
+}
+
+//go:generate go run wasm/mkfonts.go
+
+// WriteFontDefinitions converts the builtin fonts into the directory,
+// for the browser build that can't convert them itself.
+func WriteFontDefinitions(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	gen, err := NewGenerator(nil, nil, "", nil)
+	if err != nil {
+		return err
+	}
+	for name, data := range gen.defs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
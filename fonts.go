@@ -0,0 +1,185 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// fonts.go
+//
+// Font family and style per element of the calendar.
+//
+
+import (
+	"encoding/binary"
+	"github.com/phpdave11/gofpdf"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// fontElements are the elements of the monthly calendar
+// with their own font.
+var fontElements = []string{"title", "weekday", "day", "event", "small", "footer"}
+
+// coreFamilies are the PDF core fonts that provide the regular
+// and italic styles of the built-in fonts.
+var coreFamilies = map[string]string{"serif": "Times", "sans": "Helvetica", "mono": "Courier"}
+
+// fontStyles maps the style names to gofpdf styles.
+var fontStyles = map[string]string{"regular": "", "bold": "B", "italic": "I", "bolditalic": "BI"}
+
+type elementFont struct {
+	family string
+	style  string
+}
+
+// elementFonts maps an element to its font.
+type elementFonts map[string]elementFont
+
+// set selects the font of the element.
+func (f elementFonts) set(pdf *gofpdf.Fpdf, element string, size float64) {
+	e := f[element]
+	pdf.SetFont(e.family, e.style, size)
+}
+
+// loadElementFonts parses the element fonts like
+// "title=sans:italic,day=serif:regular" and adds them to the PDF.
+// Elements without font use the calendar font.
+func (g *Calendar) loadElementFonts(pdf *gofpdf.Fpdf, calFont string, fontTempdir string) elementFonts {
+	fonts := make(elementFonts)
+	for _, e := range fontElements {
+		fonts[e] = elementFont{calFont, ""}
+	}
+	loaded := make(map[string]string)
+	specs := g.OptElementFonts
+	if font := g.brand().Font; font != "" {
+		// The fonts of the user win.
+		specs = "title=" + font + ",footer=" + font + "," + specs
+	}
+	for _, spec := range strings.Split(specs, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		kv := strings.SplitN(spec, "=", 2)
+		element := strings.TrimSpace(kv[0])
+		if _, ok := fonts[element]; !ok || len(kv) != 2 {
+			g.warnf("field", "Unknown font element '%s', use one of %v", spec, fontElements)
+			continue
+		}
+		// The style is optional, and a Windows path has a colon, too.
+		// A TrueType font may also name an instance, e.g. "semibold".
+		family, style := strings.TrimSpace(kv[1]), ""
+		if i := strings.LastIndex(family, ":"); i > 0 {
+			if _, ok := fontStyles[family[i+1:]]; ok || isInstanceName(family[:i], family[i+1:]) {
+				family, style = family[:i], family[i+1:]
+			}
+		}
+
+		core, builtin := coreFamilies[family]
+		switch {
+		case builtin && style != "" && style != "bold":
+			fonts[element] = elementFont{core, fontStyles[style]}
+		default:
+			if _, named := builtinFonts[family]; !builtin && !named {
+				var ok bool
+				if family, ok = g.rootedFile(family); !ok {
+					continue
+				}
+			}
+			if !builtin && style != "" {
+				if file, ok := g.fontInstance(family, style, fontTempdir); ok {
+					family = file
+				} else if file, ok := styleFontFile(family, style); ok {
+					family = file
+				} else {
+					g.warnf("font", "No %s instance in %s and no %s file next to it, using it as it is", style, family, style)
+				}
+			}
+			name, ok := loaded[family]
+			if !ok {
+				name = g.convertFont(family, fontTempdir)
+				pdf.AddFont(name, "", name+".json")
+				loaded[family] = name
+			}
+			fonts[element] = elementFont{name, ""}
+		}
+	}
+	return fonts
+}
+
+// isInstanceName reports whether the style is a name of an instance
+// of a variable font, e.g. "semibold" for "Roboto[wght].ttf:semibold".
+func isInstanceName(fontFile, style string) bool {
+	ext := strings.ToLower(filepath.Ext(fontFile))
+	if style == "" || ext != ".ttf" && ext != ".otf" {
+		return false
+	}
+	for _, r := range style {
+		if !unicode.IsLetter(r) && r != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// styleSuffixes are the endings of the names of the font files of
+// the styles, e.g. Roboto-Italic.ttf.
+var styleSuffixes = map[string]string{"regular": "-Regular", "bold": "-Bold", "italic": "-Italic", "bolditalic": "-BoldItalic"}
+
+// styleFontFile returns the file of the style of the font family
+// next to the font file, e.g. Roboto-Italic.ttf for Roboto-Bold.ttf.
+func styleFontFile(fontFile string, style string) (string, bool) {
+	if _, ok := styleSuffixes[style]; !ok {
+		return "", false
+	}
+	ext := filepath.Ext(fontFile)
+	base := strings.TrimSuffix(fontFile, ext)
+	for _, suffix := range []string{"-BoldItalic", "-Regular", "-Bold", "-Italic"} {
+		if strings.HasSuffix(base, suffix) {
+			base = strings.TrimSuffix(base, suffix)
+			break
+		}
+	}
+	file := base + styleSuffixes[style] + ext
+	if _, err := os.Stat(file); err != nil {
+		return "", false
+	}
+	return file, true
+}
+
+// isVariableFont reports whether the TrueType font has
+// variation axes (an fvar table).
+func isVariableFont(fontFile string) bool {
+	f, err := os.Open(fontFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var header struct {
+		Version       uint32
+		NumTables     uint16
+		SearchRange   uint16
+		EntrySelector uint16
+		RangeShift    uint16
+	}
+	if binary.Read(f, binary.BigEndian, &header) != nil {
+		return false
+	}
+	for i := 0; i < int(header.NumTables); i++ {
+		var record struct {
+			Tag      [4]byte
+			Checksum uint32
+			Offset   uint32
+			Length   uint32
+		}
+		if binary.Read(f, binary.BigEndian, &record) != nil {
+			return false
+		}
+		if string(record.Tag[:]) == "fvar" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+// The fuzz targets need Go 1.18, the other tests run with Go 1.16.
+
+//go:build go1.18
+// +build go1.18
+
+package gocal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// forgetWarnings drops the warnings of the inputs before, which
+// would make the fuzzing slower and slower.
+func forgetWarnings() {
+	warningsMutex.Lock()
+	warnings = nil
+	warningsMutex.Unlock()
+}
+
+// go test -fuzz=FuzzParseConfiguration
+func FuzzParseConfiguration(f *testing.F) {
+	for _, name := range []string{"test-gocal.xml", "test-styles.xml", "test-images.xml", "test-brand.xml"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte("<Gocal><Gocaldate date=\"12/24\" text=\"\xff\xfe\"/></Gocal>"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		forgetWarnings()
+		parseConfiguration(data, "fuzz.xml")
+		New(1, 12, 2026).checkConfigurationFields(validUTF8(data, "fuzz.xml", warnf), "fuzz.xml")
+	})
+}
+
+// go test -fuzz=FuzzParseConfigDate
+func FuzzParseConfigDate(f *testing.F) {
+	for _, d := range []string{"12/24", "2026-12-24", "Sun+1 Easter", "every 2 weeks from 1/5", "last Mon in 5", "1/0", "-"} {
+		f.Add(d, "de_DE")
+	}
+	f.Fuzz(func(t *testing.T, date string, lang string) {
+		forgetWarnings()
+		parseConfigDate(date, lang, 2026)
+	})
+}
+
+// go test -fuzz=FuzzICS
+func FuzzICS(f *testing.F) {
+	f.Add([]byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART:20260704T100000Z\r\nDTEND:20260704T110000Z\r\nSUMMARY:Party\r\nDESCRIPTION:Bring\\, food\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	f.Add([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20261224\nSUMMARY:\xc3\x28\nEND:VEVENT\nEND:VCALENDAR\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		forgetWarnings()
+		g := New(1, 12, 2026)
+		g.AddFile("fuzz.ics", data)
+		g.icsReader().read("fuzz.ics", 2026, "", g.warnf)
+	})
+}
+
+// go test -fuzz=FuzzReadRecipients
+func FuzzReadRecipients(f *testing.F) {
+	data, err := ioutil.ReadFile("test-recipients.csv")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Add([]byte("\ufeffName,Photo\n\"Ann\"\"\",\xff.jpg\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		forgetWarnings()
+		recipients, err := readRecipients(bytes.NewReader(data), "fuzz.csv")
+		if err == nil && len(recipients) > MAXRECIPIENTS {
+			t.Fatalf("%d recipients", len(recipients))
+		}
+	})
+}
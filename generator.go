@@ -0,0 +1,210 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// generator.go
+//
+// A Generator prepares what all calendars need once, for servers that
+// create many calendars at the same time: the converted fonts, the
+// names of the locales and the holidays.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ICSCACHETTL is how long a generator keeps a downloaded ICS calendar
+// before it downloads it again.
+const ICSCACHETTL = 15 * time.Minute
+
+// ICSCACHESIZE is the number of downloaded ICS calendars a generator
+// keeps at most.
+const ICSCACHESIZE = 100
+
+// Generator creates calendars with the fonts, names and holidays it
+// prepared when it was made. Its calendars may be created
+// concurrently.
+type Generator struct {
+	// fonts are the names of the converted fonts by font file or
+	// builtin name, defs their definitions by file name.
+	fonts map[string]string
+	defs  map[string][]byte
+	// downloads keeps the ICS calendars that the calendars download.
+	downloads *downloadCache
+	// holidayCountry is the country of the holidays of the calendars.
+	holidayCountry string
+}
+
+// NewGenerator converts the builtin fonts and the font files, formats
+// the names of the locales and downloads the holidays of the country,
+// e.g. DE, in the years. Its calendars show the holidays of the
+// country; an empty country keeps the default, FR. Holidays that
+// can't be downloaded are an error.
+func NewGenerator(fonts, locales []string, holidayCountry string, holidayYears []int) (*Generator, error) {
+	if holidayCountry == "" {
+		holidayCountry = HOLIDAYCOUNTRY
+	}
+	gen := &Generator{fonts: make(map[string]string), defs: make(map[string][]byte), holidayCountry: holidayCountry}
+	gen.downloads = &downloadCache{ttl: ICSCACHETTL, size: ICSCACHESIZE, fetch: fetchURL, entries: make(map[string]download)}
+	for _, f := range fonts {
+		if _, ok := builtinFonts[f]; !ok {
+			if _, err := os.Stat(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := gen.convertFonts(append([]string{"sans", "mono", "serif"}, fonts...)); err != nil {
+		return nil, err
+	}
+	for _, l := range locales {
+		// The names are kept for all calendars, see localizedNames.
+		lang := getLanguage(l)
+		getLocalizedMonthNames(lang)
+		getLocalizedMonthNamesGenitive(lang)
+		getLocalizedWeekdayNames(lang, 0)
+	}
+	var err error
+	warn := func(kind string, format string, v ...interface{}) {
+		if err == nil {
+			err = fmt.Errorf(format, v...)
+		}
+	}
+	for _, y := range holidayYears {
+		fetchHolidayEvents(HOLIDAY_URL, holidayCountry, holidayCountry, holidayCountry, false, y, warn)
+		fetchHolidayEvents(SCHOOLHOLIDAY_URL, holidayCountry, holidayCountry, holidayCountry, false, y, warn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gen, nil
+}
+
+// convertFonts converts the fonts and keeps their definitions.
+func (gen *Generator) convertFonts(fonts []string) error {
+	if fontLoader != nil {
+		// The fonts are converted beforehand, see browser.go.
+		return nil
+	}
+	tempDirname, err := makeTempdir("gocal-")
+	if err != nil {
+		return err
+	}
+	defer removeTempdir(tempDirname)
+	for _, font := range fonts {
+		if _, ok := gen.fonts[font]; ok {
+			continue
+		}
+		name, err := processFontInto(font, tempDirname)
+		if err != nil {
+			return err
+		}
+		for _, ext := range []string{".json", ".z"} {
+			data, err := ioutil.ReadFile(filepath.Join(tempDirname, name+ext))
+			if err != nil {
+				return err
+			}
+			gen.defs[name+ext] = data
+		}
+		gen.fonts[font] = name
+	}
+	return nil
+}
+
+// New returns a calendar of the months b to e of the year y, which
+// uses what the generator prepared.
+func (gen *Generator) New(b int, e int, y int) *Calendar {
+	g := New(b, e, y)
+	g.generator = gen
+	g.OptHolidayCountry = gen.holidayCountry
+	g.icsReader().fetch = gen.downloads.get
+	return g
+}
+
+// Open loads the font definition of the file name for the PDF.
+func (gen *Generator) Open(name string) (io.Reader, error) {
+	data, ok := gen.defs[name]
+	if !ok {
+		return nil, fmt.Errorf("font %s was not converted by the generator", name)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// font returns the name of the converted font.
+func (gen *Generator) font(fontFile string) (string, bool) {
+	if gen == nil {
+		return "", false
+	}
+	name, ok := gen.fonts[fontFile]
+	return name, ok
+}
+
+// downloadCache keeps downloads for a while and at most size of them,
+// for the calendars of a generator.
+type downloadCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	fetch   func(url, accept string) ([]byte, error)
+	entries map[string]download
+	flight  flightGroup
+}
+
+// download is a downloaded file and when it was downloaded.
+type download struct {
+	data []byte
+	at   time.Time
+}
+
+// get returns the download of the URL, downloaded again after the ttl.
+// Failed downloads are not kept.
+func (c *downloadCache) get(url, accept string) ([]byte, error) {
+	c.mu.Lock()
+	d, ok := c.entries[url]
+	c.mu.Unlock()
+	if ok && time.Since(d.at) < c.ttl {
+		return d.data, nil
+	}
+	data, err := c.flight.do(url, func() (interface{}, error) {
+		data, err := c.fetch(url, accept)
+		if err != nil {
+			return nil, err
+		}
+		c.put(url, data)
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data.([]byte), nil
+}
+
+// put keeps the download, and makes room for it by dropping the
+// expired downloads or else the oldest one.
+func (c *downloadCache) put(url string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[url]; !ok && len(c.entries) >= c.size {
+		oldest := ""
+		for u, d := range c.entries {
+			if time.Since(d.at) >= c.ttl {
+				delete(c.entries, u)
+			} else if oldest == "" || d.at.Before(c.entries[oldest].at) {
+				oldest = u
+			}
+		}
+		if len(c.entries) >= c.size {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[url] = download{data, time.Now()}
+}
@@ -10,4 +10,6 @@ require (
 	github.com/paulrosania/go-charset v0.0.0-20190326053356-55c9d7a5834c
 	github.com/phpdave11/gofpdf v1.4.2
 	github.com/soniakeys/meeus/v3 v3.0.1
+	github.com/soniakeys/unit v1.0.0
+	golang.org/x/image v0.18.0
 )
@@ -12,17 +12,18 @@ Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
 */
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/phpdave11/gofpdf"
-	"github.com/soniakeys/meeus/v3/julian"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -42,10 +43,23 @@ const (
 	LIGHTGREY = 170
 	// BLACK is black.
 	BLACK = 0
+	// WATERMARKGREY is the intensity of grey of day number watermarks.
+	WATERMARKGREY = 225
 
 	// MOONSIZE is the size of the moon icon.
 	MOONSIZE = 4.0
 
+	// WRITABLEDAYSIZE is the size of the day numbers of the writable
+	// preset in points.
+	WRITABLEDAYSIZE = 11.0
+	// WRITABLEGRIDWIDTH is the width of the grid of the writable preset.
+	WRITABLEGRIDWIDTH = 0.1
+
+	// STRIPWEEKS is the number of weeks per page in the planner strip.
+	STRIPWEEKS = 9
+	// MONTHRULEWIDTH is the width of the rule between two months.
+	MONTHRULEWIDTH = 0.8
+
 	// Font sizes
 	EVENTFONTSIZE    = 10.0
 	HEADERFONTSIZE   = 32.0
@@ -54,11 +68,14 @@ const (
 	DOYFONTSIZE      = 12.0
 	MONTHDAYFONTSIZE = 32.0
 	FOOTERFONTSIZE   = 12.0
+	QUOTEFONTSIZE    = 14.0
 
 	// Default holiday url
 	HOLIDAY_URL = "https://openholidaysapi.org/PublicHolidays?countryIsoCode=%s&subdivisionCode=%s&languageIsoCode=%s&validFrom=%s-01-01&validTo=%s-12-31"
 	// Default School holiday url
 	SCHOOLHOLIDAY_URL = "https://openholidaysapi.org/SchoolHolidays?countryIsoCode=%s&subdivisionCode=%s&languageIsoCode=%s&validFrom=%s-01-01&validTo=%s-12-31"
+	// Default country of the holidays
+	HOLIDAYCOUNTRY = "FR"
 )
 
 var testedLanguage = map[string]bool{
@@ -71,7 +88,7 @@ var testedLanguage = map[string]bool{
 	"fr_FR": true,  // French (France)
 	"fr_CA": true,  // French (Canada)
 	"de_DE": true,  // German (Germany)
-	"hu_HU": true,  // Hungarian (Hungary)
+	"hu_HU": true,  // Hungarian (Hungary), but ő of Hétfő is missing in cp1252
 	"it_IT": true,  // Italian (Italy)
 	"nn_NO": true,  // Norwegian Nynorsk (Norway)
 	"nb_NO": true,  // Norwegian Bokmål (Norway)
@@ -152,6 +169,123 @@ type Calendar struct {
 	OptICS             []string
 	OptMargin          string
 	OptHoliday         bool
+	OptHolidayCountry  string
+	OptPageNumbers     string
+	OptPageNumberPos   string
+	OptPageNumberStart int
+	OptPageNumberSkip  bool
+	OptQuotes          string
+	OptQuoteFont       string
+	OptQuoteWrap       bool
+	TextList           []gText
+	OptGridWidth       float64
+	OptGridDash        string
+	OptGridColor       string
+	OptGridStyle       string
+	OptDayNumberPos    string
+	OptDayNumberSize   float64
+	OptDayNumberColor  string
+	OptHighlightToday  string
+	OptTodayColor      string
+	OptOtherMonths     string
+	OptJulian          bool
+	OptLiturgical      bool
+	OptLiturgicalColor bool
+	OptLocation        string
+	OptTimezone        string
+	OptShabbat         bool
+	OptCandleLighting  int
+	OptHavdalah        int
+	OptPrayer          bool
+	OptPrayerMethod    string
+	OptPrayerHanafi    bool
+	OptHistory         string
+	OptHistoryLength   int
+	ContentProvider    DayContentProvider
+	OptFiller          string
+	OptFillerCount     int
+	OptHabits          string
+	OptBudget          string
+	OptSplitMonths     bool
+	OptElementFonts    string
+	OptTextOutline     string
+	OptTextShadow      string
+	OptHeaderFill      string
+	OptFillStyle       string
+	OptNumerals        string
+	OptNumeralFont     string
+	OptNames           string
+	OptDedup           string
+	OptLeapDay         string
+	OptAstroEvents     bool
+	OptAnnotations     bool
+	OptLayers          bool
+	OptGrayscale       float64
+	OptBraille         bool
+	OptDayImages       int
+	OptPhotoFit        string
+	OptEnhance         bool
+	OptCaption         string
+	OptCaptionPos      string
+	OptCaptionStyle    string
+	OptPhotoOrder      string
+	OptPhotoSeed       int64
+	OptBackground      string
+	OptBorder          string
+	OptSeasonTheme     string
+	OptBrand           string
+	OptAddressBlock    string
+	OptReturnAddress   string
+	OptWallBound       bool
+	OptPDFX            string
+	OptTrace           bool
+	OptGenerator       string
+	OptStream          int
+	OptSanitize        string
+	OptEventLength     int
+	OptICSInclude      string
+	OptTodoColor       string
+	OptJournalColor    string
+	OptICSTentative    string
+	OptICSTransp       string
+	OptLegend          bool
+	OptSourcePrefixes  map[string]string
+	OptPanchang        bool
+	OptQuarters        int
+	OptSprintStart     string
+	OptSprintDays      int
+	OptBridgeDays      string
+	OptBridgeFill      string
+	OptVacation        int
+	OptVacationText    string
+	OptNotes           string
+	OptWritable        bool
+	OptMoonLabels      string
+	OptEphemeris       []string
+	OptAstroIcons      string
+	OptMoonAnchor      string
+	OptMoonSize        string
+	OptMoonOpacity     float64
+	OptCompanionICS    bool
+	OptArtworkURL      string
+	OptFileRoot        string
+	Astronomy          Astronomy
+	imageFrames        map[string]imageFrame
+	branding           *Gocalbrand
+	recipient          Recipient
+	output             io.Writer
+	ics                *icsReader
+	generator          *Generator
+	part               *streamPart
+	patterns           []datePattern
+	projects           []project
+	ephemeris          []ephemerisEvent
+	eventCounts        map[string]EventCount
+	files              map[string][]byte
+	report             *Report
+	strict             bool
+	exitCode           int
+	fatalPanics        bool
 }
 
 func New(b int, e int, y int) *Calendar {
@@ -181,23 +315,258 @@ func New(b int, e int, y int) *Calendar {
 		nil,     // OptICS
 		"",      // OptMargin
 		false,   // OptHoliday
+		"FR",    // OptHolidayCountry, HOLIDAYCOUNTRY
+		"",      // OptPageNumbers
+		"BR",    // OptPageNumberPos
+		1,       // OptPageNumberStart
+		false,   // OptPageNumberSkip
+		"",      // OptQuotes
+		"",      // OptQuoteFont
+		false,   // OptQuoteWrap
+		nil,     // TextList
+		0.0,     // OptGridWidth
+		"",      // OptGridDash
+		"",      // OptGridColor
+		"lines", // OptGridStyle
+		"TL",    // OptDayNumberPos
+		0.0,     // OptDayNumberSize
+		"",      // OptDayNumberColor
+		"",      // OptHighlightToday
+		"",      // OptTodayColor
+		"gray",  // OptOtherMonths
+		false,   // OptJulian
+		false,   // OptLiturgical
+		false,   // OptLiturgicalColor
+		"",      // OptLocation
+		"",      // OptTimezone
+		false,   // OptShabbat
+		18,      // OptCandleLighting, CANDLELIGHTING
+		42,      // OptHavdalah, HAVDALAH
+		false,   // OptPrayer
+		"MWL",   // OptPrayerMethod
+		false,   // OptPrayerHanafi
+		"",      // OptHistory
+		60,      // OptHistoryLength
+		nil,     // ContentProvider
+		"",      // OptFiller
+		1,       // OptFillerCount
+		"",      // OptHabits
+		"",      // OptBudget
+		false,   // OptSplitMonths
+		"",      // OptElementFonts
+		"",      // OptTextOutline
+		"",      // OptTextShadow
+		"",      // OptHeaderFill
+		"",      // OptFillStyle
+		"latn",  // OptNumerals
+		"",      // OptNumeralFont
+		"",      // OptNames
+		"",      // OptDedup, keep-all
+		"",      // OptLeapDay, skip
+		false,   // OptAstroEvents
+		false,   // OptAnnotations
+		false,   // OptLayers
+		0,       // OptGrayscale, color
+		false,   // OptBraille
+		4,       // OptDayImages, DAYIMAGES
+		"",      // OptPhotoFit, stretch
+		false,   // OptEnhance
+		"",      // OptCaption
+		"",      // OptCaptionPos, bottomright
+		"",      // OptCaptionStyle, light
+		"",      // OptPhotoOrder, name
+		0,       // OptPhotoSeed
+		"",      // OptBackground
+		"",      // OptBorder
+		"",      // OptSeasonTheme
+		"",      // OptBrand
+		"",      // OptAddressBlock
+		"",      // OptReturnAddress
+		false,   // OptWallBound
+		"",      // OptPDFX
+		false,   // OptTrace
+		"",      // OptGenerator
+		0,       // OptStream
+		"",      // OptSanitize
+		0,       // OptEventLength
+		"",      // OptICSInclude
+		"",      // OptTodoColor
+		"",      // OptJournalColor
+		"",      // OptICSTentative
+		"",      // OptICSTransp
+		false,   // OptLegend
+		nil,     // OptSourcePrefixes
+		false,   // OptPanchang
+		0,       // OptQuarters
+		"",      // OptSprintStart
+		0,       // OptSprintDays
+		"",      // OptBridgeDays
+		"",      // OptBridgeFill
+		0,       // OptVacation
+		"",      // OptVacationText
+		"",      // OptNotes
+		false,   // OptWritable
+		"",      // OptMoonLabels
+		nil,     // OptEphemeris
+		"",      // OptAstroIcons
+		"",      // OptMoonAnchor
+		"",      // OptMoonSize
+		1.0,     // OptMoonOpacity
+		false,   // OptCompanionICS
+		"",      // OptArtworkURL
+		"",      // OptFileRoot
+		nil,     // Astronomy
+		nil,     // imageFrames
+		nil,     // branding
+		nil,     // recipient
+		nil,     // output
+		nil,     // ics
+		nil,     // generator
+		nil,     // part
+		nil,     // patterns
+		nil,     // projects
+		nil,     // ephemeris
+		nil,     // eventCounts
+		nil,     // files
+		nil,     // report
+		false,   // strict
+		ExitOK,  // exitCode
+		false,   // fatalPanics
 	}
 }
 
 // gDate is a type to store single events
 type gDate struct {
-	Month   time.Month
-	Day     int
-	Text    string
-	Weekday string
-	Image   string
+	Month    time.Month `json:"month"`
+	Day      int        `json:"day"`
+	Text     string     `json:"text"`
+	Weekday  string     `json:"weekday,omitempty"`
+	Image    string     `json:"image,omitempty"`
+	Source   string     `json:"source"` // file or service of the event, for the report
+	Color    string     `json:"color,omitempty"`
+	Category string     `json:"category,omitempty"`
+	// Description holds the details of the event in UTF-8,
+	// for the annotations.
+	Description string `json:"description,omitempty"`
+	// Kind is todo, done or journal for the to-dos and journal
+	// entries of ICS files, tentative for their events that are not
+	// confirmed, empty for other events.
+	Kind string `json:"kind,omitempty"`
+	// Priority orders the events of a day, 1 first and 9 last; 0 is
+	// between 4 and 6, like 5.
+	Priority int `json:"priority,omitempty"`
+	// Time is the start of an event of an ICS file like 15:04, empty
+	// for all-day events.
+	Time string `json:"time,omitempty"`
 }
 
-// Gocaldate is an XML type to store single events
-type Gocaldate struct {
+// Levels of the style cascade, a later level overrides.
+const (
+	STYLECALENDAR = iota
+	STYLEMONTH
+	STYLEDAY
+)
+
+// gStyle is a type to store the style of the calendar, a month
+// or the days of Date.
+type gStyle struct {
+	Level int
+	Date  gDate
+	Color string
+	Fill  string
+}
+
+// Gocalstyle is an XML type to store the style of the calendar,
+// a month or a date
+type Gocalstyle struct {
+	Month int    `xml:"month,attr"`
 	Date  string `xml:"date,attr"`
+	Color string `xml:"color,attr"`
+	Fill  string `xml:"fill,attr"`
+	Class string `xml:"class,attr"`
+}
+
+// Gocalrule is an XML type to store a conditional formatting rule
+type Gocalrule struct {
+	If   string `xml:"if,attr"`
+	Then string `xml:"then,attr"`
+}
+
+// Gocalproject is an XML type to store a project, a bar from start to
+// end.
+type Gocalproject struct {
+	Name  string `xml:"name,attr"`
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+	Color string `xml:"color,attr"`
+}
+
+// Gocalimage is an XML type to store the part of an image file
+// that is shown: the crop rectangle and the focal point, whether
+// a photo is enhanced, and its caption and credit line.
+type Gocalimage struct {
+	File    string `xml:"file,attr"`
+	Crop    string `xml:"crop,attr"`
+	Focus   string `xml:"focus,attr"`
+	Enhance string `xml:"enhance,attr"`
+	Caption string `xml:"caption,attr"`
+	Credit  string `xml:"credit,attr"`
+}
+
+// Gocalbrand is an XML type to store the branding of a company: its
+// name, logo, colors, font, footer and the tagline of the cover page.
+type Gocalbrand struct {
+	Name      string `xml:"name,attr"`
+	Logo      string `xml:"logo,attr"`
+	Primary   string `xml:"primary,attr"`
+	Secondary string `xml:"secondary,attr"`
+	Font      string `xml:"font,attr"`
+	Footer    string `xml:"footer,attr"`
+	Tagline   string `xml:"tagline,attr"`
+}
+
+// Gocalclass is an XML type to store a named style that events
+// and styles refer to with their class attribute.
+type Gocalclass struct {
+	Name  string `xml:"name,attr"`
+	Color string `xml:"color,attr"`
+	Fill  string `xml:"fill,attr"`
+}
+
+// gText is a type to store decorative text elements
+type gText struct {
+	Text  string
+	X     float64
+	Y     float64
+	Angle float64
+	Size  float64
+}
+
+// Gocaltext is an XML type to store decorative text elements
+type Gocaltext struct {
+	Text  string  `xml:"text,attr"`
+	X     float64 `xml:"x,attr"`
+	Y     float64 `xml:"y,attr"`
+	Angle float64 `xml:"angle,attr"`
+	Size  float64 `xml:"size,attr"`
+}
+
+// Gocalquote is an XML type to store the quote of a month
+type Gocalquote struct {
+	Month int    `xml:"month,attr"`
 	Text  string `xml:"text,attr"`
-	Image string `xml:"image,attr"`
+}
+
+// Gocaldate is an XML type to store single events
+type Gocaldate struct {
+	Date     string `xml:"date,attr"`
+	Text     string `xml:"text,attr"`
+	Image    string `xml:"image,attr"`
+	Color    string `xml:"color,attr"`
+	Class    string `xml:"class,attr"`
+	Category string `xml:"category,attr"`
+	Desc     string `xml:"description,attr"`
+	Priority int    `xml:"priority,attr"`
 	//	Month   time.Month
 	//	Day     int
 	//	Weekday string
@@ -231,36 +600,193 @@ func (pdf myPdf) lastQuarter(x, y float64) {
 	pdf.Arc(x, y, pdf.moonSize, pdf.moonSize, 0.0, 270.0, 270.0+180.0, "F")
 }
 
+// rotatedText prints text counterclockwise by angle degrees
+// around its starting point.
+func (pdf myPdf) rotatedText(x, y, angle float64, txt string) {
+	pdf.rotatedEffectText(x, y, angle, txt, textEffect{})
+}
+
+// rotatedEffectText is rotatedText with outline and shadow.
+func (pdf myPdf) rotatedEffectText(x, y, angle float64, txt string, e textEffect) {
+	pdf.TransformBegin()
+	pdf.TransformRotate(angle, x, y)
+	pdf.effectText(x, y, txt, e)
+	pdf.TransformEnd()
+}
+
+// textEffect is an outline and a drop shadow of text.
+type textEffect struct {
+	outline      bool
+	outlineColor [3]int
+	outlineWidth float64
+	shadow       bool
+	shadowColor  [3]int
+	shadowOffset float64
+}
+
+// effectText prints text like Text, with the outline and the
+// drop shadow of the effect. It resets the dash pattern.
+func (pdf myPdf) effectText(x, y float64, txt string, e textEffect) {
+	if e.shadow {
+		r, g, b := pdf.GetTextColor()
+		pdf.SetTextColor(e.shadowColor[0], e.shadowColor[1], e.shadowColor[2])
+		pdf.Text(x+e.shadowOffset, y+e.shadowOffset, txt)
+		pdf.SetTextColor(r, g, b)
+	}
+	if !e.outline {
+		pdf.Text(x, y, txt)
+		return
+	}
+	r, g, b := pdf.GetDrawColor()
+	lw := pdf.GetLineWidth()
+	pdf.SetDrawColor(e.outlineColor[0], e.outlineColor[1], e.outlineColor[2])
+	pdf.SetLineWidth(e.outlineWidth)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetTextRenderingMode(2) // fill and stroke
+	pdf.Text(x, y, txt)
+	pdf.SetTextRenderingMode(0)
+	pdf.SetDrawColor(r, g, b)
+	pdf.SetLineWidth(lw)
+}
+
+// parseEffect parses "color,size" of an outline or shadow,
+// e.g. "#ffffff,0.4" or "rgb(0,0,0),0.8".
+func parseEffect(in string) (color [3]int, size float64, err error) {
+	i := strings.LastIndex(in, ",")
+	if i < 0 {
+		return color, 0, fmt.Errorf("invalid effect '%s', expected color,size", in)
+	}
+	size, err = strconv.ParseFloat(strings.TrimSpace(in[i+1:]), 64)
+	if err != nil {
+		return color, 0, fmt.Errorf("invalid size in effect '%s'", in)
+	}
+	color[0], color[1], color[2], err = parseColor(in[:i])
+	return color, size, err
+}
+
+// textEffect returns the configured effect of the titles.
+func (g *Calendar) textEffect() (e textEffect) {
+	var err error
+	if g.OptTextOutline != "" {
+		e.outlineColor, e.outlineWidth, err = parseEffect(g.OptTextOutline)
+		if err != nil {
+			fmt.Printf("# Error in outline: %v\n", err)
+		} else {
+			e.outline = true
+		}
+	}
+	if g.OptTextShadow != "" {
+		e.shadowColor, e.shadowOffset, err = parseEffect(g.OptTextShadow)
+		if err != nil {
+			fmt.Printf("# Error in shadow: %v\n", err)
+		} else {
+			e.shadow = true
+		}
+	}
+	return e
+}
+
 type pdfWriter struct {
+	// g is the calendar, for its exit code and warnings.
+	g           *Calendar
 	pdf         *gofpdf.Fpdf
-	fl          *os.File
+	fl          io.Writer
 	pdfFilename string
+	// keepOpen is set for standard output and the writer of
+	// SetWriter, which the caller closes.
+	keepOpen bool
+	// contrast of the grayscale output, 0 for color. The PDF is
+	// converted in buf before it is written.
+	contrast float64
+	buf      bytes.Buffer
+	// pdfx is the output intent of PDF/X, nil for a plain PDF.
+	pdfx *pdfxIntent
 }
 
 func (pw *pdfWriter) Write(p []byte) (n int, err error) {
 	if pw.pdf.Ok() {
+		if pw.contrast > 0 || pw.pdfx != nil {
+			return pw.buf.Write(p)
+		}
 		return pw.fl.Write(p)
 	}
 	return
 }
 
 func (pw *pdfWriter) Close() (err error) {
-	if pw.fl != nil {
-		pw.fl.Close()
+	if (pw.contrast > 0 || pw.pdfx != nil) && pw.pdf.Ok() {
+		data := pw.buf.Bytes()
+		if pw.contrast > 0 {
+			data = grayPDF(data, pw.contrast)
+		}
+		if pw.pdfx != nil {
+			var err error
+			if data, err = pw.pdfx.apply(data, pw.g.warnf); err != nil {
+				pw.pdf.SetErrorf("# Error writing PDF/X: %v", err)
+			}
+		}
+		if _, err := pw.fl.Write(data); err != nil && pw.pdf.Ok() {
+			pw.pdf.SetError(err)
+		}
+	}
+	if pw.keepOpen {
+		// Don't close stdout or the writer of the caller, and keep
+		// stdout clean for the PDF.
+		if !pw.pdf.Ok() {
+			fmt.Fprintf(os.Stderr, "%s\n", pw.pdf.Error())
+			countError("render")
+			pw.g.exitCode = ExitRender
+		}
+		return
+	}
+	if f, ok := pw.fl.(*os.File); ok && f != nil {
+		f.Close()
 		pw.fl = nil
 	}
 	if pw.pdf.Ok() {
 		fmt.Printf("Generated '%v'.\n", pw.pdfFilename)
 	} else {
 		fmt.Printf("%s\n", pw.pdf.Error())
+		countError("render")
+		pw.g.exitCode = ExitRender
 	}
 	return
 }
 
-func docWriter(pdf *gofpdf.Fpdf, fname string) *pdfWriter {
+// pdfStdout is the standard output at program start. The
+// command line tool redirects os.Stdout to stderr when writing
+// the PDF to stdout, so that messages don't corrupt the PDF.
+var pdfStdout = os.Stdout
+
+// docWriter writes the PDF into the output file of fn, or the writer
+// of SetWriter, in grayscale if the contrast is set, and as PDF/X
+// with the output intent.
+func (g *Calendar) docWriter(pdf *gofpdf.Fpdf, fn string) *pdfWriter {
+	fname := g.outputFilename(fn)
+	if g.part != nil {
+		g.part.pages = pdf.PageCount()
+	} else {
+		g.recordFile(fname, pdf.PageCount())
+	}
 	pw := new(pdfWriter)
+	pw.g = g
 	pw.pdfFilename = fname
 	pw.pdf = pdf
+	pw.pdfx = g.pdfxIntent()
+	if g.OptGrayscale > 0 {
+		pw.contrast = g.OptGrayscale
+		pdf.SetCompression(false)
+	}
+	if g.OptCompanionICS && g.part == nil && g.output == nil && fname != STDIN {
+		g.writeCompanionICS(fname)
+	}
+	if g.output != nil || fname == STDIN {
+		pw.fl, pw.keepOpen = g.output, true
+		if g.output == nil {
+			pw.fl = pdfStdout
+		}
+		return pw
+	}
 	if pdf.Ok() {
 		var err error
 		pw.fl, err = os.Create(pw.pdfFilename)
@@ -282,10 +808,367 @@ func (g *Calendar) SetPlain() {
 	g.OptPlain = true
 }
 
+// SetWritable leaves as much room as possible in the cells for
+// handwriting: small day numbers in the corner, no moon phases and
+// no day of year, and a thin grid. Day number size, position and
+// grid width that are set explicitly are kept.
+func (g *Calendar) SetWritable() {
+	g.OptWritable = true
+}
+
+// applyWritable sets the options of the writable preset.
+func (g *Calendar) applyWritable() {
+	g.SetHideMoon()
+	g.SetHideDOY()
+	if g.OptDayNumberSize == 0 {
+		g.SetDayNumberSize(WRITABLEDAYSIZE)
+	}
+	if g.OptDayNumberPos != "TR" {
+		g.SetDayNumberPos("TL")
+	}
+	if g.OptGridWidth == 0 {
+		g.SetGridWidth(WRITABLEGRIDWIDTH)
+	}
+}
+
 func (g *Calendar) SetHideOtherMonth() {
 	g.OptHideOtherMonths = true
 }
 
+// SetOtherMonths selects how the days of the neighbor months are
+// shown: "gray" (default) with their dates in grey, "blank" as empty
+// cells or "pattern" as hatched cells. See also SetHideOtherMonth.
+func (g *Calendar) SetOtherMonths(f string) {
+	g.OptOtherMonths = f
+}
+
+// SetJulian adds the date of the Julian calendar (old style)
+// to every day.
+func (g *Calendar) SetJulian() {
+	g.OptJulian = true
+}
+
+// SetLiturgical adds the movable and fixed feasts of the
+// liturgical year.
+func (g *Calendar) SetLiturgical() {
+	g.OptLiturgical = true
+}
+
+// SetLiturgicalColor shades the days in the color of the
+// liturgical season.
+func (g *Calendar) SetLiturgicalColor() {
+	g.OptLiturgicalColor = true
+}
+
+// SetLocation sets the location for sun related times as
+// "latitude,longitude" in decimal degrees, north and east positive.
+func (g *Calendar) SetLocation(f string) {
+	g.OptLocation = f
+}
+
+// SetTimezone sets the IANA time zone of the location, e.g.
+// "Asia/Jerusalem". The default is the local time zone.
+func (g *Calendar) SetTimezone(f string) {
+	g.OptTimezone = f
+}
+
+// SetShabbat adds candle-lighting times on Fridays and Havdalah
+// times on Saturdays. Requires a location.
+func (g *Calendar) SetShabbat() {
+	g.OptShabbat = true
+}
+
+// SetCandleLighting sets the minutes of candle lighting before
+// sunset on Fridays, the default is CANDLELIGHTING.
+func (g *Calendar) SetCandleLighting(minutes int) {
+	g.OptCandleLighting = minutes
+}
+
+// SetHavdalah sets the minutes of Havdalah after sunset on
+// Saturdays, the default is HAVDALAH.
+func (g *Calendar) SetHavdalah(minutes int) {
+	g.OptHavdalah = minutes
+}
+
+// SetPrayer adds the Islamic prayer times to every day.
+// Requires a location.
+func (g *Calendar) SetPrayer() {
+	g.OptPrayer = true
+}
+
+// SetPrayerMethod selects the calculation method of the prayer times:
+// MWL (default), ISNA, Egypt, Makkah, Karachi or Tehran.
+func (g *Calendar) SetPrayerMethod(f string) {
+	g.OptPrayerMethod = f
+}
+
+// SetPrayerHanafi uses the Hanafi rule for Asr.
+func (g *Calendar) SetPrayerHanafi() {
+	g.OptPrayerHanafi = true
+}
+
+// SetHistory fills days without events with facts from
+// an "on this day" data file.
+func (g *Calendar) SetHistory(f string) {
+	g.OptHistory = f
+}
+
+// SetHistoryLength limits the length of a fact per day.
+func (g *Calendar) SetHistoryLength(n int) {
+	g.OptHistoryLength = n
+}
+
+// SetContentProvider sets the provider for the content of
+// days without events. It replaces the history file.
+func (g *Calendar) SetContentProvider(p DayContentProvider) {
+	g.ContentProvider = p
+}
+
+// SetFiller adds filler pages between the months: sudoku or
+// a ruling blank, ruled, dots or squared, e.g. "squared:4:0.1".
+func (g *Calendar) SetFiller(f string) {
+	g.OptFiller = f
+}
+
+// SetFillerCount sets the number of filler pages between two months.
+func (g *Calendar) SetFillerCount(n int) {
+	g.OptFillerCount = n
+}
+
+// SetHabits adds a habit tracker page after every month, with
+// one row per comma separated label.
+func (g *Calendar) SetHabits(f string) {
+	g.OptHabits = f
+}
+
+// SetBudget adds a budget table to the tracker page, with
+// one row per comma separated label.
+func (g *Calendar) SetBudget(f string) {
+	g.OptBudget = f
+}
+
+// SetSplitMonths writes one extra PDF per month in addition
+// to the combined document.
+func (g *Calendar) SetSplitMonths() {
+	g.OptSplitMonths = true
+}
+
+// outputFilename expands the placeholders {year}, {month} and
+// {locale} in the output filename. For several months {month}
+// becomes the range, e.g. 01-12.
+func (g *Calendar) outputFilename(fn string) string {
+	month := fmt.Sprintf("%02d", g.WantBeginMonth)
+	if g.WantEndMonth != g.WantBeginMonth {
+		month += fmt.Sprintf("-%02d", g.WantEndMonth)
+	}
+	fn = strings.Replace(fn, "{year}", strconv.Itoa(g.WantYear), -1)
+	fn = strings.Replace(fn, "{month}", month, -1)
+	fn = strings.Replace(fn, "{locale}", g.OptLocale, -1)
+	return g.recipient.Expand(fn)
+}
+
+// monthFilename returns the filename of a single month. Without
+// {month} placeholder the month is added before the extension.
+func monthFilename(fn string, month int) string {
+	if strings.Contains(fn, "{month}") {
+		return strings.Replace(fn, "{month}", fmt.Sprintf("%02d", month), -1)
+	}
+	ext := filepath.Ext(fn)
+	return strings.TrimSuffix(fn, ext) + fmt.Sprintf("-%02d", month) + ext
+}
+
+// SetElementFonts sets font and style per element, e.g.
+// "title=sans:italic,day=serif:regular". The style of a variable
+// font selects its named instance, e.g. "title=Roboto[wght].ttf:semibold".
+func (g *Calendar) SetElementFonts(f string) {
+	g.OptElementFonts = f
+}
+
+// SetTextOutline outlines the titles and texts, e.g. "#ffffff,0.4"
+// for color and line width.
+func (g *Calendar) SetTextOutline(f string) {
+	g.OptTextOutline = f
+}
+
+// SetTextShadow adds a drop shadow to the titles and texts, e.g.
+// "#808080,0.8" for color and offset.
+func (g *Calendar) SetTextShadow(f string) {
+	g.OptTextShadow = f
+}
+
+// SetHeaderFill paints a bar behind the month title, e.g.
+// "gradient:#ffffff:#8080ff". See paintFill for the formats.
+func (g *Calendar) SetHeaderFill(f string) {
+	g.OptHeaderFill = f
+}
+
+// SetFillStyle paints the cells selected by the fill pattern,
+// e.g. the weekend, with a gradient or pattern instead of grey.
+func (g *Calendar) SetFillStyle(f string) {
+	g.OptFillStyle = f
+}
+
+// SetNumerals sets the numeral system of the day numbers: latn
+// (default), arab, arabext, deva or auto for the numerals of the locale.
+func (g *Calendar) SetNumerals(f string) {
+	g.OptNumerals = f
+}
+
+// SetNumeralFont sets a TTF with the digits of the numeral system.
+func (g *Calendar) SetNumeralFont(f string) {
+	g.OptNumeralFont = f
+}
+
+// SetNames reads custom month and weekday names from a file,
+// e.g. for unsupported languages. See readNamesfile.
+func (g *Calendar) SetNames(f string) {
+	g.OptNames = f
+}
+
+// monthNames returns the month names of the language with the
+// custom names applied. With genitive, the form for dates.
+func (g *Calendar) monthNames(lang string, genitive bool) [13]string {
+	names := getLocalizedMonthNames(lang)
+	if genitive {
+		names = getLocalizedMonthNamesGenitive(lang)
+	}
+	if g.OptNames != "" {
+		custom := readNamesfile(g.OptNames)
+		for m := time.January; m <= time.December; m++ {
+			if n, ok := custom[m.String()]; ok {
+				names[m] = n
+			}
+		}
+	}
+	return names
+}
+
+// weekdayNames returns the weekday names of the language with
+// the custom names applied, cut to cutoff characters.
+func (g *Calendar) weekdayNames(lang string, cutoff int) [8]string {
+	names := getLocalizedWeekdayNames(lang, cutoff)
+	if g.OptNames != "" {
+		custom := readNamesfile(g.OptNames)
+		for i := 0; i <= 6; i++ {
+			// index 0 is Saturday, see getLocalizedWeekdayNames
+			if n, ok := custom[time.Weekday((i+6)%7).String()]; ok {
+				if cutoff > 0 && len(n) > cutoff {
+					n = n[0:cutoff]
+				}
+				names[i] = n
+			}
+		}
+	}
+	return names
+}
+
+// SetDedup sets how events that appear in several sources with the
+// same date and summary are handled: keep-first, merge or keep-all.
+func (g *Calendar) SetDedup(strategy string) {
+	g.OptDedup = strategy
+}
+
+// SetLeapDay sets what happens to events on 2/29 in years
+// without a leap day: skip, feb28 or mar1.
+func (g *Calendar) SetLeapDay(policy string) {
+	g.OptLeapDay = policy
+}
+
+// SetAstroEvents adds the equinoxes, solstices and eclipses
+// as events.
+func (g *Calendar) SetAstroEvents() {
+	g.OptAstroEvents = true
+}
+
+// SetAnnotations attaches the descriptions of the events to the
+// day cells as PDF annotations, which viewers show as popups.
+func (g *Calendar) SetAnnotations() {
+	g.OptAnnotations = true
+}
+
+// SetLayers puts the grid, the events, the photos and the astronomy
+// of the monthly calendar on PDF layers.
+func (g *Calendar) SetLayers() {
+	g.OptLayers = true
+}
+
+// SetGrayscale converts the output, images included, to grayscale
+// for black-and-white printing. The contrast spreads the gray levels,
+// 1 keeps the luminance, 0 uses the default GRAYCONTRAST.
+func (g *Calendar) SetGrayscale(contrast float64) {
+	if contrast <= 0 {
+		contrast = GRAYCONTRAST
+	}
+	g.OptGrayscale = contrast
+}
+
+// SetBraille adds the day numbers in Braille to the monthly
+// calendar (experimental).
+func (g *Calendar) SetBraille() {
+	g.OptBraille = true
+}
+
+// SetDayImages sets the maximum number of images in a day cell,
+// the others are left out. 0 allows any number.
+func (g *Calendar) SetDayImages(n int) {
+	g.OptDayImages = n
+}
+
+// SetPhotoFit sets how the photos of the months fill their area:
+// stretch (the default) or fill, which cuts them around their focus.
+func (g *Calendar) SetPhotoFit(f string) {
+	g.OptPhotoFit = f
+}
+
+// SetEnhance improves the levels, the white balance and the
+// sharpness of the photos of the months.
+func (g *Calendar) SetEnhance() {
+	g.OptEnhance = true
+}
+
+// SetCaption sets the caption template of the photos of the months,
+// e.g. "{{.Month}} {{.Year}}".
+func (g *Calendar) SetCaption(c string) {
+	g.OptCaption = c
+}
+
+// SetCaptionPos sets where the captions are in the photos, e.g.
+// bottomright (the default), top or topleft.
+func (g *Calendar) SetCaptionPos(p string) {
+	g.OptCaptionPos = p
+}
+
+// SetCaptionStyle sets the look of the captions: light (the
+// default), dark or band.
+func (g *Calendar) SetCaptionStyle(s string) {
+	g.OptCaptionStyle = s
+}
+
+// image draws the image file, in grayscale if asked. SVG images
+// keep their aspect ratio.
+func (g *Calendar) image(pdf *gofpdf.Fpdf, file string, x, y, w, h float64) {
+	if isSVG(file) {
+		if img := loadSVG(file); img != nil {
+			drawSVG(pdf, img, rect{x, y, w, h}, false)
+		}
+		return
+	}
+	g.drawFramed(pdf, file, rect{x, y, w, h}, false)
+}
+
+// registerImage prepares the image file for pdf.Image: in grayscale
+// if asked, as PNG if the PDF library can't read it. It reports
+// whether the image can be drawn.
+func (g *Calendar) registerImage(pdf *gofpdf.Fpdf, file string) bool {
+	countCache("image", pdf.GetImageInfo(file) != nil)
+	if g.OptGrayscale > 0 {
+		registerGray(pdf, file, g.OptGrayscale)
+	} else if needsConversion(file) {
+		registerConverted(pdf, file)
+	}
+	return !needsConversion(file) || pdf.GetImageInfo(file) != nil
+}
+
 func (g *Calendar) SetHideDOY() {
 	g.OptHideDOY = true
 }
@@ -335,6 +1218,20 @@ func (g *Calendar) SetPhotos(f string) {
 	g.OptPhotos = f
 }
 
+// SetPhotoOrder sets how the photos of the directory of SetPhotos
+// are assigned to the months: name (the default), pattern for file
+// names like 01-lake.jpg, exif for the date the photo was taken, or
+// random.
+func (g *Calendar) SetPhotoOrder(o string) {
+	g.OptPhotoOrder = o
+}
+
+// SetPhotoSeed sets the seed of the random photo order, the same seed
+// gives the same photos.
+func (g *Calendar) SetPhotoSeed(s int64) {
+	g.OptPhotoSeed = s
+}
+
 func (g *Calendar) SetPhoto(f string) {
 	g.OptPhoto = f
 }
@@ -355,6 +1252,52 @@ func (g *Calendar) SetHoliday(v bool) {
 	g.OptHoliday = v
 }
 
+// SetHolidayCountry sets the country of the holidays, its ISO code,
+// e.g. DE.
+func (g *Calendar) SetHolidayCountry(country string) {
+	g.OptHolidayCountry = strings.ToUpper(country)
+}
+
+// SetPageNumbers enables page numbers. The format may contain the
+// placeholders {page} and {pages}, e.g. "Page {page} of {pages}".
+func (g *Calendar) SetPageNumbers(f string) {
+	g.OptPageNumbers = f
+}
+
+// SetPageNumberPos sets the position of the page number as a
+// combination of T/B (top, bottom) and L/C/R (left, center, right).
+func (g *Calendar) SetPageNumberPos(f string) {
+	g.OptPageNumberPos = strings.ToUpper(f)
+}
+
+// SetPageNumberStart sets the number of the first numbered page,
+// e.g. to continue the numbering of another document.
+func (g *Calendar) SetPageNumberStart(n int) {
+	g.OptPageNumberStart = n
+}
+
+// SetPageNumberSkip omits the number on the first (cover) page.
+func (g *Calendar) SetPageNumberSkip() {
+	g.OptPageNumberSkip = true
+}
+
+// SetQuotes sets a text file with one quote per line, one line per month.
+func (g *Calendar) SetQuotes(f string) {
+	g.OptQuotes = f
+}
+
+// SetQuoteFont sets the font of the quotes, see SetFont.
+// By default the calendar font is used.
+func (g *Calendar) SetQuoteFont(f string) {
+	g.OptQuoteFont = f
+}
+
+// SetQuoteWrap wraps long quotes to two lines instead of
+// shrinking them to fit a single line.
+func (g *Calendar) SetQuoteWrap() {
+	g.OptQuoteWrap = true
+}
+
 func (g *Calendar) SetLocale(f string) {
 	g.OptLocale = f
 }
@@ -375,47 +1318,341 @@ func (g *Calendar) SetMargin(f string) {
 	g.OptMargin = f
 }
 
+// SetBackground scatters a motif like stars or snowflakes over the
+// pages, e.g. "seasonal" or "12=stars:#ffe080", see parseOrnaments.
+func (g *Calendar) SetBackground(b string) {
+	g.OptBackground = b
+}
+
+// SetBorder lines a motif up along the edges of the pages, like
+// SetBackground.
+func (g *Calendar) SetBorder(b string) {
+	g.OptBorder = b
+}
+
+// SetSeasonTheme colors the grid, the day numbers and the header of
+// every month by its season, winter blues to autumn browns, unless
+// they are set. The hemisphere is "north", "south" or "auto" by the
+// location. It also selects the seasons of the seasonal motifs.
+func (g *Calendar) SetSeasonTheme(hemisphere string) {
+	g.OptSeasonTheme = hemisphere
+}
+
+// SetBrand reads the Gocalbrand entry of the XML file: the logo is
+// in the headers, the colors are those of the titles and footers, the
+// font is that of the titles and footers, and the monthly calendar
+// starts with a cover page.
+func (g *Calendar) SetBrand(f string) {
+	g.OptBrand = f
+	g.branding = nil
+}
+
+// SetAddressBlock adds a back cover with the address of the
+// recipient where the window of the envelope shows it: "din" (DIN
+// 5008 form B), "dina" (form A), "us10" or "x,y" or "x,y,w,h" in mm.
+func (g *Calendar) SetAddressBlock(f string) {
+	g.OptAddressBlock = f
+}
+
+// SetReturnAddress sets the return address printed small above the
+// address, e.g. "ACME Ltd., 1 Main Street, Springfield".
+func (g *Calendar) SetReturnAddress(f string) {
+	g.OptReturnAddress = f
+}
+
+// SetWallBound prepares a wall calendar bound at the top: the photo
+// of every month is printed upside down on the back of the sheet
+// before, a cover comes first and the hanger hole is marked.
+func (g *Calendar) SetWallBound() {
+	g.OptWallBound = true
+}
+
+// SetPDFX writes PDF/X-4 for print shops, with the output intent of
+// the ICC profile file of the printing condition, e.g. of FOGRA39,
+// or of the built-in profile for "srgb".
+func (g *Calendar) SetPDFX(profile string) {
+	g.OptPDFX = profile
+}
+
+// SetTrace prints a tiny line with the generator, e.g. "Gocal 1.0",
+// a hash of the configuration and the date at the bottom of every
+// page, to trace printed proofs back to their inputs.
+func (g *Calendar) SetTrace(generator string) {
+	g.OptTrace = true
+	g.OptGenerator = generator
+}
+
+// SetWriter writes the PDF to w instead of the output file, e.g. to
+// memory in the browser. The caller closes w.
+func (g *Calendar) SetWriter(w io.Writer) {
+	g.output = w
+}
+
 func (g *Calendar) SetFillpattern(f string) {
 	g.OptFillpattern = f
 }
 
 func (g *Calendar) AddEvent(day int, month int, text string, image string) {
-	gcd := gDate{time.Month(month), int(day), text, "", image}
+	gcd := gDate{time.Month(month), int(day), text, "", image, "api", "", "", "", "", 0, ""}
 	g.EventList = append(g.EventList, gcd)
 }
 
-func (g *Calendar) SetPaperformat(f string) {
-	g.OptPaperformat = f
+// AddText adds a decorative text to every page. The position x/y is
+// in mm from the top left corner; negative values count from the
+// right/bottom edge. The text is rotated counterclockwise by angle
+// degrees. A size of 0 selects the footer font size. The placeholders
+// {year} and {month} are replaced.
+func (g *Calendar) AddText(text string, x, y, angle, size float64) {
+	g.TextList = append(g.TextList, gText{text, x, y, angle, size})
 }
 
-func (g *Calendar) WantFill(i int, j int, wd time.Weekday) bool {
+// SetGridWidth sets the stroke width of the grid in mm.
+func (g *Calendar) SetGridWidth(w float64) {
+	g.OptGridWidth = w
+}
 
-	if wd == time.Monday && g.WantFillMode("1") {
-		return true
+// SetGridDash sets the dash pattern of the grid as a comma-separated
+// list of dash and gap lengths in mm, e.g. "2,1". An invalid pattern
+// keeps the solid grid.
+func (g *Calendar) SetGridDash(f string) {
+	if _, err := parseDashes(f); err != nil {
+		g.warnf("field", "Ignoring grid dash pattern '%s': %v", f, err)
+		return
 	}
+	g.OptGridDash = f
+}
 
-	if wd == time.Tuesday && g.WantFillMode("2") {
-		return true
+// parseDashes parses a comma-separated dash pattern, nil for none.
+func parseDashes(f string) (dashes []float64, err error) {
+	if strings.TrimSpace(f) == "" {
+		return nil, nil
 	}
-
-	if wd == time.Wednesday && g.WantFillMode("3") {
-		return true
+	for _, d := range strings.Split(f, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(d), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid length '%s'", strings.TrimSpace(d))
+		}
+		dashes = append(dashes, v)
 	}
+	return dashes, nil
+}
 
-	if wd == time.Thursday && g.WantFillMode("4") {
-		return true
-	}
+// SetGridColor sets the color of the grid, e.g. "#808080".
+func (g *Calendar) SetGridColor(f string) {
+	g.OptGridColor = f
+}
 
-	if wd == time.Friday && g.WantFillMode("5") {
-		return true
-	}
+// SetGridStyle selects "lines" (default), "none" for cells only
+// separated by whitespace or "rounded" for cells with rounded corners.
+func (g *Calendar) SetGridStyle(f string) {
+	g.OptGridStyle = f
+}
 
-	if wd == time.Saturday && g.WantFillMode("6") {
-		return true
-	}
+// SetDayNumberPos places the day number "TL" (top left, default),
+// "TR" (top right), "C" (centered) or as a "watermark" behind the
+// content of the cell.
+func (g *Calendar) SetDayNumberPos(f string) {
+	g.OptDayNumberPos = f
+}
 
-	if wd == time.Sunday && g.WantFillMode("7") {
-		return true
+// SetDayNumberSize sets the font size of the day number in points.
+// 0 selects the default size.
+func (g *Calendar) SetDayNumberSize(f float64) {
+	g.OptDayNumberSize = f
+}
+
+// SetDayNumberColor sets the color of the day numbers of the month
+// on workdays, e.g. "#204080".
+func (g *Calendar) SetDayNumberColor(f string) {
+	g.OptDayNumberColor = f
+}
+
+// SetHighlightToday marks the current date with an "outline" or "fill".
+func (g *Calendar) SetHighlightToday(f string) {
+	switch f {
+	case "", "outline", "fill":
+		g.OptHighlightToday = f
+	default:
+		g.warnf("field", "Unknown highlight '%s' of today, use outline or fill", f)
+	}
+}
+
+// SetTodayColor sets the color used to highlight the current date.
+func (g *Calendar) SetTodayColor(f string) {
+	g.OptTodayColor = f
+}
+
+// hatchCell fills a cell with diagonal lines.
+func (g *Calendar) hatchCell(pdf *gofpdf.Fpdf, x, y, w, h float64) {
+	dr, dg, db := pdf.GetDrawColor()
+	lw := pdf.GetLineWidth()
+	pdf.SetDrawColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
+	pdf.SetLineWidth(0.2)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.ClipRect(x, y, w, h, false)
+	for d := 0.0; d < w+h; d += 3.0 {
+		pdf.Line(x+d, y, x+d-h, y+h)
+	}
+	pdf.ClipEnd()
+	pdf.SetDrawColor(dr, dg, db)
+	pdf.SetLineWidth(lw)
+	g.setGridStyle(pdf)
+}
+
+// shadeLiturgical paints the cell in a light tint of the
+// color of the liturgical season.
+func (g *Calendar) shadeLiturgical(pdf *gofpdf.Fpdf, d time.Time, x, y, w, h float64) {
+	if !g.OptLiturgicalColor {
+		return
+	}
+	r, gr, b := liturgicalColor(d)
+	fr, fg, fb := pdf.GetFillColor()
+	pdf.SetFillColor(255-(255-r)/4, 255-(255-gr)/4, 255-(255-b)/4)
+	pdf.Rect(x, y, w, h, "F")
+	pdf.SetFillColor(fr, fg, fb)
+}
+
+// isToday reports whether t is the current date.
+func isToday(t time.Time) bool {
+	y, m, d := time.Now().Date()
+	return t.Year() == y && t.Month() == m && t.Day() == d
+}
+
+// highlightToday marks the cell of the current date. The fill has to
+// be painted before the content of the cell, the outline afterwards.
+func (g *Calendar) highlightToday(pdf *gofpdf.Fpdf, x, y, w, h float64, before bool) {
+	r, gr, b := 255, 230, 120 // light yellow
+	if g.OptHighlightToday == "outline" {
+		r, gr, b = 220, 0, 0
+	}
+	if g.OptTodayColor != "" {
+		var err error
+		r, gr, b, err = parseColor(g.OptTodayColor)
+		if err != nil {
+			g.warnf("field", "Error in today color: %v", err)
+			return
+		}
+	}
+	if g.OptHighlightToday == "fill" && before {
+		fr, fg, fb := pdf.GetFillColor()
+		pdf.SetFillColor(r, gr, b)
+		pdf.Rect(x, y, w, h, "F")
+		pdf.SetFillColor(fr, fg, fb)
+	} else if g.OptHighlightToday == "outline" && !before {
+		lw := pdf.GetLineWidth()
+		dr, dg, db := pdf.GetDrawColor()
+		pdf.SetDrawColor(r, gr, b)
+		pdf.SetLineWidth(0.8)
+		pdf.SetDashPattern([]float64{}, 0)
+		pdf.Rect(x, y, w, h, "D")
+		pdf.SetLineWidth(lw)
+		pdf.SetDrawColor(dr, dg, db)
+		g.setGridStyle(pdf)
+	}
+}
+
+// setDayNumberColor sets the text color for day numbers, if any.
+func (g *Calendar) setDayNumberColor(pdf *gofpdf.Fpdf) {
+	if g.OptDayNumberColor == "" {
+		return
+	}
+	r, gr, b, err := parseColor(g.OptDayNumberColor)
+	if err != nil {
+		fmt.Printf("# Error in day number color: %v\n", err)
+		return
+	}
+	pdf.SetTextColor(r, gr, b)
+}
+
+// setTextColor sets the text color of a style, if it is valid.
+func setTextColor(pdf *gofpdf.Fpdf, color string) {
+	r, g, b, err := parseColor(color)
+	if err != nil {
+		fmt.Printf("# Error in style color: %v\n", err)
+		return
+	}
+	pdf.SetTextColor(r, g, b)
+}
+
+// annotateDay attaches the descriptions of the events of the day
+// to its cell. The annotation isn't printed.
+func annotateDay(pdf *gofpdf.Fpdf, day time.Time, events []gDate, x, y, w, h float64) {
+	var parts []string
+	for _, ev := range events {
+		if ev.Description != "" {
+			parts = append(parts, convertFromCP(ev.Text)+"\n"+ev.Description)
+		}
+	}
+	if len(parts) == 0 {
+		return
+	}
+	text := strings.Join(parts, "\n\n")
+	pdf.AddAttachmentAnnotation(&gofpdf.Attachment{
+		Content:     []byte(text),
+		Filename:    day.Format("2006-01-02") + ".txt",
+		Description: text,
+	}, x, y, w, h)
+}
+
+// gridBorder returns the border string for CellFormat.
+func (g *Calendar) gridBorder() string {
+	if g.OptGridStyle == "none" {
+		return ""
+	}
+	return "1"
+}
+
+// setGridStyle applies width, color and dash pattern of the grid.
+func (g *Calendar) setGridStyle(pdf *gofpdf.Fpdf) {
+	if g.OptGridWidth > 0 {
+		pdf.SetLineWidth(g.OptGridWidth)
+	}
+	pdf.SetDrawColor(BLACK, BLACK, BLACK)
+	if g.OptGridColor != "" {
+		r, gr, b, err := parseColor(g.OptGridColor)
+		if err != nil {
+			g.warnf("field", "Error in grid color: %v", err)
+		} else {
+			pdf.SetDrawColor(r, gr, b)
+		}
+	}
+	// SetGridDash checked the pattern.
+	dashes, _ := parseDashes(g.OptGridDash)
+	pdf.SetDashPattern(dashes, 0)
+}
+
+func (g *Calendar) SetPaperformat(f string) {
+	g.OptPaperformat = f
+}
+
+func (g *Calendar) WantFill(i int, j int, wd time.Weekday) bool {
+
+	if wd == time.Monday && g.WantFillMode("1") {
+		return true
+	}
+
+	if wd == time.Tuesday && g.WantFillMode("2") {
+		return true
+	}
+
+	if wd == time.Wednesday && g.WantFillMode("3") {
+		return true
+	}
+
+	if wd == time.Thursday && g.WantFillMode("4") {
+		return true
+	}
+
+	if wd == time.Friday && g.WantFillMode("5") {
+		return true
+	}
+
+	if wd == time.Saturday && g.WantFillMode("6") {
+		return true
+	}
+
+	if wd == time.Sunday && g.WantFillMode("7") {
+		return true
 	}
 
 	if wd == time.Sunday && g.WantFillMode("S") {
@@ -455,11 +1692,14 @@ func (g *Calendar) WantFill(i int, j int, wd time.Weekday) bool {
 
 func getLanguage(inLanguage string) (outLanguage string) {
 	// First try Environment
-	outLanguage = os.Getenv("LANG")
+	outLanguage = normalizeLanguage(os.Getenv("LANG"))
 
 	// If set on the cmdline, override
 	if inLanguage != "" {
-		outLanguage = inLanguage
+		outLanguage = normalizeLanguage(inLanguage)
+		if !IsSupportedLanguage(outLanguage) {
+			fmt.Printf("# Unsupported language '%s', using en_US. Supported are: %s\n", inLanguage, strings.Join(SupportedLanguages(), " "))
+		}
 	}
 
 	// if we don't know that language, fall back to en.
@@ -469,17 +1709,155 @@ func getLanguage(inLanguage string) (outLanguage string) {
 	return
 }
 
+// normalizeLanguage turns e.g. "de_DE.UTF-8" or "de-DE" into "de_DE".
+func normalizeLanguage(in string) string {
+	if i := strings.IndexAny(in, ".@"); i >= 0 {
+		in = in[:i]
+	}
+	return strings.Replace(in, "-", "_", 1)
+}
+
+// IsSupportedLanguage reports whether month and weekday names
+// are available for the language, e.g. "de_DE".
+func IsSupportedLanguage(lang string) bool {
+	return testedLanguage[normalizeLanguage(lang)]
+}
+
+// SupportedLanguages returns the supported languages, sorted.
+func SupportedLanguages() (langs []string) {
+	for l, ok := range testedLanguage {
+		if ok {
+			langs = append(langs, l)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
 func (g *Calendar) AddWallpaper(pdf *gofpdf.Fpdf, fontTempdir string, PAGEWIDTH float64, PAGEHEIGHT float64) {
 	wallpaperFilename := g.OptWallpaper
 	if strings.HasPrefix(wallpaperFilename, "http://") {
-		wallpaperFilename = downloadFile(g.OptWallpaper, fontTempdir)
+		wallpaperFilename = g.downloadFile(g.OptWallpaper, fontTempdir)
+	}
+	g.image(pdf, wallpaperFilename, 0, 0, PAGEWIDTH, PAGEHEIGHT)
+}
+
+// getTexts collects the decorative texts from the library user
+// and the configuration files.
+func (g *Calendar) getTexts() (textList []gText) {
+	textList = append(textList, g.TextList...)
+	for _, cfg := range append([]string{g.OptConfig}, g.OptConfigs...) {
+		if cfg != "" {
+			textList = append(textList, g.readConfigurationTexts(cfg)...)
+		}
+	}
+	return textList
+}
+
+// addTexts prints the decorative texts on the current page.
+func (g *Calendar) addTexts(pdf *gofpdf.Fpdf, calFont string, fontScale float64, PAGEWIDTH float64, PAGEHEIGHT float64, textList []gText, monthName string) {
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+	effect := g.textEffect()
+	for _, t := range textList {
+		size := t.Size
+		if size == 0 {
+			size = FOOTERFONTSIZE
+		}
+		pdf.SetFont(calFont, "", size*fontScale)
+		x, y := t.X, t.Y
+		if x < 0 {
+			x += PAGEWIDTH
+		}
+		if y < 0 {
+			y += PAGEHEIGHT
+		}
+		s := strings.Replace(t.Text, "{year}", strconv.Itoa(g.WantYear), -1)
+		s = strings.Replace(s, "{month}", monthName, -1)
+		s = g.recipient.Expand(s)
+		myPdf{pdf, 0}.rotatedEffectText(x, y, t.Angle, s, effect)
+	}
+	g.setGridStyle(pdf)
+}
+
+// addQuote prints the quote of the month below the title. Unless
+// wrapping is wanted, the font shrinks until the quote fits one line.
+// The quote always occupies two lines, so that the grid doesn't move.
+func (g *Calendar) addQuote(pdf *gofpdf.Fpdf, quoteFont string, fontScale float64, quote string, width float64, lineHeight float64) {
+	x, y := pdf.GetXY()
+	pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+	fontSize := QUOTEFONTSIZE * fontScale
+	pdf.SetFont(quoteFont, "", fontSize)
+	if g.OptQuoteWrap {
+		// The text is in the font's codepage, hence split bytes not runes.
+		lines := pdf.SplitLines([]byte(quote), width)
+		if len(lines) > 2 {
+			lines = lines[:2]
+		}
+		for i, l := range lines {
+			pdf.SetXY(x, y+float64(i)*lineHeight)
+			pdf.CellFormat(width, lineHeight, string(l), "", 0, "C", false, 0, "")
+		}
+	} else {
+		for fontSize > 4.0 && pdf.GetStringWidth(quote) > width {
+			fontSize -= 0.5
+			pdf.SetFont(quoteFont, "", fontSize)
+		}
+		pdf.SetXY(x, y+0.5*lineHeight)
+		pdf.CellFormat(width, lineHeight, quote, "", 0, "C", false, 0, "")
+	}
+	pdf.SetXY(x, y+2*lineHeight)
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+}
+
+// addPageNumbers revisits every page of the finished document and
+// prints the page number. This has to happen after all pages were
+// added, otherwise the total is unknown.
+func (g *Calendar) addPageNumbers(pdf *gofpdf.Fpdf, calFont string, fontScale float64, PAGEWIDTH float64, PAGEHEIGHT float64) {
+	if g.OptPageNumbers == "" {
+		return
+	}
+	first := 1
+	if g.OptPageNumberSkip {
+		first = 2
+	}
+	// The pages of a streamed calendar continue those of the parts
+	// before.
+	offset, pages := 0, pdf.PageCount()
+	if g.part != nil {
+		offset, pages = g.part.offset, g.part.total
+	}
+	total := pages - first + g.OptPageNumberStart
+	for p := 1; p <= pdf.PageCount(); p++ {
+		if offset+p < first {
+			continue
+		}
+		// The font goes into the content of the page, so it is set
+		// on every page.
+		pdf.SetPage(p)
+		pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+		pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale*0.8)
+		s := strings.Replace(g.OptPageNumbers, "{page}", strconv.Itoa(offset+p-first+g.OptPageNumberStart), -1)
+		s = strings.Replace(s, "{pages}", strconv.Itoa(total), -1)
+		w := pdf.GetStringWidth(s)
+
+		x := PAGEWIDTH - MARGIN - w // right is default
+		if strings.Contains(g.OptPageNumberPos, "L") {
+			x = MARGIN
+		} else if strings.Contains(g.OptPageNumberPos, "C") {
+			x = 0.50*PAGEWIDTH - w*0.5
+		}
+		y := 0.95 * PAGEHEIGHT
+		if strings.Contains(g.OptPageNumberPos, "T") {
+			y = 0.5 * MARGIN
+		}
+		pdf.Text(x, y, s)
 	}
-	pdf.Image(wallpaperFilename, 0, 0, PAGEWIDTH, PAGEHEIGHT, false, "", 0, "")
 }
 
 func (g *Calendar) CreateYearCalendarInverse(fn string) {
 
 	var fontTempdir string
+	trace := g.traceLine()
 	var fontScale = g.OptFontScale
 	var calFont = g.OptFont
 
@@ -489,14 +1867,17 @@ func (g *Calendar) CreateYearCalendarInverse(fn string) {
 
 	wantyear := g.WantYear
 
-	calFont, fontTempdir = processFont(calFont)
+	calFont, fontTempdir = g.processFont(calFont)
+	defer removeTempdir(fontTempdir)
 
-	pdf := gofpdf.New(g.OptOrientation, "mm", g.OptPaperformat, fontTempdir)
+	pdf := g.newPDF(fontTempdir)
 	pdf.AddFont(calFont, "", calFont+".json")
 
 	pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
 	pdf.SetMargins(10.0, 5.0, 10.0)
 	pdf.SetTitle("Created with Gocal", true)
+	g.setGridStyle(pdf)
+	border := g.gridBorder()
 
 	PAGEWIDTH, PAGEHEIGHT, _ := pdf.PageSize(0)
 	if g.OptOrientation != "P" {
@@ -506,19 +1887,21 @@ func (g *Calendar) CreateYearCalendarInverse(fn string) {
 	cw := (PAGEWIDTH - 2*MARGIN) / 12.5
 	ch := (PAGEHEIGHT - 2*MARGIN) / 32
 	currentLanguage := getLanguage(g.OptLocale)
-	localizedWeekdayNames := getLocalizedWeekdayNames(currentLanguage, 2)
-	localizedMonthNames := getLocalizedMonthNames(currentLanguage)
+	localizedWeekdayNames := g.weekdayNames(currentLanguage, 2)
+	localizedMonthNames := g.monthNames(currentLanguage, false)
 
 	monthFracture := g.OptYearSpread
 	cw = cw * float64(monthFracture)
 	monthOnePage := 12 / monthFracture
 
+	textList := g.getTexts()
 	for pageCount := 0; pageCount < monthFracture; pageCount++ {
 		pdf.AddPage()
 
-		pdf.SetTextColor(BLACK, BLACK, BLACK)
+		g.setTitleColor(pdf)
 		pdf.SetFont(calFont, "", HEADERFONTSIZE*fontScale)
 		pdf.CellFormat(PAGEWIDTH-MARGIN, MARGIN, fmt.Sprintf("%d", wantyear), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(BLACK, BLACK, BLACK)
 
 		if g.OptWallpaper != "" {
 			g.AddWallpaper(pdf, fontTempdir, PAGEWIDTH, PAGEHEIGHT)
@@ -527,19 +1910,19 @@ func (g *Calendar) CreateYearCalendarInverse(fn string) {
 		pdf.Ln(-1)
 
 		pdf.SetTextColor(BLACK, BLACK, BLACK)
-		pdf.CellFormat(cw*0.5/float64(monthFracture), ch*0.75, "", "1", 0, "C", false, 0, "")
+		pdf.CellFormat(cw*0.5/float64(monthFracture), ch*0.75, "", border, 0, "C", false, 0, "")
 
 		pdf.SetTextColor(BLACK, BLACK, BLACK)
 		pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale*0.8)
 		pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
 		for mo := pageCount*monthOnePage + 1; mo <= pageCount*monthOnePage+monthOnePage; mo++ {
-			pdf.CellFormat(cw, ch*0.75, fmt.Sprintf("%s", localizedMonthNames[mo]), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(cw, ch*0.75, fmt.Sprintf("%s", localizedMonthNames[mo]), border, 0, "C", false, 0, "")
 		}
 		pdf.Ln(-1)
 		pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.25)
 		for i := 1; i <= 31; i++ {
 			pdf.SetTextColor(BLACK, BLACK, BLACK)
-			pdf.CellFormat(cw*0.5/float64(monthFracture), ch*0.9, fmt.Sprintf("%d", i), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(cw*0.5/float64(monthFracture), ch*0.9, fmt.Sprintf("%d", i), border, 0, "C", false, 0, "")
 			for j := pageCount*monthOnePage + 1; j <= pageCount*monthOnePage+monthOnePage; j++ {
 				tDay := time.Date(wantyear, time.Month(j), i, 0, 0, 0, 0, time.UTC)
 				wd := localizedWeekdayNames[(tDay.Weekday()+1)%7]
@@ -552,55 +1935,63 @@ func (g *Calendar) CreateYearCalendarInverse(fn string) {
 
 				_, readbackMonth, _ := tDay.Date()
 				if int(readbackMonth) == int(j) {
+					highlight := g.OptHighlightToday != "" && isToday(tDay)
+					if highlight {
+						x, y := pdf.GetXY()
+						g.highlightToday(pdf, x, y, cw, ch*0.9, true)
+					}
 
 					// Day of year, lower right
 					if g.OptHideDOY == false && int(tDay.Month()) == j {
-						doy := julian.DayOfYearGregorian(wantyear, int(time.Month(j)), int(tDay.Day()))
 						pdf.SetFont(calFont, "", DOYFONTSIZE*fontScale*0.5)
-						pdf.CellFormat(cw, ch*0.9, fmt.Sprintf("%d", doy), "1", 0, "BR", false, 0, "")
+						pdf.CellFormat(cw, ch*0.9, g.dayNumber(tDay), border, 0, "BR", false, 0, "")
 						pdf.SetX(pdf.GetX() - cw) // reset
 					}
 					// Add week number, lower left
 					if tDay.Weekday() == time.Monday && g.OptHideWeek == false {
 						pdf.SetFont(calFont, "", WEEKFONTSIZE*0.5*fontScale)
 						_, weeknr := tDay.ISOWeek()
-						pdf.CellFormat(cw, ch*0.9, fmt.Sprintf("W %d", weeknr), "1", 0, "BL", false, 0, "")
+						pdf.CellFormat(cw, ch*0.9, fmt.Sprintf("W %d", weeknr), border, 0, "BL", false, 0, "")
 						pdf.SetX(pdf.GetX() - cw) // reset
 					}
 
-					fillBox := g.WantFill(i, j, tDay.Weekday())
+					fillBox := g.WantFill(i, j, tDay.Weekday()) && !(highlight && g.OptHighlightToday == "fill")
 
 					pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.25)
-					pdf.CellFormat(cw, ch*0.9, fmt.Sprintf("%s", wd), "1", 0, "TL", fillBox, 0, "")
+					pdf.CellFormat(cw, ch*0.9, fmt.Sprintf("%s", wd), border, 0, "TL", fillBox, 0, "")
+					if highlight {
+						x, y := pdf.GetXY()
+						g.highlightToday(pdf, x-cw, y, cw, ch*0.9, false)
+					}
 				} else {
 					// empty cell to skip ahead
-					pdf.CellFormat(cw, ch*0.9, "", "1", 0, "TL", false, 0, "")
+					pdf.CellFormat(cw, ch*0.9, "", border, 0, "TL", false, 0, "")
 				}
 			}
 			pdf.Ln(-1)
 		}
 
 		pdf.Ln(-1)
-		pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+		g.setFooterColor(pdf)
 		pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale)
-		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.OptFooter)*0.5, 0.95*PAGEHEIGHT, fmt.Sprintf("%s", g.OptFooter))
+		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.footer())*0.5, 0.95*PAGEHEIGHT, g.footer())
+
 
+		// TODO Hardcoded A4 portrait
+		myPdf{pdf, 0}.rotatedText(210.0*0.96, 297.0*0.05, 270, g.OptMargin)
 
-		pdf.TransformBegin()// TODO Hardcoded A4 portrait
-		ctrX := 210.0 * 0.96
-		ctrY := 297.0 * 0.05
-		pdf.TransformRotate(270, ctrX, ctrY)
-		pdf.Text(ctrX, ctrY, fmt.Sprintf("%s", g.OptMargin))
-		pdf.TransformEnd()
+		g.addTexts(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, textList, "")
 	}
 
-	pdf.OutputAndClose(docWriter(pdf, fn))
-	removeTempdir(fontTempdir)
+	g.addPageNumbers(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT)
+	g.addTraceLine(pdf, calFont, trace, PAGEHEIGHT)
+	pdf.OutputAndClose(g.docWriter(pdf, fn))
 }
 
 func (g *Calendar) CreateYearCalendar(fn string) {
 
 	var fontTempdir string
+	trace := g.traceLine()
 	var fontScale = g.OptFontScale
 	var calFont = g.OptFont
 
@@ -610,14 +2001,17 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 
 	wantyear := g.WantYear
 
-	calFont, fontTempdir = processFont(calFont)
+	calFont, fontTempdir = g.processFont(calFont)
+	defer removeTempdir(fontTempdir)
 
-	pdf := gofpdf.New(g.OptOrientation, "mm", g.OptPaperformat, fontTempdir)
+	pdf := g.newPDF(fontTempdir)
 	pdf.AddFont(calFont, "", calFont+".json")
 
 	pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
 	pdf.SetMargins(10.0, 5.0, 10.0)
 	pdf.SetTitle("Created with Gocal", true)
+	g.setGridStyle(pdf)
+	border := g.gridBorder()
 
 	PAGEWIDTH, PAGEHEIGHT, _ := pdf.PageSize(0)
 	if g.OptOrientation != "P" {
@@ -625,8 +2019,8 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 	}
 
 	currentLanguage := getLanguage(g.OptLocale)
-	localizedWeekdayNames := getLocalizedWeekdayNames(currentLanguage, 2)
-	localizedMonthNames := getLocalizedMonthNames(currentLanguage)
+	localizedWeekdayNames := g.weekdayNames(currentLanguage, 2)
+	localizedMonthNames := g.monthNames(currentLanguage, false)
 
 	monthFracture := g.OptYearSpread
 	monthOnePage := 12 / monthFracture
@@ -634,6 +2028,7 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 	cw := (PAGEWIDTH - 2*MARGIN) / 32
 	ch := (PAGEHEIGHT - 2*MARGIN) / 14
 	ch = ch * float64(monthFracture)
+	textList := g.getTexts()
 	for pageCount := 0; pageCount < monthFracture; pageCount++ {
 		pdf.AddPage()
 		pdf.SetTextColor(BLACK, BLACK, BLACK)
@@ -643,14 +2038,16 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 		}
 
 		pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale)
+		g.setTitleColor(pdf)
 		pdf.CellFormat(PAGEWIDTH-MARGIN, MARGIN, fmt.Sprintf("%d", wantyear), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(BLACK, BLACK, BLACK)
 		pdf.Ln(-1)
 
 		pdf.SetTextColor(BLACK, BLACK, BLACK)
 		monthTable := func(mymonth int, myyear int) {
 			var day int64 = 1
 
-			pdf.CellFormat(cw, ch, "", "1", 0, "C", false, 0, "")
+			pdf.CellFormat(cw, ch, "", border, 0, "C", false, 0, "")
 			for j := 1; j < 32; j++ {
 				pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.25)
 
@@ -665,26 +2062,34 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 				// the month that arrived.
 				_, readbackMonth, _ := tDay.Date()
 				if int(readbackMonth) == mymonth {
+					highlight := g.OptHighlightToday != "" && isToday(tDay)
+					if highlight {
+						x, y := pdf.GetXY()
+						g.highlightToday(pdf, x, y, cw, ch, true)
+					}
 
 					// Day of year, lower right
 					if g.OptHideDOY == false && int(tDay.Month()) == mymonth && tDay.Weekday() != time.Monday {
-						doy := julian.DayOfYearGregorian(wantyear, int(mymonth), int(tDay.Day()))
 						pdf.SetFont(calFont, "", DOYFONTSIZE*fontScale*0.5)
-						pdf.CellFormat(cw, ch, fmt.Sprintf("%d", doy), "1", 0, "BR", false, 0, "")
+						pdf.CellFormat(cw, ch, g.dayNumber(tDay), border, 0, "BR", false, 0, "")
 						pdf.SetX(pdf.GetX() - cw) // reset
 					}
 					// Add week number, lower left
 					if tDay.Weekday() == time.Monday && g.OptHideWeek == false {
 						pdf.SetFont(calFont, "", WEEKFONTSIZE*0.5*fontScale)
 						_, weeknr := tDay.ISOWeek()
-						pdf.CellFormat(cw, ch, fmt.Sprintf("W %d", weeknr), "1", 0, "BL", false, 0, "")
+						pdf.CellFormat(cw, ch, fmt.Sprintf("W %d", weeknr), border, 0, "BL", false, 0, "")
 						pdf.SetX(pdf.GetX() - cw) // reset
 					}
 
-					fillBox := g.WantFill(mymonth, j, tDay.Weekday())
+					fillBox := g.WantFill(mymonth, j, tDay.Weekday()) && !(highlight && g.OptHighlightToday == "fill")
 
 					pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.25)
-					pdf.CellFormat(cw, ch, fmt.Sprintf("%s", localizedWeekdayNames[(tDay.Weekday()+1)%7]), "1", 0, "TL", fillBox, 0, "")
+					pdf.CellFormat(cw, ch, fmt.Sprintf("%s", localizedWeekdayNames[(tDay.Weekday()+1)%7]), border, 0, "TL", fillBox, 0, "")
+					if highlight {
+						x, y := pdf.GetXY()
+						g.highlightToday(pdf, x-cw, y, cw, ch, false)
+					}
 					day++
 				}
 			}
@@ -694,12 +2099,12 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 
 		// The header cells shall not scale with the monthFracture. Undo it.
 		var ch_header = ch / float64(monthFracture) * 0.3
-		pdf.CellFormat(cw, ch_header, "", "1", 0, "C", false, 0, "")
+		pdf.CellFormat(cw, ch_header, "", border, 0, "C", false, 0, "")
 
 		// top row: 1..31
 		for j := 0; j < 31; j++ {
 			pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.25)
-			pdf.CellFormat(cw, ch_header, fmt.Sprintf("%d", day), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(cw, ch_header, fmt.Sprintf("%d", day), border, 0, "C", false, 0, "")
 			day++
 		}
 		pdf.Ln(-1)
@@ -708,37 +2113,33 @@ func (g *Calendar) CreateYearCalendar(fn string) {
 		for mo := pageCount*monthOnePage + 1; mo <= pageCount*monthOnePage+monthOnePage; mo++ {
 			pdf.SetTextColor(BLACK, BLACK, BLACK)
 			pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale*0.8)
-			pdf.TransformBegin()
 			x, y := pdf.GetXY()
-			pdf.TransformRotate(90, x+cw-CELLMARGIN, y+ch-CELLMARGIN)
-			pdf.Text(x+cw-CELLMARGIN, y+ch-CELLMARGIN*2, localizedMonthNames[mo])
-			pdf.TransformEnd()
+			myPdf{pdf, 0}.rotatedText(x+cw-CELLMARGIN, y+ch-CELLMARGIN*2, 90, localizedMonthNames[mo])
 			monthTable(mo, wantyear)
 			pdf.Ln(-1)
 		}
 		pdf.Ln(-1)
-		pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+		g.setFooterColor(pdf)
 		pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale)
-		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.OptFooter)*0.5, 0.95*PAGEHEIGHT, fmt.Sprintf("%s", g.OptFooter))
+		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.footer())*0.5, 0.95*PAGEHEIGHT, g.footer())
 
-		pdf.TransformBegin()// TODO Hardcoded A4 portrait
-		ctrX := 210.0 * 0.96
-		ctrY := 297.0 * 0.05
-		pdf.TransformRotate(270, ctrX, ctrY)
-		pdf.Text(ctrX, ctrY, fmt.Sprintf("%s", g.OptMargin))
-		pdf.TransformEnd()
+		// TODO Hardcoded A4 portrait
+		myPdf{pdf, 0}.rotatedText(210.0*0.96, 297.0*0.05, 270, g.OptMargin)
+
+		g.addTexts(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, textList, "")
 	}
 
-	pdf.OutputAndClose(docWriter(pdf, fn))
-	removeTempdir(fontTempdir)
+	g.addPageNumbers(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT)
+	g.addTraceLine(pdf, calFont, trace, PAGEHEIGHT)
+	pdf.OutputAndClose(g.docWriter(pdf, fn))
 }
 
-func getPhotolist(in string, temp string) (out [12]string) {
+func (g *Calendar) getPhotolist(in string, temp string) (out [12]string) {
 	if in != "" {
 		for i := 0; i < 12; i++ {
 			photoname := in
 			if strings.HasPrefix(photoname, "http://") {
-				photoname = downloadFile(photoname, temp)
+				photoname = g.downloadFile(photoname, temp)
 			}
 			out[i] = photoname
 		}
@@ -746,59 +2147,73 @@ func getPhotolist(in string, temp string) (out [12]string) {
 	return out
 }
 
-func getPhotoslist(in string) (out [12]string) {
+// getPhotoslist assigns the photos of the directory to the months
+// in the order, see assignPhotos.
+func getPhotoslist(in string, order string, seed int64) (out [12]string) {
 	if in != "" {
-		fileList, err := filepath.Glob(in + string(os.PathSeparator) + "*")
-		if err == nil {
-			for i := 0; i < 12; i++ {
-				out[i] = fileList[i%len(fileList)]
-			}
-		} else {
+		fileList, err := filepath.Glob(filepath.Join(in, "*"))
+		if err != nil {
 			fmt.Printf("# There is an error in your path to photos: %v\n", err)
+			return out
+		}
+		if len(fileList) == 0 {
+			fmt.Printf("# There are no photos in %v\n", in)
+			return out
+		}
+		if order != "" && !isPhotoOrder(order) {
+			fmt.Printf("# Unknown photo order '%s', use one of %v\n", order, photoOrders)
+		}
+		month := filenameMonth
+		if order == "exif" {
+			month = exifMonth
 		}
+		out = assignPhotos(fileList, order, seed, month)
 	}
 	return out
 }
 
-func fetchHolidayEvents(url string, country string, subDiv string, lang string, onlyNationWide bool, year int) (eL []gDate) {
-
+// holidayCache keeps the downloaded holidays, so that several
+// calendars in one run download them only once. holidayFlight makes
+// the calendars that want the same holidays at the same time wait for
+// one download.
+var holidayCache = make(map[string][]gDate)
+var holidayMutex sync.Mutex
+var holidayFlight flightGroup
+
+// fetchHolidayEvents returns the holidays of the year, the errors go
+// to warn.
+func fetchHolidayEvents(url string, country string, subDiv string, lang string, onlyNationWide bool, year int, warn func(kind string, format string, v ...interface{})) []gDate {
 	yearString := strconv.Itoa(year)
 	fullurl := fmt.Sprintf(url, country, subDiv, lang, yearString, yearString)
 
-	fmt.Printf("%v\n", fullurl)
-
-	spaceClient := http.Client{
-		Timeout: time.Second * 2, // Timeout after 2 seconds
-	}
-
-	req, err := http.NewRequest(http.MethodGet, fullurl, nil)
-	if err != nil {
-		log.Println(err)
-		return nil
-	}
-
-	req.Header.Set("accept", "text/json")
-
-	res, getErr := spaceClient.Do(req)
-	if getErr != nil {
-		log.Println(getErr)
-		return nil
+	cacheKey := fmt.Sprintf("%s %v", fullurl, onlyNationWide)
+	holidayMutex.Lock()
+	cached, ok := holidayCache[cacheKey]
+	holidayMutex.Unlock()
+	if !ok {
+		v, _ := holidayFlight.do(cacheKey, func() (interface{}, error) {
+			return downloadHolidayEvents(fullurl, cacheKey, onlyNationWide, warn), nil
+		})
+		cached, _ = v.([]gDate)
 	}
+	return append([]gDate(nil), cached...)
+}
 
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
+// downloadHolidayEvents downloads the holidays and keeps them in the
+// cache under the key.
+func downloadHolidayEvents(fullurl string, cacheKey string, onlyNationWide bool, warn func(kind string, format string, v ...interface{})) (eL []gDate) {
+	fmt.Printf("%v\n", fullurl)
 
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		log.Println(readErr)
+	body, fetchErr := fetchURL(fullurl, "text/json")
+	if fetchErr != nil {
+		warn("download", "Error downloading holidays: %v", fetchErr)
 		return nil
 	}
 
 	people1 := people{}
 	jsonErr := json.Unmarshal(body, &people1)
 	if jsonErr != nil {
-		log.Println(jsonErr)
+		warn("download", "Error reading holidays: %v", jsonErr)
 		return nil
 	}
 
@@ -816,43 +2231,50 @@ func fetchHolidayEvents(url string, country string, subDiv string, lang string,
 				holidayMon, _ = strconv.Atoi(parts[1])
 				fmt.Printf("Creating new event %v.%v. %v\n", holidayDay, holidayMon, holidayText)
 
-				gcd := gDate{time.Month(holidayMon), holidayDay, holidayText, "", ""}
+				gcd := gDate{time.Month(holidayMon), holidayDay, holidayText, "", "", fullurl, "", "", "", "", 0, ""}
 				eL = append(eL, gcd)
 			} else {
-				log.Fatal("Error parsing date")
+				warn("date", "Ignoring holiday '%s', unknown date '%s'", holidayText, p.StartDate)
 			}
 		}
 	}
+	holidayMutex.Lock()
+	holidayCache[cacheKey] = eL
+	holidayMutex.Unlock()
 	return eL
 }
 
-func (g *Calendar) CreateCalendar(fn string) {
-
-	var fontTempdir string
-	var fontScale = g.OptFontScale
-
-	if g.OptPlain == true {
-		g.SetHideOtherMonth()
-		g.SetHideDOY()
-		g.SetHideMoon()
-		g.SetHideWeek()
-	}
-
-	if g.OptSmall == true {
-		fontScale = 0.75
-	}
+// getEventList returns the events of the calendar and counts them
+// for the report.
+func (g *Calendar) getEventList() (eventList []gDate) {
+	all := g.collectEvents()
+	eventList = g.normalizeEvents(all)
+	g.eventCounts = countEvents(all, eventList, g.WantBeginMonth, g.WantEndMonth)
+	return g.prefixEvents(eventList)
+}
 
-	currentLanguage := getLanguage(g.OptLocale)
+// normalizeEvents applies the leap day policy, removes the
+// duplicates and sorts the events.
+func (g *Calendar) normalizeEvents(eventList []gDate) []gDate {
+	eventList = moveLeapDayEvents(eventList, g.OptLeapDay, g.WantYear)
+	eventList = dedupEvents(eventList, g.OptDedup)
+	sortEvents(eventList)
+	return eventList
+}
 
-	var fileEventList = make([]gDate, 10000) // Maximum number of events
+// collectEvents collects the events from the configuration files,
+// the ICS files, the holiday service and the library user, and adds
+// the bridge days.
+func (g *Calendar) collectEvents() (eventList []gDate) {
+	var fileEventList []gDate
 
 	if g.OptConfig != "" {
-		fileEventList = readConfigurationfile(g.OptConfig)
+		fileEventList = g.readConfigurationfile(g.OptConfig, getLanguage(g.OptLocale), g.WantYear)
 	}
 
 	if len(g.OptICS) > 0 {
 		for _, evfile := range g.OptICS {
-			thiseventList := readICSfile(evfile, g.WantYear)
+			thiseventList := g.sanitizeEvents(g.icsEvents(g.icsReader().read(evfile, g.WantYear, g.OptICSTransp, g.warnf)))
 			for _, ev := range thiseventList {
 				fileEventList = append(fileEventList, ev)
 			}
@@ -861,7 +2283,7 @@ func (g *Calendar) CreateCalendar(fn string) {
 
 	if len(g.OptConfigs) > 0 {
 		for _, evfile := range g.OptConfigs {
-			thiseventList := readConfigurationfile(evfile)
+			thiseventList := g.readConfigurationfile(evfile, getLanguage(g.OptLocale), g.WantYear)
 			for _, ev := range thiseventList {
 				fileEventList = append(fileEventList, ev)
 			}
@@ -871,33 +2293,297 @@ func (g *Calendar) CreateCalendar(fn string) {
 	//public Holiday not only nation wide
 	if g.OptHoliday {
 		var holidayEventList = make([]gDate, 10000) // Maximum number of events
-		holidayEventList = fetchHolidayEvents(HOLIDAY_URL, "FR", "FR", "FR", false, g.WantYear)
+		holidayEventList = fetchHolidayEvents(HOLIDAY_URL, g.OptHolidayCountry, g.OptHolidayCountry, g.OptHolidayCountry, false, g.WantYear, g.warnf)
 		fileEventList = append(fileEventList, holidayEventList...)
 	}
 	//school Holiday not only nation wide
 	if g.OptHoliday {
 		var holidayEventList = make([]gDate, 10000) // Maximum number of events
-		holidayEventList = fetchHolidayEvents(SCHOOLHOLIDAY_URL, "FR", "FR", "FR", false, g.WantYear)
+		holidayEventList = fetchHolidayEvents(SCHOOLHOLIDAY_URL, g.OptHolidayCountry, g.OptHolidayCountry, g.OptHolidayCountry, false, g.WantYear, g.warnf)
 		fileEventList = append(fileEventList, holidayEventList...)
 	}
 
-	eventList := fileEventList
+	if g.OptLiturgical {
+		fileEventList = append(fileEventList, liturgicalEvents(g.WantYear)...)
+	}
+
+	if g.OptShabbat {
+		lat, lon, err := parseLocation(g.OptLocation)
+		if err != nil {
+			fmt.Printf("# Error, no Shabbat times: %v\n", err)
+		} else {
+			from, to := g.astroRange()
+			fileEventList = append(fileEventList, shabbatEvents(g.astronomy(), from, to, lat, lon, g.OptCandleLighting, g.OptHavdalah, getLocation(g.OptTimezone))...)
+		}
+	}
+
+	if g.OptAstroEvents {
+		fileEventList = append(fileEventList, astroEvents(g.astronomy(), g.WantYear, getLocation(g.OptTimezone))...)
+	}
+	fileEventList = append(fileEventList, g.ephemerisEvents()...)
+
+	fileEventList = append(fileEventList, g.recipientEvents()...)
+
+	eventList = fileEventList
 	for _, ev := range g.EventList {
 		eventList = append(eventList, ev)
 	}
+	return append(eventList, g.bridgeEvents(eventList)...)
+}
+
+// moveLeapDayEvents applies the policy to the events on 2/29 if
+// the year has no leap day: skip drops them, feb28 and mar1 move
+// them to that day.
+func moveLeapDayEvents(eL []gDate, policy string, year int) []gDate {
+	if time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Month() == time.February {
+		return eL
+	}
+	var out []gDate
+	for _, ev := range eL {
+		if ev.Month == time.February && ev.Day == 29 {
+			switch policy {
+			case "", "skip":
+				continue
+			case "feb28":
+				ev.Day = 28
+			case "mar1":
+				ev.Month, ev.Day = time.March, 1
+			default:
+				fmt.Printf("# Error, unknown leap day policy '%s', skipping %s\n", policy, ev.Text)
+				continue
+			}
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+// normalizeSummary folds case and whitespace of an event text, so
+// that "Easter Monday" and "easter  monday " are the same event.
+// The text is cp1252, so only ASCII letters are folded.
+func normalizeSummary(s string) string {
+	b := []byte(strings.Join(strings.Fields(s), " "))
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return strings.TrimRight(string(b), ".!")
+}
+
+// dedupEvents removes events with the same date and summary. With
+// keep-first the first one wins, with merge the first one also
+// takes the fields of the later ones that it lacks. keep-all changes
+// nothing.
+func dedupEvents(eL []gDate, strategy string) []gDate {
+	switch strategy {
+	case "", "keep-all":
+		return eL
+	case "keep-first", "merge":
+	default:
+		fmt.Printf("# Error, unknown dedup strategy '%s', keeping all events\n", strategy)
+		return eL
+	}
+	type eventKey struct {
+		month   time.Month
+		day     int
+		weekday string
+		text    string
+	}
+	seen := make(map[eventKey]int)
+	var out []gDate
+	for _, ev := range eL {
+		k := eventKey{ev.Month, ev.Day, ev.Weekday, normalizeSummary(ev.Text)}
+		if i, ok := seen[k]; ok {
+			if strategy == "merge" {
+				mergeEvent(&out[i], ev)
+			}
+			continue
+		}
+		seen[k] = len(out)
+		out = append(out, ev)
+	}
+	return out
+}
+
+// mergeEvent fills the empty fields of ev with those of the duplicate
+// dup, the date, summary and source stay those of ev.
+func mergeEvent(ev *gDate, dup gDate) {
+	for _, f := range []struct{ to, from *string }{
+		{&ev.Image, &dup.Image},
+		{&ev.Color, &dup.Color},
+		{&ev.Category, &dup.Category},
+		{&ev.Description, &dup.Description},
+		{&ev.Kind, &dup.Kind},
+		{&ev.Time, &dup.Time},
+	} {
+		if *f.to == "" {
+			*f.to = *f.from
+		}
+	}
+	if ev.Priority == 0 {
+		ev.Priority = dup.Priority
+	}
+}
+
+// eventMatches reports whether the event is due on day d.
+func eventMatches(ev gDate, d time.Time) bool {
+	if len(ev.Text) == 0 {
+		return false
+	}
+	return dateMatches(ev, d)
+}
+
+// dateMatches reports whether d is the date or weekday of ev.
+func dateMatches(ev gDate, d time.Time) bool {
+	if weekdayMatches(ev.Weekday, d) {
+		return true
+	}
+	return d.Day() == ev.Day && d.Month() == ev.Month
+}
+
+// getStyles returns the styles of the configuration files.
+func (g *Calendar) getStyles() (styleList []gStyle) {
+	for _, cfg := range append([]string{g.OptConfig}, g.OptConfigs...) {
+		if cfg != "" {
+			styleList = append(styleList, g.readConfigurationStyles(cfg, getLanguage(g.OptLocale), g.WantYear)...)
+		}
+	}
+	return styleList
+}
+
+// getRules returns the formatting rules of the configuration files.
+func (g *Calendar) getRules() (ruleList []gRule) {
+	for _, cfg := range append([]string{g.OptConfig}, g.OptConfigs...) {
+		if cfg != "" {
+			ruleList = append(ruleList, g.readConfigurationRules(cfg, getLanguage(g.OptLocale))...)
+		}
+	}
+	return ruleList
+}
+
+// dayStyle returns the color and fill of day d: the styles of the
+// calendar, the month and the day override each other in this order.
+func dayStyle(styleList []gStyle, d time.Time) (color, fill string) {
+	for level := STYLECALENDAR; level <= STYLEDAY; level++ {
+		for _, st := range styleList {
+			if st.Level != level {
+				continue
+			}
+			if level == STYLEMONTH && st.Date.Month != d.Month() {
+				continue
+			}
+			if level == STYLEDAY && !dateMatches(st.Date, d) {
+				continue
+			}
+			if st.Color != "" {
+				color = st.Color
+			}
+			if st.Fill != "" {
+				fill = st.Fill
+			}
+		}
+	}
+	return color, fill
+}
+
+func (g *Calendar) CreateCalendar(fn string) {
+
+	if g.OptSplitMonths {
+		for mo := g.WantBeginMonth; mo <= g.WantEndMonth; mo++ {
+			single := *g
+			single.OptSplitMonths = false
+			single.WantBeginMonth, single.WantEndMonth = mo, mo
+			single.CreateCalendar(monthFilename(fn, mo))
+		}
+	}
+	if g.streamed() {
+		g.createStreamed(fn)
+		return
+	}
+
+	var fontTempdir string
+	trace := g.traceLine()
+	var fontScale = g.OptFontScale
+
+	if g.OptPlain == true {
+		g.SetHideOtherMonth()
+		g.SetHideDOY()
+		g.SetHideMoon()
+		g.SetHideWeek()
+	}
+
+	if g.OptWritable {
+		g.applyWritable()
+	}
+
+	if g.OptSmall == true {
+		fontScale = 0.75
+	}
+
+	currentLanguage := getLanguage(g.OptLocale)
+
+	eventList := g.getEventList()
+	styleList := g.getStyles()
+	ruleList := g.getRules()
+	projectList := g.getProjects()
+	var vacation [13]int
+	if g.OptVacation > 0 {
+		vacation = vacationDays(eventList, g.WantYear)
+	}
 
 	wantyear := g.WantYear
 	wantmonths := monthRange{g.WantBeginMonth, g.WantEndMonth}
-	localizedMonthNames := getLocalizedMonthNames(currentLanguage)
-	localizedWeekdayNames := getLocalizedWeekdayNames(currentLanguage, 0)
+	localizedMonthNames := g.monthNames(currentLanguage, false)
+	localizedMonthNamesGenitive := g.monthNames(currentLanguage, true)
+	localizedWeekdayNames := g.weekdayNames(currentLanguage, 0)
 
 	var calFont = g.OptFont
 
-	calFont, fontTempdir = processFont(calFont)
+	calFont, fontTempdir = g.processFont(calFont)
+	defer removeTempdir(fontTempdir)
 
-	pdf := gofpdf.New(g.OptOrientation, "mm", g.OptPaperformat, fontTempdir)
+	pdf := g.newPDF(fontTempdir)
 	pdf.SetTitle("Created with Gocal", true)
 	pdf.AddFont(calFont, "", calFont+".json")
+	fonts := g.loadElementFonts(pdf, calFont, fontTempdir)
+	numerals := g.loadNumeralFont(pdf)
+	if numerals != "latn" {
+		fonts["day"] = elementFont{NUMERALFONT, ""}
+	}
+	g.setGridStyle(pdf)
+	border := g.gridBorder()
+	layers := newPDFLayers(pdf, g.OptLayers && g.OptPDFX == "")
+	if g.OptGridStyle == "rounded" || layers.active() {
+		border = "" // the cells are drawn separately
+	}
+
+	// Quotes from the configuration files, the quote file wins.
+	var quotes [13]string
+	for _, cfg := range append([]string{g.OptConfig}, g.OptConfigs...) {
+		if cfg == "" {
+			continue
+		}
+		for i, q := range g.readConfigurationQuotes(cfg) {
+			if q != "" {
+				quotes[i] = q
+			}
+		}
+	}
+	if g.OptQuotes != "" {
+		quotes = readQuotesfile(g.OptQuotes)
+	}
+	hasQuotes := false
+	for _, q := range quotes {
+		if q != "" {
+			hasQuotes = true
+		}
+	}
+	quoteFont := calFont
+	if hasQuotes && g.OptQuoteFont != "" {
+		quoteFont = g.convertFont(g.OptQuoteFont, fontTempdir)
+		pdf.AddFont(quoteFont, "", quoteFont+".json")
+	}
 
 	PAGEWIDTH, PAGEHEIGHT, _ := pdf.PageSize(0)
 	if g.OptOrientation != "P" {
@@ -907,36 +2593,98 @@ func (g *Calendar) CreateCalendar(fn string) {
 	cw := (PAGEWIDTH - 2*MARGIN) / COLUMNS // cellwidth w margin
 	ch := PAGEHEIGHT / (LINES + 2)         // cellheight
 
+	// Room for two lines of quote below the title
+	quoteLineHeight := QUOTEFONTSIZE * fontScale * 0.45
+	if hasQuotes {
+		ch = (PAGEHEIGHT - 2*quoteLineHeight) / (LINES + 2)
+	}
+
 	var photoList [12]string
-	photoList = getPhotolist(g.OptPhoto, fontTempdir)
+	photoList = g.getPhotolist(g.OptPhoto, fontTempdir)
 	if g.OptPhotos != "" {
-		photoList = getPhotoslist(g.OptPhotos)
+		photoList = getPhotoslist(g.OptPhotos, g.OptPhotoOrder, g.OptPhotoSeed)
+	}
+	if g.OptWallBound && (g.OptFiller != "" || g.OptHabits != "" || g.OptBudget != "" || g.OptNotes != "") {
+		fmt.Printf("# Filler and tracker pages are left out of wall-bound calendars\n")
 	}
 	if g.OptPhoto != "" || g.OptPhotos != "" {
-		ch *= 0.5
+		if !g.OptWallBound {
+			ch *= 0.5
+		}
+		if g.OptPhotoFit != "" && g.OptPhotoFit != "stretch" && g.OptPhotoFit != "fill" {
+			fmt.Printf("# Unknown photo fit '%s', stretching the photos\n", g.OptPhotoFit)
+		}
+		if _, ok := captionPositions[g.OptCaptionPos]; g.OptCaptionPos != "" && !ok {
+			fmt.Printf("# Unknown caption position '%s', using bottomright\n", g.OptCaptionPos)
+		}
+		if g.OptCaptionStyle != "" && !isCaptionStyle(g.OptCaptionStyle) {
+			fmt.Printf("# Unknown caption style '%s', use one of %v\n", g.OptCaptionStyle, captionStyles)
+		}
+	}
+
+	// drawGrid draws the cell at x, y on the grid layer.
+	drawGrid := func(x, y float64) {
+		if layers.active() && g.OptGridStyle != "rounded" && g.gridBorder() != "" {
+			layers.begin(pdf, layers.grid)
+			pdf.Rect(x, y, cw, ch, "D")
+			layers.end(pdf)
+		}
 	}
 
-	// Map of date to String for all days in the YEAR.
+	// Map of date to String for the days in the grids of the months.
 	moonj := make(map[string]string)
-	computeMoonphasesJ(moonj, wantyear)
+	if g.OptHideMoon == false {
+		from, to := g.astroRange()
+		moonj = moonPhasesBetween(g.astronomy(), from, to)
+	}
+	moonLabels := g.moonPhaseLabels(currentLanguage)
+
+	contentProvider := g.ContentProvider
+	if contentProvider == nil && g.OptHistory != "" {
+		contentProvider = newOnThisDay(g.OptHistory, g.OptHistoryLength, g.warnf)
+	}
+
+	showPrayer := false
+	var prayerLat, prayerLon float64
+	prayerLoc := getLocation(g.OptTimezone)
+	method, ok := prayerMethods[g.OptPrayerMethod]
+	if g.OptPrayer {
+		var err error
+		prayerLat, prayerLon, err = parseLocation(g.OptLocation)
+		if err != nil {
+			fmt.Printf("# Error, no prayer times: %v\n", err)
+		} else {
+			showPrayer = true
+		}
+		if !ok {
+			fmt.Printf("# Unknown prayer time method '%s', using MWL\n", g.OptPrayerMethod)
+			method = prayerMethods["MWL"]
+		}
+	}
+
+	showPanchang := false
+	var panchangLat, panchangLon float64
+	if g.OptPanchang {
+		var err error
+		panchangLat, panchangLon, err = parseLocation(g.OptLocation)
+		if err != nil {
+			fmt.Printf("# Error, no Panchang: %v\n", err)
+		} else {
+			showPanchang = true
+		}
+	}
 
 	calendarTable := func(mymonth int, myyear int) {
-		pdf.SetFont(calFont, "", WEEKDAYFONTSIZE*fontScale)
+		fonts.set(pdf, "weekday", WEEKDAYFONTSIZE*fontScale)
 		for weekday := 0; weekday <= 6; weekday++ { // Print weekdays in first row
 			// The week row can be smaller
-			pdf.CellFormat(cw, ch*0.33, localizedWeekdayNames[(weekday+2)%7], "0", 0, "C", false, 0, "")
+			pdf.CellFormat(cw, ch*0.33, localizedWeekdayNames[(columnWeekday(weekday)+1)%7], "0", 0, "C", false, 0, "")
 		}
 		pdf.Ln(-1)
 
-		// Figure out the first day in the calendar which depends on the weekday
-		// of the first day
-		var day int64 = 1
-		t := time.Date(myyear, time.Month(mymonth), 1, 0, 0, 0, 0, time.UTC)
-
-		day -= int64(t.Weekday())
-		if day > 0 { // adjust silly exception where month starts w/ Sunday.
-			day -= 7
-		}
+		// The first day in the calendar depends on the weekday of the
+		// first day, see CheckGrid.
+		day := int64(firstCellOffset(myyear, mymonth))
 
 		for i := 0; i < LINES; i++ {
 			for j := 0; j < COLUMNS; j++ {
@@ -962,118 +2710,640 @@ func (g *Calendar) CreateCalendar(fn string) {
 				}
 				pdf.SetCellMargin(CELLMARGIN)
 
+				if fill && g.OptFillStyle != "" {
+					x, y := pdf.GetXY()
+					radius := 0.0
+					if g.OptGridStyle == "rounded" {
+						x, y, radius = x+CELLMARGIN, y+CELLMARGIN, 2*CELLMARGIN
+					}
+					if err := paintFill(pdf, g.OptFillStyle, x, y, cw-2*radius, ch-2*radius, radius); err != nil {
+						fmt.Printf("# Error in fill style: %v\n", err)
+						g.OptFillStyle = ""
+					}
+					fill = false
+				}
+
+				if g.OptGridStyle == "rounded" {
+					x, y := pdf.GetXY()
+					style := "D"
+					if fill {
+						style = "DF"
+					}
+					layers.begin(pdf, layers.grid)
+					pdf.RoundedRect(x+CELLMARGIN, y+CELLMARGIN, cw-2*CELLMARGIN, ch-2*CELLMARGIN, 2*CELLMARGIN, "1234", style)
+					layers.end(pdf)
+					fill = false // already done
+				} else if fill {
+					// Paint the background first, so that it doesn't hide
+					// the content of the cell.
+					x, y := pdf.GetXY()
+					pdf.Rect(x, y, cw, ch, "F")
+					fill = false
+				}
+
+				if today.Month() != time.Month(mymonth) && (g.OptOtherMonths == "blank" || g.OptOtherMonths == "pattern") {
+					if g.OptOtherMonths == "pattern" {
+						x, y := pdf.GetXY()
+						g.hatchCell(pdf, x, y, cw, ch)
+					}
+					drawGrid(pdf.GetXY())
+					pdf.CellFormat(cw, ch, "", border, 0, "", false, 0, "")
+					day++
+					continue
+				}
+
+				if today.Month() == time.Month(mymonth) {
+					x, y := pdf.GetXY()
+					g.shadeLiturgical(pdf, today, x, y, cw, ch)
+				}
+
+				var todaysEvents []gDate
+				for _, ev := range eventList {
+					if eventMatches(ev, today) {
+						todaysEvents = append(todaysEvents, ev)
+					}
+				}
+				styleColor, styleFill := dayStyle(styleList, today)
+				if g.OptBridgeFill != "" && hasBridgeDay(todaysEvents) {
+					styleFill = g.OptBridgeFill
+				}
+				format, todaysEvents := applyRules(ruleList, today, todaysEvents)
+				if format.Color != "" {
+					styleColor = format.Color
+				}
+				if format.Fill != "" {
+					styleFill = format.Fill
+				}
+				if today.Month() != time.Month(mymonth) {
+					styleColor, styleFill, format.Icon = "", "", ""
+				}
+				if styleFill != "" {
+					x, y := pdf.GetXY()
+					if err := paintFill(pdf, styleFill, x, y, cw, ch, 0); err != nil {
+						fmt.Printf("# Error in style fill: %v\n", err)
+					}
+				}
+
+				highlight := g.OptHighlightToday != "" && isToday(today)
+				if highlight {
+					x, y := pdf.GetXY()
+					g.highlightToday(pdf, x, y, cw, ch, true)
+				}
+
+				// The day number is printed last, but it reserves its
+				// area of the cell first.
+				cellX, cellY := pdf.GetXY()
+				layout := newCellLayout(cellX, cellY, cw, ch)
+				dayNumber := localizeDigits(fmt.Sprintf("%d", today.Day()), numerals)
+				align := "TL"
+				switch g.OptDayNumberPos {
+				case "TR":
+					align = "TR"
+				case "C":
+					align = "CM"
+				}
+				dayNumberSize := MONTHDAYFONTSIZE * fontScale
+				if g.OptDayNumberSize > 0 {
+					dayNumberSize = g.OptDayNumberSize
+				}
+				fonts.set(pdf, "day", dayNumberSize)
+				if g.OptDayNumberPos != "watermark" {
+					layout.reserveText(pdf, dayNumber, align)
+				}
+
+				// Project bars, above the week number and day of year
+				if len(projectList) > 0 {
+					layers.begin(pdf, layers.events)
+					drawProjectBars(pdf, layout, fonts, projectList, today, j, cellY+ch-CELLMARGIN-pdf.PointConvert(DOYFONTSIZE*fontScale))
+					layers.end(pdf)
+					fonts.set(pdf, "day", dayNumberSize)
+				}
+
+				if g.OptDayNumberPos == "watermark" {
+					x, y := pdf.GetXY()
+					r, gr, b := pdf.GetTextColor()
+					pdf.SetTextColor(WATERMARKGREY, WATERMARKGREY, WATERMARKGREY)
+					if today.Month() == time.Month(mymonth) {
+						g.setDayNumberColor(pdf)
+					}
+					size := g.OptDayNumberSize
+					if size == 0 {
+						size = ch * 2.0 // about 70% of the cell height
+					}
+					fonts.set(pdf, "day", size)
+					pdf.CellFormat(cw, ch, localizeDigits(fmt.Sprintf("%d", today.Day()), numerals), "", 0, "CM", false, 0, "")
+					pdf.SetXY(x, y)
+					pdf.SetTextColor(r, gr, b)
+				}
+
 				if g.OptHideMoon == false {
 					// Do we have a relevant moon today?
 					todayString := today.Format("2006-01-02")
-					if m, ok := moonj[todayString]; ok == true {
+					if m, ok := moonj[todayString]; ok == true && g.moonSymbols() {
 						x, y := pdf.GetXY()
-						moonLocX, moonLocY := x+cw*0.82, y+ch*0.2
-
 						moonsize := MOONSIZE
 						if g.OptPhoto != "" || g.OptPhotos != "" {
 							moonsize *= 0.6
 						}
-						myMoonPDF := myPdf{pdf, moonsize}
-						pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
-						switch m {
-						case "Full":
-							myMoonPDF.fullMoon(moonLocX, moonLocY)
-						case "New":
-							myMoonPDF.newMoon(moonLocX, moonLocY)
-						case "First":
-							myMoonPDF.firstQuarter(moonLocX, moonLocY)
-						case "Last":
-							myMoonPDF.lastQuarter(moonLocX, moonLocY)
-						}
+						moonLocX, moonLocY, moonsize := g.moonPlace(rect{x, y, cw, ch}, moonAnchors["TR"], moonsize)
+						layout.reserve(rect{moonLocX - moonsize, moonLocY - moonsize, 2 * moonsize, 2 * moonsize})
+						layers.begin(pdf, layers.astronomy)
+						g.drawMoonSymbol(pdf, m, moonLocX, moonLocY, moonsize)
+						layers.end(pdf)
+						g.setGridStyle(pdf)
+					}
+					if m, ok := moonj[todayString]; ok == true && g.moonNames() {
+						r, gr, b := pdf.GetTextColor()
+						pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+						layers.begin(pdf, layers.astronomy)
+						drawMoonLabel(pdf, layout, fonts, moonLabels[m], DOYFONTSIZE*fontScale*0.6)
+						layers.end(pdf)
+						pdf.SetTextColor(r, gr, b)
 					}
 				}
 
 				// Day of year, lower right
 				if g.OptHideDOY == false && int(today.Month()) == mymonth {
-					doy := julian.DayOfYearGregorian(myyear, mymonth, int(today.Day()))
-					pdf.SetFont(calFont, "", DOYFONTSIZE*fontScale)
-					pdf.CellFormat(cw, ch, fmt.Sprintf("%d", doy), "1", 0, "BR", fill, 0, "")
+					doy := g.dayNumber(today)
+					fonts.set(pdf, "small", DOYFONTSIZE*fontScale)
+					layout.reserveText(pdf, doy, "BR")
+					pdf.CellFormat(cw, ch, doy, border, 0, "BR", fill, 0, "")
 					pdf.SetX(pdf.GetX() - cw) // reset
 				}
 
+				// Julian date (old style), right below the moon
+				if g.OptJulian {
+					_, jm, jd := gregorianToJulian(today)
+					julianDate := fmt.Sprintf("%d", jd)
+					if jd == 1 {
+						julianDate += " " + localizedMonthNamesGenitive[jm]
+					}
+					x, y := pdf.GetXY()
+					fonts.set(pdf, "small", DOYFONTSIZE*fontScale)
+					_, h := pdf.GetFontSize()
+					w := pdf.GetStringWidth(julianDate)
+					layout.reserve(rect{x + cw - CELLMARGIN - w, y + 0.45*ch - ASCENT*h, w, h})
+					pdf.Text(x+cw-CELLMARGIN-w, y+0.45*ch, julianDate)
+				}
+
+				// Prayer times, two small lines above the week number
+				if showPrayer && int(today.Month()) == mymonth {
+					lines := formatPrayerTimes(prayerTimes(today, prayerLat, prayerLon, method, g.OptPrayerHanafi), prayerLoc)
+					x, y := pdf.GetXY()
+					fonts.set(pdf, "small", DOYFONTSIZE*fontScale*0.5)
+					_, h := pdf.GetFontSize()
+					w := math.Max(pdf.GetStringWidth(lines[0]), pdf.GetStringWidth(lines[1]))
+					layout.reserve(rect{x + CELLMARGIN, y + 0.72*ch - ASCENT*h, w, 0.08*ch + h})
+					pdf.Text(x+CELLMARGIN, y+0.72*ch, lines[0])
+					pdf.Text(x+CELLMARGIN, y+0.80*ch, lines[1])
+				}
+
+				// Tithi and nakshatra, two small lines on the right
+				if showPanchang && int(today.Month()) == mymonth {
+					lines := formatPanchang(panchang(g.astronomy(), today, panchangLat, panchangLon, prayerLoc))
+					fonts.set(pdf, "small", DOYFONTSIZE*fontScale*0.5)
+					_, h := pdf.GetFontSize()
+					w := math.Max(pdf.GetStringWidth(lines[0]), pdf.GetStringWidth(lines[1]))
+					if r, ok := layout.placeRight(w, 2*h); ok {
+						pdf.Text(r.x+r.w-pdf.GetStringWidth(lines[0]), r.y+ASCENT*h, lines[0])
+						pdf.Text(r.x+r.w-pdf.GetStringWidth(lines[1]), r.y+h+ASCENT*h, lines[1])
+					}
+				}
+
 				// Add week number, lower left
 				if today.Weekday() == time.Monday && g.OptHideWeek == false {
-					pdf.SetFont(calFont, "", WEEKFONTSIZE*fontScale)
+					fonts.set(pdf, "small", WEEKFONTSIZE*fontScale)
 					_, weeknr := today.ISOWeek()
-					pdf.CellFormat(cw, ch, fmt.Sprintf("W %d", weeknr), "1", 0, "BL", fill, 0, "")
+					layout.reserveText(pdf, fmt.Sprintf("W %d", weeknr), "BL")
+					pdf.CellFormat(cw, ch, fmt.Sprintf("W %d", weeknr), border, 0, "BL", fill, 0, "")
 					pdf.SetX(pdf.GetX() - cw) // reset
 				}
 
-				// Add event text
-				for _, ev := range eventList {
-					if len(ev.Text) == 0 {
-						continue
+				// Day number in Braille, on the right
+				if g.OptBraille && today.Month() == time.Month(mymonth) {
+					cells := brailleNumber(today.Day())
+					w, h := brailleSize(cells)
+					if r, ok := layout.placeRight(w, h); ok {
+						pdf.SetFillColor(BLACK, BLACK, BLACK)
+						drawBraille(pdf, r.x, r.y, cells)
 					}
-					if today.Weekday().String() == string(ev.Weekday) {
-						x, y := pdf.GetXY()
-						pdf.SetFont(calFont, "", EVENTFONTSIZE*fontScale)
+				}
 
-						if ev.Image != "" {
-							pdf.Image(ev.Image, x, y, cw, ch, false, "", 0, "")
-						}
-						for i, j := range strings.Split(ev.Text, "\\n") {
-							pdf.Text(x+0.02*cw, y+0.50*ch+float64(i)*EVENTFONTSIZE*fontScale/3.0, fmt.Sprintf("%s", j))
-						}
+				// Icon of a formatting rule, on the right
+				layers.begin(pdf, layers.events)
+				if format.Icon != "" {
+					g.drawIcon(pdf, layout, fonts, format.Icon, math.Min(cw, ch)*0.25)
+				}
+
+				// Labels of the date patterns and icons of the ephemeris
+				// events, on the right
+				if int(today.Month()) == mymonth {
+					for _, label := range g.patternLabels(today) {
+						g.drawPatternLabel(pdf, layout, fonts, label, math.Min(cw, ch)*0.25, DOYFONTSIZE*fontScale*0.6)
 					}
-					if today.Day() == ev.Day && today.Month() == ev.Month {
-						x, y := pdf.GetXY()
-						pdf.SetFont(calFont, "", EVENTFONTSIZE*fontScale)
+					for _, kind := range g.ephemerisKinds(today) {
+						g.drawAstroIcon(pdf, layout, fonts, kind, math.Min(cw, ch)*0.2)
+					}
+				}
 
-						if ev.Image != "" {
-							pdf.Image(ev.Image, x, y, cw, ch, false, "", 0, "")
-						}
-						for i, j := range strings.Split(ev.Text, "\\n") {
-							pdf.Text(x+0.02*cw, y+0.50*ch+float64(i)*EVENTFONTSIZE*fontScale/3.0, fmt.Sprintf("%s", j))
+				// Add event text into the free areas of the cell,
+				// images are drawn behind everything.
+				g.drawDayImages(pdf, todaysEvents, rect{cellX, cellY, cw, ch})
+				if len(todaysEvents) > 0 {
+					eventSize := EVENTFONTSIZE * fontScale
+					if format.Scale > 0 {
+						eventSize *= format.Scale
+					}
+					fonts.set(pdf, "event", eventSize)
+					lineHeight := eventSize / 3.0
+					layout.drawEvents(pdf, fonts["event"], eventSize, todaysEvents, lineHeight, 0.5*ch-ASCENT*lineHeight, styleColor)
+					if g.OptAnnotations {
+						annotateDay(pdf, today, todaysEvents, cellX, cellY, cw, ch)
+					}
+				}
+
+				// Fill empty days from the content provider
+				if contentProvider != nil && len(todaysEvents) == 0 && int(today.Month()) == mymonth {
+					if text := contentProvider.DayContent(today); text != "" {
+						fonts.set(pdf, "event", EVENTFONTSIZE*fontScale*0.8)
+						lineHeight := EVENTFONTSIZE * fontScale * 0.8 / 3.0
+						from := 0.45*ch + (1-ASCENT)*lineHeight
+						for _, line := range pdf.SplitLines([]byte(convertCP(text)), cw-2*CELLMARGIN) {
+							r, ok := layout.place(CELLMARGIN, from, pdf.GetStringWidth(string(line)), lineHeight)
+							if !ok || r.y < cellY+0.45*ch {
+								break
+							}
+							pdf.Text(r.x, r.y+ASCENT*lineHeight, string(line))
+							from = r.y - cellY + lineHeight
 						}
 					}
 				}
+				layers.end(pdf)
 
 				// day of the month, big number
-				pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale)
-				pdf.CellFormat(cw, ch, fmt.Sprintf("%d", today.Day()), "1", 0, "TL", fill, 0, "")
+				if g.OptDayNumberPos == "watermark" {
+					dayNumber = "" // already printed
+				}
+				if today.Month() == time.Month(mymonth) && !((today.Weekday() == time.Saturday || today.Weekday() == time.Sunday) && !g.OptNocolor) {
+					g.setDayNumberColor(pdf)
+				}
+				if styleColor != "" {
+					setTextColor(pdf, styleColor)
+				}
+				fonts.set(pdf, "day", dayNumberSize)
+				drawGrid(pdf.GetXY())
+				pdf.CellFormat(cw, ch, dayNumber, border, 0, align, fill, 0, "")
+				if highlight {
+					x, y := pdf.GetXY()
+					g.highlightToday(pdf, x-cw, y, cw, ch, false)
+				}
 				day++
 			}
 			pdf.Ln(-1)
 		}
 	}
 
+	textList := g.getTexts()
+	backgrounds, borders := g.ornaments()
+	theme := g.seasonTheme()
+	defer theme.restore()
+	// drawPhoto draws the photo of the month with its caption.
+	drawPhoto := func(mo int, r rect) {
+		photo := photoList[mo-1] // this list is zero-based.
+		if photo == "" {
+			return
+		}
+		g.enhancePhoto(pdf, photo)
+		if g.OptPhotoFit == "fill" {
+			g.imageCover(pdf, photo, r)
+		} else {
+			g.image(pdf, photo, r.x, r.y, r.w, r.h)
+		}
+		g.drawCaption(pdf, fonts, fontScale, photo, r, localizedMonthNames[mo], mo)
+	}
+	hasPhotos := g.OptPhoto != "" || g.OptPhotos != ""
+
+	if (g.brand().hasBrand() || g.OptWallBound) && g.part.isFirst() {
+		g.addCoverPage(pdf, fonts, fontScale, PAGEWIDTH, PAGEHEIGHT)
+		if g.OptWallBound {
+			drawHangerMark(pdf, PAGEWIDTH)
+		}
+	}
 	for mo := wantmonths.begin; mo <= wantmonths.end; mo++ {
+		if g.OptWallBound {
+			// The back of the sheet before, above the grid when hung.
+			pdf.AddPage()
+			layers.begin(pdf, layers.photos)
+			upsideDown(pdf, PAGEWIDTH, PAGEHEIGHT, func() {
+				drawBackground(pdf, backgrounds[mo], rect{0, 0, PAGEWIDTH, PAGEHEIGHT})
+				if hasPhotos {
+					drawPhoto(mo, rect{0, 0, PAGEWIDTH, PAGEHEIGHT})
+				}
+			})
+			layers.end(pdf)
+			drawHangerMark(pdf, PAGEWIDTH)
+		}
 		//fmt.Printf("Printing page %d\n", page)
 		pdf.AddPage()
+		theme.apply(pdf, time.Month(mo))
+		layers.begin(pdf, layers.photos)
 		if g.OptWallpaper != "" {
 			g.AddWallpaper(pdf, fontTempdir, PAGEWIDTH, PAGEHEIGHT)
 		}
+		drawBackground(pdf, backgrounds[mo], rect{0, 0, PAGEWIDTH, PAGEHEIGHT})
+		drawBorder(pdf, borders[mo], rect{0, 0, PAGEWIDTH, PAGEHEIGHT})
 
-		if g.OptPhoto != "" || g.OptPhotos != "" {
-			photo := photoList[mo-1] // this list is zero-based.
-			if photo != "" {
-				pdf.Image(photo, 0, PAGEHEIGHT*0.5, PAGEWIDTH, PAGEHEIGHT*0.5, false, "", 0, "")
-			}
+		if hasPhotos && !g.OptWallBound {
+			drawPhoto(mo, rect{0, PAGEHEIGHT * 0.5, PAGEWIDTH, PAGEHEIGHT * 0.5})
+		}
+		layers.end(pdf)
+		if g.OptWallBound {
+			drawHangerMark(pdf, PAGEWIDTH)
 		}
 
+		g.setTitleColor(pdf)
+		fonts.set(pdf, "title", HEADERFONTSIZE*fontScale)
+		title := localizedMonthNames[mo] + " " + fmt.Sprintf("%d", wantyear)
+		if g.OptHeaderFill != "" {
+			_, y := pdf.GetXY()
+			if err := paintFill(pdf, g.OptHeaderFill, MARGIN, y, PAGEWIDTH-2*MARGIN, MARGIN, 0); err != nil {
+				fmt.Printf("# Error in header fill: %v\n", err)
+				g.OptHeaderFill = ""
+			}
+		}
+		if logo := g.brand().Logo; logo != "" {
+			x, y := pdf.GetXY()
+			g.drawLogo(pdf, logo, rect{MARGIN, y, 2.5 * MARGIN, MARGIN}, 0)
+			pdf.SetXY(x, y)
+		}
+		if effect := g.textEffect(); effect.outline || effect.shadow {
+			// Print like the cell below, centered with the same baseline.
+			x, y := pdf.GetXY()
+			_, fontSize := pdf.GetFontSize()
+			myPdf{pdf, 0}.effectText(x+(PAGEWIDTH-MARGIN-pdf.GetStringWidth(title))/2, y+0.5*MARGIN+0.3*fontSize, title, effect)
+			g.setGridStyle(pdf)
+			title = ""
+		}
+		pdf.CellFormat(PAGEWIDTH-MARGIN, MARGIN, title, "", 0, "C", false, 0, "")
 		pdf.SetTextColor(BLACK, BLACK, BLACK)
-		pdf.SetFont(calFont, "", HEADERFONTSIZE*fontScale)
-		pdf.CellFormat(PAGEWIDTH-MARGIN, MARGIN, localizedMonthNames[mo]+" "+fmt.Sprintf("%d", wantyear), "", 0, "C", false, 0, "")
 		pdf.Ln(-1)
+		if hasQuotes {
+			g.addQuote(pdf, quoteFont, fontScale, quotes[mo], PAGEWIDTH-2*MARGIN, quoteLineHeight)
+		}
 		calendarTable(mo, wantyear)
 
 		pdf.Ln(-1)
-		pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+		g.setFooterColor(pdf)
+		fonts.set(pdf, "footer", FOOTERFONTSIZE*fontScale)
+		footerX := 0.50*PAGEWIDTH - pdf.GetStringWidth(g.footer())*0.5
+		pdf.Text(footerX, 0.95*PAGEHEIGHT, g.footer())
+		rightX := PAGEWIDTH - MARGIN
+		if g.OptVacation > 0 {
+			g.drawVacation(pdf, vacation[mo], rightX, 0.95*PAGEHEIGHT)
+			rightX -= pdf.GetStringWidth(g.vacationText(vacation[mo])) + 4
+		}
+		if g.footer() == "" {
+			footerX = rightX
+		}
+		g.drawLegend(pdf, fonts, fontScale, 0.95*PAGEHEIGHT, footerX-4)
+
+		// TODO Hardcoded A4 portrait
+		myPdf{pdf, 0}.rotatedText(210.0*0.96, 297.0*0.05, 270, g.OptMargin)
+
+		g.addTexts(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, textList, localizedMonthNames[mo])
+
+		if g.OptWallBound {
+			continue
+		}
+		g.addTrackerPage(pdf, calFont, fontScale, PAGEWIDTH, localizedMonthNames[mo]+" "+fmt.Sprintf("%d", wantyear), mo, wantyear)
+
+		if mo < wantmonths.end || !g.part.isLast() {
+			g.addFillerPages(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, int64(wantyear*100+mo))
+		}
+	}
+	if g.OptAddressBlock != "" && g.part.isLast() {
+		g.addBackCover(pdf, fonts, fontScale, PAGEWIDTH, PAGEHEIGHT)
+	} else if g.OptWallBound && g.part.isLast() {
+		pdf.AddPage() // the back of the last sheet
+	}
+	if g.OptWallBound && g.part.isLast() {
+		drawHangerMark(pdf, PAGEWIDTH)
+	}
+	g.addPageNumbers(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT)
+	g.addTraceLine(pdf, calFont, trace, PAGEHEIGHT)
+	pdf.OutputAndClose(g.docWriter(pdf, fn))
+}
+
+// CreateContinuousCalendar creates the planner strip: the weeks of the
+// selected months flow continuously without empty leading cells. The
+// months are separated by a thick rule instead of page breaks.
+func (g *Calendar) CreateContinuousCalendar(fn string) {
+
+	var fontTempdir string
+	trace := g.traceLine()
+	var fontScale = g.OptFontScale
+	var calFont = g.OptFont
+
+	if g.OptSmall == true {
+		fontScale = 0.75
+	}
+
+	wantyear := g.WantYear
+	currentLanguage := getLanguage(g.OptLocale)
+	localizedMonthNamesGenitive := g.monthNames(currentLanguage, true)
+	localizedWeekdayNames := g.weekdayNames(currentLanguage, 0)
+	eventList := g.getEventList()
+
+	calFont, fontTempdir = g.processFont(calFont)
+	defer removeTempdir(fontTempdir)
+
+	pdf := g.newPDF(fontTempdir)
+	pdf.SetTitle("Created with Gocal", true)
+	pdf.AddFont(calFont, "", calFont+".json")
+	pdf.SetAutoPageBreak(false, 0)
+	g.setGridStyle(pdf)
+	border := g.gridBorder()
+
+	PAGEWIDTH, PAGEHEIGHT, _ := pdf.PageSize(0)
+	if g.OptOrientation != "P" {
+		PAGEWIDTH, PAGEHEIGHT = PAGEHEIGHT, PAGEWIDTH
+	}
+
+	// A narrow first column holds the week number.
+	cw := (PAGEWIDTH - 2*MARGIN) / (COLUMNS + 0.5)
+	ch := (PAGEHEIGHT - 4*MARGIN) / STRIPWEEKS
+
+	moonj := make(map[string]string)
+	if g.OptHideMoon == false {
+		from, to := g.astroRange()
+		moonj = moonPhasesBetween(g.astronomy(), from, to)
+	}
+	moonLabels := g.moonPhaseLabels(currentLanguage)
+
+	// Start with the Monday of the week of the first day.
+	first := time.Date(wantyear, time.Month(g.WantBeginMonth), 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(wantyear, time.Month(g.WantEndMonth)+1, 0, 0, 0, 0, 0, time.UTC)
+	monday := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+
+	g.checkSprints()
+
+	textList := g.getTexts()
+	backgrounds, borders := g.ornaments()
+	theme := g.seasonTheme()
+	defer theme.restore()
+	for week := monday; !week.After(last); {
+		pdf.AddPage()
+		theme.apply(pdf, week.Month())
+		if g.OptWallpaper != "" {
+			g.AddWallpaper(pdf, fontTempdir, PAGEWIDTH, PAGEHEIGHT)
+		}
+		drawBackground(pdf, backgrounds[week.Month()], rect{0, 0, PAGEWIDTH, PAGEHEIGHT})
+		drawBorder(pdf, borders[week.Month()], rect{0, 0, PAGEWIDTH, PAGEHEIGHT})
+
+		g.setTitleColor(pdf)
+		pdf.SetFont(calFont, "", HEADERFONTSIZE*fontScale)
+		pdf.CellFormat(PAGEWIDTH-2*MARGIN, MARGIN, fmt.Sprintf("%d", wantyear), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(BLACK, BLACK, BLACK)
+		pdf.Ln(-1)
+
+		pdf.SetFont(calFont, "", WEEKDAYFONTSIZE*fontScale)
+		pdf.SetX(MARGIN + 0.5*cw)
+		for weekday := 0; weekday <= 6; weekday++ {
+			pdf.CellFormat(cw, MARGIN, localizedWeekdayNames[(weekday+2)%7], "0", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+
+		for row := 0; row < STRIPWEEKS && !week.After(last); row++ {
+			x0, y0 := MARGIN, pdf.GetY()
+
+			if g.OptHideWeek == false {
+				pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+				pdf.SetFont(calFont, "", WEEKFONTSIZE*fontScale)
+				_, weeknr := week.ISOWeek()
+				pdf.SetXY(x0, y0)
+				pdf.CellFormat(0.5*cw, ch, fmt.Sprintf("W %d", weeknr), "", 0, "CM", false, 0, "")
+			}
+
+			for j := 0; j < COLUMNS; j++ {
+				day := week.AddDate(0, 0, j)
+				x := x0 + 0.5*cw + float64(j)*cw
+
+				inRange := !day.Before(first) && !day.After(last)
+				if !inRange {
+					pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+				} else if (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) && !g.OptNocolor {
+					pdf.SetTextColor(255, 0, 0) // RED
+				} else {
+					pdf.SetTextColor(BLACK, BLACK, BLACK)
+				}
+
+				if g.WantFill(row, j, day.Weekday()) {
+					pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
+					pdf.Rect(x, y0, cw, ch, "F")
+				} else if inRange {
+					g.shadeLiturgical(pdf, day, x, y0, cw, ch)
+				}
+				if inRange {
+					g.drawSprintBand(pdf, calFont, fontScale, day, x, y0, cw, ch, j == 0)
+				}
+				highlight := g.OptHighlightToday != "" && isToday(day)
+				if highlight {
+					g.highlightToday(pdf, x, y0, cw, ch, true)
+				}
+
+				label := fmt.Sprintf("%d", day.Day())
+				if day.Day() == 1 {
+					label += " " + localizedMonthNamesGenitive[day.Month()]
+				}
+				if g.quarterStart(day) {
+					q, _ := quarterOf(day, g.OptQuarters)
+					label += fmt.Sprintf(" Q%d", q)
+				}
+				pdf.SetXY(x, y0)
+				pdf.SetCellMargin(CELLMARGIN)
+				pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.5)
+				pdf.CellFormat(cw, ch, label, border, 0, "TL", false, 0, "")
+
+				// Day of the quarter, right above the sprint band
+				if g.OptQuarters > 0 && g.OptHideDOY == false && inRange {
+					pdf.SetFont(calFont, "", DOYFONTSIZE*fontScale*0.5)
+					doq := g.dayNumber(day)
+					pdf.Text(x+cw-CELLMARGIN-pdf.GetStringWidth(doq), y0+ch*(1-SPRINTBAND)-CELLMARGIN, doq)
+				}
+
+				if g.OptHideMoon == false {
+					m, ok := moonj[day.Format("2006-01-02")]
+					cx, cy, radius := g.moonPlace(rect{x, y0, cw, ch}, [2]float64{0.88, 0.2}, MOONSIZE*0.5)
+					if ok && g.moonSymbols() {
+						g.drawMoonSymbol(pdf, m, cx, cy, radius)
+						g.setGridStyle(pdf)
+					}
+					if ok && g.moonNames() {
+						pdf.SetFont(calFont, "", DOYFONTSIZE*fontScale*0.5)
+						_, h := pdf.GetFontSize()
+						r, gr, b := pdf.GetTextColor()
+						pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+						y := cy + radius + ASCENT*h
+						if !g.moonSymbols() {
+							y = cy
+						}
+						pdf.Text(x+cw-CELLMARGIN-pdf.GetStringWidth(moonLabels[m]), y, moonLabels[m])
+						pdf.SetTextColor(r, gr, b)
+					}
+				}
+
+				line := 0
+				eventSize := EVENTFONTSIZE * fontScale * 0.8
+				pdf.SetFont(calFont, "", eventSize)
+				var daysEvents []gDate
+				for _, ev := range eventList {
+					if !eventMatches(ev, day) {
+						continue
+					}
+					daysEvents = append(daysEvents, ev)
+					for _, t := range strings.Split(ev.Text, "\\n") {
+						drawSpans(pdf, elementFont{calFont, ""}, eventSize, x+CELLMARGIN, y0+0.55*ch+float64(line)*eventSize/3.0, parseMarkup(t))
+						line++
+					}
+				}
+				if g.OptAnnotations {
+					annotateDay(pdf, day, daysEvents, x, y0, cw, ch)
+				}
+
+				if highlight {
+					g.highlightToday(pdf, x, y0, cw, ch, false)
+				}
+
+				// The thick rule between two months: above the first
+				// week of a month and left of the first day. It is
+				// thicker between two quarters.
+				lw := pdf.GetLineWidth()
+				pdf.SetLineWidth(MONTHRULEWIDTH)
+				if g.quarterStart(day.AddDate(0, 0, 1-day.Day())) {
+					pdf.SetLineWidth(QUARTERRULEWIDTH)
+				}
+				if day.AddDate(0, 0, -7).Month() != day.Month() {
+					pdf.Line(x, y0, x+cw, y0)
+				}
+				if day.Day() == 1 && j > 0 {
+					pdf.Line(x, y0, x, y0+ch)
+				}
+				pdf.SetLineWidth(lw)
+			}
+			pdf.SetXY(MARGIN, y0+ch)
+			week = week.AddDate(0, 0, 7)
+		}
+
+		g.setFooterColor(pdf)
 		pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale)
-		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.OptFooter)*0.5, 0.95*PAGEHEIGHT, fmt.Sprintf("%s", g.OptFooter))
+		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.footer())*0.5, 0.95*PAGEHEIGHT, g.footer())
+
+		// TODO Hardcoded A4 portrait
+		myPdf{pdf, 0}.rotatedText(210.0*0.96, 297.0*0.05, 270, g.OptMargin)
 
-		pdf.TransformBegin() // TODO Hardcoded A4 portrait
-		ctrX := 210.0 * 0.96
-		ctrY := 297.0 * 0.05
-		pdf.TransformRotate(270, ctrX, ctrY)
-		pdf.Text(ctrX, ctrY, fmt.Sprintf("%s", g.OptMargin))
-		pdf.TransformEnd()
+		g.addTexts(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, textList, "")
 	}
-	pdf.OutputAndClose(docWriter(pdf, fn))
-	removeTempdir(fontTempdir)
+	g.addPageNumbers(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT)
+	g.addTraceLine(pdf, calFont, trace, PAGEHEIGHT)
+	pdf.OutputAndClose(g.docWriter(pdf, fn))
 }
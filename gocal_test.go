@@ -6,10 +6,13 @@
 package gocal_test
 
 import (
+	"encoding/base64"
+	"fmt"
 	"github.com/StefanSchroeder/Gocal"
 	"os"
 	"runtime"
 	"testing"
+	"time"
 )
 
 var outdir = "test-output" + string(os.PathSeparator)
@@ -164,3 +167,537 @@ func Test_Example21(t *testing.T) {
 	g.SetSmall()
 	g.CreateCalendar(outdir + "test-example21.pdf")
 }
+
+func Test_Example22(t *testing.T) {
+	g := gocal.New(1, 12, 2022)
+	g.SetPageNumbers("Page {page} of {pages}")
+	g.SetPageNumberPos("BC")
+	g.SetPageNumberSkip()
+	g.CreateCalendar(outdir + "test-example22.pdf")
+}
+
+func Test_Example23(t *testing.T) {
+	g := gocal.New(1, 12, 2023)
+	g.SetQuotes("test-quotes.txt")
+	g.SetQuoteFont("sans")
+	g.SetQuoteWrap()
+	g.CreateCalendar(outdir + "test-example23.pdf")
+}
+
+func Test_Example24(t *testing.T) {
+	g := gocal.New(1, 12, 2024)
+	g.AddText("{year}", -8, 20, 270, 24)
+	g.CreateYearCalendar(outdir + "test-example24.pdf")
+}
+
+func Test_Example25(t *testing.T) {
+	g := gocal.New(1, 2, 2025)
+	g.SetGridStyle("rounded")
+	g.SetGridColor("#3060a0")
+	g.SetGridWidth(0.5)
+	g.SetFillpattern("S")
+	g.CreateCalendar(outdir + "test-example25.pdf")
+}
+
+func Test_Example26(t *testing.T) {
+	g := gocal.New(1, 12, 2025)
+	g.SetGridDash("1,1")
+	g.SetGridColor("128,128,128")
+	g.CreateYearCalendar(outdir + "test-example26.pdf")
+}
+
+func Test_Example27(t *testing.T) {
+	g := gocal.New(5, 6, 2025)
+	g.SetDayNumberPos("watermark")
+	g.SetDayNumberColor("#c0d0f0")
+	g.SetFillpattern("s")
+	g.AddEvent(10, 5, "Behind is the number", "")
+	g.CreateCalendar(outdir + "test-example27.pdf")
+}
+
+func Test_Example28(t *testing.T) {
+	g := gocal.New(5, 5, 2025)
+	g.SetDayNumberPos("TR")
+	g.SetDayNumberSize(20)
+	g.CreateCalendar(outdir + "test-example28.pdf")
+}
+
+func Test_Example29(t *testing.T) {
+	now := time.Now()
+	g := gocal.New(int(now.Month()), int(now.Month()), now.Year())
+	g.SetHighlightToday("outline")
+	g.CreateCalendar(outdir + "test-example29.pdf")
+	g.SetHighlightToday("fill")
+	g.CreateYearCalendarInverse(outdir + "test-example29b.pdf")
+}
+
+func Test_Example30(t *testing.T) {
+	g := gocal.New(2, 3, 2025)
+	g.SetOtherMonths("pattern")
+	g.CreateCalendar(outdir + "test-example30.pdf")
+}
+
+func Test_Example31(t *testing.T) {
+	g := gocal.New(1, 6, 2026)
+	g.SetConfig("test-gocal.xml")
+	g.CreateContinuousCalendar(outdir + "test-example31.pdf")
+}
+
+func Test_Example32(t *testing.T) {
+	g := gocal.New(1, 1, 2024)
+	g.SetJulian()
+	g.SetLocale("de_DE")
+	g.CreateCalendar(outdir + "test-example32.pdf")
+}
+
+func Test_Example33(t *testing.T) {
+	g := gocal.New(1, 12, 2025)
+	g.SetLiturgical()
+	g.SetLiturgicalColor()
+	g.CreateCalendar(outdir + "test-example33.pdf")
+}
+
+func Test_Example34(t *testing.T) {
+	g := gocal.New(3, 4, 2025)
+	g.SetLocation("31.778,35.235")
+	g.SetTimezone("Asia/Jerusalem")
+	g.SetShabbat()
+	g.CreateCalendar(outdir + "test-example34.pdf")
+}
+
+func Test_Example35(t *testing.T) {
+	g := gocal.New(1, 1, 2025)
+	g.SetLocation("21.4225,39.8262")
+	g.SetTimezone("Asia/Riyadh")
+	g.SetPrayer()
+	g.SetPrayerMethod("Makkah")
+	g.CreateCalendar(outdir + "test-example35.pdf")
+}
+
+func Test_Example36(t *testing.T) {
+	g := gocal.New(1, 1, 2025)
+	g.SetHistory("test-history.txt")
+	g.SetHistoryLength(40)
+	g.AddEvent(7, 1, "Event", "")
+	g.CreateCalendar(outdir + "test-example36.pdf")
+}
+
+func Test_Example37(t *testing.T) {
+	g := gocal.New(1, 4, 2025)
+	g.SetFiller("sudoku")
+	g.CreateCalendar(outdir + "test-example37.pdf")
+
+	g = gocal.New(1, 2, 2025)
+	g.SetFiller("dots")
+	g.SetFillerCount(2)
+	g.CreateCalendar(outdir + "test-example37b.pdf")
+}
+
+func Test_Example38(t *testing.T) {
+	g := gocal.New(2, 3, 2025)
+	g.SetHabits("Sport, Reading, Water, Sleep 8h")
+	g.SetBudget("Rent,Food,Leisure")
+	g.CreateCalendar(outdir + "test-example38.pdf")
+}
+
+func Test_Example39(t *testing.T) {
+	g := gocal.New(1, 3, 2025)
+	g.SetSplitMonths()
+	g.CreateCalendar(outdir + "test-example39-{year}-{month}.pdf")
+}
+
+func Test_Example40(t *testing.T) {
+	g := gocal.New(5, 5, 2025)
+	g.SetElementFonts("title=sans:italic,weekday=serif:regular,day=mono:bolditalic,event=sans")
+	g.AddEvent(12, 5, "Styled event", "")
+	g.CreateCalendar(outdir + "test-example40.pdf")
+}
+
+func Test_Example41(t *testing.T) {
+	g := gocal.New(6, 6, 2025)
+	g.SetTextOutline("#ff0000,0.3")
+	g.SetTextShadow("#808080,0.8")
+	g.AddText("Summer {year}", 20, 60, 30, 24)
+	g.CreateCalendar(outdir + "test-example41.pdf")
+}
+
+func Test_Example42(t *testing.T) {
+	g := gocal.New(7, 7, 2025)
+	g.SetFillpattern("sS")
+	g.SetFillStyle("vgradient:#ffffff:#c0c0ff")
+	g.SetHeaderFill("gradient:#8080ff:#ffffff")
+	g.CreateCalendar(outdir + "test-example42.pdf")
+
+	for i, style := range []string{"stripes:#c0c0c0", "dots:#8080ff", "checks:#e0e0e0"} {
+		g = gocal.New(7, 7, 2025)
+		g.SetFillpattern("Y")
+		g.SetFillStyle(style)
+		g.SetGridStyle("rounded")
+		g.CreateCalendar(outdir + fmt.Sprintf("test-example42-%d.pdf", i))
+	}
+}
+
+func Test_Example43(t *testing.T) {
+	for _, n := range []string{"arab", "arabext", "deva"} {
+		g := gocal.New(8, 8, 2025)
+		g.SetNumerals(n)
+		g.CreateCalendar(outdir + "test-example43-" + n + ".pdf")
+	}
+}
+
+func Test_Example44(t *testing.T) {
+	g := gocal.New(6, 6, 2025)
+	g.SetLocale("gl_ES")
+	g.SetNames("test-names.txt")
+	g.CreateCalendar(outdir + "test-example44.pdf")
+}
+
+func Test_Example45(t *testing.T) {
+	g := gocal.New(5, 5, 2025)
+	g.SetDedup("keep-first")
+	g.AddEvent(1, 5, "Labour Day", "")
+	g.AddEvent(1, 5, "labour day", "")
+	g.CreateCalendar(outdir + "test-example45.pdf")
+}
+
+// The benchmarks generate a full year, e.g.
+//
+//	go test -run XXX -bench Year -benchmem
+//
+// to track the performance of the generation.
+
+func benchmarkYear(b *testing.B, setup func(g *gocal.Calendar)) {
+	os.Mkdir(outdir, 0777)
+	for i := 0; i < b.N; i++ {
+		g := gocal.New(1, 12, 2025)
+		setup(g)
+		g.CreateCalendar(outdir + "bench-year.pdf")
+	}
+}
+
+func BenchmarkYear(b *testing.B) {
+	benchmarkYear(b, func(g *gocal.Calendar) {})
+}
+
+func BenchmarkYearNoMoon(b *testing.B) {
+	benchmarkYear(b, func(g *gocal.Calendar) { g.SetHideMoon() })
+}
+
+func BenchmarkYearPhotos(b *testing.B) {
+	benchmarkYear(b, func(g *gocal.Calendar) {
+		g.SetPhotos("gocalendar" + string(os.PathSeparator) + "pics")
+	})
+}
+
+func BenchmarkYearAstronomy(b *testing.B) {
+	benchmarkYear(b, func(g *gocal.Calendar) {
+		g.SetLocation("48.85,2.35")
+		g.SetShabbat()
+		g.SetPrayer()
+	})
+}
+
+func Test_Example46(t *testing.T) {
+	g := gocal.New(3, 3, 2025)
+	for i := 1; i <= 8; i++ {
+		g.AddEvent(14, 3, fmt.Sprintf("Meeting %d", i), "")
+	}
+	g.AddEvent(17, 3, "Two\\nlines", "")
+	g.CreateCalendar(outdir + "test-example46.pdf")
+}
+
+func Test_Example47(t *testing.T) {
+	g := gocal.New(10, 12, 2025)
+	g.SetConfig("test-styles.xml")
+	g.CreateCalendar(outdir + "test-example47.pdf")
+}
+
+func Test_Example48(t *testing.T) {
+	g := gocal.New(5, 5, 2025)
+	g.AddEvent(12, 5, "*Exam* _room 4_", "")
+	g.AddEvent(20, 5, "Pay\\n*rent*", "")
+	g.SetElementFonts("event=sans:regular")
+	g.AddEvent(22, 5, "*Core* _fonts_", "")
+	g.CreateCalendar(outdir + "test-example48.pdf")
+}
+
+func Test_Example49(t *testing.T) {
+	g := gocal.New(10, 10, 2025)
+	g.SetConfig("test-styles.xml")
+	g.SetAnnotations()
+	g.CreateCalendar(outdir + "test-example49.pdf")
+}
+
+func Test_Example50(t *testing.T) {
+	g := gocal.New(1, 2, 2025)
+	g.SetConfig("test-gocal.xml")
+	g.SetPhotos("gocalendar" + string(os.PathSeparator) + "pics")
+	g.SetLayers()
+	g.CreateCalendar(outdir + "test-example50.pdf")
+}
+
+func Test_Example51(t *testing.T) {
+	g := gocal.New(10, 10, 2025)
+	g.SetConfig("test-styles.xml")
+	g.SetPhotos("gocalendar" + string(os.PathSeparator) + "pics")
+	g.SetFillStyle("gradient:#ffcc00:#3366ff")
+	g.SetFillpattern("sS")
+	g.SetGrayscale(0)
+	g.CreateCalendar(outdir + "test-example51.pdf")
+}
+
+func Test_Example52(t *testing.T) {
+	g := gocal.New(2, 2, 2025)
+	g.SetBraille()
+	g.CreateCalendar(outdir + "test-example52.pdf")
+}
+
+func Test_Example53(t *testing.T) {
+	g := gocal.New(1, 12, 2025)
+	g.AddDiff("test-diff-old.xml", "test-diff-new.xml")
+	g.CreateCalendar(outdir + "test-example53.pdf")
+}
+
+func Test_Example54(t *testing.T) {
+	data, err := os.ReadFile("golang-gopher.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"gopher-b.png", "gopher-c.png"} {
+		os.WriteFile(outdir+f, data, 0644)
+	}
+	g := gocal.New(2, 2, 2025)
+	g.AddEvent(14, 2, "Anna", "golang-gopher.png")
+	g.AddEvent(14, 2, "Ben", outdir+"gopher-b.png")
+	g.AddEvent(20, 2, "Twins", "golang-gopher.png,"+outdir+"gopher-b.png,"+outdir+"gopher-c.png")
+	g.CreateCalendar(outdir + "test-example54.pdf")
+}
+
+func Test_Example55(t *testing.T) {
+	g := gocal.New(3, 3, 2025)
+	g.SetPhoto("test-logo.svg")
+	g.AddEvent(8, 3, "Logo", "test-logo.svg")
+	g.CreateCalendar(outdir + "test-example55.pdf")
+}
+
+func Test_Example56(t *testing.T) {
+	// 1x1 lossy WebP
+	data, _ := base64.StdEncoding.DecodeString("UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA")
+	os.WriteFile(outdir+"pixel.webp", data, 0644)
+	g := gocal.New(4, 4, 2025)
+	g.SetPhoto(outdir + "pixel.webp")
+	g.AddEvent(1, 4, "WebP", outdir+"pixel.webp")
+	g.CreateCalendar(outdir + "test-example56.pdf")
+}
+
+func Test_Example57(t *testing.T) {
+	g := gocal.New(5, 5, 2025)
+	g.SetConfig("test-images.xml")
+	g.SetPhoto("gocalendar" + string(os.PathSeparator) + "pics" + string(os.PathSeparator) + "taxi.JPG")
+	g.SetPhotoFit("fill")
+	g.CreateCalendar(outdir + "test-example57.pdf")
+}
+
+func Test_Example58(t *testing.T) {
+	g := gocal.New(5, 6, 2025)
+	g.SetConfig("test-images.xml")
+	g.SetPhoto("gocalendar" + string(os.PathSeparator) + "pics" + string(os.PathSeparator) + "taxi.JPG")
+	g.SetCaptionPos("topleft")
+	g.SetCaptionStyle("band")
+	g.CreateCalendar(outdir + "test-example58.pdf")
+}
+
+func Test_Example59(t *testing.T) {
+	g := gocal.New(1, 12, 2025)
+	g.SetBackground("seasonal")
+	g.SetBorder("hearts:#ffc0c0,12=stars:#e0c000")
+	g.CreateCalendar(outdir + "test-example59.pdf")
+}
+
+func Test_Example60(t *testing.T) {
+	g := gocal.New(1, 12, 2025)
+	g.SetSeasonTheme("south")
+	g.SetBackground("seasonal")
+	g.SetGridColor("#808080")
+	g.CreateCalendar(outdir + "test-example60.pdf")
+}
+
+func Test_Example61(t *testing.T) {
+	g := gocal.New(1, 3, 2026)
+	g.SetBrand("test-brand.xml")
+	g.SetPageNumbers("{page}")
+	g.SetPageNumberSkip()
+	g.CreateCalendar(outdir + "test-example61.pdf")
+}
+
+func Test_Example62(t *testing.T) {
+	recipients, err := gocal.ReadRecipients("test-recipients.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recipients {
+		g := gocal.New(1, 12, 2026)
+		g.SetConfig("test-gocal.xml")
+		g.SetFooter("Made for {name}")
+		g.SetRecipient(r)
+		g.CreateCalendar(outdir + "test-example62-{name}.pdf")
+	}
+}
+
+func Test_Example63(t *testing.T) {
+	recipients, err := gocal.ReadRecipients("test-recipients.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gocal.New(1, 2, 2026)
+	g.SetAddressBlock("din")
+	g.SetReturnAddress("Gopher Supplies Ltd., 1 Go Way, 10001 Gotown")
+	g.SetRecipient(recipients[0])
+	g.CreateCalendar(outdir + "test-example63.pdf")
+}
+
+func Test_Example64(t *testing.T) {
+	g := gocal.New(1, 3, 2026)
+	g.SetPhoto("gocalendar" + string(os.PathSeparator) + "pics" + string(os.PathSeparator) + "taxi.JPG")
+	g.SetCaption("{{.Month}} {{.Year}}")
+	g.SetWallBound()
+	g.CreateCalendar(outdir + "test-example64.pdf")
+}
+
+func Test_Example65(t *testing.T) {
+	g := gocal.New(1, 2, 2026)
+	g.SetPDFX("srgb")
+	g.SetPhoto("gocalendar" + string(os.PathSeparator) + "pics" + string(os.PathSeparator) + "taxi.JPG")
+	g.CreateCalendar(outdir + "test-example65.pdf")
+}
+
+func Test_Example66(t *testing.T) {
+	g := gocal.New(1, 2, 2026)
+	g.SetTrace("Gocal test")
+	g.CreateCalendar(outdir + "test-example66.pdf")
+}
+
+func Test_Example67(t *testing.T) {
+	g := gocal.New(1, 12, 2026)
+	g.SetStream(3)
+	g.SetPageNumbers("{page}/{pages}")
+	g.CreateCalendar(outdir + "test-example67.pdf")
+}
+
+func Test_Example68(t *testing.T) {
+	g := gocal.New(3, 3, 2026)
+	g.AddFile("test-todos.ics", []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"+
+		"BEGIN:VTODO\r\nUID:1\r\nDUE;VALUE=DATE:20260310\r\nSUMMARY:File taxes\r\nEND:VTODO\r\n"+
+		"BEGIN:VTODO\r\nUID:2\r\nDUE:20260317T120000Z\r\nSTATUS:COMPLETED\r\nSUMMARY:Renew passport\r\nEND:VTODO\r\n"+
+		"BEGIN:VJOURNAL\r\nUID:3\r\nDTSTART;VALUE=DATE:20260320\r\nSUMMARY:First day of spring\r\nEND:VJOURNAL\r\n"+
+		"END:VCALENDAR\r\n"))
+	g.AddICS("test-todos.ics")
+	g.SetICSInclude("todo,journal")
+	g.SetICSColors("red", "gray")
+	g.CreateCalendar(outdir + "test-example68.pdf")
+}
+
+func Test_Example69(t *testing.T) {
+	g := gocal.New(3, 3, 2026)
+	g.AddFile("test-work.ics", []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nX-WR-CALNAME:Work\r\nX-APPLE-CALENDAR-COLOR:#1BADF8\r\n"+
+		"BEGIN:VEVENT\r\nUID:1\r\nDTSTART;VALUE=DATE:20260310\r\nDTEND;VALUE=DATE:20260311\r\nSUMMARY:Review\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	g.AddFile("test-family.ics", []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nX-WR-CALNAME:Family\r\nX-APPLE-CALENDAR-COLOR:#CC73E1\r\n"+
+		"BEGIN:VEVENT\r\nUID:2\r\nDTSTART;VALUE=DATE:20260314\r\nDTEND;VALUE=DATE:20260315\r\nSUMMARY:Birthday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	g.AddICS("test-work.ics")
+	g.AddICS("test-family.ics")
+	g.SetLegend()
+	g.SetFooter("Gocal")
+	g.CreateCalendar(outdir + "test-example69.pdf")
+}
+
+func Test_Example70(t *testing.T) {
+	g := gocal.New(2, 3, 2026)
+	g.SetDatePatterns("friday13,repdigit")
+	g.AddDatePattern("Payday", func(d time.Time) bool { return d.Day() == 25 })
+	g.CreateCalendar(outdir + "test-example70.pdf")
+}
+
+func Test_Example71(t *testing.T) {
+	g := gocal.New(1, 3, 2026)
+	g.SetQuarters(1)
+	g.SetSprints("2025-12-29", 14)
+	g.CreateContinuousCalendar(outdir + "test-example71.pdf")
+}
+
+func Test_Example72(t *testing.T) {
+	g := gocal.New(3, 4, 2026)
+	g.AddProject("Website relaunch", "2026-03-09", "2026-04-17", "teal")
+	g.AddProject("Audit", "2026-03-30", "2026-04-03", "#e0a030")
+	g.AddEvent(10, 3, "Kickoff", "")
+	g.CreateCalendar(outdir + "test-example72.pdf")
+}
+
+func Test_Example73(t *testing.T) {
+	g := gocal.New(5, 6, 2026)
+	g.AddFile("test-vacation.xml", []byte(`<Gocal>
+  <Gocaldate date="5/14" text="Ascension Day" category="holiday" />
+  <Gocaldate date="5/11-5/15" text="Vacation" category="vacation" />
+  <Gocaldate date="6/1-6/5" text="Vacation" category="vacation" />
+</Gocal>`))
+	g.AddConfig("test-vacation.xml")
+	g.SetVacation(30)
+	g.SetFooter("Gocal")
+	g.CreateCalendar(outdir + "test-example73.pdf")
+}
+
+func Test_Example74(t *testing.T) {
+	g := gocal.New(3, 3, 2026)
+	g.SetWritable()
+	g.SetFiller("squared")
+	g.SetNotes("ruled:7")
+	g.CreateCalendar(outdir + "test-example74.pdf")
+}
+
+func Test_Example75(t *testing.T) {
+	g := gocal.New(1, 3, 2027)
+	g.AddFile("test-birthdays.xml", []byte(`<Gocal>
+  <Gocaldate date="2/29" text="Leap day birthday" />
+  <Gocaldate date="2/14" text="Anniversary" />
+  <Gocaldate date="2/14" text="Aunt *Mary*" />
+  <Gocaldate date="Monday" text="Weekly" />
+</Gocal>`))
+	g.AddConfig("test-birthdays.xml")
+	g.CreatePerpetualCalendar(outdir + "test-example75.pdf")
+}
+
+func Test_Example76(t *testing.T) {
+	g := gocal.New(4, 4, 2026)
+	g.SetLocale("fr_FR")
+	g.SetMoonLabels("names")
+	g.CreateCalendar(outdir + "test-example76.pdf")
+}
+
+func Test_Example77(t *testing.T) {
+	g := gocal.New(8, 8, 2026)
+	g.AddFile("test-sky.json", []byte(`[
+  {"date": "2026-08-12", "kind": "meteor", "text": "Perseids"},
+  {"date": "2026-08-12", "kind": "iss", "text": "ISS pass", "time": "21:14"},
+  {"date": "2026-08-04", "kind": "opposition", "text": "Saturn at opposition"},
+  {"date": "2026-08-18", "kind": "conjunction", "text": "Venus and Jupiter"},
+  {"date": "2026-08-25", "kind": "comet", "text": "Comet"},
+  {"date": "2026-08-28", "kind": "planet", "text": "Mercury at elongation"}
+]`))
+	g.AddEphemeris("test-sky.json")
+	g.CreateCalendar(outdir + "test-example77.pdf")
+}
+
+func Test_Example78(t *testing.T) {
+	g := gocal.New(5, 5, 2026)
+	g.SetMoonAnchor("C")
+	g.SetMoonSize("40%")
+	g.SetMoonOpacity(0.3)
+	g.CreateCalendar(outdir + "test-example78.pdf")
+}
+
+func Test_Example79(t *testing.T) {
+	g := gocal.New(4, 4, 2026)
+	g.AddEvent(22, 4, "Earth day", "")
+	g.SetCompanionICS(true)
+	g.SetArtworkURL("https://example.org/calendars/")
+	g.CreateCalendar(outdir + "test-example79.pdf")
+}
@@ -6,11 +6,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/StefanSchroeder/Gocal"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -31,44 +42,385 @@ var configFiles arrayFlags
 // A list of options on the cmdline for ICS events
 var icsFiles arrayFlags
 
+// A list of prefixes of the events of sources, source=prefix
+var sourcePrefixes arrayFlags
+
+// A list of ephemeris files with astronomical events
+var ephemerisFiles arrayFlags
+
 const VERSION = "0.9 the Unready"
 
+// ENVPREFIX is the prefix of the environment variables for options.
+const ENVPREFIX = "GOCAL_"
+
+var optOptions = flag.String("options", "", "Options file with lines name=value")
+var optBatch = flag.String("batch", "", "Manifest file with one calendar job per line")
+var optRecipients = flag.String("recipients", "", "CSV file with one personalized calendar per row")
+var optAddressBlock = flag.String("addressblock", "", "Back cover with the address for windowed envelopes (din dina us10 x,y)")
+var optReturnAddress = flag.String("returnaddress", "", "Return address above the address block")
+var optTrace = flag.Bool("trace", false, "Tiny line with version, configuration hash and date on every page")
+var optStream = flag.Int("stream", 0, "Render the monthly calendar in parts of n months to save memory")
+var optPDFX = flag.String("pdfx", "", "PDF/X-4 with the output intent of an ICC profile file or srgb")
+var optWallBound = flag.Bool("wallbound", false, "Wire-O wall calendar: photos on the backs, upside down, hanger hole marked")
+var optKeepTemp = flag.Bool("keep-temp", false, "Keep temporary files for debugging")
+var optStrict = flag.Bool("strict", false, "Malformed dates, unknown fields and failed downloads are errors")
+var optReport = flag.String("report", "", "Write a JSON report of the generated files to this file")
+var optCheckGrid = flag.Bool("checkgrid", false, "Print and check the weekdays of the grid of the months instead of a calendar")
+var optExportICS = flag.String("exportics", "", "Write the events of the calendar to this ICS file")
+var optExportJSON = flag.String("exportjson", "", "Write the events of the calendar to this JSON file")
+var optExportCSV = flag.String("exportcsv", "", "Write the events of the calendar to this CSV file")
+var optCompanionICS = flag.Bool("companionics", false, "Also write an ICS file with the events and moon phases next to the PDF")
+var optArtworkURL = flag.String("artworkurl", "", "Address of the published PDF and images for the links of the ICS file")
+var optTimeout = flag.Duration("timeout", 10*time.Second, "Timeout of downloads")
+var optRetries = flag.Int("retries", 3, "Number of retries of failed downloads")
+var optMaxSize = flag.Int64("maxsize", 50, "Maximum size of a download in MB")
+var optCACert = flag.String("cacert", "", "PEM file with additional CA certificates")
+var optInsecure = flag.Bool("insecure", false, "Don't verify TLS certificates of downloads")
+var optNoRemote = flag.Bool("noremote", false, "Don't download images, ICS calendars and holidays")
+var optAllowHosts = flag.String("allowhosts", "", "Comma separated hosts that downloads may come from, e.g. *.example.com")
+var optMaxImageSize = flag.Int64("maximagesize", 0, "Maximum size of a downloaded image in MB, 0 for -maxsize")
+var optMaxICSSize = flag.Int64("maxicssize", 0, "Maximum size of a downloaded ICS calendar in MB, 0 for -maxsize")
+var optNoFontCache = flag.Bool("nofontcache", false, "Don't cache converted fonts")
+
 var optFont = flag.String("font", "serif", "Font")
 var optFontScale = flag.Float64("fontscale", 1.0, "Font")
+var optElementFonts = flag.String("fonts", "", "Font per element, e.g. \"title=sans:italic,day=serif:regular\"")
+var optTextOutline = flag.String("outline", "", "Outline of titles, color and width, e.g. \"#ffffff,0.4\"")
+var optTextShadow = flag.String("shadow", "", "Shadow of titles, color and offset, e.g. \"#808080,0.8\"")
 var optYearSpread = flag.Int("spread", 1, "Spread year over multiple pages")
 var optFooter = flag.String("footer", "Gocal", "Footer note")
 var optHideDOY = flag.Bool("nodoy", false, "Hide day of year (false)")
 var optPlain = flag.Bool("plain", false, "Hide everything")
+var optWritable = flag.Bool("writable", false, "Room for handwriting: small day numbers, no moon, thin grid")
 var optHideEvents = flag.Bool("noevents", false, "Hide events from config file (false)")
 var optHideMoon = flag.Bool("nomoon", false, "Hide moon phases (false)")
+var optMoonLabels = flag.String("moonlabels", "symbols", "Moon phases as symbols, names (symbol and name) or text (name only)")
+var optMoonAnchor = flag.String("moonpos", "TR", "Position of the moon in the cell (TL TR BL BR C)")
+var optMoonSize = flag.String("moonsize", "", "Diameter of the moon in mm, e.g. 5, or relative to the cell, e.g. 20%")
+var optMoonOpacity = flag.Float64("moonopacity", 1.0, "Opacity of the moon from 0 to 1")
 var optHideWeek = flag.Bool("noweek", false, "Hide week number (false)")
 var optLocale = flag.String("lang", "", "Language")
+var optNames = flag.String("names", "", "File with custom month and weekday names")
+var optNumerals = flag.String("numerals", "latn", "Numerals of the day numbers (latn arab arabext deva auto)")
+var optNumeralFont = flag.String("numeralfont", "", "TTF with the digits of the numerals")
 var optOrientation = flag.String("p", "P", "Orientation (L)andscape/(P)ortrait")
 var optPaper = flag.String("paper", "A4", "Paper format (A3 A4 A5 Letter Legal)")
 var optPhoto = flag.String("photo", "", "Show photo (single image PNG JPG GIF)")
 var optPhotos = flag.String("photos", "", "Show photos (directory PNG JPG GIF)")
+var optPhotoOrder = flag.String("photoorder", "name", "Assign the photos to the months by (name pattern exif random)")
+var optPhotoSeed = flag.Int64("photoseed", 0, "Seed of the random photo order")
 var optWallpaper = flag.String("wall", "", "Show wallpaper PNG JPG GIF")
-var outfilename = flag.String("o", "output.pdf", "Output filename")
+var outfilename = flag.String("o", "output.pdf", "Output filename, may contain {year} {month} {locale}, - for stdout")
 var optSmall = flag.Bool("small", false, "Smaller fonts")
 var optHideOtherMonths = flag.Bool("noother", false, "Hide neighboring month days")
+var optOtherMonths = flag.String("other", "gray", "Neighboring month days (gray blank pattern)")
 var optNocolor = flag.Bool("nocolor", false, "Sundays and Saturdays in black, instead of red.")
 var optYearA = flag.Bool("yearA", false, "Year calendar (design A)")
 var optYearB = flag.Bool("yearB", false, "Year calendar (design B)")
+var optStrip = flag.Bool("strip", false, "Continuous weeks (planner strip)")
+var optPerpetual = flag.Bool("perpetual", false, "Perpetual calendar without a year, a list of 31 days per month")
+var optQuarters = flag.Int("quarters", 0, "Number the days by quarter, the quarters from this month (1 or e.g. 4 for a fiscal year from April)")
+var optSprintStart = flag.String("sprintstart", "", "Start of the first sprint as YYYY-MM-DD, shown in the planner strip")
+var optSprintDays = flag.Int("sprintdays", 14, "Length of the sprints in days")
+var optJulian = flag.Bool("julian", false, "Add the Julian calendar date (old style)")
+var optDedup = flag.String("dedup", "keep-all", "Duplicate events (keep-first merge keep-all)")
+var optLeapDay = flag.String("leapday", "skip", "Events on 2/29 in other years (skip feb28 mar1)")
+var optSanitize = flag.String("sanitize", "", "Cleanups of the texts of ICS events (controls urls templates all)")
+var optEventLength = flag.Int("eventlen", 0, "Maximum length of the texts of ICS events, 0 for no limit")
+var optICSInclude = flag.String("icsinclude", "", "Also show the to-dos and journal entries of ICS files (todo journal)")
+var optTodoColor = flag.String("todocolor", "", "Color of the to-dos of ICS files")
+var optJournalColor = flag.String("journalcolor", "", "Color of the journal entries of ICS files")
+var optICSTentative = flag.String("tentative", "show", "Tentative events of ICS files (show dim skip)")
+var optICSTransp = flag.String("transp", "all", "Events of ICS files by how they show the time (all busy free)")
+var optLegend = flag.Bool("legend", false, "Print the names and colors of the ICS calendars below the months")
+var optLiturgical = flag.Bool("liturgical", false, "Add the feasts of the liturgical year")
+var optLiturgicalColor = flag.Bool("liturgicalcolor", false, "Shade days in the liturgical color")
+var optLocation = flag.String("location", "", "Location as \"latitude,longitude\"")
+var optTimezone = flag.String("tz", "", "Time zone of the location, e.g. \"Asia/Jerusalem\"")
+var optAstro = flag.Bool("astro", false, "Add equinoxes, solstices and eclipses")
+var optAstroIcons = flag.String("astroicons", "", "Icons of the kinds of ephemeris events, e.g. \"meteor=meteor.png,iss=S\"")
+var optAnnotations = flag.Bool("annotations", false, "Attach event descriptions as PDF annotations")
+var optLayers = flag.Bool("layers", false, "Put grid, events, photos and astronomy on PDF layers")
+var optBraille = flag.Bool("braille", false, "Add day numbers in Braille (experimental)")
+var optPhotoFit = flag.String("photofit", "stretch", "How photos fill their area (stretch fill)")
+var optEnhance = flag.Bool("enhance", false, "Enhance levels, white balance and sharpness of the photos")
+var optCaption = flag.String("caption", "", "Caption of the photos, e.g. \"{{.Month}} {{.Year}}\"")
+var optCaptionPos = flag.String("captionpos", "bottomright", "Position of the captions (topleft top topright bottomleft bottom bottomright)")
+var optCaptionStyle = flag.String("captionstyle", "light", "Style of the captions (light dark band)")
+var optDayImages = flag.Int("dayimages", gocal.DAYIMAGES, "Maximum number of images in a day cell, 0 for any")
+var optGrayscale = flag.Bool("grayscale", false, "Grayscale output for black-and-white printing")
+var optContrast = flag.Float64("contrast", gocal.GRAYCONTRAST, "Contrast of the grayscale output, 1 keeps the luminance")
+var optShabbat = flag.Bool("shabbat", false, "Add candle-lighting and Havdalah times, as events of the monthly and continuous layouts")
+var optCandles = flag.Int("candles", gocal.CANDLELIGHTING, "Minutes of candle lighting before sunset")
+var optHavdalah = flag.Int("havdalah", gocal.HAVDALAH, "Minutes of Havdalah after sunset")
+var optPrayer = flag.Bool("prayer", false, "Add Islamic prayer times to the days of the monthly layout")
+var optPrayerMethod = flag.String("prayermethod", "MWL", "Prayer time method (MWL ISNA Egypt Makkah Karachi Tehran)")
+var optPrayerHanafi = flag.Bool("hanafi", false, "Hanafi rule for Asr")
+var optPanchang = flag.Bool("panchang", false, "Add tithi and nakshatra of the Hindu Panchang")
+var optPatterns = flag.String("patterns", "", "Label interesting dates (friday13 palindrome repdigit), e.g. friday13=skull.png")
+var optHistory = flag.String("history", "", "Fill empty days from an 'on this day' file")
+var optHistoryLength = flag.Int("historylen", 60, "Maximum length of a fact per day")
+var optFiller = flag.String("filler", "", "Filler pages between months (blank ruled dots squared sudoku), rulings as style:spacing:weight")
+var optFillerCount = flag.Int("fillercount", 1, "Number of filler pages between months")
+var optHabits = flag.String("habits", "", "Habit tracker page, comma separated habits")
+var optBudget = flag.String("budget", "", "Budget table on the tracker page, comma separated items")
+var optNotes = flag.String("notes", "", "Notes area on the tracker page (blank ruled dots squared), as style:spacing:weight")
+var optSplitMonths = flag.Bool("split", false, "Write one PDF per month in addition to the combined one")
 var optFillpattern = flag.String("fill", "", "Set grid fill pattern.")
+var optBackground = flag.String("background", "", "Background motif, e.g. seasonal or 12=stars:#ffe080")
+var optBrand = flag.String("brand", "", "XML file with the Gocalbrand of a company (logo, colors, font, cover page)")
+var optSeasonTheme = flag.String("seasontheme", "", "Color the months by season for the hemisphere (north south auto)")
+var optBorder = flag.String("border", "", "Border motif along the page edges, like -background")
+var optFillStyle = flag.String("fillstyle", "", "Fill of the -fill cells, e.g. \"gradient:#ffffff:#c0c0ff\"")
+var optHeaderFill = flag.String("headerfill", "", "Bar behind the month title, e.g. \"vgradient:#8080ff:#ffffff\"")
 var optVersion = flag.Bool("v", false, "Version.")
 var optMargin = flag.String("margin", "", "Margin comment")
 var optHoliday = flag.Bool("holiday", false, "Download public holidays.")
+var optHolidayCountry = flag.String("holidaycountry", gocal.HOLIDAYCOUNTRY, "Country of the holidays, e.g. DE")
+var optBridgeDays = flag.String("bridgedays", "", "Mark the bridge days between holidays and weekends with this label")
+var optBridgeFill = flag.String("bridgefill", "", "Fill of the cells of the bridge days")
+var optVacation = flag.Int("vacation", 0, "Vacation allowance in days, the used days are printed in the footer")
+var optVacationText = flag.String("vacationtext", gocal.VACATIONTEXT, "Text of the vacation budget with {used} {allowance} {left}")
+var optPageNumbers = flag.String("pagenum", "", "Page number format, e.g. \"Page {page} of {pages}\"")
+var optPageNumberPos = flag.String("pagenumpos", "BR", "Page number position (T/B + L/C/R)")
+var optPageNumberStart = flag.Int("pagenumstart", 1, "First page number")
+var optPageNumberSkip = flag.Bool("pagenumskip", false, "No page number on the cover page")
+var optQuotes = flag.String("quotes", "", "Text file with one quote per month")
+var optQuoteFont = flag.String("quotefont", "", "Font of the quotes")
+var optQuoteWrap = flag.Bool("quotewrap", false, "Wrap long quotes instead of shrinking them")
+var optGridWidth = flag.Float64("gridwidth", 0.0, "Grid line width in mm")
+var optGridDash = flag.String("griddash", "", "Grid dash pattern in mm, e.g. \"2,1\"")
+var optGridColor = flag.String("gridcolor", "", "Grid color, e.g. \"#808080\"")
+var optGridStyle = flag.String("gridstyle", "lines", "Grid style (lines none rounded)")
+var optDayNumberPos = flag.String("daypos", "TL", "Day number position (TL TR C watermark)")
+var optDayNumberSize = flag.Float64("daysize", 0.0, "Day number font size in points")
+var optDayNumberColor = flag.String("daycolor", "", "Day number color, e.g. \"#204080\"")
+var optHighlightToday = flag.String("today", "", "Highlight today (outline fill)")
+var optTodayColor = flag.String("todaycolor", "", "Color to highlight today")
 
 func main() {
-	flag.Var(&configFiles, "config", "Configuration XML files.")
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "shift" {
+		runShift(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+	flag.Var(&configFiles, "config", "Configuration XML files, - for stdin.")
+	flag.Var(&configFiles, "events", "Configuration XML files, - for stdin (same as -config).")
 	flag.Var(&icsFiles, "ics", "Calendar ICS files.")
+	flag.Var(&sourcePrefixes, "prefix", "Prefix of the events of a source, e.g. work.ics=W: or holidays=*")
+	flag.Var(&ephemerisFiles, "ephemeris", "Ephemeris CSV or JSON files with astronomical events (date,kind,text,time)")
 	flag.Parse()
+	applyDefaults(flag.CommandLine)
 
 	if *optVersion {
 		fmt.Printf("# Gocal version %s\n", VERSION)
 		os.Exit(0)
 	}
 
+	// The PDF goes to stdout, all messages to stderr.
+	if *outfilename == "-" {
+		os.Stdout = os.Stderr
+	}
+
+	gocal.SetKeepTemp(*optKeepTemp)
+	setDownloadOptions()
+	gocal.SetRemotePolicy(remotePolicy(*optNoRemote, *optAllowHosts, *optMaxImageSize, *optMaxICSSize))
+	cleanupOnInterrupt()
+
+	if *optBatch != "" {
+		runBatch(*optBatch)
+	} else {
+		runJob()
+	}
+	if *optReport != "" {
+		writeReport(*optReport)
+	}
+	if exitCode != gocal.ExitOK {
+		os.Exit(exitCode)
+	}
+}
+
+// exitCode is the exit code of the errors of the calendars that
+// didn't stop the run, see gocal.Calendar.ExitCode.
+var exitCode = gocal.ExitOK
+
+// setDownloadOptions sets the options of the downloads and of the
+// font cache, for the calendars and the server.
+func setDownloadOptions() {
+	gocal.SetHTTPOptions(*optTimeout, *optRetries, *optMaxSize<<20)
+	gocal.SetTLSOptions(*optCACert, *optInsecure)
+	gocal.SetFontCache(!*optNoFontCache)
+}
+
+// cleanupOnInterrupt removes the temporary files when the program is
+// interrupted.
+func cleanupOnInterrupt() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		fmt.Fprintf(os.Stderr, "# Interrupted (%v), cleaning up\n", sig)
+		gocal.Cleanup()
+		os.Exit(1)
+	}()
+}
+
+// fatalf logs the error and exits with one of the exit codes
+// of gocal, see gocal.ExitConfig etc.
+func fatalf(code int, format string, v ...interface{}) {
+	gocal.Cleanup()
+	log.Printf(format, v...)
+	os.Exit(code)
+}
+
+// writeReport writes the report of the run as JSON.
+func writeReport(filename string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(gocal.GetReport()); err != nil {
+		fatalf(gocal.ExitError, "# Error writing report: %v", err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		fatalf(gocal.ExitError, "# Error writing report: %v", err)
+	}
+}
+
+// runDiff prints the changes of the events between two configuration
+// files: gocalendar diff [-year Y] [-lang L] [-pdf F] old.xml new.xml.
+// With -pdf it creates a calendar of the new file with the changes
+// marked.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	year := fs.Int("year", time.Now().Year(), "Year of the events")
+	lang := fs.String("lang", "", "Language of the weekday names")
+	pdf := fs.String("pdf", "", "Calendar with the changes marked")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gocalendar diff [options] old.xml new.xml\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(gocal.ExitConfig)
+	}
+	g := gocal.New(1, 12, *year)
+	g.SetLocale(*lang)
+	changes := g.AddDiff(fs.Arg(0), fs.Arg(1))
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if *pdf != "" {
+		g.CreateCalendar(*pdf)
+	}
+	if code := g.ExitCode(); code != gocal.ExitOK {
+		os.Exit(code)
+	}
+}
+
+// runShift writes the configuration of the next year to standard
+// output: gocalendar shift [-from Y] [-to Y] [-lang L] [-weekdays]
+// file, a configuration or an ICS file of the year before.
+func runShift(args []string) {
+	fs := flag.NewFlagSet("shift", flag.ExitOnError)
+	to := fs.Int("to", time.Now().Year()+1, "Year of the new configuration")
+	from := fs.Int("from", 0, "Year of the file (default: the year before -to)")
+	lang := fs.String("lang", "", "Language of the weekday names")
+	weekdays := fs.Bool("weekdays", false, "Keep the weekday of fixed dates, e.g. the 2nd Tuesday")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gocalendar shift [options] events.xml|events.ics\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(gocal.ExitConfig)
+	}
+	if *from == 0 {
+		*from = *to - 1
+	}
+	var warnings []gocal.Warning
+	var err error
+	if strings.HasSuffix(strings.ToLower(fs.Arg(0)), ".ics") {
+		warnings, err = gocal.ShiftICS(os.Stdout, fs.Arg(0), *from, *to, *weekdays)
+	} else {
+		warnings, err = gocal.ShiftConfig(os.Stdout, fs.Arg(0), *lang, *from, *to, *weekdays)
+	}
+	// The configuration goes to stdout, the warnings to stderr.
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "# %s\n", w.Message)
+	}
+	if err != nil {
+		fatalf(gocal.ExitConfig, "# Error reading %v: %v", fs.Arg(0), err)
+	}
+}
+
+// runServe serves the job API of gocal.JobServer: gocalendar serve
+// [-addr A] [-workers N] [-dir D] [-quota MB] [-keep D] [-noremote]
+// [-allowhosts H] [-maximagesize MB] [-maxicssize MB] and the options
+// of the downloads, -strict and -options. The environment variables
+// and the options file set them like for the calendars.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	for _, name := range []string{"options", "strict", "timeout", "retries", "maxsize", "cacert", "insecure", "nofontcache"} {
+		f := flag.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	workers := fs.Int("workers", 2, "Number of calendars created at the same time")
+	dir := fs.String("dir", filepath.Join(os.TempDir(), "gocal-jobs"), "Directory of the jobs")
+	quota := fs.Int64("quota", 500, "Disk quota of all jobs in MB")
+	keep := fs.Duration("keep", 24*time.Hour, "Time to keep finished jobs")
+	noRemote := fs.Bool("noremote", false, "Don't download images, ICS calendars and holidays")
+	allowHosts := fs.String("allowhosts", "", "Comma separated hosts that downloads may come from, e.g. *.example.com")
+	maxImageSize := fs.Int64("maximagesize", 0, "Maximum size of a downloaded image in MB, 0 for 50")
+	maxICSSize := fs.Int64("maxicssize", 0, "Maximum size of a downloaded ICS calendar in MB, 0 for 50")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gocalendar serve [options]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	applyDefaults(fs)
+	if fs.NArg() != 0 || *workers < 1 {
+		fs.Usage()
+		os.Exit(gocal.ExitConfig)
+	}
+	setDownloadOptions()
+	gocal.SetRemotePolicy(remotePolicy(*noRemote, *allowHosts, *maxImageSize, *maxICSSize))
+	cleanupOnInterrupt()
+	js, err := gocal.NewJobServer(*dir, *workers, *quota<<20, *keep)
+	if err != nil {
+		fatalf(gocal.ExitError, "# Error creating the job directory: %v", err)
+	}
+	js.SetStrict(*optStrict)
+	fmt.Printf("# Serving the job API on http://%s/jobs\n", *addr)
+	if err := http.ListenAndServe(*addr, js); err != nil {
+		fatalf(gocal.ExitError, "# Error serving: %v", err)
+	}
+}
+
+// remotePolicy returns the policy of the download options, with the
+// sizes in MB.
+func remotePolicy(noRemote bool, allowHosts string, maxImageSize, maxICSSize int64) gocal.RemotePolicy {
+	p := gocal.RemotePolicy{Deny: noRemote, MaxImageSize: maxImageSize << 20, MaxICSSize: maxICSSize << 20}
+	for _, h := range strings.Split(allowHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			p.Hosts = append(p.Hosts, h)
+		}
+	}
+	return p
+}
+
+// run creates the calendar of the parsed command line.
+func run() {
 	wantyear := int(time.Now().Year())
 	beginmonth := 1
 	endmonth := 12
@@ -92,11 +444,28 @@ func main() {
 	}
 
 	g := gocal.New(beginmonth, endmonth, wantyear)
+	defer func() {
+		if code := g.ExitCode(); code != gocal.ExitOK {
+			exitCode = code
+		}
+	}()
+	g.SetStrict(*optStrict)
 	g.SetFont(*optFont)
 	g.SetOrientation(*optOrientation)
 	g.SetPaperformat(*optPaper)
+	if *optLocale != "" && *optNames == "" && !gocal.IsSupportedLanguage(*optLocale) {
+		fatalf(gocal.ExitConfig, "# Error: unsupported language '%s'. Supported are: %s\nUse -names to supply custom names.", *optLocale, strings.Join(gocal.SupportedLanguages(), " "))
+	}
 	g.SetLocale(*optLocale)
+	g.SetNames(*optNames)
+	g.SetNumerals(*optNumerals)
+	g.SetNumeralFont(*optNumeralFont)
 	g.SetHoliday(*optHoliday)
+	g.SetHolidayCountry(*optHolidayCountry)
+	g.SetBridgeDays(*optBridgeDays)
+	g.SetBridgeFill(*optBridgeFill)
+	g.SetVacation(*optVacation)
+	g.SetVacationText(*optVacationText)
 	g.SetYearSpread(*optYearSpread)
 	if *optYearSpread != 1 && (!*optYearA && !*optYearB) {
 		fmt.Printf("WARN: Option 'spread' ignored. Only valid for year-mode.\n")
@@ -105,21 +474,51 @@ func main() {
 	for _, i := range icsFiles {
 		g.AddICS(i)
 	}
+	g.SetICSInclude(*optICSInclude)
+	g.SetICSColors(*optTodoColor, *optJournalColor)
+	g.SetICSTentative(*optICSTentative)
+	g.SetICSTransp(*optICSTransp)
+	if *optLegend {
+		g.SetLegend()
+	}
+	for _, p := range sourcePrefixes {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			fatalf(gocal.ExitConfig, "# Error in -prefix '%s', use source=prefix", p)
+		}
+		g.AddSourcePrefix(strings.TrimSpace(kv[0]), kv[1])
+	}
 	for _, i := range configFiles {
 		g.AddConfig(i)
 	}
 	if *optPlain == true {
 		g.SetPlain()
 	}
+	if *optWritable == true {
+		g.SetWritable()
+	}
 	if *optHideDOY == true {
 		g.SetHideDOY()
 	}
+	if *optQuarters < 0 || *optQuarters > 12 {
+		fatalf(gocal.ExitConfig, "# Error, -quarters must be a month from 1 to 12")
+	}
+	g.SetQuarters(*optQuarters)
+	if *optSprintStart != "" {
+		g.SetSprints(*optSprintStart, *optSprintDays)
+	}
 	if *optHideWeek == true {
 		g.SetHideWeek()
 	}
 	if *optHideMoon == true {
 		g.SetHideMoon()
 	}
+	g.SetMoonLabels(*optMoonLabels)
+	g.SetMoonAnchor(*optMoonAnchor)
+	g.SetMoonSize(*optMoonSize)
+	g.SetMoonOpacity(*optMoonOpacity)
+	g.SetCompanionICS(*optCompanionICS)
+	g.SetArtworkURL(*optArtworkURL)
 	if *optSmall == true {
 		g.SetSmall()
 	}
@@ -129,24 +528,355 @@ func main() {
 	if *optHideOtherMonths == true {
 		g.SetHideOtherMonth()
 	}
+	if *optJulian == true {
+		g.SetJulian()
+	}
+	if *optLiturgical == true {
+		g.SetLiturgical()
+	}
+	if *optLiturgicalColor == true {
+		g.SetLiturgicalColor()
+	}
+	g.SetDedup(*optDedup)
+	g.SetLeapDay(*optLeapDay)
+	g.SetSanitize(*optSanitize)
+	g.SetEventLength(*optEventLength)
+	g.SetLocation(*optLocation)
+	g.SetTimezone(*optTimezone)
+	if *optAstro == true {
+		g.SetAstroEvents()
+	}
+	for _, f := range ephemerisFiles {
+		g.AddEphemeris(f)
+	}
+	g.SetAstroIcons(*optAstroIcons)
+	if *optAnnotations == true {
+		g.SetAnnotations()
+	}
+	if *optLayers == true {
+		g.SetLayers()
+	}
+	if *optBraille == true {
+		g.SetBraille()
+	}
+	g.SetDayImages(*optDayImages)
+	if *optGrayscale == true {
+		g.SetGrayscale(*optContrast)
+	}
+	if *optShabbat == true {
+		g.SetShabbat()
+	}
+	g.SetCandleLighting(*optCandles)
+	g.SetHavdalah(*optHavdalah)
+	if *optPrayer == true {
+		g.SetPrayer()
+	}
+	g.SetPrayerMethod(*optPrayerMethod)
+	if *optPrayerHanafi == true {
+		g.SetPrayerHanafi()
+	}
+	if *optPanchang {
+		g.SetPanchang()
+	}
+	if *optPatterns != "" {
+		g.SetDatePatterns(*optPatterns)
+	}
+	if *optHistory != "" {
+		g.SetHistory(*optHistory)
+	}
+	g.SetHistoryLength(*optHistoryLength)
+	if *optFiller != "" {
+		g.SetFiller(*optFiller)
+	}
+	g.SetFillerCount(*optFillerCount)
+	if *optHabits != "" {
+		g.SetHabits(*optHabits)
+	}
+	if *optBudget != "" {
+		g.SetBudget(*optBudget)
+	}
+	if *optNotes != "" {
+		g.SetNotes(*optNotes)
+	}
+	if *optSplitMonths == true {
+		g.SetSplitMonths()
+	}
+	g.SetOtherMonths(*optOtherMonths)
 	g.SetFontScale(*optFontScale)
+	g.SetElementFonts(*optElementFonts)
+	g.SetTextOutline(*optTextOutline)
+	g.SetTextShadow(*optTextShadow)
 	g.SetWallpaper(*optWallpaper)
 	g.SetPhotos(*optPhotos)
+	g.SetPhotoOrder(*optPhotoOrder)
+	g.SetPhotoSeed(*optPhotoSeed)
 	g.SetPhoto(*optPhoto)
+	g.SetPhotoFit(*optPhotoFit)
+	if *optEnhance == true {
+		g.SetEnhance()
+	}
+	g.SetCaption(*optCaption)
+	g.SetCaptionPos(*optCaptionPos)
+	g.SetCaptionStyle(*optCaptionStyle)
 	g.SetFooter(*optFooter)
 	g.SetMargin(*optMargin)
 	g.SetFillpattern(*optFillpattern)
+	g.SetBackground(*optBackground)
+	g.SetBorder(*optBorder)
+	g.SetSeasonTheme(*optSeasonTheme)
+	g.SetBrand(*optBrand)
+	g.SetAddressBlock(*optAddressBlock)
+	g.SetReturnAddress(*optReturnAddress)
+	if *optWallBound == true {
+		g.SetWallBound()
+	}
+	g.SetPDFX(*optPDFX)
+	if *optTrace == true {
+		g.SetTrace("Gocal " + VERSION)
+	}
+	if *optStream > 0 {
+		g.SetStream(*optStream)
+	}
+	g.SetFillStyle(*optFillStyle)
+	g.SetHeaderFill(*optHeaderFill)
+	g.SetPageNumbers(*optPageNumbers)
+	g.SetPageNumberPos(*optPageNumberPos)
+	g.SetPageNumberStart(*optPageNumberStart)
+	if *optPageNumberSkip == true {
+		g.SetPageNumberSkip()
+	}
+	g.SetQuotes(*optQuotes)
+	g.SetQuoteFont(*optQuoteFont)
+	if *optQuoteWrap == true {
+		g.SetQuoteWrap()
+	}
+	g.SetGridWidth(*optGridWidth)
+	g.SetGridDash(*optGridDash)
+	g.SetGridColor(*optGridColor)
+	g.SetGridStyle(*optGridStyle)
+	g.SetDayNumberPos(*optDayNumberPos)
+	g.SetDayNumberSize(*optDayNumberSize)
+	g.SetDayNumberColor(*optDayNumberColor)
+	g.SetHighlightToday(*optHighlightToday)
+	g.SetTodayColor(*optTodayColor)
 	/*
 	  // How to create an event:
 	  g.AddEvent(31, 1, "one", "")
 	  g.AddEvent(28, 2, "two", "")
 	  g.AddEvent(31, 3, "three", "")
 	*/
+	if recipient != nil {
+		g.SetRecipient(recipient)
+	}
+	if *optCheckGrid {
+		if err := g.WriteGridReport(os.Stdout); err != nil {
+			fatalf(gocal.ExitRender, "# Error in the grid: %v", err)
+		}
+		return
+	}
 	if *optYearA == true {
 		g.CreateYearCalendar(*outfilename)
 	} else if *optYearB == true {
 		g.CreateYearCalendarInverse(*outfilename)
+	} else if *optStrip == true {
+		g.CreateContinuousCalendar(*outfilename)
+	} else if *optPerpetual == true {
+		g.CreatePerpetualCalendar(*outfilename)
 	} else {
 		g.CreateCalendar(*outfilename)
 	}
+	if *optExportICS != "" {
+		exportEvents(recipient.Expand(*optExportICS), g.WriteICS)
+	}
+	if *optExportJSON != "" {
+		exportEvents(recipient.Expand(*optExportJSON), g.WriteJSON)
+	}
+	if *optExportCSV != "" {
+		exportEvents(recipient.Expand(*optExportCSV), g.WriteCSV)
+	}
+}
+
+// exportEvents writes the events of the calendar to the file in the
+// format of write.
+func exportEvents(filename string, write func(io.Writer) error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		fatalf(gocal.ExitError, "# Error exporting events: %v", err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		fatalf(gocal.ExitError, "# Error exporting events: %v", err)
+	}
+	fmt.Printf("Generated '%v'.\n", filename)
+}
+
+// envName returns the environment variable of an option,
+// e.g. GOCAL_PAGENUM for -pagenum, GOCAL_KEEP_TEMP for -keep-temp.
+func envName(name string) string {
+	return ENVPREFIX + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// readOptionsFile reads lines "name = value" into a map.
+// Empty lines and lines starting with # are ignored.
+func readOptionsFile(filename string) map[string]string {
+	opts := make(map[string]string)
+	if filename == "" {
+		return opts
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		fatalf(gocal.ExitConfig, "# Error reading options file: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			fatalf(gocal.ExitConfig, "# Error in options file %s line %d: expected name=value", filename, n)
+		}
+		opts[strings.TrimPrefix(strings.TrimSpace(kv[0]), "-")] = strings.TrimSpace(kv[1])
+	}
+	return opts
+}
+
+// applyDefaults sets the options of fs that were not given on the
+// command line. The precedence is: command line, environment variable
+// GOCAL_<NAME>, options file.
+func applyDefaults(fs *flag.FlagSet) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	optionsFile := *optOptions
+	if !set["options"] {
+		optionsFile = os.Getenv(envName("options"))
+	}
+	fileOpts := readOptionsFile(optionsFile)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || f.Name == "options" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			value, ok = fileOpts[f.Name]
+		}
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if _, multi := f.Value.(*arrayFlags); multi {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if err := f.Value.Set(strings.TrimSpace(v)); err != nil {
+				fatalf(gocal.ExitConfig, "# Error in option %s: %v", f.Name, err)
+			}
+		}
+	})
+}
+
+// splitArgs splits a manifest line into arguments like a shell,
+// with single or double quotes around arguments with spaces.
+func splitArgs(line string) (args []string) {
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// recipient is the recipient of the calendar that run creates, if
+// any.
+var recipient gocal.Recipient
+
+// runJob creates the calendar, or with -recipients one personalized
+// calendar for every recipient.
+func runJob() {
+	if *optRecipients == "" {
+		run()
+		return
+	}
+	recipients, err := gocal.ReadRecipients(*optRecipients)
+	if err != nil {
+		fatalf(gocal.ExitConfig, "# Error reading recipients: %v", err)
+	}
+	if !strings.Contains(*outfilename, "{") {
+		fatalf(gocal.ExitConfig, "# Error: with -recipients the output filename needs a variable, e.g. -o \"calendar-{name}.pdf\"")
+	}
+	for i, r := range recipients {
+		fmt.Printf("# Recipient %d: %s\n", i+1, r.Expand("{name}"))
+		recipient = r
+		run()
+	}
+	recipient = nil
+}
+
+// runBatch creates one calendar per line of the manifest. A line holds
+// the options and arguments of a job, like the command line. The
+// options of the command line are the defaults of all jobs.
+func runBatch(filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		fatalf(gocal.ExitConfig, "# Error reading batch manifest: %v", err)
+	}
+	defer f.Close()
+
+	// Remember the defaults to reset them before every job.
+	defaults := make(map[string]string)
+	flag.VisitAll(func(fl *flag.Flag) { defaults[fl.Name] = fl.Value.String() })
+	defaultConfigs := append(arrayFlags(nil), configFiles...)
+	defaultICS := append(arrayFlags(nil), icsFiles...)
+	defaultPrefixes := append(arrayFlags(nil), sourcePrefixes...)
+	defaultEphemeris := append(arrayFlags(nil), ephemerisFiles...)
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		flag.VisitAll(func(fl *flag.Flag) {
+			if _, multi := fl.Value.(*arrayFlags); !multi {
+				fl.Value.Set(defaults[fl.Name])
+			}
+		})
+		configFiles = append(arrayFlags(nil), defaultConfigs...)
+		icsFiles = append(arrayFlags(nil), defaultICS...)
+		sourcePrefixes = append(arrayFlags(nil), defaultPrefixes...)
+		ephemerisFiles = append(arrayFlags(nil), defaultEphemeris...)
+
+		if err := flag.CommandLine.Parse(splitArgs(line)); err != nil {
+			fatalf(gocal.ExitConfig, "# Error in batch manifest %s line %d: %v", filename, n, err)
+		}
+		if *optBatch != filename {
+			fatalf(gocal.ExitConfig, "# Error in batch manifest %s line %d: nested -batch", filename, n)
+		}
+		fmt.Printf("# Job %d: %s\n", n, line)
+		runJob()
+	}
 }
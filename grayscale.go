@@ -0,0 +1,138 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// grayscale.go
+//
+// Grayscale output for black-and-white printing. The images are
+// converted when they are added, the colors of the pages when the
+// PDF is written, keeping every object at its offset.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// GRAYCONTRAST is the default contrast of the grayscale output: the
+// gray levels are spread from the middle, so that light fills get
+// lighter and colored texts darker.
+const GRAYCONTRAST = 1.2
+
+// grayLevel returns the gray level of a color with components from
+// 0 to 1: the luminance, spread around the middle by the contrast.
+func grayLevel(r, g, b float64, contrast float64) float64 {
+	l := 0.299*r + 0.587*g + 0.114*b
+	l = 0.5 + (l-0.5)*contrast
+	return math.Max(0, math.Min(1, l))
+}
+
+// registerGray adds the image file in grayscale to the PDF under its
+// own name, so that pdf.Image uses the gray version. Transparent
+// parts become white.
+func registerGray(pdf *gofpdf.Fpdf, file string, contrast float64) {
+	if pdf.GetImageInfo(file) != nil {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		return // pdf.Image reports it
+	}
+	img, err := decodeImage(file)
+	if err != nil {
+		fmt.Printf("# Error converting %v to grayscale: %v\n", file, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, grayImage(img, contrast)); err != nil {
+		fmt.Printf("# Error converting %v to grayscale: %v\n", file, err)
+		return
+	}
+	pdf.RegisterImageOptionsReader(file, gofpdf.ImageOptions{ImageType: "PNG"}, &buf)
+}
+
+// grayImage converts the image to grayscale, transparent parts
+// become white.
+func grayImage(img image.Image, contrast float64) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			l := 1.0
+			if a > 0 {
+				// The components are premultiplied with alpha.
+				l = grayLevel(float64(r)/float64(a), float64(g)/float64(a), float64(bl)/float64(a), contrast)
+				l = l*float64(a)/0xffff + 1 - float64(a)/0xffff
+			}
+			gray.SetGray(x, y, color.Gray{uint8(math.Round(l * 255))})
+		}
+	}
+	return gray
+}
+
+var (
+	// contentStream finds the uncompressed page contents.
+	contentStream = regexp.MustCompile(`<</Length (\d+)>>\nstream\n`)
+	// colorOp is a color operator of gofpdf, RGB or gray.
+	colorOp = regexp.MustCompile(`(^|\s)(\d+\.\d+)(?: (\d+\.\d+) (\d+\.\d+))? (rg|RG|g|G)\b`)
+	// gradientColor is a color of a gradient.
+	gradientColor = regexp.MustCompile(`/C[01] \[(\d+\.\d+) (\d+\.\d+) (\d+\.\d+)\]`)
+)
+
+// padTo fills s with spaces to the length n, the length of the
+// text it replaces.
+func padTo(s string, n int) string {
+	for len(s) < n {
+		s += " "
+	}
+	return s
+}
+
+// grayPDF turns the colors of an uncompressed PDF of gofpdf into
+// gray levels. Every replacement has the length of the original,
+// so the cross-reference table stays valid.
+func grayPDF(data []byte, contrast float64) []byte {
+	out := append([]byte{}, data...)
+	for _, loc := range contentStream.FindAllSubmatchIndex(data, -1) {
+		n, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		start, end := loc[1], loc[1]+n
+		if end > len(data) {
+			continue
+		}
+		s := colorOp.ReplaceAllFunc(data[start:end], func(m []byte) []byte {
+			sm := colorOp.FindSubmatch(m)
+			r, _ := strconv.ParseFloat(string(sm[2]), 64)
+			g, b := r, r
+			if len(sm[3]) > 0 {
+				g, _ = strconv.ParseFloat(string(sm[3]), 64)
+				b, _ = strconv.ParseFloat(string(sm[4]), 64)
+			}
+			op := "g"
+			if sm[5][0] == 'R' || sm[5][0] == 'G' {
+				op = "G"
+			}
+			return []byte(padTo(fmt.Sprintf("%s%.3f %s", sm[1], grayLevel(r, g, b, contrast), op), len(m)))
+		})
+		copy(out[start:end], s)
+	}
+	return gradientColor.ReplaceAllFunc(out, func(m []byte) []byte {
+		sm := gradientColor.FindSubmatch(m)
+		var c [3]float64
+		for i := range c {
+			c[i], _ = strconv.ParseFloat(string(sm[i+1]), 64)
+		}
+		l := grayLevel(c[0], c[1], c[2], contrast)
+		return []byte(padTo(fmt.Sprintf("%s[%.3f %.3f %.3f]", m[:4], l, l, l), len(m)))
+	})
+}
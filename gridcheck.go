@@ -0,0 +1,111 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// gridcheck.go
+//
+// The check of the grid of the monthly calendar: that every cell is
+// under the column of its weekday and the month fits into the rows,
+// with the number of each weekday in the month, for QA across years.
+//
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MonthGrid is the grid of a month of the monthly calendar: the column
+// of the 1st, from 0, the rows of the month and the number of each
+// weekday, by time.Weekday.
+type MonthGrid struct {
+	Year        int
+	Month       time.Month
+	FirstColumn int
+	Rows        int
+	Weekdays    [7]int
+}
+
+// firstCellOffset returns the day of the first cell of the grid of the
+// month, relative to the 1st: 0 if the month begins on a Monday, -6 if
+// it begins on a Sunday.
+func firstCellOffset(year int, month int) int {
+	t := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	return -((int(t.Weekday()) + 6) % 7)
+}
+
+// columnWeekday returns the weekday of the column of the grid, the
+// weeks begin on Monday.
+func columnWeekday(col int) time.Weekday {
+	return time.Weekday((col + 1) % 7)
+}
+
+// monthGrid lays out the month like the monthly calendar and checks
+// that each day is in the column of its weekday and in the rows.
+func monthGrid(year int, month int) (m MonthGrid, err error) {
+	m.Year, m.Month = year, time.Month(month)
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	offset := firstCellOffset(year, month)
+	for i := 0; i < LINES; i++ {
+		for j := 0; j < COLUMNS; j++ {
+			day := first.AddDate(0, 0, offset+i*COLUMNS+j)
+			if day.Weekday() != columnWeekday(j) {
+				return m, fmt.Errorf("%s is a %s, but in the column of %s", day.Format("2006-01-02"), day.Weekday(), columnWeekday(j))
+			}
+			if day.Month() != time.Month(month) {
+				continue
+			}
+			if day.Day() == 1 {
+				if i != 0 {
+					return m, fmt.Errorf("%s is in row %d", day.Format("2006-01-02"), i+1)
+				}
+				m.FirstColumn = j
+			}
+			m.Rows = i + 1
+			m.Weekdays[day.Weekday()]++
+		}
+	}
+	last := first.AddDate(0, 1, -1)
+	days := 0
+	for _, n := range m.Weekdays {
+		days += n
+	}
+	if days != last.Day() {
+		return m, fmt.Errorf("%d of %d days of %s fit into the grid", days, last.Day(), first.Format("2006-01"))
+	}
+	return m, nil
+}
+
+// CheckGrid checks the grid of the months of the calendar and returns
+// their layouts, up to the first month with an error.
+func (g *Calendar) CheckGrid() (grids []MonthGrid, err error) {
+	for mo := g.WantBeginMonth; mo <= g.WantEndMonth; mo++ {
+		m, err := monthGrid(g.WantYear, mo)
+		if err != nil {
+			return grids, err
+		}
+		grids = append(grids, m)
+	}
+	return grids, nil
+}
+
+// WriteGridReport writes a line per month with the weekday and column
+// of the 1st, the rows and the number of each weekday, and returns the
+// error of CheckGrid.
+func (g *Calendar) WriteGridReport(w io.Writer) error {
+	grids, err := g.CheckGrid()
+	fmt.Fprintf(w, "# Month    1st  Col  Rows  Mo Tu We Th Fr Sa Su\n")
+	for _, m := range grids {
+		var counts []string
+		for j := 0; j < COLUMNS; j++ {
+			counts = append(counts, fmt.Sprintf("%2d", m.Weekdays[columnWeekday(j)]))
+		}
+		first := time.Date(m.Year, m.Month, 1, 0, 0, 0, 0, time.UTC)
+		fmt.Fprintf(w, "  %s  %s  %3d  %4d  %s\n", first.Format("2006-01"), first.Weekday().String()[:3], m.FirstColumn+1, m.Rows, strings.Join(counts, " "))
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+//go:build !js
+// +build !js
+
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// heic.go
+//
+// The conversion of HEIC photos by an external program, which the
+// browser doesn't have.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// heicConverters are the programs that convert HEIC to PNG, tried
+// in this order, with their arguments for the input and output file.
+var heicConverters = [][]string{
+	{"heif-convert", "{in}", "{out}"},
+	{"magick", "{in}", "{out}"},
+	{"convert", "{in}", "{out}"},
+	{"sips", "-s", "format", "png", "{in}", "--out", "{out}"},
+}
+
+// convertHEIC converts the HEIC file to PNG with the first of the
+// heicConverters that is installed.
+func convertHEIC(file string) (image.Image, error) {
+	for _, c := range heicConverters {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		dir, err := makeTempdir("gocal-heic-")
+		if err != nil {
+			return nil, err
+		}
+		defer removeTempdir(dir)
+		out := filepath.Join(dir, "photo.png")
+		var args []string
+		for _, a := range c[1:] {
+			args = append(args, strings.NewReplacer("{in}", file, "{out}", out).Replace(a))
+		}
+		if msg, err := exec.Command(c[0], args...).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("%s: %v %s", c[0], err, bytes.TrimSpace(msg))
+		}
+		f, err := os.Open(out)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return png.Decode(f)
+	}
+	return nil, fmt.Errorf("no converter for HEIC found, install libheif (heif-convert) or ImageMagick")
+}
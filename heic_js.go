@@ -0,0 +1,25 @@
+//go:build js
+// +build js
+
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// heic_js.go
+//
+// HEIC photos in the browser, where there are no programs to convert
+// them.
+//
+
+import (
+	"fmt"
+	"image"
+)
+
+// convertHEIC fails, convert the photo to JPEG or PNG first.
+func convertHEIC(file string) (image.Image, error) {
+	return nil, fmt.Errorf("HEIC photos can't be converted in the browser, use JPEG or PNG")
+}
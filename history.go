@@ -0,0 +1,103 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// history.go
+//
+// Content for days without events, e.g. "on this day" facts.
+//
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// HISTORYLENGTH is the default maximum length of a fact.
+const HISTORYLENGTH = 60
+
+// DayContentProvider supplies a short text for days that have
+// no events. An empty string leaves the day empty.
+type DayContentProvider interface {
+	DayContent(d time.Time) string
+}
+
+// onThisDay is a DayContentProvider with historical facts
+// read from a local data file.
+type onThisDay struct {
+	facts  map[string][]string
+	maxLen int
+}
+
+// newOnThisDay reads a data file with one fact per line in
+// the format "MM-DD text", e.g. "07-20 1969: First man on the moon".
+// Empty lines and lines starting with # are ignored.
+func newOnThisDay(filename string, maxLen int, warn func(kind string, format string, v ...interface{})) *onThisDay {
+	o := &onThisDay{make(map[string][]string), maxLen}
+	if o.maxLen <= 0 {
+		o.maxLen = HISTORYLENGTH
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("# Error reading history file %v\n", filename)
+		return o
+	}
+	for n, line := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			warn("date", "Ignoring line %d of %v", n+1, filename)
+			continue
+		}
+		if _, err := time.Parse("01-02", fields[0]); err != nil && fields[0] != "02-29" {
+			warn("date", "Ignoring line %d of %v", n+1, filename)
+			continue
+		}
+		o.facts[fields[0]] = append(o.facts[fields[0]], strings.TrimSpace(fields[1]))
+	}
+	return o
+}
+
+// DayContent returns a fact for the day. Facts that fit into
+// the length limit are preferred, changing from year to year.
+// Longer facts are shortened.
+func (o *onThisDay) DayContent(d time.Time) string {
+	facts := o.facts[d.Format("01-02")]
+	if len(facts) == 0 {
+		return ""
+	}
+	var fitting []string
+	for _, f := range facts {
+		if len([]rune(f)) <= o.maxLen {
+			fitting = append(fitting, f)
+		}
+	}
+	if len(fitting) > 0 {
+		return fitting[d.Year()%len(fitting)]
+	}
+	return shortenText(facts[d.Year()%len(facts)], o.maxLen)
+}
+
+// shortenText cuts the text at a word boundary to at most
+// maxLen characters, including the ellipsis.
+func shortenText(text string, maxLen int) string {
+	r := []rune(text)
+	if len(r) <= maxLen {
+		return text
+	}
+	if maxLen < 4 {
+		return string(r[:maxLen])
+	}
+	cut := string(r[:maxLen-3])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "..."
+}
@@ -0,0 +1,351 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// ics.go
+//
+// The events of ICS calendars. Every calendar has its own reader, so
+// that calendars created at the same time share neither files nor
+// caches.
+//
+
+import (
+	"fmt"
+	"github.com/PuloV/ics-golang"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// icsParseMutex guards the parser of ICS files, which counts the
+// calendars in a package variable.
+var icsParseMutex sync.Mutex
+
+// icsReader reads the events of ICS files and URLs, and keeps them
+// because the configuration is read more than once.
+type icsReader struct {
+	mu    sync.Mutex
+	cache map[string][]gDate
+	// sources are the names and colors of the files.
+	sources map[string]icsSource
+	// fetch downloads the calendars of URLs, open reads the files.
+	fetch func(url, accept string) ([]byte, error)
+	open  func(filename string) ([]byte, error)
+}
+
+// icsSource is the name and the color of an ICS file, from its
+// X-WR-CALNAME and X-APPLE-CALENDAR-COLOR.
+type icsSource struct {
+	name  string
+	color string
+}
+
+// newICSReader returns a reader that downloads with the shared client.
+func newICSReader() *icsReader {
+	return &icsReader{cache: make(map[string][]gDate), sources: make(map[string]icsSource), fetch: fetchURL, open: readInputFile}
+}
+
+// source returns the name and the color of the ICS file that was read.
+func (r *icsReader) source(filename string) icsSource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sources[filename]
+}
+
+// parseICSSource returns the name and the color of the calendar of
+// the ICS data; the color may have an alpha channel, #rrggbbaa.
+// An invalid color goes to warn.
+func parseICSSource(data string, filename string, warn func(kind string, format string, v ...interface{})) (src icsSource) {
+	cals := icsComponents(data, "VCALENDAR")
+	if len(cals) == 0 {
+		return src
+	}
+	src.name = icsUnescaper.Replace(strings.TrimSpace(cals[0].props["X-WR-CALNAME"]))
+	color := strings.TrimSpace(cals[0].props["X-APPLE-CALENDAR-COLOR"])
+	if len(color) == 9 && color[0] == '#' {
+		color = color[:7]
+	}
+	if color == "" {
+		return src
+	}
+	if _, _, _, err := parseColor(color); err != nil {
+		warn("field", "Ignoring the color of %v: %v", filename, err)
+		return src
+	}
+	src.color = color
+	return src
+}
+
+// icsReader returns the reader of the ICS files of the calendar.
+func (g *Calendar) icsReader() *icsReader {
+	if g.ics == nil {
+		g.ics = newICSReader()
+		g.ics.open = g.readFile
+	}
+	return g.ics
+}
+
+// SetICSInclude adds the to-dos with a due date and the journal
+// entries of the ICS files to the events, comma separated: todo,
+// journal.
+func (g *Calendar) SetICSInclude(kinds string) {
+	g.OptICSInclude = kinds
+}
+
+// SetICSColors sets the colors of the to-dos and of the journal
+// entries of the ICS files, instead of the color of their calendar.
+func (g *Calendar) SetICSColors(todo string, journal string) {
+	g.OptTodoColor = todo
+	g.OptJournalColor = journal
+}
+
+// SetICSTentative sets what happens to the tentative events of the
+// ICS files: show (the default), dim or skip.
+func (g *Calendar) SetICSTentative(policy string) {
+	g.OptICSTentative = policy
+}
+
+// SetICSTransp keeps only the events of the ICS files that show the
+// time as busy, or only those that leave it free; all keeps both.
+func (g *Calendar) SetICSTransp(only string) {
+	g.OptICSTransp = only
+}
+
+// icsEvents returns the events of an ICS file with the to-dos and
+// journal entries of OptICSInclude, in their colors, and the
+// tentative events as OptICSTentative says.
+func (g *Calendar) icsEvents(eL []gDate) (out []gDate) {
+	include := make(map[string]bool)
+	for _, k := range strings.Split(g.OptICSInclude, ",") {
+		switch k = strings.TrimSpace(k); k {
+		case "todo":
+			include["todo"], include["done"] = true, true
+		case "journal":
+			include[k] = true
+		case "":
+		default:
+			g.warnf("field", "Unknown ICS entry '%s', use todo or journal", k)
+		}
+	}
+	switch g.OptICSTentative {
+	case "", "show", "dim", "skip":
+	default:
+		g.warnf("field", "Unknown policy '%s' of tentative events, showing them", g.OptICSTentative)
+	}
+	for _, ev := range eL {
+		switch {
+		case ev.Kind == "":
+		case ev.Kind == "tentative" && g.OptICSTentative == "skip":
+			continue
+		case ev.Kind == "tentative":
+			if g.OptICSTentative != "dim" {
+				ev.Kind = ""
+			}
+		case !include[ev.Kind]:
+			continue
+		case ev.Kind == "journal" && g.OptJournalColor != "":
+			ev.Color = g.OptJournalColor
+		case ev.Kind != "journal" && g.OptTodoColor != "":
+			ev.Color = g.OptTodoColor
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+// read returns the events of the ICS file or URL in the year.
+// Cancelled events are left out, and with transp busy or free the
+// events that leave the time free or those that don't.
+// There is an ugly hack lurking here. The events in ICS contain
+// years, but we wanted the configuration to be agnostic of years.
+// The errors go to warn, e.g. the warnf of the calendar.
+func (r *icsReader) read(filename string, targetyear int, transp string, warn func(kind, format string, v ...interface{})) (eL []gDate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cacheKey := fmt.Sprintf("%s %d %s", filename, targetyear, transp)
+	if cached, ok := r.cache[cacheKey]; ok {
+		return append([]gDate(nil), cached...)
+	}
+	defer func() { r.cache[cacheKey] = append([]gDate(nil), eL...) }()
+
+	var data []byte
+	var err error
+	if strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
+		if data, err = r.fetch(filename, "text/calendar"); err != nil {
+			warn("download", "Error downloading %v: %v", filename, err)
+			return nil
+		}
+	} else if data, err = r.open(filename); err != nil {
+		warn("field", "Error reading %v: %v", filename, err)
+		return nil
+	}
+
+	if err = checkInputSize(data, filename); err != nil {
+		warn("field", "Ignoring ICS calendar: %v", err)
+		return nil
+	}
+	data = validUTF8(data, filename, warn)
+
+	calendars, err := parseICS(data)
+	if err != nil {
+		warn("field", "Ignoring ICS calendar %v: %v", filename, err)
+		return nil
+	}
+	switch transp {
+	case "", "all", "busy", "free":
+	default:
+		warn("field", "Unknown transparency '%s', use all, busy or free", transp)
+	}
+	src := parseICSSource(string(data), filename, warn)
+	r.sources[filename] = src
+	// The parser knows neither TRANSP nor where STATUS is.
+	events := make(map[string]icsComponent)
+	for _, c := range icsComponents(string(data), "VEVENT") {
+		if _, ok := events[c.props["UID"]]; !ok && c.props["UID"] != "" {
+			events[c.props["UID"]] = c
+		}
+	}
+	for _, cal := range calendars {
+		for _, event := range cal.GetEvents() {
+			start := event.GetStart()
+			if start.Year() != targetyear {
+				continue
+			}
+			props := events[event.GetImportedID()].props
+			status := strings.ToUpper(strings.TrimSpace(props["STATUS"]))
+			free := strings.EqualFold(strings.TrimSpace(props["TRANSP"]), "TRANSPARENT")
+			if status == "CANCELLED" || transp == "busy" && free || transp == "free" && !free {
+				continue
+			}
+			kind := ""
+			if status == "TENTATIVE" {
+				kind = "tentative"
+			}
+			at := icsTime(props["DTSTART"])
+			if props == nil && !event.GetWholeDayEvent() {
+				at = start.Format("15:04")
+			}
+			description := icsUnescaper.Replace(event.GetDescription())
+			eL = append(eL, gDate{start.Month(), start.Day(), convertCP(event.GetSummary()), "", "", filename, src.color, "", description, kind, icsPriority(props["PRIORITY"]), at})
+		}
+	}
+	// The parser knows only events.
+	for _, c := range icsComponents(string(data), "VTODO", "VJOURNAL") {
+		date := c.props["DUE"]
+		if date == "" || c.name == "VJOURNAL" {
+			date = c.props["DTSTART"]
+		}
+		day, ok := parseICSDate(date)
+		if !ok || day.Year() != targetyear {
+			continue
+		}
+		if strings.EqualFold(c.props["STATUS"], "CANCELLED") {
+			continue
+		}
+		kind := "journal"
+		if c.name == "VTODO" {
+			kind = "todo"
+			if strings.EqualFold(c.props["STATUS"], "COMPLETED") || c.props["COMPLETED"] != "" {
+				kind = "done"
+			}
+		}
+		summary, description := icsUnescaper.Replace(c.props["SUMMARY"]), icsUnescaper.Replace(c.props["DESCRIPTION"])
+		eL = append(eL, gDate{day.Month(), day.Day(), convertCP(summary), "", "", filename, src.color, "", description, kind, icsPriority(c.props["PRIORITY"]), icsTime(date)})
+	}
+	return eL
+}
+
+// icsComponent is a component of an ICS file with the values of its
+// properties by name, without the parameters.
+type icsComponent struct {
+	name  string
+	props map[string]string
+}
+
+// icsComponents returns the components of the ICS data with one of
+// the names, without the properties of the components inside them,
+// like alarms.
+func icsComponents(data string, names ...string) (comps []icsComponent) {
+	data = strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(data)
+	var cur *icsComponent
+	depth := 0
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		name, value := strings.ToUpper(line[:colon]), line[colon+1:]
+		if i := strings.IndexByte(name, ';'); i >= 0 {
+			name = name[:i]
+		}
+		switch {
+		case name == "BEGIN" && cur != nil:
+			depth++
+		case name == "BEGIN" && stringInSlice(strings.ToUpper(value), names):
+			cur = &icsComponent{strings.ToUpper(value), make(map[string]string)}
+		case name == "END" && cur != nil && depth > 0:
+			depth--
+		case name == "END" && cur != nil:
+			comps = append(comps, *cur)
+			cur = nil
+		case cur != nil && depth == 0:
+			if _, ok := cur.props[name]; !ok {
+				cur.props[name] = value
+			}
+		}
+	}
+	return comps
+}
+
+// icsTime returns the time of an ICS date-time like 20260704T100000Z
+// as 10:00, or "" for a date.
+func icsTime(value string) string {
+	if len(value) < 13 || value[8] != 'T' {
+		return ""
+	}
+	return value[9:11] + ":" + value[11:13]
+}
+
+// icsPriority returns the PRIORITY of an ICS component, 0 if it has
+// none or an invalid one.
+func icsPriority(value string) int {
+	p, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || p < 0 || p > 9 {
+		return 0
+	}
+	return p
+}
+
+// parseICSDate returns the day of an ICS date or date-time like
+// 20260704 or 20260704T100000Z; timezones are ignored.
+func parseICSDate(value string) (time.Time, bool) {
+	if len(value) < 8 {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("20060102", value[:8])
+	return day, err == nil
+}
+
+// parseICS returns the calendars of the ICS data; a malformed calendar
+// that the parser can't handle is an error.
+func parseICS(data []byte) (calendars []*ics.Calendar, err error) {
+	icsParseMutex.Lock()
+	defer icsParseMutex.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			calendars, err = nil, fmt.Errorf("malformed calendar: %v", r)
+		}
+	}()
+	parser := ics.New()
+	parser.Load(string(data))
+	calendars, _ = parser.GetCalendars()
+	return calendars, nil
+}
+
+// icsUnescaper undoes the escapes of ICS texts.
+var icsUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
@@ -0,0 +1,76 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// imageformats.go
+//
+// Image formats that the PDF library can't read: WebP, decoded in
+// Go, and HEIC photos of phones, converted by an external program
+// (heic.go). They are added to the PDF as PNG.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	_ "golang.org/x/image/webp" // decoder for the photos
+	"image"
+	"image/draw"
+	_ "image/gif"  // decoder for the photos
+	_ "image/jpeg" // decoder for the photos
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isHEIC reports whether the image file is a HEIC/HEIF photo.
+func isHEIC(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return ext == ".heic" || ext == ".heif"
+}
+
+// needsConversion reports whether the PDF library can't read the
+// image file itself.
+func needsConversion(file string) bool {
+	return isHEIC(file) || strings.EqualFold(filepath.Ext(file), ".webp")
+}
+
+// decodeImage reads an image file in any of the supported formats.
+func decodeImage(file string) (image.Image, error) {
+	if isHEIC(file) {
+		return convertHEIC(file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// registerConverted adds the image file as PNG to the PDF under its
+// own name, so that pdf.Image can draw it.
+func registerConverted(pdf *gofpdf.Fpdf, file string) {
+	if pdf.GetImageInfo(file) != nil {
+		return
+	}
+	img, err := decodeImage(file)
+	if err != nil {
+		fmt.Printf("# Error reading image %v: %v\n", file, err)
+		return
+	}
+	// The PDF library reads 8 bit PNG only.
+	rgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		fmt.Printf("# Error converting %v: %v\n", file, err)
+		return
+	}
+	pdf.RegisterImageOptionsReader(file, gofpdf.ImageOptions{ImageType: "PNG"}, &buf)
+}
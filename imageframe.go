@@ -0,0 +1,163 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// imageframe.go
+//
+// The part of an image that is shown: a crop rectangle, and a focal
+// point that stays in view when the image is cut to fill an area,
+// e.g. a face in a photo.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// imageFrame is the part of an image to show: crop is x, y, width
+// and height as fractions of the image, focus x and y as fractions
+// of the crop. noEnhance leaves a photo as it is, caption and
+// credit are printed on it.
+type imageFrame struct {
+	crop      [4]float64
+	focus     [2]float64
+	noEnhance bool
+	caption   string
+	credit    string
+}
+
+var fullFrame = imageFrame{[4]float64{0, 0, 1, 1}, [2]float64{0.5, 0.5}, false, "", ""}
+
+// parseFractions reads n numbers from 0 to 1 separated by commas,
+// e.g. "0.1,0.2" or "10%,20%".
+func parseFractions(s string, n int) ([]float64, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, false
+	}
+	var v []float64
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		scale := 1.0
+		if strings.HasSuffix(p, "%") {
+			p, scale = strings.TrimSuffix(p, "%"), 0.01
+		}
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil || f*scale < 0 || f*scale > 1 {
+			return nil, false
+		}
+		v = append(v, f*scale)
+	}
+	return v, true
+}
+
+// parseImageFrame reads the crop rectangle "x,y,w,h" and the focus
+// "x,y" of an image, empty ones keep the whole image and its center.
+func parseImageFrame(crop string, focus string) (imageFrame, bool) {
+	fr := fullFrame
+	if crop != "" {
+		v, ok := parseFractions(crop, 4)
+		if !ok || v[2] == 0 || v[3] == 0 || v[0]+v[2] > 1+1e-9 || v[1]+v[3] > 1+1e-9 {
+			return fr, false
+		}
+		copy(fr.crop[:], v)
+	}
+	if focus != "" {
+		v, ok := parseFractions(focus, 2)
+		if !ok {
+			return fr, false
+		}
+		copy(fr.focus[:], v)
+	}
+	return fr, true
+}
+
+// placeImage returns where to draw an image of size iw x ih, so that
+// the crop of the frame shows in the area r, stretched or, with fill,
+// scaled to cover r and cut around the focus. The area outside of r
+// has to be clipped.
+func placeImage(iw, ih float64, fr imageFrame, r rect, fill bool) rect {
+	cx, cy := fr.crop[0]*iw, fr.crop[1]*ih
+	cw, ch := fr.crop[2]*iw, fr.crop[3]*ih
+	if !fill {
+		sx, sy := r.w/cw, r.h/ch
+		return rect{r.x - cx*sx, r.y - cy*sy, iw * sx, ih * sy}
+	}
+	s := math.Max(r.w/cw, r.h/ch)
+	vw, vh := r.w/s, r.h/s // the visible part of the crop
+	ox := math.Max(0, math.Min(cw-vw, fr.focus[0]*cw-vw/2))
+	oy := math.Max(0, math.Min(ch-vh, fr.focus[1]*ch-vh/2))
+	return rect{r.x - (cx+ox)*s, r.y - (cy+oy)*s, iw * s, ih * s}
+}
+
+// readConfigurationImages returns the frames of the images of the
+// XML file by file name.
+func (g *Calendar) readConfigurationImages(filename string) map[string]imageFrame {
+	frames := make(map[string]imageFrame)
+	v := g.loadConfigurationfile(filename)
+	for _, m := range v.Gocalimage {
+		fr, ok := parseImageFrame(m.Crop, m.Focus)
+		if !ok {
+			g.warnf("field", "Ignoring image '%s' with invalid crop='%s' or focus='%s'", m.File, m.Crop, m.Focus)
+			continue
+		}
+		switch strings.ToLower(m.Enhance) {
+		case "no", "false", "off":
+			fr.noEnhance = true
+		}
+		fr.caption, fr.credit = m.Caption, m.Credit
+		frames[m.File] = fr
+	}
+	return frames
+}
+
+// frame returns the frame of the image file of the configuration
+// files, by path or base name, and whether it has one.
+func (g *Calendar) frame(file string) (imageFrame, bool) {
+	if g.imageFrames == nil {
+		g.imageFrames = make(map[string]imageFrame)
+		for _, cfg := range append([]string{g.OptConfig}, g.OptConfigs...) {
+			if cfg == "" {
+				continue
+			}
+			for f, fr := range g.readConfigurationImages(cfg) {
+				g.imageFrames[f] = fr
+			}
+		}
+	}
+	if fr, ok := g.imageFrames[file]; ok {
+		return fr, true
+	}
+	fr, ok := g.imageFrames[filepath.Base(file)]
+	if !ok {
+		return fullFrame, false
+	}
+	return fr, true
+}
+
+// drawFramed draws the frame of the image file into the area,
+// stretched or, with fill, cut to fill it.
+func (g *Calendar) drawFramed(pdf *gofpdf.Fpdf, file string, r rect, fill bool) {
+	if !g.registerImage(pdf, file) {
+		return
+	}
+	fr, framed := g.frame(file)
+	if !framed && !fill {
+		pdf.Image(file, r.x, r.y, r.w, r.h, false, "", 0, "")
+		return
+	}
+	info := pdf.RegisterImageOptions(file, gofpdf.ImageOptions{ReadDpi: true})
+	if info == nil || info.Width() <= 0 || info.Height() <= 0 {
+		return
+	}
+	p := placeImage(info.Width(), info.Height(), fr, r, fill)
+	pdf.ClipRect(r.x, r.y, r.w, r.h, false)
+	pdf.Image(file, p.x, p.y, p.w, p.h, false, "", 0, "")
+	pdf.ClipEnd()
+}
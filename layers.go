@@ -0,0 +1,60 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// layers.go
+//
+// Optional content groups of the PDF: the grid, the events, the
+// photos and the astronomy are put on layers, which viewers like
+// Acrobat can show and hide.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+)
+
+// pdfLayers are the ids of the layers of the calendar,
+// -1 without layers.
+type pdfLayers struct {
+	grid      int
+	events    int
+	photos    int
+	astronomy int
+}
+
+// newPDFLayers adds the layers to the PDF if on is set.
+func newPDFLayers(pdf *gofpdf.Fpdf, on bool) pdfLayers {
+	if !on {
+		return pdfLayers{-1, -1, -1, -1}
+	}
+	l := pdfLayers{
+		pdf.AddLayer("Grid", true),
+		pdf.AddLayer("Events", true),
+		pdf.AddLayer("Photos", true),
+		pdf.AddLayer("Astronomy", true),
+	}
+	pdf.OpenLayerPane()
+	return l
+}
+
+// active reports whether the PDF has layers.
+func (l pdfLayers) active() bool {
+	return l.grid >= 0
+}
+
+// begin puts the following content on the layer.
+func (l pdfLayers) begin(pdf *gofpdf.Fpdf, id int) {
+	if id >= 0 {
+		pdf.BeginLayer(id)
+	}
+}
+
+// end puts the following content on no layer again.
+func (l pdfLayers) end(pdf *gofpdf.Fpdf) {
+	if l.active() {
+		pdf.EndLayer()
+	}
+}
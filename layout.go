@@ -0,0 +1,235 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// layout.go
+//
+// Placement of the content of a day cell: the day number, the
+// moon and the small numbers reserve their area first, the event
+// lines are then put into the free space without overlapping.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"sort"
+	"strings"
+)
+
+// ASCENT is the part of a line height above the baseline.
+const ASCENT = 0.75
+
+// rect is an area of the page in mm.
+type rect struct {
+	x, y, w, h float64
+}
+
+// overlaps reports whether the rectangles share an area.
+func (r rect) overlaps(o rect) bool {
+	return r.x < o.x+o.w && o.x < r.x+r.w && r.y < o.y+o.h && o.y < r.y+r.h
+}
+
+// cellLayout keeps track of the used areas of a day cell.
+type cellLayout struct {
+	cell rect
+	used []rect
+}
+
+func newCellLayout(x, y, w, h float64) *cellLayout {
+	return &cellLayout{cell: rect{x, y, w, h}}
+}
+
+// reserve marks an area as used, e.g. by the day number.
+func (l *cellLayout) reserve(r rect) {
+	l.used = append(l.used, r)
+}
+
+// free reports whether the area is inside the cell and unused.
+func (l *cellLayout) free(r rect) bool {
+	c := l.cell
+	if r.x < c.x || r.y < c.y || r.x+r.w > c.x+c.w || r.y+r.h > c.y+c.h {
+		return false
+	}
+	for _, u := range l.used {
+		if r.overlaps(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// place reserves the first free area of size w x h at dx from the
+// left of the cell. The search starts at from below the top of the
+// cell and goes down in steps of h/2, then continues at the top.
+func (l *cellLayout) place(dx, from, w, h float64) (rect, bool) {
+	step := h / 2
+	if step <= 0 {
+		return rect{}, false
+	}
+	n := int(l.cell.h / step)
+	start := int(from / step)
+	for i := 0; i <= n; i++ {
+		r := rect{l.cell.x + dx, l.cell.y + float64((start+i)%(n+1))*step, w, h}
+		if l.free(r) {
+			l.reserve(r)
+			return r, true
+		}
+	}
+	return rect{}, false
+}
+
+// placeRight reserves an area of size w x h at the right edge of the
+// cell, at the top, in the middle or at the bottom, whichever is free.
+func (l *cellLayout) placeRight(w, h float64) (rect, bool) {
+	c := l.cell
+	x := c.x + c.w - CELLMARGIN - w
+	for _, y := range []float64{c.y + CELLMARGIN, c.y + (c.h-h)/2, c.y + c.h - CELLMARGIN - h} {
+		if r := (rect{x, y, w, h}); l.free(r) {
+			l.reserve(r)
+			return r, true
+		}
+	}
+	return rect{}, false
+}
+
+// placeLines places all lines of a text or none of them. widths
+// are the widths of the lines.
+func (l *cellLayout) placeLines(dx, from float64, widths []float64, h float64) ([]rect, bool) {
+	mark := len(l.used)
+	var rs []rect
+	for _, w := range widths {
+		r, ok := l.place(dx, from, w, h)
+		if !ok {
+			l.used = l.used[:mark]
+			return nil, false
+		}
+		rs = append(rs, r)
+		from = r.y - l.cell.y + h
+	}
+	return rs, true
+}
+
+// reserveText reserves the area of a text of the current font that
+// CellFormat prints in the cell with the alignment, e.g. "TL" or "BR".
+func (l *cellLayout) reserveText(pdf *gofpdf.Fpdf, text string, align string) {
+	if text == "" {
+		return
+	}
+	_, h := pdf.GetFontSize()
+	w := pdf.GetStringWidth(text) + 2*CELLMARGIN
+	h += CELLMARGIN
+	c := l.cell
+	x, y := c.x, c.y
+	if strings.Contains(align, "R") {
+		x = c.x + c.w - w
+	} else if strings.Contains(align, "C") {
+		x = c.x + (c.w-w)/2
+	}
+	if strings.Contains(align, "B") {
+		y = c.y + c.h - h
+	} else if strings.Contains(align, "M") {
+		y = c.y + (c.h-h)/2
+	}
+	l.reserve(rect{x, y, w, h})
+}
+
+// drawEvents prints the event lines of a day into the free areas of
+// the cell, events of the day before the weekly ones. Events that
+// don't fit are summarized as "+N", which may take the place of the
+// last event that fits. Events without color have the color of the
+// day, or the current text color. The texts may have markup; to-dos
+// begin with a checkbox, journal entries are italic and tentative
+// events pale.
+func (l *cellLayout) drawEvents(pdf *gofpdf.Fpdf, font elementFont, size float64, events []gDate, lineHeight float64, from float64, dayColor string) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Weekday == "" && events[j].Weekday != ""
+	})
+	dx := 0.02 * l.cell.w
+	type placed struct {
+		ev    gDate
+		lines [][]textSpan
+		rs    []rect
+		mark  int
+	}
+	var done []placed
+	for _, ev := range events {
+		var lines [][]textSpan
+		var widths []float64
+		for _, line := range strings.Split(ev.Text, "\\n") {
+			spans := parseMarkup(line)
+			for i := range spans {
+				spans[i].italic = spans[i].italic || ev.Kind == "journal"
+			}
+			lines = append(lines, spans)
+			widths = append(widths, spansWidth(pdf, font, size, spans))
+		}
+		if ev.Kind == "todo" || ev.Kind == "done" {
+			widths[0] += lineHeight
+		}
+		mark := len(l.used)
+		rs, ok := l.placeLines(dx, from, widths, lineHeight)
+		if !ok {
+			break
+		}
+		done = append(done, placed{ev, lines, rs, mark})
+	}
+	for len(done) < len(events) {
+		more := fmt.Sprintf("+%d", len(events)-len(done))
+		if r, ok := l.place(dx, from, pdf.GetStringWidth(more), lineHeight); ok {
+			pdf.Text(r.x, r.y+ASCENT*lineHeight, more)
+			break
+		}
+		if len(done) == 0 {
+			break
+		}
+		l.used = l.used[:done[len(done)-1].mark]
+		done = done[:len(done)-1]
+	}
+	r, g, b := pdf.GetTextColor()
+	for _, p := range done {
+		pdf.SetTextColor(r, g, b)
+		color := p.ev.Color
+		if color == "" {
+			color = dayColor
+		}
+		if color != "" {
+			setTextColor(pdf, color)
+		}
+		if p.ev.Kind == "tentative" {
+			// halfway to white
+			tr, tg, tb := pdf.GetTextColor()
+			pdf.SetTextColor((tr+255)/2, (tg+255)/2, (tb+255)/2)
+		}
+		for k, r := range p.rs {
+			if k == 0 && (p.ev.Kind == "todo" || p.ev.Kind == "done") {
+				drawCheckbox(pdf, r.x, r.y, lineHeight, p.ev.Kind == "done")
+				r.x += lineHeight
+			}
+			drawSpans(pdf, font, size, r.x, r.y+ASCENT*lineHeight, p.lines[k])
+		}
+	}
+	pdf.SetTextColor(r, g, b)
+}
+
+// drawCheckbox draws the checkbox of a to-do at the start of a line
+// of height h, ticked when it is done, in the color of the text.
+func drawCheckbox(pdf *gofpdf.Fpdf, x, y, h float64, done bool) {
+	s := 0.6 * h
+	x, y = x+0.1*h, y+0.5*(h-s)
+	dr, dg, db := pdf.GetDrawColor()
+	lw := pdf.GetLineWidth()
+	pdf.SetDrawColor(pdf.GetTextColor())
+	pdf.SetLineWidth(0.08 * h)
+	pdf.Rect(x, y, s, s, "D")
+	if done {
+		pdf.MoveTo(x+0.2*s, y+0.5*s)
+		pdf.LineTo(x+0.45*s, y+0.75*s)
+		pdf.LineTo(x+0.85*s, y+0.2*s)
+		pdf.DrawPath("D")
+	}
+	pdf.SetLineWidth(lw)
+	pdf.SetDrawColor(dr, dg, db)
+}
@@ -0,0 +1,76 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// legend.go
+//
+// The legend of the monthly calendar: the ICS calendars with their
+// colors and names, left of the footer.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"path"
+)
+
+// LEGENDFONTSIZE is the font size of the legend in points.
+const LEGENDFONTSIZE = 9.0
+
+// SetLegend prints the names of the ICS calendars in their colors at
+// the bottom of the months.
+func (g *Calendar) SetLegend() {
+	g.OptLegend = true
+}
+
+// legendEntries returns the names and colors of the ICS files that
+// were read; calendars without a name have that of the file.
+func (g *Calendar) legendEntries() (entries []icsSource) {
+	for _, f := range g.OptICS {
+		src := g.icsReader().source(f)
+		if src.name == "" && src.color == "" {
+			continue
+		}
+		if src.name == "" {
+			src.name = path.Base(f)
+		}
+		entries = append(entries, src)
+	}
+	return entries
+}
+
+// drawLegend prints the legend from the left margin on the line of
+// the footer, up to the footer, and leaves the font of the footer.
+func (g *Calendar) drawLegend(pdf *gofpdf.Fpdf, fonts elementFonts, fontScale float64, y float64, maxX float64) {
+	if !g.OptLegend {
+		return
+	}
+	entries := g.legendEntries()
+	if len(entries) == 0 {
+		return
+	}
+	fr, fg, fb := pdf.GetFillColor()
+	defer pdf.SetFillColor(fr, fg, fb)
+	defer fonts.set(pdf, "footer", FOOTERFONTSIZE*fontScale)
+	size := LEGENDFONTSIZE * fontScale
+	fonts.set(pdf, "footer", size)
+	box := pdf.PointConvert(size) * 0.7
+	x := MARGIN
+	for _, e := range entries {
+		name := convertCP(e.name)
+		w := box + 1.5 + pdf.GetStringWidth(name)
+		if x+w > maxX {
+			break
+		}
+		if e.color != "" {
+			r, gr, b, _ := parseColor(e.color)
+			pdf.SetFillColor(r, gr, b)
+			pdf.Rect(x, y-box, box, box, "F")
+		}
+		g.setFooterColor(pdf)
+		pdf.Text(x+box+1.5, y, name)
+		x += w + 4
+	}
+}
@@ -0,0 +1,148 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// limits.go
+//
+// Limits of the configurations, ICS calendars and recipient lists
+// that gocal reads, which may come from anyone in serve mode: their
+// size, the nesting of the XML, and texts that aren't valid UTF-8.
+//
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// MAXINPUTSIZE is the largest configuration, ICS calendar or
+// recipients file that is read, in bytes.
+const MAXINPUTSIZE = 16 << 20
+
+// MAXXMLDEPTH is the deepest nesting of elements in a configuration;
+// the elements gocal knows are nested two deep.
+const MAXXMLDEPTH = 32
+
+// MAXRECIPIENTS is the largest number of rows of a recipients file.
+const MAXRECIPIENTS = 100000
+
+// checkInputSize returns an error if the data of name is larger than
+// MAXINPUTSIZE.
+func checkInputSize(data []byte, name string) error {
+	if len(data) > MAXINPUTSIZE {
+		return fmt.Errorf("%s is larger than %d bytes", name, MAXINPUTSIZE)
+	}
+	return nil
+}
+
+// validUTF8 replaces the bytes of data that aren't UTF-8 by the
+// replacement character, with a warning of warn.
+func validUTF8(data []byte, name string, warn func(kind string, format string, v ...interface{})) []byte {
+	if utf8.Valid(data) {
+		return data
+	}
+	warn("field", "%s is not valid UTF-8, the invalid bytes are replaced", name)
+	return bytes.ToValidUTF8(data, []byte("\uFFFD"))
+}
+
+// checkXMLDepth returns an error if the elements of the XML data are
+// nested deeper than MAXXMLDEPTH.
+func checkXMLDepth(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			// Syntax errors are reported by the unmarshaling.
+			return nil
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			if depth++; depth > MAXXMLDEPTH {
+				return fmt.Errorf("the elements are nested deeper than %d", MAXXMLDEPTH)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// parseConfiguration checks and unmarshals the XML configuration
+// name. Bytes that aren't UTF-8 are replaced, the calendar warns
+// about them when it reads the file.
+func parseConfiguration(data []byte, name string) (v TelegramStore, err error) {
+	if err = checkInputSize(data, name); err != nil {
+		return v, err
+	}
+	data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	if err = checkXMLDepth(data); err != nil {
+		return v, err
+	}
+	err = xml.Unmarshal(data, &v)
+	return v, err
+}
+
+// SetFileRoot makes the calendar read the images, logos, icons and
+// fonts that the configuration files name only in the directory, for
+// configurations it doesn't trust. Relative names are relative to it.
+func (g *Calendar) SetFileRoot(dir string) {
+	g.OptFileRoot = dir
+}
+
+// rootedFile returns the file that a configuration names, in the
+// directory of SetFileRoot if there is one. A file outside of it is
+// refused with a warning. URLs are left to the remote policy.
+func (g *Calendar) rootedFile(file string) (string, bool) {
+	if g.OptFileRoot == "" || file == "" || strings.Contains(file, "://") {
+		return file, true
+	}
+	root, err := filepath.Abs(g.OptFileRoot)
+	if err != nil {
+		return "", false
+	}
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	real := path
+	if r, err := filepath.EvalSymlinks(path); err == nil {
+		real = r
+	}
+	if rel, err := filepath.Rel(root, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		g.warnf("field", "Ignoring %s, it is outside of the file root", file)
+		return "", false
+	}
+	return path, true
+}
+
+// rootedFiles is rootedFile for files separated by commas, e.g. the
+// images of an event.
+func (g *Calendar) rootedFiles(list string) string {
+	if g.OptFileRoot == "" {
+		return list
+	}
+	var files []string
+	for _, f := range strings.Split(list, ",") {
+		if f, ok := g.rootedFile(strings.TrimSpace(f)); ok && f != "" {
+			files = append(files, f)
+		}
+	}
+	return strings.Join(files, ",")
+}
+
+// CheckConfig returns the error of the XML configuration data, which
+// would otherwise end the program when the calendar is created. The
+// browser checks the configuration it gets with it.
+func CheckConfig(data []byte) error {
+	_, err := parseConfiguration(data, "the configuration")
+	return err
+}
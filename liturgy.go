@@ -0,0 +1,110 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// liturgy.go
+//
+// Seasons and movable feasts of the Western Christian
+// liturgical year.
+//
+
+import (
+	"time"
+)
+
+// easterSunday computes the date of Easter in the Gregorian
+// calendar (anonymous Gregorian algorithm).
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// adventSunday returns the first Sunday of Advent, the fourth
+// Sunday before Christmas.
+func adventSunday(year int) time.Time {
+	christmas := time.Date(year, 12, 25, 0, 0, 0, 0, time.UTC)
+	offset := int(christmas.Weekday())
+	if offset == 0 {
+		offset = 7
+	}
+	return christmas.AddDate(0, 0, -offset-21)
+}
+
+// baptismOfTheLord returns the Sunday after Epiphany which
+// ends the Christmas season.
+func baptismOfTheLord(year int) time.Time {
+	epiphany := time.Date(year, 1, 6, 0, 0, 0, 0, time.UTC)
+	return epiphany.AddDate(0, 0, 7-int(epiphany.Weekday()))
+}
+
+// liturgicalEvents returns the feasts of the year.
+func liturgicalEvents(year int) (eL []gDate) {
+	easter := easterSunday(year)
+	feasts := []struct {
+		day  time.Time
+		text string
+	}{
+		{time.Date(year, 1, 6, 0, 0, 0, 0, time.UTC), "Epiphany"},
+		{easter.AddDate(0, 0, -46), "Ash Wednesday"},
+		{easter.AddDate(0, 0, -7), "Palm Sunday"},
+		{easter.AddDate(0, 0, -3), "Maundy Thursday"},
+		{easter.AddDate(0, 0, -2), "Good Friday"},
+		{easter, "Easter Sunday"},
+		{easter.AddDate(0, 0, 1), "Easter Monday"},
+		{easter.AddDate(0, 0, 39), "Ascension"},
+		{easter.AddDate(0, 0, 49), "Pentecost"},
+		{easter.AddDate(0, 0, 50), "Whit Monday"},
+		{easter.AddDate(0, 0, 56), "Trinity Sunday"},
+		{easter.AddDate(0, 0, 60), "Corpus Christi"},
+		{time.Date(year, 11, 1, 0, 0, 0, 0, time.UTC), "All Saints"},
+		{adventSunday(year), "1st Advent"},
+		{adventSunday(year).AddDate(0, 0, 7), "2nd Advent"},
+		{adventSunday(year).AddDate(0, 0, 14), "3rd Advent"},
+		{adventSunday(year).AddDate(0, 0, 21), "4th Advent"},
+		{time.Date(year, 12, 25, 0, 0, 0, 0, time.UTC), "Christmas"},
+	}
+	for _, f := range feasts {
+		eL = append(eL, gDate{f.day.Month(), f.day.Day(), f.text, "", "", "liturgical", "", "", "", "", 0, ""})
+	}
+	return eL
+}
+
+// liturgicalColor returns the color of the liturgical season
+// of the day.
+func liturgicalColor(d time.Time) (r, g, b int) {
+	year := d.Year()
+	easter := easterSunday(year)
+	switch {
+	case d.Before(baptismOfTheLord(year).AddDate(0, 0, 1)):
+		return 230, 190, 60 // Christmas: white/gold
+	case d.Before(easter.AddDate(0, 0, -46)):
+		return 40, 140, 60 // Ordinary time: green
+	case d.Before(easter):
+		return 120, 50, 150 // Lent: purple
+	case d.Before(easter.AddDate(0, 0, 49)):
+		return 230, 190, 60 // Easter: white/gold
+	case d.Equal(easter.AddDate(0, 0, 49)):
+		return 200, 30, 30 // Pentecost: red
+	case d.Before(adventSunday(year)):
+		return 40, 140, 60 // Ordinary time: green
+	case d.Before(time.Date(year, 12, 25, 0, 0, 0, 0, time.UTC)):
+		return 120, 50, 150 // Advent: purple
+	}
+	return 230, 190, 60 // Christmas: white/gold
+}
@@ -0,0 +1,154 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// markup.go
+//
+// Minimal markup of event texts: *bold* and _italic_. Line breaks
+// are written as \n.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"strings"
+)
+
+// textSpan is a part of a line of an event text with its style.
+type textSpan struct {
+	text   string
+	bold   bool
+	italic bool
+}
+
+// isWordByte reports whether c is part of a word, so that markers
+// inside words like snake_case stay text.
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// parseMarkup splits a line of an event text at the markers *bold*
+// and _italic_. A marker opens at the start of a word and closes at
+// its end; other markers, and those escaped as \* or \_, are text.
+func parseMarkup(line string) (spans []textSpan) {
+	var cur textSpan
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			cur.text = b.String()
+			spans = append(spans, cur)
+			b.Reset()
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && (line[i+1] == '*' || line[i+1] == '_') {
+			b.WriteByte(line[i+1])
+			i++
+			continue
+		}
+		if c != '*' && c != '_' {
+			b.WriteByte(c)
+			continue
+		}
+		on := cur.bold
+		if c == '_' {
+			on = cur.italic
+		}
+		var toggle bool
+		if on {
+			toggle = i > 0 && line[i-1] != ' ' && (i+1 == len(line) || !isWordByte(line[i+1]))
+		} else {
+			toggle = (i == 0 || !isWordByte(line[i-1])) && i+1 < len(line) && line[i+1] != ' ' &&
+				strings.IndexByte(line[i+1:], c) >= 0
+		}
+		if !toggle {
+			b.WriteByte(c)
+			continue
+		}
+		flush()
+		if c == '*' {
+			cur.bold = !on
+		} else {
+			cur.italic = !on
+		}
+	}
+	flush()
+	return spans
+}
+
+// isCoreFamily reports whether the font is a PDF core font, which
+// has real bold and italic styles.
+func isCoreFamily(family string) bool {
+	for _, core := range coreFamilies {
+		if core == family {
+			return true
+		}
+	}
+	return false
+}
+
+// setSpanFont selects the font of the span. Fonts from TrueType
+// files have a single style, so their bold and italic are drawn by
+// drawSpans.
+func setSpanFont(pdf *gofpdf.Fpdf, font elementFont, size float64, span textSpan) {
+	style := font.style
+	if isCoreFamily(font.family) {
+		if span.bold && !strings.Contains(style, "B") {
+			style = "B" + style
+		}
+		if span.italic && !strings.Contains(style, "I") {
+			style += "I"
+		}
+	}
+	pdf.SetFont(font.family, style, size)
+}
+
+// spansWidth returns the width of a line of spans.
+func spansWidth(pdf *gofpdf.Fpdf, font elementFont, size float64, spans []textSpan) (w float64) {
+	for _, s := range spans {
+		setSpanFont(pdf, font, size, s)
+		w += pdf.GetStringWidth(s.text)
+	}
+	pdf.SetFont(font.family, font.style, size)
+	return w
+}
+
+// drawSpans prints a line of spans with its baseline at y. For fonts
+// without styles, bold is printed twice with a small offset and
+// italic slanted.
+func drawSpans(pdf *gofpdf.Fpdf, font elementFont, size float64, x, y float64, spans []textSpan) {
+	core := isCoreFamily(font.family)
+	for _, s := range spans {
+		setSpanFont(pdf, font, size, s)
+		if s.italic && !core {
+			pdf.TransformBegin()
+			pdf.TransformSkewX(12, x, y)
+		}
+		pdf.Text(x, y, s.text)
+		if s.bold && !core {
+			pdf.Text(x+size/100, y, s.text)
+		}
+		if s.italic && !core {
+			pdf.TransformEnd()
+		}
+		x += pdf.GetStringWidth(s.text)
+	}
+	pdf.SetFont(font.family, font.style, size)
+}
+
+// plainText removes the markup from an event text, the lines are
+// joined by newlines.
+func plainText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\\n") {
+		var b strings.Builder
+		for _, s := range parseMarkup(line) {
+			b.WriteString(s.text)
+		}
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,152 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// metrics.go
+//
+// The metrics of the server mode in the text format of Prometheus:
+// the render durations, the pages, the jobs, the errors by kind and
+// the lookups of the font and image caches.
+//
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// renderBuckets are the upper bounds of the buckets of the render
+// durations, in seconds.
+var renderBuckets = []float64{0.5, 1, 2, 5, 10, 30, 60, 120}
+
+var (
+	metricsMutex sync.Mutex
+	// errorCounts counts the errors and warnings by kind.
+	errorCounts = make(map[string]int64)
+	// cacheCounts counts the misses and the hits of the caches.
+	cacheCounts = make(map[string][2]int64)
+)
+
+// countError counts an error of the kind.
+func countError(kind string) {
+	metricsMutex.Lock()
+	errorCounts[kind]++
+	metricsMutex.Unlock()
+}
+
+// countCache counts a lookup in the cache.
+func countCache(cache string, hit bool) {
+	metricsMutex.Lock()
+	c := cacheCounts[cache]
+	if hit {
+		c[1]++
+	} else {
+		c[0]++
+	}
+	cacheCounts[cache] = c
+	metricsMutex.Unlock()
+}
+
+// histogram counts observations in the renderBuckets.
+type histogram struct {
+	buckets []int64
+	sum     float64
+	count   int64
+}
+
+// observe counts the value v.
+func (h *histogram) observe(v float64) {
+	if h.buckets == nil {
+		h.buckets = make([]int64, len(renderBuckets))
+	}
+	for i, le := range renderBuckets {
+		if v <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metricHeader writes the help and the type of a metric.
+func metricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sortedKeys returns the keys of the counts, sorted.
+func sortedKeys(m map[string]int64) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeMetrics writes the metrics of the jobs of the server and of
+// the package.
+func (s *JobServer) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	states := map[string]int64{JobQueued: 0, JobRunning: 0}
+	var used int64
+	for _, j := range s.jobs {
+		if j.Status == JobQueued || j.Status == JobRunning {
+			states[j.Status]++
+		}
+		used += j.Bytes
+	}
+	finished := make(map[string]int64)
+	for k, v := range s.finished {
+		finished[k] = v
+	}
+	renders, pages := s.renders, s.pages
+	buckets := append([]int64(nil), renders.buckets...)
+	s.mu.Unlock()
+
+	metricHeader(w, "gocal_render_duration_seconds", "histogram", "Time to create a calendar.")
+	for i, le := range renderBuckets {
+		var n int64
+		if buckets != nil {
+			n = buckets[i]
+		}
+		fmt.Fprintf(w, "gocal_render_duration_seconds_bucket{le=\"%g\"} %d\n", le, n)
+	}
+	fmt.Fprintf(w, "gocal_render_duration_seconds_bucket{le=\"+Inf\"} %d\n", renders.count)
+	fmt.Fprintf(w, "gocal_render_duration_seconds_sum %g\n", renders.sum)
+	fmt.Fprintf(w, "gocal_render_duration_seconds_count %d\n", renders.count)
+
+	metricHeader(w, "gocal_pages_total", "counter", "Pages of the created calendars.")
+	fmt.Fprintf(w, "gocal_pages_total %d\n", pages)
+
+	metricHeader(w, "gocal_jobs_total", "counter", "Finished jobs by status.")
+	for _, k := range sortedKeys(finished) {
+		fmt.Fprintf(w, "gocal_jobs_total{status=%q} %d\n", k, finished[k])
+	}
+	metricHeader(w, "gocal_jobs", "gauge", "Jobs waiting or running.")
+	for _, k := range sortedKeys(states) {
+		fmt.Fprintf(w, "gocal_jobs{status=%q} %d\n", k, states[k])
+	}
+	metricHeader(w, "gocal_jobs_bytes", "gauge", "Disk space of the jobs.")
+	fmt.Fprintf(w, "gocal_jobs_bytes %d\n", used)
+
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	metricHeader(w, "gocal_errors_total", "counter", "Errors and warnings by kind.")
+	for _, k := range sortedKeys(errorCounts) {
+		fmt.Fprintf(w, "gocal_errors_total{kind=%q} %d\n", k, errorCounts[k])
+	}
+	metricHeader(w, "gocal_cache_lookups_total", "counter", "Lookups of the font and image caches.")
+	var caches []string
+	for k := range cacheCounts {
+		caches = append(caches, k)
+	}
+	sort.Strings(caches)
+	for _, k := range caches {
+		fmt.Fprintf(w, "gocal_cache_lookups_total{cache=%q,result=\"hit\"} %d\n", k, cacheCounts[k][1])
+		fmt.Fprintf(w, "gocal_cache_lookups_total{cache=%q,result=\"miss\"} %d\n", k, cacheCounts[k][0])
+	}
+}
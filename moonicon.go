@@ -0,0 +1,117 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// moonicon.go
+//
+// Position, size and opacity of the moon symbol in the day cells, so
+// that it leaves room for dense event text.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// moonAnchors are the centers of the moon symbol in the cell, as parts
+// of its width and height.
+var moonAnchors = map[string][2]float64{
+	"TL": {0.18, 0.2},
+	"TR": {0.82, 0.2},
+	"BL": {0.18, 0.8},
+	"BR": {0.82, 0.8},
+	"C":  {0.5, 0.5},
+}
+
+// SetMoonAnchor places the moon symbol in the cell: TL, TR (default),
+// BL, BR or C.
+func (g *Calendar) SetMoonAnchor(anchor string) {
+	anchor = strings.ToUpper(anchor)
+	if _, ok := moonAnchors[anchor]; !ok && anchor != "" {
+		fmt.Printf("# Error, unknown moon anchor '%s', use TL, TR, BL, BR or C\n", anchor)
+		return
+	}
+	g.OptMoonAnchor = anchor
+}
+
+// SetMoonSize sets the diameter of the moon symbol in mm, e.g. "5" or
+// "5mm", or relative to the smaller side of the cell, e.g. "20%".
+func (g *Calendar) SetMoonSize(size string) {
+	if _, _, err := parseMoonSize(size); err != nil {
+		fmt.Printf("# Error, %v\n", err)
+		return
+	}
+	g.OptMoonSize = size
+}
+
+// SetMoonOpacity sets the opacity of the moon symbol from 0 to 1
+// (opaque, default); e.g. 0.4 lets the events behind it show through.
+func (g *Calendar) SetMoonOpacity(opacity float64) {
+	if opacity < 0 || opacity > 1 {
+		fmt.Printf("# Error, invalid moon opacity %v, expected 0 to 1\n", opacity)
+		return
+	}
+	g.OptMoonOpacity = opacity
+}
+
+// parseMoonSize reads a size of the moon symbol in mm or, with
+// relative, in percent of the cell.
+func parseMoonSize(size string) (v float64, relative bool, err error) {
+	s := strings.TrimSpace(size)
+	if s == "" {
+		return 0, false, nil
+	}
+	if strings.HasSuffix(s, "%") {
+		s, relative = strings.TrimSuffix(s, "%"), true
+	} else {
+		s = strings.TrimSuffix(s, "mm")
+	}
+	v, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v <= 0 || relative && v > 100 {
+		return 0, false, fmt.Errorf("invalid moon size '%s', expected mm like 5 or percent like 20%%", size)
+	}
+	return v, relative, nil
+}
+
+// moonPlace returns the center and radius of the moon symbol in the
+// cell. The anchor def and the radius r apply unless the options set
+// others.
+func (g *Calendar) moonPlace(cell rect, def [2]float64, r float64) (cx, cy, radius float64) {
+	if a, ok := moonAnchors[g.OptMoonAnchor]; ok {
+		def = a
+	}
+	if v, relative, err := parseMoonSize(g.OptMoonSize); err == nil && v > 0 {
+		r = v / 2
+		if relative {
+			r = math.Min(cell.w, cell.h) * v / 200
+		}
+	}
+	return cell.x + cell.w*def[0], cell.y + cell.h*def[1], r
+}
+
+// drawMoonSymbol draws the symbol of the moon phase with the opacity.
+func (g *Calendar) drawMoonSymbol(pdf *gofpdf.Fpdf, phase string, cx, cy, r float64) {
+	if g.OptMoonOpacity < 1 {
+		pdf.SetAlpha(g.OptMoonOpacity, "Normal")
+		defer pdf.SetAlpha(1, "Normal")
+	}
+	myMoonPDF := myPdf{pdf, r}
+	pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
+	pdf.SetDashPattern([]float64{}, 0)
+	switch phase {
+	case "Full":
+		myMoonPDF.fullMoon(cx, cy)
+	case "New":
+		myMoonPDF.newMoon(cx, cy)
+	case "First":
+		myMoonPDF.firstQuarter(cx, cy)
+	case "Last":
+		myMoonPDF.lastQuarter(cx, cy)
+	}
+}
@@ -0,0 +1,91 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// moonlabels.go
+//
+// The names of the moon phases in the languages of the calendar, next
+// to the moon symbols or instead of them.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"strings"
+)
+
+// moonPhaseNames are the names of the moon phases by language, in the
+// order of moonPhases. The English names are the keys of the names
+// file.
+var moonPhaseNames = map[string][4]string{
+	"en": {"New moon", "First quarter", "Full moon", "Last quarter"},
+	"ca": {"Lluna nova", "Quart creixent", "Lluna plena", "Quart minvant"},
+	"da": {"Nymåne", "Første kvarter", "Fuldmåne", "Sidste kvarter"},
+	"de": {"Neumond", "Erstes Viertel", "Vollmond", "Letztes Viertel"},
+	"es": {"Luna nueva", "Cuarto creciente", "Luna llena", "Cuarto menguante"},
+	"fi": {"Uusikuu", "Ensimmäinen neljännes", "Täysikuu", "Viimeinen neljännes"},
+	"fr": {"Nouvelle lune", "Premier quartier", "Pleine lune", "Dernier quartier"},
+	"hu": {"Újhold", "Első negyed", "Telihold", "Utolsó negyed"},
+	"it": {"Luna nuova", "Primo quarto", "Luna piena", "Ultimo quarto"},
+	"nb": {"Nymåne", "Første kvarter", "Fullmåne", "Siste kvarter"},
+	"nl": {"Nieuwe maan", "Eerste kwartier", "Volle maan", "Laatste kwartier"},
+	"nn": {"Nymåne", "Første kvarter", "Fullmåne", "Siste kvarter"},
+	"pt": {"Lua nova", "Quarto crescente", "Lua cheia", "Quarto minguante"},
+	"sv": {"Nymåne", "Första kvarteret", "Fullmåne", "Sista kvarteret"},
+}
+
+// SetMoonLabels selects how the moon phases are shown: "symbols"
+// (default), "names" for the symbol with the name of the phase or
+// "text" for the name only. The names are those of the language, or
+// of the names file, keyed by the English name, e.g. "Full moon = FM".
+func (g *Calendar) SetMoonLabels(mode string) {
+	switch mode {
+	case "", "symbols", "names", "text":
+		g.OptMoonLabels = mode
+	default:
+		g.warnf("field", "Unknown moon labels '%s', use symbols, names or text", mode)
+	}
+}
+
+// moonSymbols reports whether the moon phases are drawn as symbols.
+func (g *Calendar) moonSymbols() bool {
+	return g.OptMoonLabels != "text"
+}
+
+// moonNames reports whether the names of the moon phases are printed.
+func (g *Calendar) moonNames() bool {
+	return g.OptMoonLabels == "names" || g.OptMoonLabels == "text"
+}
+
+// moonPhaseLabels returns the names of the moon phases of the language
+// by their keys, with the custom names applied.
+func (g *Calendar) moonPhaseLabels(lang string) map[string]string {
+	names, ok := moonPhaseNames[strings.SplitN(lang, "_", 2)[0]]
+	if !ok {
+		names = moonPhaseNames["en"]
+	}
+	var custom map[string]string
+	if g.OptNames != "" {
+		custom = readNamesfile(g.OptNames)
+	}
+	labels := make(map[string]string)
+	for i, p := range moonPhases {
+		labels[p.name] = convertCP(names[i])
+		if n, ok := custom[moonPhaseNames["en"][i]]; ok {
+			labels[p.name] = n
+		}
+	}
+	return labels
+}
+
+// drawMoonLabel prints the name of a moon phase in small type on the
+// right of the cell, if there is room.
+func drawMoonLabel(pdf *gofpdf.Fpdf, layout *cellLayout, fonts elementFonts, label string, size float64) {
+	fonts.set(pdf, "small", size)
+	_, h := pdf.GetFontSize()
+	if r, ok := layout.placeRight(pdf.GetStringWidth(label), h); ok {
+		pdf.Text(r.x, r.y+ASCENT*h, label)
+	}
+}
@@ -0,0 +1,139 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// notes.go
+//
+// Rulings of note areas: blank, ruled, dot grid or squared, with their
+// spacing and line weight, for the filler pages and the notes on the
+// tracker pages.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SQUARESPACING is the size of the squares on squared pages.
+	SQUARESPACING = 5.0
+	// RULINGWEIGHT is the width of the lines of a ruling.
+	RULINGWEIGHT = 0.2
+	// DOTWEIGHT is the diameter of the dots of a dot grid.
+	DOTWEIGHT = 0.5
+)
+
+// ruling is the style of a note area with the distance of its lines or
+// dots and their weight, the line width or the dot diameter, in mm.
+type ruling struct {
+	style   string
+	spacing float64
+	weight  float64
+}
+
+// parseRuling reads a ruling as style[:spacing[:weight]], e.g. "ruled",
+// "dots:4" or "squared:5:0.1", with the styles blank, ruled, dots and
+// squared.
+func parseRuling(spec string) (r ruling, err error) {
+	parts := strings.Split(spec, ":")
+	r.style = strings.ToLower(strings.TrimSpace(parts[0]))
+	switch r.style {
+	case "blank":
+	case "ruled":
+		r.spacing, r.weight = RULEDLINESPACING, RULINGWEIGHT
+	case "dots":
+		r.spacing, r.weight = DOTSPACING, DOTWEIGHT
+	case "squared":
+		r.spacing, r.weight = SQUARESPACING, RULINGWEIGHT
+	default:
+		return r, fmt.Errorf("unknown ruling '%s'", parts[0])
+	}
+	if len(parts) > 3 {
+		return r, fmt.Errorf("invalid ruling '%s', expected style:spacing:weight", spec)
+	}
+	for i, v := range []*float64{&r.spacing, &r.weight} {
+		if len(parts) < i+2 || parts[i+1] == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(parts[i+1]), 64)
+		if err != nil || f <= 0 {
+			return r, fmt.Errorf("invalid ruling '%s', expected style:spacing:weight", spec)
+		}
+		*v = f
+	}
+	return r, nil
+}
+
+// drawRuling draws the ruling into the area: the lines of a ruled area
+// from its top, the dots of a dot grid on its corners and the squares
+// of a squared area as many as fit.
+func drawRuling(pdf *gofpdf.Fpdf, area rect, r ruling) {
+	if r.spacing <= 0 {
+		return
+	}
+	lw := pdf.GetLineWidth()
+	defer pdf.SetLineWidth(lw)
+	dr, dg, db := pdf.GetDrawColor()
+	defer pdf.SetDrawColor(dr, dg, db)
+	fr, fg, fb := pdf.GetFillColor()
+	defer pdf.SetFillColor(fr, fg, fb)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetDrawColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
+	pdf.SetLineWidth(r.weight)
+	right, bottom := area.x+area.w, area.y+area.h
+	switch r.style {
+	case "ruled":
+		for y := area.y; y < bottom; y += r.spacing {
+			pdf.Line(area.x, y, right, y)
+		}
+	case "dots":
+		pdf.SetFillColor(DARKGREY, DARKGREY, DARKGREY)
+		for y := area.y; y <= bottom; y += r.spacing {
+			for x := area.x; x <= right; x += r.spacing {
+				pdf.Circle(x, y, r.weight/2, "F")
+			}
+		}
+	case "squared":
+		nx, ny := int(area.w/r.spacing), int(area.h/r.spacing)
+		for i := 0; i <= nx; i++ {
+			x := area.x + float64(i)*r.spacing
+			pdf.Line(x, area.y, x, area.y+float64(ny)*r.spacing)
+		}
+		for i := 0; i <= ny; i++ {
+			y := area.y + float64(i)*r.spacing
+			pdf.Line(area.x, y, area.x+float64(nx)*r.spacing, y)
+		}
+	}
+}
+
+// SetNotes adds a notes area with the ruling, e.g. "ruled" or
+// "dots:4:0.4", below the tables of the tracker page after every month,
+// or as the whole tracker page without habits and budget.
+func (g *Calendar) SetNotes(spec string) {
+	g.OptNotes = spec
+}
+
+// drawNotes fills the rest of the page from y with the notes area.
+func (g *Calendar) drawNotes(pdf *gofpdf.Fpdf, calFont string, fontScale float64, y float64) {
+	if g.OptNotes == "" {
+		return
+	}
+	r, err := parseRuling(g.OptNotes)
+	if err != nil {
+		fmt.Printf("# Error, no notes area: %v\n", err)
+		return
+	}
+	w, h := pdf.GetPageSize()
+	pdf.SetFont(calFont, "", TRACKERFONTSIZE*fontScale)
+	pdf.SetXY(MARGIN, y)
+	pdf.CellFormat(w-2*MARGIN, TRACKERROWHEIGHT, "Notes", "", 0, "L", false, 0, "")
+	y += TRACKERROWHEIGHT
+	if y < h-MARGIN {
+		drawRuling(pdf, rect{MARGIN, y, w - 2*MARGIN, h - MARGIN - y}, r)
+	}
+}
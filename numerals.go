@@ -0,0 +1,93 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// numerals.go
+//
+// Day numbers in the numerals of the locale.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"io/ioutil"
+	"strings"
+)
+
+// NUMERALFONT is the font family of the non-Western numerals.
+const NUMERALFONT = "gocalnumerals"
+
+// numeralZeros are the digits zero of the numeral systems.
+var numeralZeros = map[string]rune{
+	"latn":    '0',
+	"arab":    '٠', // Arabic-Indic
+	"arabext": '۰', // Persian, Urdu
+	"deva":    '०', // Devanagari
+}
+
+// localeNumerals are the numeral systems of locales for "auto".
+var localeNumerals = map[string]string{
+	"ar": "arab",
+	"fa": "arabext",
+	"ur": "arabext",
+	"hi": "deva",
+	"mr": "deva",
+	"ne": "deva",
+}
+
+// numeralSystem resolves "auto" by the language of the locale.
+func numeralSystem(system string, locale string) string {
+	if system != "auto" {
+		return system
+	}
+	if s, ok := localeNumerals[strings.SplitN(locale, "_", 2)[0]]; ok {
+		return s
+	}
+	return "latn"
+}
+
+// localizeDigits replaces the Western digits of s by the
+// digits of the numeral system.
+func localizeDigits(s string, system string) string {
+	zero, ok := numeralZeros[system]
+	if !ok || zero == '0' {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return zero + r - '0'
+		}
+		return r
+	}, s)
+}
+
+// loadNumeralFont adds the UTF-8 font for the numerals. The
+// built-in serif font has Arabic-Indic, Persian and Devanagari
+// digits. It returns the resolved numeral system.
+func (g *Calendar) loadNumeralFont(pdf *gofpdf.Fpdf) string {
+	system := numeralSystem(g.OptNumerals, g.OptLocale)
+	if _, ok := numeralZeros[system]; !ok {
+		g.warnf("field", "Unknown numerals '%s', use latn, arab, arabext, deva or auto", g.OptNumerals)
+		return "latn"
+	}
+	if system == "latn" {
+		return system
+	}
+	font := freeserifbold
+	if g.OptNumeralFont != "" {
+		file, ok := g.rootedFile(g.OptNumeralFont)
+		if !ok {
+			return "latn"
+		}
+		var err error
+		font, err = ioutil.ReadFile(file)
+		if err != nil {
+			g.warnf("font", "Error reading numeral font: %v", err)
+			return "latn"
+		}
+	}
+	pdf.AddUTF8FontFromBytes(NUMERALFONT, "", font)
+	return system
+}
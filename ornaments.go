@@ -0,0 +1,239 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// ornaments.go
+//
+// A small library of vector motifs, scattered over the page as a
+// background or lined up along its edges as a border. Vectors keep
+// the file small, unlike a wallpaper image.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BACKGROUNDSPACING is the distance of the motifs of a background.
+const BACKGROUNDSPACING = 24.0
+
+// BACKGROUNDSIZE is the size of the motifs of a background.
+const BACKGROUNDSIZE = 8.0
+
+// BORDERSIZE is the size of the motifs of a border.
+const BORDERSIZE = 5.0
+
+// motif draws an ornament of the size around x, y in the fill and
+// the draw color.
+type motif func(pdf *gofpdf.Fpdf, x, y, size float64)
+
+// motifs are the ornaments by name.
+var motifs = map[string]motif{
+	"snowflakes": drawSnowflake,
+	"flowers":    drawFlower,
+	"suns":       drawSun,
+	"leaves":     drawLeaf,
+	"stars":      drawStar,
+	"hearts":     drawHeart,
+}
+
+// seasonalMotifs are the motifs of the seasons for "seasonal", from
+// winter to autumn, see seasonOf.
+var seasonalMotifs = [4]string{"snowflakes", "flowers", "suns", "leaves"}
+
+// motifNames returns the names of the motifs, sorted.
+func motifNames() []string {
+	var names []string
+	for n := range motifs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ornament is the motif of a month and its color.
+type ornament struct {
+	motif string
+	color [3]int
+}
+
+// parseOrnaments reads the motifs of the months from a list like
+// "stars", "seasonal:#c0d0ff" or "seasonal,12=hearts:#ffc0c0". An
+// entry without month is for every month, later entries win. The
+// color is optional. south selects the seasons of the southern
+// hemisphere.
+func parseOrnaments(spec string, defaultColor [3]int, south bool) (out [13]ornament, err error) {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		first, last := 1, 12
+		if i := strings.Index(entry, "="); i >= 0 {
+			m, err := strconv.Atoi(strings.TrimSpace(entry[:i]))
+			if err != nil || m < 1 || m > 12 {
+				return out, fmt.Errorf("invalid month in '%s'", entry)
+			}
+			first, last, entry = m, m, strings.TrimSpace(entry[i+1:])
+		}
+		name, color := entry, defaultColor
+		if i := strings.Index(entry, ":"); i >= 0 {
+			name = entry[:i]
+			color[0], color[1], color[2], err = parseColor(entry[i+1:])
+			if err != nil {
+				return out, err
+			}
+		}
+		if _, ok := motifs[name]; !ok && name != "seasonal" {
+			return out, fmt.Errorf("unknown motif '%s', use seasonal or one of %v", name, motifNames())
+		}
+		for m := first; m <= last; m++ {
+			out[m] = ornament{name, color}
+			if name == "seasonal" {
+				out[m].motif = seasonalMotifs[seasonOf(time.Month(m), south)]
+			}
+		}
+	}
+	return out, nil
+}
+
+// ornaments returns the backgrounds and the borders of the months.
+func (g *Calendar) ornaments() (backgrounds, borders [13]ornament) {
+	var err error
+	backgrounds, err = parseOrnaments(g.OptBackground, [3]int{WATERMARKGREY, WATERMARKGREY, WATERMARKGREY}, g.southern())
+	if err != nil {
+		fmt.Printf("# Error in background: %v\n", err)
+		backgrounds = [13]ornament{}
+	}
+	borders, err = parseOrnaments(g.OptBorder, [3]int{LIGHTGREY, LIGHTGREY, LIGHTGREY}, g.southern())
+	if err != nil {
+		fmt.Printf("# Error in border: %v\n", err)
+		borders = [13]ornament{}
+	}
+	return backgrounds, borders
+}
+
+// use sets the colors of the ornament and returns its motif, and a
+// function that restores the colors.
+func (o ornament) use(pdf *gofpdf.Fpdf) (motif, func()) {
+	fr, fg, fb := pdf.GetFillColor()
+	dr, dg, db := pdf.GetDrawColor()
+	lw := pdf.GetLineWidth()
+	pdf.SetFillColor(o.color[0], o.color[1], o.color[2])
+	pdf.SetDrawColor(o.color[0], o.color[1], o.color[2])
+	return motifs[o.motif], func() {
+		pdf.SetFillColor(fr, fg, fb)
+		pdf.SetDrawColor(dr, dg, db)
+		pdf.SetLineWidth(lw)
+	}
+}
+
+// drawBackground scatters the motif of the ornament over the area,
+// every other row shifted by half the spacing.
+func drawBackground(pdf *gofpdf.Fpdf, o ornament, r rect) {
+	if o.motif == "" {
+		return
+	}
+	draw, restore := o.use(pdf)
+	defer restore()
+	for row, y := 0, r.y+BACKGROUNDSPACING/2; y < r.y+r.h; row, y = row+1, y+BACKGROUNDSPACING {
+		x := r.x + BACKGROUNDSPACING/2
+		if row%2 == 1 {
+			x += BACKGROUNDSPACING / 2
+		}
+		for ; x < r.x+r.w; x += BACKGROUNDSPACING {
+			draw(pdf, x, y, BACKGROUNDSIZE)
+		}
+	}
+}
+
+// drawBorder lines the motif of the ornament up along the edges of
+// the area, evenly spaced with one in every corner.
+func drawBorder(pdf *gofpdf.Fpdf, o ornament, r rect) {
+	if o.motif == "" {
+		return
+	}
+	draw, restore := o.use(pdf)
+	defer restore()
+	d := BORDERSIZE * 0.8 // from the edge to the centers
+	x0, y0, x1, y1 := r.x+d, r.y+d, r.x+r.w-d, r.y+r.h-d
+	n := int(math.Max(1, math.Floor((x1-x0)/(2*BORDERSIZE))))
+	for i := 0; i <= n; i++ {
+		x := x0 + (x1-x0)*float64(i)/float64(n)
+		draw(pdf, x, y0, BORDERSIZE)
+		draw(pdf, x, y1, BORDERSIZE)
+	}
+	n = int(math.Max(1, math.Floor((y1-y0)/(2*BORDERSIZE))))
+	for i := 1; i < n; i++ {
+		y := y0 + (y1-y0)*float64(i)/float64(n)
+		draw(pdf, x0, y, BORDERSIZE)
+		draw(pdf, x1, y, BORDERSIZE)
+	}
+}
+
+// drawSnowflake draws six arms with two branches each.
+func drawSnowflake(pdf *gofpdf.Fpdf, x, y, size float64) {
+	pdf.SetLineWidth(size / 14)
+	for k := 0; k < 6; k++ {
+		a := float64(k) * math.Pi / 3
+		pdf.Line(x, y, x+math.Cos(a)*size/2, y+math.Sin(a)*size/2)
+		bx, by := x+math.Cos(a)*size*0.3, y+math.Sin(a)*size*0.3
+		for _, b := range []float64{a - math.Pi/4, a + math.Pi/4} {
+			pdf.Line(bx, by, bx+math.Cos(b)*size/6, by+math.Sin(b)*size/6)
+		}
+	}
+}
+
+// drawFlower draws five round petals.
+func drawFlower(pdf *gofpdf.Fpdf, x, y, size float64) {
+	for k := 0; k < 5; k++ {
+		a := float64(k)*2*math.Pi/5 - math.Pi/2
+		pdf.Circle(x+math.Cos(a)*size/4, y+math.Sin(a)*size/4, size/5, "F")
+	}
+}
+
+// drawSun draws a disc with eight rays.
+func drawSun(pdf *gofpdf.Fpdf, x, y, size float64) {
+	pdf.Circle(x, y, size/4, "F")
+	pdf.SetLineWidth(size / 12)
+	for k := 0; k < 8; k++ {
+		a := float64(k) * math.Pi / 4
+		pdf.Line(x+math.Cos(a)*size/3, y+math.Sin(a)*size/3, x+math.Cos(a)*size/2, y+math.Sin(a)*size/2)
+	}
+}
+
+// drawLeaf draws a slanted leaf with its stem.
+func drawLeaf(pdf *gofpdf.Fpdf, x, y, size float64) {
+	pdf.Ellipse(x, y, size*0.4, size/5, 45, "F")
+	pdf.SetLineWidth(size / 16)
+	pdf.Line(x-size*0.25, y+size*0.25, x-size/2, y+size/2)
+}
+
+// drawStar draws a five-pointed star.
+func drawStar(pdf *gofpdf.Fpdf, x, y, size float64) {
+	var points []gofpdf.PointType
+	for k := 0; k < 10; k++ {
+		a := float64(k)*math.Pi/5 - math.Pi/2
+		r := size / 2
+		if k%2 == 1 {
+			r = size / 5
+		}
+		points = append(points, gofpdf.PointType{X: x + math.Cos(a)*r, Y: y + math.Sin(a)*r})
+	}
+	pdf.Polygon(points, "F")
+}
+
+// drawHeart draws a heart of two discs and a triangle.
+func drawHeart(pdf *gofpdf.Fpdf, x, y, size float64) {
+	pdf.Circle(x-size*0.2, y-size*0.1, size*0.22, "F")
+	pdf.Circle(x+size*0.2, y-size*0.1, size*0.22, "F")
+	pdf.Polygon([]gofpdf.PointType{{X: x - size*0.41, Y: y - size*0.02}, {X: x + size*0.41, Y: y - size*0.02}, {X: x, Y: y + size*0.4}}, "F")
+}
@@ -0,0 +1,112 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// panchang.go
+//
+// The basics of the Hindu Panchang: the tithi, the lunar day, and the
+// nakshatra, the lunar mansion, at sunrise at a location.
+//
+
+import (
+	"math"
+	"time"
+)
+
+// tithiNames are the names of the tithis of each half of the lunar
+// month; the 15th is Purnima in the bright half and Amavasya in the
+// dark half.
+var tithiNames = [15]string{"Pratipada", "Dwitiya", "Tritiya", "Chaturthi", "Panchami",
+	"Shashthi", "Saptami", "Ashtami", "Navami", "Dashami", "Ekadashi", "Dwadashi",
+	"Trayodashi", "Chaturdashi", "Purnima"}
+
+// nakshatraNames are the 27 lunar mansions from 0 degrees sidereal.
+var nakshatraNames = [27]string{"Ashwini", "Bharani", "Krittika", "Rohini", "Mrigashira",
+	"Ardra", "Punarvasu", "Pushya", "Ashlesha", "Magha", "Purva Phalguni", "Uttara Phalguni",
+	"Hasta", "Chitra", "Swati", "Vishakha", "Anuradha", "Jyeshtha", "Mula", "Purva Ashadha",
+	"Uttara Ashadha", "Shravana", "Dhanishta", "Shatabhisha", "Purva Bhadrapada",
+	"Uttara Bhadrapada", "Revati"}
+
+// SetPanchang adds the tithi and the nakshatra at sunrise at the
+// location of SetLocation to every day.
+func (g *Calendar) SetPanchang() {
+	g.OptPanchang = true
+}
+
+// julianCenturies returns the Julian centuries of t since J2000.0.
+func julianCenturies(t time.Time) float64 {
+	return (float64(t.Unix())/86400 + 2440587.5 - J2000) / 36525
+}
+
+// normDeg returns the angle in degrees in [0, 360).
+func normDeg(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// sunMoonLongitudes computes the ecliptic longitudes of the sun and
+// the moon with the largest terms of chapters 25 and 47 of Meeus,
+// Astronomical Algorithms, to a few hundredths of a degree.
+func sunMoonLongitudes(t time.Time) (sun, moon float64) {
+	T := julianCenturies(t)
+	M := 357.5291092 + 35999.0502909*T
+	C := (1.914602-0.004817*T)*sinDeg(M) + 0.019993*sinDeg(2*M) + 0.000289*sinDeg(3*M)
+	sun = normDeg(280.46646 + 36000.76983*T + C)
+
+	L := 218.3164477 + 481267.88123421*T
+	D := 297.8501921 + 445267.1114034*T
+	Mm := 134.9633964 + 477198.8675055*T
+	F := 93.2720950 + 483202.0175233*T
+	moon = normDeg(L + 6.288774*sinDeg(Mm) + 1.274027*sinDeg(2*D-Mm) + 0.658314*sinDeg(2*D) +
+		0.213618*sinDeg(2*Mm) - 0.185116*sinDeg(M) - 0.114332*sinDeg(2*F) +
+		0.058793*sinDeg(2*D-2*Mm) + 0.057066*sinDeg(2*D-M-Mm) + 0.053322*sinDeg(2*D+Mm) +
+		0.045758*sinDeg(2*D-M) - 0.040923*sinDeg(M-Mm) - 0.034720*sinDeg(D) -
+		0.030383*sinDeg(M+Mm))
+	return sun, moon
+}
+
+// lahiriAyanamsa returns the Lahiri ayanamsa at t, the distance in
+// degrees of the sidereal zodiac from the tropical one.
+func lahiriAyanamsa(t time.Time) float64 {
+	return 23.853 + 1.3969*julianCenturies(t)
+}
+
+// panchangAt returns the tithi (0-29, 0-14 in the bright half) and the
+// nakshatra (0-26) of the longitudes of the sun and the moon.
+func panchangAt(sun, moon, ayanamsa float64) (tithi, nakshatra int) {
+	tithi = int(normDeg(moon-sun) / 12)
+	nakshatra = int(normDeg(moon-ayanamsa) / (360.0 / 27))
+	return tithi % 30, nakshatra % 27
+}
+
+// panchang returns the tithi and the nakshatra of the day at sunrise
+// at the location, or at 6 o'clock if the sun doesn't rise.
+func panchang(a Astronomy, day time.Time, lat, lon float64, loc *time.Location) (tithi, nakshatra int) {
+	t, _, ok := a.SunTimes(day, lat, lon)
+	if !ok {
+		t = time.Date(day.Year(), day.Month(), day.Day(), 6, 0, 0, 0, loc)
+	}
+	sun, moon := a.Longitudes(t)
+	return panchangAt(sun, moon, lahiriAyanamsa(t))
+}
+
+// formatPanchang returns the tithi, S for the bright and K for the dark
+// half, and the nakshatra as two short lines.
+func formatPanchang(tithi, nakshatra int) (lines [2]string) {
+	switch {
+	case tithi == 29:
+		lines[0] = "Amavasya"
+	case tithi < 15:
+		lines[0] = "S " + tithiNames[tithi]
+	default:
+		lines[0] = "K " + tithiNames[tithi-15]
+	}
+	lines[1] = nakshatraNames[nakshatra]
+	return lines
+}
@@ -0,0 +1,130 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// patterns.go
+//
+// Interesting dates, like Friday the 13th or palindromic dates, that
+// decorate their cells with a label or an icon.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DatePattern reports whether a day is an interesting date.
+type DatePattern func(day time.Time) bool
+
+// FridayThe13th matches Friday the 13th.
+func FridayThe13th(day time.Time) bool {
+	return day.Weekday() == time.Friday && day.Day() == 13
+}
+
+// PalindromeDate matches the dates that read the same backwards as
+// YYYYMMDD, like 2021-12-02.
+func PalindromeDate(day time.Time) bool {
+	s := day.Format("20060102")
+	for i := 0; i < len(s)/2; i++ {
+		if s[i] != s[len(s)-1-i] {
+			return false
+		}
+	}
+	return true
+}
+
+// RepdigitDate matches the dates whose month and day, without leading
+// zeros, repeat a single digit, like 11/11, 2/22 or 3/3.
+func RepdigitDate(day time.Time) bool {
+	s := fmt.Sprintf("%d%d", day.Month(), day.Day())
+	return strings.Count(s, s[:1]) == len(s)
+}
+
+// namedPatterns are the patterns of SetDatePatterns and of the rules
+// like "day is palindrome", with their default labels.
+var namedPatterns = map[string]struct {
+	match DatePattern
+	label string
+}{
+	"friday13":   {FridayThe13th, "Friday 13th"},
+	"palindrome": {PalindromeDate, "Palindrome"},
+	"repdigit":   {RepdigitDate, "Repdigit"},
+}
+
+// datePattern is a pattern with the label of its days.
+type datePattern struct {
+	label string
+	match DatePattern
+}
+
+// AddDatePattern decorates the days that match with the label: an
+// image file or a single character is drawn as an icon like that of a
+// rule, a longer text in small type.
+func (g *Calendar) AddDatePattern(label string, match DatePattern) {
+	g.patterns = append(g.patterns, datePattern{label, match})
+}
+
+// SetDatePatterns decorates the days of the patterns, comma separated:
+// friday13, palindrome and repdigit, each with its default label or
+// another one like friday13=skull.png.
+func (g *Calendar) SetDatePatterns(s string) {
+	for _, p := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if kv[0] == "" {
+			continue
+		}
+		named, ok := namedPatterns[strings.ToLower(kv[0])]
+		if !ok {
+			fmt.Printf("# Error, unknown date pattern '%s'\n", kv[0])
+			continue
+		}
+		label := named.label
+		if len(kv) == 2 {
+			label = kv[1]
+		}
+		g.AddDatePattern(label, named.match)
+	}
+}
+
+// patternLabels returns the labels of the patterns that match the day.
+func (g *Calendar) patternLabels(day time.Time) (labels []string) {
+	for _, p := range g.patterns {
+		if p.match(day) {
+			labels = append(labels, p.label)
+		}
+	}
+	return labels
+}
+
+// isIconLabel reports whether the label is an image file or a single
+// character.
+func isIconLabel(label string) bool {
+	switch strings.ToLower(filepath.Ext(label)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg":
+		return true
+	}
+	return utf8.RuneCountInString(label) == 1
+}
+
+// drawPatternLabel draws the label of a pattern on the right of the
+// cell, an icon of size s or a text of the font size, if there is
+// room.
+func (g *Calendar) drawPatternLabel(pdf *gofpdf.Fpdf, layout *cellLayout, fonts elementFonts, label string, s float64, size float64) {
+	if isIconLabel(label) {
+		g.drawIcon(pdf, layout, fonts, label, s)
+		return
+	}
+	text := convertCP(label)
+	fonts.set(pdf, "small", size)
+	_, h := pdf.GetFontSize()
+	if r, ok := layout.placeRight(pdf.GetStringWidth(text), h); ok {
+		pdf.Text(r.x, r.y+ASCENT*h, text)
+	}
+}
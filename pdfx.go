@@ -0,0 +1,466 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// pdfx.go
+//
+// PDF/X-4 output for print shops: the output intent with its ICC
+// profile, the trim box of every page, the metadata of PDF/X and
+// no trapping. gofpdf knows none of this, so the PDF is completed by
+// an incremental update when it is written.
+//
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PDFXVERSION is the version of PDF/X of the output.
+const PDFXVERSION = "PDF/X-4"
+
+// PDFXTITLE is the title of the document in the metadata.
+const PDFXTITLE = "Created with Gocal"
+
+// SRGBDESCRIPTION is the description of the built-in sRGB profile,
+// which is also its registered output condition.
+const SRGBDESCRIPTION = "sRGB IEC61966-2.1"
+
+// pdfxIntent is the output intent of a PDF/X file.
+type pdfxIntent struct {
+	profile    []byte // the ICC profile of the printing condition
+	components int    // 1 for gray, 3 for RGB, 4 for CMYK
+	condition  string // the description of the profile
+	date       time.Time
+}
+
+// fogra finds the characterized printing conditions of FOGRA in the
+// descriptions of profiles.
+var fogra = regexp.MustCompile(`FOGRA\d+`)
+
+// identifier returns the registered name of the printing condition,
+// or Custom for the conditions the registry of the ICC doesn't know.
+func (x *pdfxIntent) identifier() string {
+	if x.condition == SRGBDESCRIPTION {
+		return SRGBDESCRIPTION
+	}
+	if id := fogra.FindString(x.condition); id != "" {
+		return id
+	}
+	return "Custom"
+}
+
+// pdfxIntent returns the output intent of the PDF/X option: the
+// built-in sRGB profile for "srgb", or else the ICC profile file of
+// the printing condition. It returns nil without PDF/X or if the
+// profile can't be used.
+func (g *Calendar) pdfxIntent() *pdfxIntent {
+	if g.OptPDFX == "" {
+		return nil
+	}
+	x := &pdfxIntent{date: time.Now().UTC().Truncate(time.Second)}
+	if strings.ToLower(g.OptPDFX) == "srgb" {
+		x.profile = srgbProfile()
+	} else {
+		data, err := ioutil.ReadFile(g.OptPDFX)
+		if err != nil {
+			fmt.Printf("# Error reading output intent profile: %v\n", err)
+			return nil
+		}
+		x.profile = data
+	}
+	var err error
+	x.components, x.condition, err = iccInfo(x.profile)
+	if err != nil {
+		fmt.Printf("# Error in output intent profile '%s': %v\n", g.OptPDFX, err)
+		return nil
+	}
+	return x
+}
+
+// newPDF creates the PDF of the calendar, with the trim box on every
+// page for PDF/X.
+func (g *Calendar) newPDF(fontTempdir string) *gofpdf.Fpdf {
+	pdf := gofpdf.New(g.OptOrientation, "mm", g.OptPaperformat, fontTempdir)
+	if fontLoader != nil {
+		pdf.SetFontLoader(fontLoader)
+	} else if g.generator != nil {
+		pdf.SetFontLoader(g.generator)
+	}
+	if g.OptPDFX != "" {
+		w, h := pdf.GetPageSize()
+		pdf.SetPageBox("trim", 0, 0, w, h)
+		if g.OptLayers {
+			fmt.Printf("# Layers are left out of PDF/X, which wants flat artwork\n")
+		}
+	}
+	return pdf
+}
+
+// iccInfo returns the number of color components and the description
+// of an ICC profile.
+func iccInfo(data []byte) (components int, description string, err error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return 0, "", fmt.Errorf("not an ICC profile")
+	}
+	switch string(data[16:20]) {
+	case "GRAY":
+		components = 1
+	case "RGB ":
+		components = 3
+	case "CMYK":
+		components = 4
+	default:
+		return 0, "", fmt.Errorf("unsupported color space '%s'", strings.TrimSpace(string(data[16:20])))
+	}
+	n := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < n && 132+12*i+12 <= len(data); i++ {
+		entry := data[132+12*i:]
+		if string(entry[:4]) != "desc" {
+			continue
+		}
+		off, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if off < 0 || size < 12 || off+size > len(data) {
+			break
+		}
+		description = tagText(data[off : off+size])
+	}
+	return components, description, nil
+}
+
+// tagText returns the text of a textDescriptionType tag of version 2
+// or the first text of a multiLocalizedUnicodeType tag of version 4.
+func tagText(tag []byte) string {
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if n < 1 || 12+n > len(tag) {
+			return ""
+		}
+		return strings.TrimRight(string(tag[12:12+n]), "\x00")
+	case "mluc":
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:]) == 0 {
+			return ""
+		}
+		n, off := int(binary.BigEndian.Uint32(tag[20:])), int(binary.BigEndian.Uint32(tag[24:]))
+		if off+n > len(tag) {
+			return ""
+		}
+		u := make([]uint16, n/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(tag[off+2*i:])
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
+
+// srgbProfile returns an ICC profile of version 2 for sRGB: the
+// primaries adapted to D50 and the tone curve of IEC 61966-2-1.
+func srgbProfile() []byte {
+	s15 := func(v float64) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(int32(math.Round(v*65536))))
+		return b
+	}
+	xyz := func(x, y, z float64) []byte {
+		b := append([]byte("XYZ \x00\x00\x00\x00"), s15(x)...)
+		return append(append(b, s15(y)...), s15(z)...)
+	}
+	desc := append([]byte("desc\x00\x00\x00\x00"), 0, 0, 0, byte(len(SRGBDESCRIPTION)+1))
+	desc = append(append(desc, SRGBDESCRIPTION...), 0)
+	desc = append(desc, make([]byte, 4+4+2+1+67)...)
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x04\x00")
+	for i := 0; i < 1024; i++ {
+		v := float64(i) / 1023
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = append(curve, byte(int(math.Round(v*65535))>>8), byte(int(math.Round(v*65535))))
+	}
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9505, 1, 1.0891)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", nil}, // the same curve
+		{"bTRC", nil},
+	}
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	for i, v := range []uint16{1998, 2, 9, 6, 49, 0} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	copy(header[68:], append(append(s15(0.9642), s15(1)...), s15(0.8249)...))
+	table := make([]byte, 4+12*len(tags))
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+	var body []byte
+	off, size := 0, 0
+	for i, t := range tags {
+		if t.data != nil {
+			for len(body)%4 != 0 {
+				body = append(body, 0)
+			}
+			off, size = len(header)+len(table)+len(body), len(t.data)
+			body = append(body, t.data...)
+		}
+		copy(table[4+12*i:], t.sig)
+		binary.BigEndian.PutUint32(table[8+12*i:], uint32(off))
+		binary.BigEndian.PutUint32(table[12+12*i:], uint32(size))
+	}
+	profile := append(append(header, table...), body...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+var (
+	// startXref finds the offset of the last cross-reference table.
+	startXref = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	// xrefEntry is an entry of a cross-reference table of gofpdf.
+	xrefEntry = regexp.MustCompile(`^(\d{10}) (\d{5}) ([nf])`)
+	// trailerRef finds a reference in the trailer.
+	trailerRef = regexp.MustCompile(`/(Root|Info) (\d+) 0 R`)
+	// groupRGB is the blending color space of the pages of gofpdf.
+	groupRGB = regexp.MustCompile(`/Group <</Type /Group /S /Transparency /CS /DeviceRGB>>`)
+	// coreFont finds the fonts of gofpdf that aren't embedded.
+	coreFont = regexp.MustCompile(`/BaseFont /([\w-]+)\n/Subtype /Type1\n(?:/Encoding /WinAnsiEncoding\n)?>>`)
+	// infoDate finds the dates of the document information.
+	infoDate = regexp.MustCompile(`(?m)^/(CreationDate|ModDate|Title) .*\n`)
+)
+
+// pdfObjects reads the cross-reference table of a PDF of gofpdf: the
+// offsets of the objects, the root and the info object.
+func pdfObjects(data []byte) (offsets map[int]int, xref, root, info int, err error) {
+	m := startXref.FindSubmatch(data)
+	if m == nil {
+		return nil, 0, 0, 0, fmt.Errorf("no cross-reference table")
+	}
+	xref, _ = strconv.Atoi(string(m[1]))
+	if xref >= len(data) {
+		return nil, 0, 0, 0, fmt.Errorf("invalid cross-reference offset %d", xref)
+	}
+	lines := strings.Split(string(data[xref:]), "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != "xref" {
+		return nil, 0, 0, 0, fmt.Errorf("no cross-reference table at %d", xref)
+	}
+	offsets = make(map[int]int)
+	i := 1
+	for ; i < len(lines); i++ {
+		var first, count int
+		if _, err := fmt.Sscanf(lines[i], "%d %d", &first, &count); err != nil {
+			break
+		}
+		for k := 0; k < count && i+1+k < len(lines); k++ {
+			e := xrefEntry.FindStringSubmatch(lines[i+1+k])
+			if e != nil && e[3] == "n" {
+				offsets[first+k], _ = strconv.Atoi(e[1])
+			}
+		}
+		i += count
+	}
+	for _, r := range trailerRef.FindAllStringSubmatch(strings.Join(lines[i:], "\n"), -1) {
+		n, _ := strconv.Atoi(r[2])
+		if r[1] == "Root" {
+			root = n
+		} else {
+			info = n
+		}
+	}
+	if root == 0 || info == 0 {
+		return nil, 0, 0, 0, fmt.Errorf("no root or info in the trailer")
+	}
+	return offsets, xref, root, info, nil
+}
+
+// pdfDict returns the dictionary of the object n, without the
+// closing ">>".
+func pdfDict(data []byte, offsets map[int]int, n int) (string, error) {
+	off, ok := offsets[n]
+	if !ok || off >= len(data) {
+		return "", fmt.Errorf("object %d not found", n)
+	}
+	obj := string(data[off:])
+	start, end := strings.Index(obj, "obj"), strings.Index(obj, "endobj")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("object %d not found", n)
+	}
+	dict := strings.TrimSpace(obj[start+3 : end])
+	if !strings.HasPrefix(dict, "<<") || !strings.HasSuffix(dict, ">>") {
+		return "", fmt.Errorf("object %d is no dictionary", n)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(dict, ">>")), nil
+}
+
+// pdfDate returns the date of the document information.
+func pdfDate(t time.Time) string {
+	return "(D:" + t.Format("20060102150405") + "Z)"
+}
+
+// xmp returns the metadata of PDF/X for the document.
+func (x *pdfxIntent) xmp(id string) []byte {
+	date := x.date.Format("2006-01-02T15:04:05Z")
+	return []byte(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+ xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+ xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"
+ xmlns:pdfxid="http://www.npes.org/pdfx/ns/id/">
+<dc:format>application/pdf</dc:format>
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">` + PDFXTITLE + `</rdf:li></rdf:Alt></dc:title>
+<xmp:CreateDate>` + date + `</xmp:CreateDate>
+<xmp:ModifyDate>` + date + `</xmp:ModifyDate>
+<xmp:MetadataDate>` + date + `</xmp:MetadataDate>
+<xmp:CreatorTool>Gocal</xmp:CreatorTool>
+<pdf:Trapped>False</pdf:Trapped>
+<xmpMM:DocumentID>uuid:` + id + `</xmpMM:DocumentID>
+<xmpMM:InstanceID>uuid:` + id + `</xmpMM:InstanceID>
+<xmpMM:VersionID>1</xmpMM:VersionID>
+<xmpMM:RenditionClass>default</xmpMM:RenditionClass>
+<pdfxid:GTS_PDFXVersion>` + PDFXVERSION + `</pdfxid:GTS_PDFXVersion>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+}
+
+// apply completes a PDF of gofpdf to PDF/X by an incremental update:
+// the output intent and the metadata in the catalog, the dates and
+// the trapping in the information, and sRGB for the DeviceRGB colors
+// in the resources. The blending spaces of the pages are changed in
+// place, keeping every object at its offset. The fonts that are not
+// embedded go to warn.
+func (x *pdfxIntent) apply(data []byte, warn func(kind string, format string, v ...interface{})) ([]byte, error) {
+	offsets, xref, root, info, err := pdfObjects(data)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := pdfDict(data, offsets, root)
+	if err != nil {
+		return nil, err
+	}
+	infoDict, err := pdfDict(data, offsets, info)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := pdfDict(data, offsets, 2)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range coreFont.FindAllSubmatch(data, -1) {
+		warn("pdfx", "The font %s is not embedded, PDF/X wants embedded fonts", f[1])
+	}
+
+	out := append([]byte{}, data...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	next := 0
+	for n := range offsets {
+		if n > next {
+			next = n
+		}
+	}
+	updated := make(map[int]int)
+	put := func(n int, obj string, stream []byte) {
+		updated[n] = len(out)
+		out = append(out, fmt.Sprintf("%d 0 obj\n%s\n", n, obj)...)
+		if stream != nil {
+			out = append(append(append(out, "stream\n"...), stream...), "\nendstream\n"...)
+		}
+		out = append(out, "endobj\n"...)
+	}
+	newObj := func(obj string, stream []byte) int {
+		next++
+		put(next, obj, stream)
+		return next
+	}
+	iccStream := func(profile []byte, components int) int {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(profile)
+		w.Close()
+		return newObj(fmt.Sprintf("<</N %d /Filter /FlateDecode /Length %d>>", components, buf.Len()), buf.Bytes())
+	}
+
+	sum := md5.Sum(data)
+	id := fmt.Sprintf("%x", sum)
+	uuid := fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	dest := iccStream(x.profile, x.components)
+	srgb := dest
+	if x.components != 3 {
+		srgb = iccStream(srgbProfile(), 3)
+	}
+	rgbSpace := newObj(fmt.Sprintf("[/ICCBased %d 0 R]", srgb), nil)
+	intent := newObj(fmt.Sprintf("<</Type /OutputIntent /S /GTS_PDFX /OutputConditionIdentifier (%s) /OutputCondition (%s) /Info (%s) /RegistryName (http://www.color.org) /DestOutputProfile %d 0 R>>",
+		pdfEscape(x.identifier()), pdfEscape(x.condition), pdfEscape(x.condition), dest), nil)
+	meta := x.xmp(uuid)
+	metadata := newObj(fmt.Sprintf("<</Type /Metadata /Subtype /XML /Length %d>>", len(meta)), meta)
+
+	put(root, fmt.Sprintf("%s\n/OutputIntents [%d 0 R]\n/Metadata %d 0 R\n>>", catalog, intent, metadata), nil)
+	infoDict = infoDate.ReplaceAllString(infoDict+"\n", "")
+	put(info, fmt.Sprintf("%s/Title (%s)\n/CreationDate %s\n/ModDate %s\n/Trapped /False\n/GTS_PDFXVersion (%s)\n>>",
+		infoDict, PDFXTITLE, pdfDate(x.date), pdfDate(x.date), PDFXVERSION), nil)
+	if i := strings.Index(resources, "/ColorSpace <<"); i >= 0 {
+		i += len("/ColorSpace <<")
+		resources = resources[:i] + fmt.Sprintf("\n/DefaultRGB %d 0 R", rgbSpace) + resources[i:]
+	} else {
+		resources += fmt.Sprintf("\n/ColorSpace <<\n/DefaultRGB %d 0 R\n>>", rgbSpace)
+	}
+	put(2, resources+"\n>>", nil)
+
+	// The same length in place, the offsets stay valid.
+	group := fmt.Sprintf("/Group <</Type /Group /S /Transparency /CS %d 0 R>>", rgbSpace)
+	out = groupRGB.ReplaceAllFunc(out, func(m []byte) []byte {
+		if len(group) > len(m) {
+			return m
+		}
+		return []byte(padTo(group, len(m)))
+	})
+
+	var numbers []int
+	for n := range updated {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	start := len(out)
+	out = append(out, "xref\n"...)
+	for _, n := range numbers {
+		out = append(out, fmt.Sprintf("%d 1\n%010d 00000 n \n", n, updated[n])...)
+	}
+	out = append(out, fmt.Sprintf("trailer\n<<\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n/Prev %d\n/ID [<%s><%s>]\n>>\nstartxref\n%d\n%%%%EOF\n",
+		next+1, root, info, xref, id, id, start)...)
+	return out, nil
+}
+
+// pdfEscape escapes the text for a PDF string.
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
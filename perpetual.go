@@ -0,0 +1,143 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// perpetual.go
+//
+// The perpetual calendar: a calendar without a year for birthdays and
+// anniversaries, each month a list of 31 days without weekdays.
+//
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PERPETUALSLOTS is the number of days in the list of every month.
+const PERPETUALSLOTS = 31
+
+// perpetualDays returns the number of days of the month in a leap year,
+// so February has its 29th.
+func perpetualDays(month time.Month) int {
+	return time.Date(2000, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// perpetualEvents returns the events on fixed dates. The events of
+// weekdays and the bridge days depend on the year and are left out,
+// those of a leap day are kept.
+func (g *Calendar) perpetualEvents() (eL []gDate) {
+	for _, ev := range g.collectEvents() {
+		if ev.Weekday == "" && ev.Day > 0 && ev.Text != "" && ev.Source != "bridge" {
+			eL = append(eL, ev)
+		}
+	}
+	eL = dedupEvents(eL, g.OptDedup)
+	sortEvents(eL)
+	return g.prefixEvents(eL)
+}
+
+// CreatePerpetualCalendar creates a calendar without a year, e.g.
+// for birthdays: a page per month with a row for each of its days and
+// the events of the day. The days that no year has, like 2/30, are
+// hatched, so every month has 31 rows.
+func (g *Calendar) CreatePerpetualCalendar(fn string) {
+
+	var fontTempdir string
+	trace := g.traceLine()
+	var fontScale = g.OptFontScale
+	var calFont = g.OptFont
+
+	if g.OptSmall == true {
+		fontScale = 0.75
+	}
+
+	currentLanguage := getLanguage(g.OptLocale)
+	localizedMonthNames := g.monthNames(currentLanguage, false)
+	eventList := g.perpetualEvents()
+
+	calFont, fontTempdir = g.processFont(calFont)
+	defer removeTempdir(fontTempdir)
+
+	pdf := g.newPDF(fontTempdir)
+	pdf.SetTitle("Created with Gocal", true)
+	pdf.AddFont(calFont, "", calFont+".json")
+	pdf.SetAutoPageBreak(false, 0)
+	g.setGridStyle(pdf)
+
+	PAGEWIDTH, PAGEHEIGHT, _ := pdf.PageSize(0)
+	if g.OptOrientation != "P" {
+		PAGEWIDTH, PAGEHEIGHT = PAGEHEIGHT, PAGEWIDTH
+	}
+	numerals := g.loadNumeralFont(pdf)
+
+	width := PAGEWIDTH - 2*MARGIN
+	font := elementFont{calFont, ""}
+	textList := g.getTexts()
+	for mo := g.WantBeginMonth; mo <= g.WantEndMonth; mo++ {
+		pdf.AddPage()
+		if g.OptWallpaper != "" {
+			g.AddWallpaper(pdf, fontTempdir, PAGEWIDTH, PAGEHEIGHT)
+		}
+
+		g.setTitleColor(pdf)
+		pdf.SetFont(calFont, "", HEADERFONTSIZE*fontScale)
+		pdf.CellFormat(width, MARGIN, localizedMonthNames[mo], "", 0, "C", false, 0, "")
+		pdf.SetTextColor(BLACK, BLACK, BLACK)
+		pdf.Ln(-1)
+
+		top := pdf.GetY() + 0.5*MARGIN
+		rh := (PAGEHEIGHT - top - 2*MARGIN) / PERPETUALSLOTS
+		nw := width * 0.08
+		days := perpetualDays(time.Month(mo))
+		for d := 1; d <= PERPETUALSLOTS; d++ {
+			y := top + float64(d-1)*rh
+			if d > days {
+				g.hatchCell(pdf, MARGIN, y, width, rh)
+				pdf.Line(MARGIN, y+rh, MARGIN+width, y+rh)
+				continue
+			}
+			pdf.SetXY(MARGIN, y)
+			pdf.SetCellMargin(CELLMARGIN)
+			pdf.SetFont(calFont, "", MONTHDAYFONTSIZE*fontScale*0.4)
+			g.setDayNumberColor(pdf)
+			pdf.CellFormat(nw, rh, localizeDigits(fmt.Sprintf("%d", d), numerals), "", 0, "RM", false, 0, "")
+			pdf.SetTextColor(BLACK, BLACK, BLACK)
+
+			// The events of the day in a line, cut at the end of the row.
+			eventSize := EVENTFONTSIZE * fontScale
+			pdf.SetFont(calFont, "", eventSize)
+			_, h := pdf.GetFontSize()
+			x := MARGIN + nw + 2*CELLMARGIN
+			pdf.ClipRect(x, y, MARGIN+width-x, rh, false)
+			for _, ev := range eventList {
+				if ev.Month != time.Month(mo) || ev.Day != d {
+					continue
+				}
+				if ev.Color != "" {
+					setTextColor(pdf, ev.Color)
+				}
+				spans := parseMarkup(strings.Replace(ev.Text, "\\n", " ", -1))
+				drawSpans(pdf, font, eventSize, x, y+(rh-h)/2+ASCENT*h, spans)
+				x += spansWidth(pdf, font, eventSize, spans) + 3*CELLMARGIN
+				pdf.SetTextColor(BLACK, BLACK, BLACK)
+			}
+			pdf.ClipEnd()
+
+			pdf.Line(MARGIN+nw, y, MARGIN+nw, y+rh)
+			pdf.Line(MARGIN, y+rh, MARGIN+width, y+rh)
+		}
+
+		g.setFooterColor(pdf)
+		pdf.SetFont(calFont, "", FOOTERFONTSIZE*fontScale)
+		pdf.Text(0.50*PAGEWIDTH-pdf.GetStringWidth(g.footer())*0.5, 0.95*PAGEHEIGHT, g.footer())
+
+		g.addTexts(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT, textList, localizedMonthNames[mo])
+	}
+	g.addPageNumbers(pdf, calFont, fontScale, PAGEWIDTH, PAGEHEIGHT)
+	g.addTraceLine(pdf, calFont, trace, PAGEHEIGHT)
+	pdf.OutputAndClose(g.docWriter(pdf, fn))
+}
@@ -0,0 +1,176 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// photoorder.go
+//
+// The assignment of the photos of a directory to the months: by name,
+// by a month number in the file name, by the date in the EXIF data of
+// the photo, or shuffled with a seed.
+//
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// photoOrders are the ways to assign the photos to the months.
+var photoOrders = []string{"name", "pattern", "exif", "random"}
+
+// isPhotoOrder reports whether s is one of the photoOrders.
+func isPhotoOrder(s string) bool {
+	for _, o := range photoOrders {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+// monthPrefix finds the month number at the start of a file name,
+// e.g. 01-lake.jpg or 3_beach.png.
+var monthPrefix = regexp.MustCompile(`^(\d{1,2})[-_. ]`)
+
+// filenameMonth returns the month of the file name like 01-lake.jpg.
+func filenameMonth(file string) (time.Month, bool) {
+	m := monthPrefix.FindStringSubmatch(filepath.Base(file))
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	return time.Month(n), n >= 1 && n <= 12
+}
+
+// assignPhotos returns the photo of every month. With pattern and
+// exif, month gives the month of a file, the first file of a month
+// wins. The months without a photo get the remaining files, or all
+// of them if none remain, in name order.
+func assignPhotos(files []string, order string, seed int64, month func(string) (time.Month, bool)) (out [12]string) {
+	files = append([]string(nil), files...)
+	if order == "random" {
+		rand.New(rand.NewSource(seed)).Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+	}
+	rest := files
+	if order == "pattern" || order == "exif" {
+		rest = nil
+		for _, f := range files {
+			if m, ok := month(f); ok && out[m-1] == "" {
+				out[m-1] = f
+			} else {
+				rest = append(rest, f)
+			}
+		}
+		if len(rest) == 0 {
+			rest = files
+		}
+	}
+	k := 0
+	for i := range out {
+		if out[i] == "" {
+			out[i] = rest[k%len(rest)]
+			k++
+		}
+	}
+	return out
+}
+
+// exifMonth returns the month in which the JPEG photo was taken.
+func exifMonth(file string) (time.Month, bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	// The EXIF data is at the start of the file.
+	data := make([]byte, 128*1024)
+	n, _ := io.ReadFull(f, data)
+	t, ok := exifDate(data[:n])
+	return t.Month(), ok
+}
+
+// exifDate returns the DateTimeOriginal of the EXIF data of a JPEG
+// file, or its DateTime.
+func exifDate(data []byte) (time.Time, bool) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return time.Time{}, false
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			break // the image data starts
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			break
+		}
+		if marker == 0xe1 && bytes.HasPrefix(data[i+4:end], []byte("Exif\x00\x00")) {
+			return tiffDate(data[i+10 : end])
+		}
+		i = end
+	}
+	return time.Time{}, false
+}
+
+// tiffDate reads the date from the TIFF structure of EXIF data.
+func tiffDate(t []byte) (time.Time, bool) {
+	if len(t) < 8 {
+		return time.Time{}, false
+	}
+	var bo binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+	// entry returns the count and the value of the tag in the IFD
+	// at the offset.
+	entry := func(off uint32, tag uint16) (uint32, uint32, bool) {
+		if int64(off)+2 > int64(len(t)) {
+			return 0, 0, false
+		}
+		for k := 0; k < int(bo.Uint16(t[off:])); k++ {
+			e := int(off) + 2 + 12*k
+			if e+12 > len(t) {
+				break
+			}
+			if bo.Uint16(t[e:]) == tag {
+				return bo.Uint32(t[e+4:]), bo.Uint32(t[e+8:]), true
+			}
+		}
+		return 0, 0, false
+	}
+	ascii := func(count, off uint32) string {
+		if int64(off)+int64(count) > int64(len(t)) {
+			return ""
+		}
+		return strings.TrimRight(string(t[off:off+count]), "\x00")
+	}
+	ifd0 := bo.Uint32(t[4:])
+	date := ""
+	if _, sub, ok := entry(ifd0, 0x8769); ok {
+		if count, off, ok := entry(sub, 0x9003); ok {
+			date = ascii(count, off)
+		}
+	}
+	if date == "" {
+		if count, off, ok := entry(ifd0, 0x0132); ok {
+			date = ascii(count, off)
+		}
+	}
+	d, err := time.Parse("2006:01:02 15:04:05", date)
+	return d, err == nil
+}
@@ -0,0 +1,169 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// projects.go
+//
+// Projects as thin bars across the days of the monthly calendar, like
+// in a Gantt chart: the bars continue across the week rows and the
+// pages, and overlapping projects get bars on top of each other.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"sort"
+	"time"
+)
+
+const (
+	// PROJECTLANES is the largest number of project bars on one day.
+	PROJECTLANES = 4
+	// PROJECTBAR is the height of a project bar relative to the cell.
+	PROJECTBAR = 0.08
+	// PROJECTCOLOR is the color of a project without one.
+	PROJECTCOLOR = "steelblue"
+)
+
+// project is a project of the configuration or of AddProject, lane is
+// the position of its bar from the bottom of the cell.
+type project struct {
+	name       string
+	start, end time.Time
+	color      string
+	lane       int
+}
+
+// parseProject reads a project with start and end as 2006-01-02.
+func parseProject(name, start, end, color string) (p project, err error) {
+	p.name, p.color = name, color
+	if p.start, err = time.Parse("2006-01-02", start); err != nil {
+		return p, fmt.Errorf("invalid start '%s' of project '%s'", start, name)
+	}
+	if p.end, err = time.Parse("2006-01-02", end); err != nil {
+		return p, fmt.Errorf("invalid end '%s' of project '%s'", end, name)
+	}
+	if p.end.Before(p.start) {
+		return p, fmt.Errorf("project '%s' ends before it starts", name)
+	}
+	if p.color == "" {
+		p.color = PROJECTCOLOR
+	}
+	if _, _, _, err = parseColor(p.color); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// AddProject draws a bar for the project from start to end, both as
+// 2006-01-02, in the color, e.g. "teal" or "#4682b4".
+func (g *Calendar) AddProject(name, start, end, color string) {
+	p, err := parseProject(name, start, end, color)
+	if err != nil {
+		g.warnf("date", "Ignoring project: %v", err)
+		return
+	}
+	g.projects = append(g.projects, p)
+}
+
+// readConfigurationProjects returns the projects of the XML file.
+func (g *Calendar) readConfigurationProjects(filename string) (pL []project) {
+	v := g.loadConfigurationfile(filename)
+	for _, m := range v.Gocalproject {
+		p, err := parseProject(m.Name, m.Start, m.End, m.Color)
+		if err != nil {
+			g.warnf("date", "Ignoring project in %v: %v", filename, err)
+			continue
+		}
+		pL = append(pL, p)
+	}
+	return pL
+}
+
+// getProjects returns the projects of the configuration files and of
+// AddProject with their lanes.
+func (g *Calendar) getProjects() []project {
+	pL := append([]project(nil), g.projects...)
+	for _, cfg := range append([]string{g.OptConfig}, g.OptConfigs...) {
+		if cfg != "" {
+			pL = append(pL, g.readConfigurationProjects(cfg)...)
+		}
+	}
+	return assignLanes(pL, g.warnf)
+}
+
+// assignLanes puts each project, the earliest first, into the lowest
+// lane that is free on all its days. Projects that don't fit into
+// PROJECTLANES lanes are left out, with a warning of warn.
+func assignLanes(pL []project, warn func(kind string, format string, v ...interface{})) (out []project) {
+	sort.SliceStable(pL, func(i, j int) bool { return pL[i].start.Before(pL[j].start) })
+	var laneEnd [PROJECTLANES]time.Time
+	for _, p := range pL {
+		p.lane = -1
+		for l := range laneEnd {
+			if laneEnd[l].IsZero() || laneEnd[l].Before(p.start) {
+				p.lane, laneEnd[l] = l, p.end
+				break
+			}
+		}
+		if p.lane < 0 {
+			warn("date", "Ignoring project '%s', more than %d projects at a time", p.name, PROJECTLANES)
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// drawProjectBars draws the bars of the projects of the day in the
+// cell, in the column col of the week row, above bottom and reserves
+// their areas. The name of a project is printed on its first day, at
+// the start of a row and on the first of a month.
+func drawProjectBars(pdf *gofpdf.Fpdf, layout *cellLayout, fonts elementFonts, pL []project, day time.Time, col int, bottom float64) {
+	c := layout.cell
+	bh := c.h * PROJECTBAR
+	fr, fg, fb := pdf.GetFillColor()
+	defer pdf.SetFillColor(fr, fg, fb)
+	tr, tg, tb := pdf.GetTextColor()
+	defer pdf.SetTextColor(tr, tg, tb)
+	for _, p := range pL {
+		if day.Before(p.start) || day.After(p.end) {
+			continue
+		}
+		x, w := c.x, c.w
+		if day.Equal(p.start) {
+			x, w = x+CELLMARGIN, w-CELLMARGIN
+		}
+		if day.Equal(p.end) {
+			w -= CELLMARGIN
+		}
+		y := bottom - float64(p.lane+1)*bh*1.25
+		r, gr, b, _ := parseColor(p.color)
+		pdf.SetFillColor(r, gr, b)
+		pdf.Rect(x, y, w, bh, "F")
+		layout.reserve(rect{x, y, w, bh})
+		if !day.Equal(p.start) && col > 0 && day.Day() != 1 {
+			continue
+		}
+		// The name is cut to the bar up to the end of the row.
+		days := int(p.end.Sub(day).Hours()/24) + 1
+		if days > COLUMNS-col {
+			days = COLUMNS - col
+		}
+		fonts.set(pdf, "small", bh*72/25.4*0.8)
+		name := convertCP(p.name)
+		for len(name) > 0 && pdf.GetStringWidth(name) > float64(days)*c.w-2*CELLMARGIN {
+			name = name[:len(name)-1]
+		}
+		if grayLevel(float64(r)/255, float64(gr)/255, float64(b)/255, 1) < 0.5 {
+			pdf.SetTextColor(255, 255, 255)
+		} else {
+			pdf.SetTextColor(BLACK, BLACK, BLACK)
+		}
+		_, h := pdf.GetFontSize()
+		pdf.Text(x+CELLMARGIN, y+(bh-h)/2+ASCENT*h, name)
+	}
+}
@@ -0,0 +1,126 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// quarters.go
+//
+// Quarters and sprints for corporate planners: the days are numbered
+// within their quarter, and the planner strip marks the quarter
+// boundaries and shows the sprints as bands across the weeks.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"github.com/soniakeys/meeus/v3/julian"
+	"time"
+)
+
+const (
+	// QUARTERRULEWIDTH is the width of the rule between two quarters.
+	QUARTERRULEWIDTH = 2 * MONTHRULEWIDTH
+	// SPRINTBAND is the height of the sprint band relative to the cell.
+	SPRINTBAND = 0.12
+)
+
+// sprintColors are the fills of the sprint bands, in turn.
+var sprintColors = [2]string{"#dbe9f6", "#f6e7d2"}
+
+// SetQuarters numbers the days within their quarter instead of the
+// year. The first quarter begins in the month, e.g. 4 for a fiscal
+// year from April; 0 numbers the days of the year again.
+func (g *Calendar) SetQuarters(firstMonth int) {
+	g.OptQuarters = firstMonth
+}
+
+// SetSprints shows the sprints in the planner strip. The first sprint
+// begins on start, as 2006-01-02, and each lasts days days.
+func (g *Calendar) SetSprints(start string, days int) {
+	g.OptSprintStart = start
+	g.OptSprintDays = days
+}
+
+// quarterOf returns the quarter (1-4) of the day and its first day,
+// for quarters from the month firstMonth.
+func quarterOf(day time.Time, firstMonth int) (quarter int, start time.Time) {
+	m := (int(day.Month()) - firstMonth + 12) % 12
+	start = time.Date(day.Year(), day.Month()-time.Month(m%3), 1, 0, 0, 0, 0, time.UTC)
+	return m/3 + 1, start
+}
+
+// dayOfQuarter returns the number of the day in its quarter, from 1.
+func dayOfQuarter(day time.Time, firstMonth int) int {
+	_, start := quarterOf(day, firstMonth)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return int(day.Sub(start).Hours()/24) + 1
+}
+
+// dayNumber returns the number of the day in its year or, with
+// OptQuarters, in its quarter.
+func (g *Calendar) dayNumber(day time.Time) string {
+	if g.OptQuarters > 0 {
+		return fmt.Sprintf("%d", dayOfQuarter(day, g.OptQuarters))
+	}
+	return fmt.Sprintf("%d", julian.DayOfYearGregorian(day.Year(), int(day.Month()), day.Day()))
+}
+
+// quarterStart reports whether a quarter begins on the day.
+func (g *Calendar) quarterStart(day time.Time) bool {
+	return g.OptQuarters > 0 && day.Day() == 1 && (int(day.Month())-g.OptQuarters+12)%3 == 0
+}
+
+// sprintOf returns the number of the sprint of the day, from 1, and
+// whether the sprint begins on the day. ok is false without sprints
+// and for the days before the first sprint.
+func (g *Calendar) sprintOf(day time.Time) (n int, first bool, ok bool) {
+	if g.OptSprintStart == "" || g.OptSprintDays <= 0 {
+		return 0, false, false
+	}
+	start, err := time.Parse("2006-01-02", g.OptSprintStart)
+	if err != nil {
+		return 0, false, false
+	}
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Before(start) {
+		return 0, false, false
+	}
+	d := int(day.Sub(start).Hours() / 24)
+	return d/g.OptSprintDays + 1, d%g.OptSprintDays == 0, true
+}
+
+// checkSprints reports an invalid start of the sprints.
+func (g *Calendar) checkSprints() {
+	if g.OptSprintStart == "" {
+		return
+	}
+	if _, err := time.Parse("2006-01-02", g.OptSprintStart); err != nil {
+		g.warnf("date", "Invalid start of the sprints '%s', expected YYYY-MM-DD", g.OptSprintStart)
+	} else if g.OptSprintDays <= 0 {
+		g.warnf("field", "Invalid length of the sprints %d", g.OptSprintDays)
+	}
+}
+
+// drawSprintBand draws the band of the sprint of the day at the bottom
+// of its cell, with the name of the sprint where it begins and at the
+// start of a week.
+func (g *Calendar) drawSprintBand(pdf *gofpdf.Fpdf, fontName string, fontScale float64, day time.Time, x, y, w, h float64, weekStart bool) {
+	n, first, ok := g.sprintOf(day)
+	if !ok {
+		return
+	}
+	fr, fg, fb := pdf.GetFillColor()
+	defer pdf.SetFillColor(fr, fg, fb)
+	bh := h * SPRINTBAND
+	r, gr, b, _ := parseColor(sprintColors[n%2])
+	pdf.SetFillColor(r, gr, b)
+	pdf.Rect(x, y+h-bh, w, bh, "F")
+	if first || weekStart {
+		pdf.SetTextColor(BLACK, BLACK, BLACK)
+		pdf.SetFont(fontName, "", DOYFONTSIZE*fontScale*0.5)
+		_, fh := pdf.GetFontSize()
+		pdf.Text(x+CELLMARGIN, y+h-bh+(bh-fh)/2+ASCENT*fh, fmt.Sprintf("Sprint %d", n))
+	}
+}
@@ -0,0 +1,136 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// recipients.go
+//
+// Personalized calendars: every recipient of a CSV file gets a
+// calendar with their own photo and dates on top of the shared
+// configuration.
+//
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Recipient is a row of a recipients file, the values by the lower
+// case names of the columns. The columns photo, photos, config and
+// dates personalize the calendar, all columns are variables like
+// {name} in the output filename, the footer and the texts.
+type Recipient map[string]string
+
+// ReadRecipients reads the recipients of a CSV file with the names
+// of the columns in the first row.
+func ReadRecipients(filename string) ([]Recipient, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readRecipients(f, filename)
+}
+
+// readRecipients reads the recipients of the CSV file name from in.
+func readRecipients(in io.Reader, name string) ([]Recipient, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(in, MAXINPUTSIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInputSize(data, name); err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(validUTF8(data, name, warnf)))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+	var header []string
+	var out []Recipient
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			for _, h := range row {
+				header = append(header, strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))))
+			}
+			continue
+		}
+		if len(out) == MAXRECIPIENTS {
+			return nil, fmt.Errorf("%s has more than %d recipients", name, MAXRECIPIENTS)
+		}
+		rec := make(Recipient)
+		for i, v := range row {
+			if i < len(header) && header[i] != "" {
+				rec[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		out = append(out, rec)
+	}
+	if header == nil {
+		return nil, fmt.Errorf("%s has no header row", name)
+	}
+	return out, nil
+}
+
+// Expand replaces the variables like {name} by the values of the
+// recipient.
+func (r Recipient) Expand(s string) string {
+	for k, v := range r {
+		s = strings.Replace(s, "{"+k+"}", v, -1)
+	}
+	return s
+}
+
+// SetRecipient personalizes the calendar for the recipient: the
+// photo of every month, the directory of photos, an additional
+// configuration file, and the dates like "3/14=Birthday of {name};
+// 6/1=Anniversary" as events.
+func (g *Calendar) SetRecipient(r Recipient) {
+	g.recipient = r
+	if r["photo"] != "" {
+		g.SetPhoto(r["photo"])
+	}
+	if r["photos"] != "" {
+		g.SetPhotos(r["photos"])
+	}
+	if r["config"] != "" {
+		g.AddConfig(r["config"])
+	}
+}
+
+// recipientEvents returns the personal dates of the recipient.
+func (g *Calendar) recipientEvents() (eL []gDate) {
+	for _, entry := range strings.Split(g.recipient["dates"], ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			g.warnf("date", "Ignoring personal date '%s', expected date=text", entry)
+			continue
+		}
+		date, text := strings.TrimSpace(kv[0]), g.recipient.Expand(strings.TrimSpace(kv[1]))
+		days, ok := parseConfigDate(date, getLanguage(g.OptLocale), g.WantYear)
+		if !ok {
+			g.warnf("date", "Ignoring event '%s', unknown date '%s'", text, date)
+			continue
+		}
+		for _, d := range days {
+			eL = append(eL, gDate{d.Month, d.Day, convertCP(text), d.Weekday, "", "recipient", "", "", "", "", 0, ""})
+		}
+	}
+	return eL
+}
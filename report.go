@@ -0,0 +1,146 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// report.go
+//
+// What a run produced, for pipelines that check the results:
+// the files with their pages and events, the downloads and
+// the warnings.
+//
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Report describes the files generated so far, see GetReport.
+type Report struct {
+	Files     []FileReport `json:"files"`
+	Downloads []Download   `json:"downloads"`
+	Warnings  []Warning    `json:"warnings"`
+}
+
+// FileReport is a generated file with the number of pages and
+// the events per source.
+type FileReport struct {
+	File   string                `json:"file"`
+	Pages  int                   `json:"pages"`
+	Events map[string]EventCount `json:"events,omitempty"`
+}
+
+// EventCount counts the events of a source that were placed on
+// the calendar and those that were skipped, e.g. because they are
+// outside of the months, duplicates or on a missing leap day.
+type EventCount struct {
+	Placed  int `json:"placed"`
+	Skipped int `json:"skipped"`
+}
+
+// Download is a performed download, with the error if it failed.
+type Download struct {
+	URL   string `json:"url"`
+	Bytes int    `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	reportMutex sync.Mutex
+	report      Report
+)
+
+// GetReport returns the report of the files generated so far.
+func GetReport() Report {
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	return Report{
+		Files:     append([]FileReport{}, report.Files...),
+		Downloads: append([]Download{}, report.Downloads...),
+		Warnings:  append([]Warning{}, Warnings()...),
+	}
+}
+
+// recordDownload adds a download to the report.
+func recordDownload(url string, data []byte, err error) {
+	d := Download{URL: url, Bytes: len(data)}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	reportMutex.Lock()
+	report.Downloads = append(report.Downloads, d)
+	reportMutex.Unlock()
+}
+
+// countEvents counts the events per source: all is the list of
+// all sources, placed the events that made it on the calendar
+// of the months from begin to end.
+func countEvents(all []gDate, placed []gDate, begin int, end int) map[string]EventCount {
+	counts := make(map[string]EventCount)
+	for _, ev := range all {
+		c := counts[ev.Source]
+		c.Skipped++
+		counts[ev.Source] = c
+	}
+	for _, ev := range placed {
+		if ev.Weekday == "" && (int(ev.Month) < begin || int(ev.Month) > end) {
+			continue
+		}
+		c := counts[ev.Source]
+		c.Placed++
+		c.Skipped--
+		counts[ev.Source] = c
+	}
+	return counts
+}
+
+// recordFile adds a written file to the report, with the event
+// counts of its calendar.
+func recordFile(fname string, pages int, events map[string]EventCount) {
+	reportMutex.Lock()
+	report.Files = append(report.Files, FileReport{fname, pages, events})
+	reportMutex.Unlock()
+}
+
+// SetReport makes the calendar keep the files it writes and its
+// warnings in r instead of the report of GetReport, e.g. for the jobs
+// of a server, whose reports would otherwise grow forever.
+func (g *Calendar) SetReport(r *Report) {
+	g.report = r
+}
+
+// recordFile adds a written file of the calendar to its report.
+func (g *Calendar) recordFile(fname string, pages int) {
+	if g.report == nil {
+		recordFile(fname, pages, g.eventCounts)
+		return
+	}
+	reportMutex.Lock()
+	g.report.Files = append(g.report.Files, FileReport{fname, pages, g.eventCounts})
+	reportMutex.Unlock()
+}
+
+// warnf reports a problem of the calendar: in strict mode it is a
+// fatal error, otherwise it is printed and kept in the report of the
+// calendar, see SetReport. The configuration is read more than once,
+// so repeated warnings are reported only once.
+func (g *Calendar) warnf(kind string, format string, v ...interface{}) {
+	if g.report == nil && !g.strict {
+		warnf(kind, format, v...)
+		return
+	}
+	countError(kind)
+	msg := fmt.Sprintf(format, v...)
+	if g.strict {
+		code := ExitConfig
+		if kind == "download" {
+			code = ExitFetch
+		}
+		g.fatalf(code, "# Error: %s", msg)
+	}
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	g.report.Warnings = addWarning(g.report.Warnings, Warning{kind, msg})
+}
@@ -0,0 +1,311 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// rules.go
+//
+// Conditional formatting of the monthly calendar: rules of the
+// configuration file like if="category == work" then="color gray",
+// evaluated for every day with its events.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRule is a rule of the configuration file, the actions apply
+// when all conditions hold.
+type gRule struct {
+	conds   []ruleCond
+	actions []ruleAction
+}
+
+// ruleCond compares a subject of the day or of an event with a value.
+type ruleCond struct {
+	subject string // category, text, weekday, day, month, count or pattern
+	op      string
+	value   string
+}
+
+// ruleAction is a formatting of a rule, scale is the factor of shrink.
+type ruleAction struct {
+	name  string // color, fill, icon or shrink
+	value string
+	scale float64
+}
+
+// ruleFormat is the formatting of a day by the rules.
+type ruleFormat struct {
+	Color string
+	Fill  string
+	Icon  string
+	Scale float64 // of the event font, 0 keeps it
+}
+
+// ruleOps are the operators of the conditions, longer ones first.
+var ruleOps = []string{"==", "!=", ">=", "<=", ">", "<", "contains"}
+
+// ruleSubjects are the subjects of the conditions, true if they
+// are numbers.
+var ruleSubjects = map[string]bool{
+	"category": false,
+	"text":     false,
+	"weekday":  false,
+	"day":      true,
+	"month":    true,
+	"count":    true,
+}
+
+// parseRule reads the conditions, joined by "and", and the actions,
+// separated by commas. Weekday names may be in lang.
+func parseRule(cond string, then string, lang string) (r gRule, ok bool) {
+	for _, c := range strings.Split(cond, " and ") {
+		cs, ok := parseRuleCond(c, lang)
+		if !ok {
+			return r, false
+		}
+		r.conds = append(r.conds, cs...)
+	}
+	for _, a := range strings.Split(then, ",") {
+		act, ok := parseRuleAction(a)
+		if !ok {
+			return r, false
+		}
+		r.actions = append(r.actions, act)
+	}
+	return r, len(r.conds) > 0
+}
+
+// parseRuleCond reads a condition like "category == work" or
+// "event count > 3". "day is Friday 13" is short for the conditions
+// weekday == Friday and day == 13, "day is palindrome" matches the
+// days of a date pattern.
+func parseRuleCond(s string, lang string) ([]ruleCond, bool) {
+	fields := strings.Fields(s)
+	if len(fields) > 0 && (fields[0] == "event" || fields[0] == "events") {
+		fields = fields[1:]
+	}
+	if len(fields) < 3 {
+		return nil, false
+	}
+	subject := strings.ToLower(fields[0])
+	if subject == "day" && fields[1] == "is" {
+		var cs []ruleCond
+		for _, f := range fields[2:] {
+			if _, err := strconv.Atoi(f); err == nil {
+				cs = append(cs, ruleCond{"day", "==", f})
+			} else if wd, ok := lookupWeekday(strings.ToLower(f), lang); ok {
+				cs = append(cs, ruleCond{"weekday", "==", wd.String()})
+			} else if _, ok := namedPatterns[strings.ToLower(f)]; ok {
+				cs = append(cs, ruleCond{"pattern", "==", strings.ToLower(f)})
+			} else {
+				return nil, false
+			}
+		}
+		return cs, true
+	}
+	numeric, ok := ruleSubjects[subject]
+	if !ok || !stringInSlice(fields[1], ruleOps) {
+		return nil, false
+	}
+	c := ruleCond{subject, fields[1], strings.Join(fields[2:], " ")}
+	switch {
+	case numeric:
+		_, err := strconv.Atoi(c.value)
+		return []ruleCond{c}, err == nil && c.op != "contains"
+	case subject == "weekday":
+		wd, ok := lookupWeekday(strings.ToLower(c.value), lang)
+		c.value = wd.String()
+		return []ruleCond{c}, ok && (c.op == "==" || c.op == "!=")
+	case subject == "text":
+		c.value = convertCP(c.value) // like the event texts
+	}
+	return []ruleCond{c}, c.op == "==" || c.op == "!=" || c.op == "contains"
+}
+
+// parseRuleAction reads an action like "color gray", "fill #eeeeee",
+// "icon skull.png", "icon †" or "shrink font" (to 80%) and "shrink 0.7".
+func parseRuleAction(s string) (ruleAction, bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return ruleAction{}, false
+	}
+	a := ruleAction{name: strings.ToLower(fields[0]), value: strings.Join(fields[1:], " ")}
+	switch a.name {
+	case "color", "fill", "icon":
+		return a, true
+	case "shrink":
+		if a.value == "font" {
+			a.scale = 0.8
+			return a, true
+		}
+		f, err := strconv.ParseFloat(a.value, 64)
+		a.scale = f
+		return a, err == nil && f > 0 && f <= 1
+	}
+	return ruleAction{}, false
+}
+
+// eventSubject reports whether the condition is about an event
+// instead of the day.
+func (c ruleCond) eventSubject() bool {
+	return c.subject == "category" || c.subject == "text"
+}
+
+// matchDay checks a condition of the day d with n events.
+func (c ruleCond) matchDay(d time.Time, n int) bool {
+	switch c.subject {
+	case "weekday":
+		return (d.Weekday().String() == c.value) == (c.op == "==")
+	case "day":
+		return compareInts(d.Day(), c.op, c.value)
+	case "month":
+		return compareInts(int(d.Month()), c.op, c.value)
+	case "count":
+		return compareInts(n, c.op, c.value)
+	case "pattern":
+		return namedPatterns[c.value].match(d)
+	}
+	return false
+}
+
+// matchEvent checks a condition of an event.
+func (c ruleCond) matchEvent(ev gDate) bool {
+	s := ev.Category
+	if c.subject == "text" {
+		s = ev.Text
+	}
+	switch c.op {
+	case "==":
+		return strings.EqualFold(s, c.value)
+	case "!=":
+		return !strings.EqualFold(s, c.value)
+	case "contains":
+		return strings.Contains(s, c.value)
+	}
+	return false
+}
+
+// compareInts compares a with the number b.
+func compareInts(a int, op string, b string) bool {
+	n, _ := strconv.Atoi(b)
+	switch op {
+	case "==":
+		return a == n
+	case "!=":
+		return a != n
+	case ">":
+		return a > n
+	case "<":
+		return a < n
+	case ">=":
+		return a >= n
+	case "<=":
+		return a <= n
+	}
+	return false
+}
+
+// applyRules returns the formatting of day d by the rules, and the
+// events of the day with their colors. The color of a rule with
+// conditions on events colors the matching events, otherwise the day.
+func applyRules(rules []gRule, d time.Time, events []gDate) (f ruleFormat, out []gDate) {
+	out = append(out, events...)
+	for _, r := range rules {
+		var evConds []ruleCond
+		dayOK := true
+		for _, c := range r.conds {
+			if c.eventSubject() {
+				evConds = append(evConds, c)
+			} else if !c.matchDay(d, len(events)) {
+				dayOK = false
+			}
+		}
+		if !dayOK {
+			continue
+		}
+		matched := len(evConds) == 0
+		for i := range out {
+			if len(evConds) == 0 {
+				break
+			}
+			all := true
+			for _, c := range evConds {
+				all = all && c.matchEvent(out[i])
+			}
+			if !all {
+				continue
+			}
+			matched = true
+			for _, a := range r.actions {
+				if a.name == "color" {
+					out[i].Color = a.value
+				}
+			}
+		}
+		if !matched {
+			continue
+		}
+		for _, a := range r.actions {
+			switch a.name {
+			case "color":
+				if len(evConds) == 0 {
+					f.Color = a.value
+				}
+			case "fill":
+				f.Fill = a.value
+			case "icon":
+				f.Icon = a.value
+			case "shrink":
+				f.Scale = a.scale
+			}
+		}
+	}
+	return f, out
+}
+
+// readConfigurationRules returns the rules of the XML file.
+func (g *Calendar) readConfigurationRules(filename string, lang string) (rL []gRule) {
+	v := g.loadConfigurationfile(filename)
+	for _, m := range v.Gocalrule {
+		r, ok := parseRule(m.If, m.Then, lang)
+		if !ok {
+			g.warnf("field", "Ignoring invalid rule if='%s' then='%s'", m.If, m.Then)
+			continue
+		}
+		rL = append(rL, r)
+	}
+	return rL
+}
+
+// drawIcon draws the icon of a rule, an image file or a short text,
+// of size s on the right of the cell, if there is room.
+func (g *Calendar) drawIcon(pdf *gofpdf.Fpdf, layout *cellLayout, fonts elementFonts, icon string, s float64) {
+	r, ok := layout.placeRight(s, s)
+	if !ok {
+		return
+	}
+	switch strings.ToLower(filepath.Ext(icon)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg":
+		icon, ok := g.rootedFile(icon)
+		if !ok {
+			return
+		}
+		if _, err := os.Stat(icon); err != nil {
+			g.warnf("field", "Icon %v not found", icon)
+			return
+		}
+		g.image(pdf, icon, r.x, r.y, r.w, r.h)
+	default:
+		fonts.set(pdf, "event", s*2.5)
+		pdf.Text(r.x, r.y+ASCENT*s, convertCP(icon))
+	}
+}
@@ -0,0 +1,96 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// sanitize.go
+//
+// Cleanup of the texts of events from ICS feeds that anyone may
+// write: control characters, overlong texts, URLs, and sequences
+// like {{.Month}} or {name} that templates would expand.
+//
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// sanitizations are the cleanups of SetSanitize.
+var sanitizations = []string{"controls", "urls", "templates"}
+
+// SetSanitize sets the cleanups of the texts of ICS events, comma
+// separated: controls removes control characters, urls removes web
+// addresses, templates escapes sequences like {{.Month}} and {name}.
+// all does all of them.
+func (g *Calendar) SetSanitize(s string) {
+	g.OptSanitize = s
+}
+
+// SetEventLength limits the length of the texts of ICS events in
+// characters, 0 for no limit.
+func (g *Calendar) SetEventLength(n int) {
+	g.OptEventLength = n
+}
+
+var (
+	urlPattern      = regexp.MustCompile(`(?i)\b(?:https?|ftp)://\S+|\bwww\.\S+|\bmailto:\S+`)
+	templatePattern = regexp.MustCompile(`\{\{.*?\}\}|\{[A-Za-z_][A-Za-z0-9_]*\}`)
+	spacesPattern   = regexp.MustCompile(`[ \t]{2,}`)
+)
+
+// sanitizeText applies the cleanups to the UTF-8 text s and cuts it
+// to maxLen characters.
+func sanitizeText(s string, cleanups map[string]bool, maxLen int) string {
+	if cleanups["controls"] {
+		s = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\n' || r == '\u200b' || unicode.In(r, unicode.Bidi_Control) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if cleanups["urls"] {
+		s = strings.TrimSpace(spacesPattern.ReplaceAllString(urlPattern.ReplaceAllString(s, ""), " "))
+	}
+	if cleanups["templates"] {
+		s = templatePattern.ReplaceAllStringFunc(s, func(m string) string {
+			return strings.NewReplacer("{", "(", "}", ")").Replace(m)
+		})
+	}
+	if maxLen > 0 {
+		s = shortenText(s, maxLen)
+	}
+	return s
+}
+
+// sanitizeEvents applies the cleanups of OptSanitize and the limit of
+// OptEventLength to the summaries and descriptions of the events.
+func (g *Calendar) sanitizeEvents(eL []gDate) []gDate {
+	if g.OptSanitize == "" && g.OptEventLength <= 0 {
+		return eL
+	}
+	cleanups := make(map[string]bool)
+	for _, c := range strings.Split(g.OptSanitize, ",") {
+		switch c = strings.TrimSpace(c); {
+		case c == "all":
+			for _, a := range sanitizations {
+				cleanups[a] = true
+			}
+		case stringInSlice(c, sanitizations):
+			cleanups[c] = true
+		case c != "":
+			fmt.Printf("# Error, unknown sanitization '%s'\n", c)
+		}
+	}
+	out := make([]gDate, len(eL))
+	for i, ev := range eL {
+		ev.Text = convertCP(sanitizeText(convertFromCP(ev.Text), cleanups, g.OptEventLength))
+		ev.Description = sanitizeText(ev.Description, cleanups, g.OptEventLength)
+		out[i] = ev
+	}
+	return out
+}
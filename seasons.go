@@ -0,0 +1,103 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// seasons.go
+//
+// The seasons of the months, in the northern or the southern
+// hemisphere, and the colors of the seasonal theme.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+	"time"
+)
+
+// seasonPalette are the colors of a season: the grid, the day
+// numbers and the fill of the header.
+type seasonPalette struct {
+	grid       string
+	dayNumber  string
+	headerFill string
+}
+
+// seasonPalettes are the palettes from winter to autumn: winter
+// blues, spring greens, summer yellows and autumn browns.
+var seasonPalettes = [4]seasonPalette{
+	{"#4a6fa5", "#2b4c7e", "#dce8f5"},
+	{"#5a9e4b", "#3d7a2f", "#e2f2d9"},
+	{"#e0a020", "#c0601a", "#fff0c8"},
+	{"#b5651d", "#8b3a0f", "#f5e0c8"},
+}
+
+// seasonOf returns the season of the month, 0 for winter to 3 for
+// autumn. In the southern hemisphere, December is summer.
+func seasonOf(mo time.Month, south bool) int {
+	if south {
+		mo = (mo+5)%12 + 1
+	}
+	return int(mo%12) / 3
+}
+
+// southern reports whether the seasons are those of the southern
+// hemisphere: by the season theme, or else by the location.
+func (g *Calendar) southern() bool {
+	switch g.OptSeasonTheme {
+	case "south":
+		return true
+	case "north":
+		return false
+	}
+	lat, _, err := parseLocation(g.OptLocation)
+	return g.OptLocation != "" && err == nil && lat < 0
+}
+
+// seasonTheme keeps the colors the user set, which the theme of a
+// season doesn't change.
+type seasonTheme struct {
+	g       *Calendar
+	south   bool
+	palette seasonPalette
+}
+
+// seasonTheme returns the seasonal theme, nil if there is none.
+func (g *Calendar) seasonTheme() *seasonTheme {
+	switch g.OptSeasonTheme {
+	case "":
+		return nil
+	case "north", "south", "auto":
+	default:
+		g.warnf("field", "Unknown season theme '%s', use north, south or auto", g.OptSeasonTheme)
+	}
+	return &seasonTheme{g, g.southern(), seasonPalette{g.OptGridColor, g.OptDayNumberColor, g.OptHeaderFill}}
+}
+
+// apply sets the colors of the season of the month.
+func (t *seasonTheme) apply(pdf *gofpdf.Fpdf, mo time.Month) {
+	if t == nil {
+		return
+	}
+	p := seasonPalettes[seasonOf(mo, t.south)]
+	t.restore()
+	if t.palette.grid == "" {
+		t.g.OptGridColor = p.grid
+	}
+	if t.palette.dayNumber == "" {
+		t.g.OptDayNumberColor = p.dayNumber
+	}
+	if t.palette.headerFill == "" {
+		t.g.OptHeaderFill = p.headerFill
+	}
+	t.g.setGridStyle(pdf)
+}
+
+// restore sets the colors of the user again.
+func (t *seasonTheme) restore() {
+	if t == nil {
+		return
+	}
+	t.g.OptGridColor, t.g.OptDayNumberColor, t.g.OptHeaderFill = t.palette.grid, t.palette.dayNumber, t.palette.headerFill
+}
@@ -0,0 +1,448 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// server.go
+//
+// The job API of the server mode: a configuration is posted, a pool
+// of workers creates the calendar in a directory of its own, and the
+// client polls the job until it can download the PDF. Finished jobs
+// expire, and the disk space of all jobs is limited by a quota. A job
+// reads files only in its directory, and its fatal errors fail the
+// job instead of stopping the server.
+//
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MAXJOBCONFIG is the largest configuration a job accepts, in bytes.
+const MAXJOBCONFIG = 1 << 20
+
+// JOBQUEUE is the number of jobs that wait for a worker.
+const JOBQUEUE = 100
+
+// JOBOUTPUT is the disk space a job reserves for its calendar until
+// it is done and its real size is known, in bytes.
+const JOBOUTPUT = 4 << 20
+
+// The states of a job.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a calendar job of the server, as its status reports it.
+type Job struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Year     int       `json:"year"`
+	Begin    int       `json:"begin"`
+	End      int       `json:"end"`
+	Layout   string    `json:"layout"`
+	Created  time.Time `json:"created"`
+	PDF      string    `json:"pdf,omitempty"`
+	Bytes    int64     `json:"bytes"`
+	Warnings []Warning `json:"warnings,omitempty"`
+	dir      string
+	done     time.Time
+}
+
+// JobServer serves the job API:
+//
+//	POST   /jobs?year=2026&begin=1&end=12&layout=monthly  a configuration file
+//	GET    /jobs/{id}                                      the status of the job
+//	GET    /jobs/{id}/pdf                                  the calendar
+//	DELETE /jobs/{id}                                      removes the job
+//	GET    /metrics                                        the metrics for Prometheus
+//
+// The layouts are monthly, year, yearinverse, strip and perpetual.
+type JobServer struct {
+	dir   string
+	quota int64
+	keep  time.Duration
+	// gen has the fonts converted for all jobs.
+	gen *Generator
+	// strict makes the warnings of the jobs fatal, see SetStrict.
+	strict bool
+	queue  chan *Job
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	// the metrics of the finished jobs
+	renders  histogram
+	pages    int64
+	finished map[string]int64
+}
+
+// Layouts create the calendar of a layout, by the names that the job
+// server and the browser accept.
+var Layouts = map[string]func(g *Calendar, fn string){
+	"monthly":     (*Calendar).CreateCalendar,
+	"year":        (*Calendar).CreateYearCalendar,
+	"yearinverse": (*Calendar).CreateYearCalendarInverse,
+	"strip":       (*Calendar).CreateContinuousCalendar,
+	"perpetual":   (*Calendar).CreatePerpetualCalendar,
+}
+
+// LayoutNames returns the sorted names of the Layouts, e.g. for
+// messages.
+func LayoutNames() string {
+	var names []string
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// NewJobServer returns a server with the workers that keeps the jobs
+// in dir, at most quota bytes of them, and finished jobs for keep.
+func NewJobServer(dir string, workers int, quota int64, keep time.Duration) (*JobServer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	gen, err := NewGenerator(nil, nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &JobServer{dir: dir, quota: quota, keep: keep, gen: gen, queue: make(chan *Job, JOBQUEUE), jobs: make(map[string]*Job), finished: make(map[string]int64)}
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s, nil
+}
+
+// SetStrict makes malformed dates, unknown fields in the configuration
+// and failed downloads fail the jobs.
+func (s *JobServer) SetStrict(strict bool) {
+	s.strict = strict
+}
+
+// ServeHTTP routes the requests of the job API.
+func (s *JobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.expire()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "jobs" && r.Method == http.MethodPost:
+		s.create(w, r)
+	case len(parts) == 2 && parts[0] == "jobs" && r.Method == http.MethodGet:
+		s.status(w, parts[1])
+	case len(parts) == 2 && parts[0] == "jobs" && r.Method == http.MethodDelete:
+		s.remove(w, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "pdf" && r.Method == http.MethodGet:
+		s.download(w, r, parts[1])
+	case len(parts) == 1 && parts[0] == "metrics" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.writeMetrics(w)
+	default:
+		jobError(w, http.StatusNotFound, "no such resource: %s %s", r.Method, r.URL.Path)
+	}
+}
+
+// jobError answers with the status and the error as JSON.
+func jobError(w http.ResponseWriter, code int, format string, v ...interface{}) {
+	writeJSON(w, code, map[string]string{"error": fmt.Sprintf(format, v...)})
+}
+
+// writeJSON answers with the status and v as JSON.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// queryInt returns the integer parameter of the query, or def.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", name, v)
+	}
+	return n, nil
+}
+
+// create queues the job of the posted configuration.
+func (s *JobServer) create(w http.ResponseWriter, r *http.Request) {
+	j := &Job{Status: JobQueued, Layout: r.URL.Query().Get("layout"), Created: time.Now()}
+	var err error
+	if j.Year, err = queryInt(r, "year", time.Now().Year()); err == nil {
+		if j.Begin, err = queryInt(r, "begin", 1); err == nil {
+			j.End, err = queryInt(r, "end", 12)
+		}
+	}
+	if err != nil {
+		jobError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if j.Year < 1 || j.Year > 9999 {
+		jobError(w, http.StatusBadRequest, "invalid year %d", j.Year)
+		return
+	}
+	if j.Begin < 1 || j.End > 12 || j.Begin > j.End {
+		jobError(w, http.StatusBadRequest, "invalid months %d to %d", j.Begin, j.End)
+		return
+	}
+	if j.Layout == "" {
+		j.Layout = "monthly"
+	}
+	if _, ok := Layouts[j.Layout]; !ok {
+		jobError(w, http.StatusBadRequest, "unknown layout '%s', use %s", j.Layout, LayoutNames())
+		return
+	}
+	config, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MAXJOBCONFIG))
+	if err != nil {
+		jobError(w, http.StatusRequestEntityTooLarge, "the configuration is larger than %d bytes", MAXJOBCONFIG)
+		return
+	}
+	// An invalid configuration would stop the server.
+	if len(strings.TrimSpace(string(config))) > 0 {
+		if _, err := parseConfiguration(config, "the configuration"); err != nil {
+			jobError(w, http.StatusBadRequest, "invalid configuration: %v", err)
+			return
+		}
+	}
+	if j.ID, err = newJobID(); err == nil {
+		j.dir = filepath.Join(s.dir, j.ID)
+		if err = os.Mkdir(j.dir, 0700); err == nil {
+			err = ioutil.WriteFile(filepath.Join(j.dir, "config.xml"), config, 0600)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(j.dir)
+		jobError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	j.Bytes = int64(len(config)) + JOBOUTPUT
+
+	// The job is added with the room it reserves, so that the jobs
+	// posted at the same time don't reserve the same room.
+	s.mu.Lock()
+	if !s.makeRoom(j.Bytes) {
+		s.mu.Unlock()
+		os.RemoveAll(j.dir)
+		jobError(w, http.StatusInsufficientStorage, "the disk quota of the jobs is used up")
+		return
+	}
+	select {
+	case s.queue <- j:
+		s.jobs[j.ID] = j
+	default:
+		s.mu.Unlock()
+		os.RemoveAll(j.dir)
+		jobError(w, http.StatusServiceUnavailable, "too many jobs are waiting")
+		return
+	}
+	status := *j
+	s.mu.Unlock()
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// newJobID returns a random id.
+func newJobID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// status answers with the status of the job.
+func (s *JobServer) status(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	var status Job
+	if ok {
+		status = *j
+	}
+	s.mu.Unlock()
+	if !ok {
+		jobError(w, http.StatusNotFound, "no job %s", id)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// download sends the calendar of a finished job.
+func (s *JobServer) download(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	var status string
+	if ok {
+		status = j.Status
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		jobError(w, http.StatusNotFound, "no job %s", id)
+	case status != JobDone:
+		jobError(w, http.StatusConflict, "job %s is %s", id, status)
+	default:
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"calendar-"+id+".pdf\"")
+		http.ServeFile(w, r, filepath.Join(j.dir, "calendar.pdf"))
+	}
+}
+
+// remove removes a job that is not running.
+func (s *JobServer) remove(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	running := ok && j.Status == JobRunning
+	if ok && !running {
+		// A queued job is skipped by the worker.
+		delete(s.jobs, id)
+		os.RemoveAll(j.dir)
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		jobError(w, http.StatusNotFound, "no job %s", id)
+	case running:
+		jobError(w, http.StatusConflict, "job %s is running", id)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// work runs the jobs of the queue.
+func (s *JobServer) work() {
+	for j := range s.queue {
+		s.mu.Lock()
+		_, ok := s.jobs[j.ID]
+		if ok {
+			j.Status = JobRunning
+		}
+		s.mu.Unlock()
+		if ok {
+			s.run(j)
+		}
+	}
+}
+
+// run creates the calendar of the job in its directory.
+func (s *JobServer) run(j *Job) {
+	status, msg := JobDone, ""
+	config := filepath.Join(j.dir, "config.xml")
+	pdf := filepath.Join(j.dir, "calendar.pdf")
+	var report Report
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(fatalError); ok {
+				status, msg = JobFailed, strings.TrimPrefix(e.msg, "# Error: ")
+			} else {
+				status, msg = JobFailed, fmt.Sprintf("%v", r)
+				countError("panic")
+			}
+		}
+		pages := 0
+		for _, f := range report.Files {
+			if f.File == pdf {
+				pages = f.Pages
+			}
+		}
+		size := dirSize(j.dir)
+		s.mu.Lock()
+		if status == JobDone && !s.makeRoom(size-j.Bytes) {
+			status, msg, pages = JobFailed, "the calendar exceeds the disk quota of the jobs", 0
+			os.Remove(pdf)
+			size = dirSize(j.dir)
+		}
+		j.Status, j.Error, j.Bytes, j.Warnings, j.done = status, msg, size, report.Warnings, time.Now()
+		if status == JobDone {
+			j.PDF = "/jobs/" + j.ID + "/pdf"
+		}
+		s.renders.observe(time.Since(start).Seconds())
+		s.pages += int64(pages)
+		s.finished[status]++
+		s.mu.Unlock()
+	}()
+	g := s.gen.New(j.Begin, j.End, j.Year)
+	// Fatal errors fail the job instead of stopping the server.
+	g.fatalPanics = true
+	g.SetStrict(s.strict)
+	g.SetReport(&report)
+	g.SetFileRoot(j.dir)
+	if info, err := os.Stat(config); err == nil && info.Size() > 0 {
+		g.AddConfig(config)
+	}
+	Layouts[j.Layout](g, pdf)
+	if info, err := os.Stat(pdf); err != nil || info.Size() == 0 {
+		status, msg = JobFailed, "no PDF was generated"
+	}
+}
+
+// dirSize returns the bytes of the files in the directory.
+func dirSize(dir string) (size int64) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// expire removes the finished jobs older than the time to keep them.
+func (s *JobServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, j := range s.jobs {
+		if !j.done.IsZero() && time.Since(j.done) > s.keep {
+			delete(s.jobs, id)
+			os.RemoveAll(j.dir)
+		}
+	}
+}
+
+// reserve makes room for n more bytes under the quota, removing the
+// oldest finished jobs if needed. It reports whether they fit.
+func (s *JobServer) reserve(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.makeRoom(n)
+}
+
+// makeRoom is reserve with the lock held.
+func (s *JobServer) makeRoom(n int64) bool {
+	var used int64
+	var finished []*Job
+	for _, j := range s.jobs {
+		used += j.Bytes
+		if !j.done.IsZero() {
+			finished = append(finished, j)
+		}
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].done.Before(finished[b].done) })
+	for _, j := range finished {
+		if used+n <= s.quota {
+			break
+		}
+		used -= j.Bytes
+		delete(s.jobs, j.ID)
+		os.RemoveAll(j.dir)
+	}
+	return used+n <= s.quota
+}
@@ -0,0 +1,136 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// shift.go
+//
+// The configuration of a new year from the one of the year before,
+// e.g. to start the next release of a community calendar.
+//
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shiftDate returns the date of the configuration in the year to.
+// Weekdays, ranges and relative dates like "3rd Sunday of 6" are
+// resolved in every year and stay. Fixed dates stay too, or with
+// weekdays they keep their weekday: the 2nd Tuesday of March in the
+// year from moves to the 2nd Tuesday of March in to, a 5th weekday
+// to the last one.
+func shiftDate(date string, lang string, from, to int, weekdays bool) (string, bool) {
+	days, ok := parseConfigDate(date, lang, from)
+	if !ok {
+		return date, false
+	}
+	fields := strings.Split(strings.TrimSpace(date), "/")
+	if len(days) != 1 || len(fields) != 2 || strings.Contains(date, "-") || !weekdays {
+		return date, true
+	}
+	t := time.Date(from, days[0].Month, days[0].Day, 0, 0, 0, 0, time.UTC)
+	if t.Month() != days[0].Month {
+		return date, true // e.g. 2/29
+	}
+	nth := (t.Day()-1)/7 + 1
+	if nth == 5 {
+		nth = -1
+	}
+	moved, ok := nthDayOfMonth(to, t.Month(), nth, func(d time.Time) bool { return d.Weekday() == t.Weekday() })
+	if !ok {
+		return date, true
+	}
+	return fmt.Sprintf("%d/%d", int(moved.Month()), moved.Day()), true
+}
+
+// dateAttr finds the date attribute in the start tag of an entry.
+var dateAttr = regexp.MustCompile(`(\sdate\s*=\s*)("[^"]*"|'[^']*')`)
+
+// ShiftConfig writes the configuration file for the year to, with
+// the dates of the events of the year from moved by shiftDate. The
+// rest of the file is copied as it is. The dates it can't move are
+// kept and returned as warnings.
+func ShiftConfig(w io.Writer, filename string, lang string, from, to int, weekdays bool) (warnings []Warning, err error) {
+	data, err := readInputFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	last := int64(0)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return warnings, err
+		}
+		offset := dec.InputOffset()
+		tag := data[last:offset]
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "Gocaldate" {
+			tag = dateAttr.ReplaceAllFunc(tag, func(m []byte) []byte {
+				sm := dateAttr.FindSubmatch(m)
+				for _, a := range se.Attr {
+					if a.Name.Local != "date" {
+						continue
+					}
+					d, ok := shiftDate(a.Value, lang, from, to, weekdays)
+					if !ok {
+						warnings = append(warnings, Warning{"date", fmt.Sprintf("Keeping unknown date '%s'", a.Value)})
+					}
+					var b bytes.Buffer
+					b.Write(sm[1])
+					b.WriteByte('"')
+					xml.EscapeText(&b, []byte(d))
+					b.WriteByte('"')
+					return b.Bytes()
+				}
+				return m
+			})
+		}
+		out.Write(tag)
+		last = offset
+	}
+	out.Write(data[last:])
+	_, err = w.Write(out.Bytes())
+	return warnings, err
+}
+
+// ShiftICS writes a configuration file for the year to with the
+// events of the ICS file in the year from, moved by shiftDate. The
+// problems of the ICS file are returned as warnings.
+func ShiftICS(w io.Writer, filename string, from, to int, weekdays bool) (warnings []Warning, err error) {
+	warn := func(kind string, format string, v ...interface{}) {
+		warnings = append(warnings, Warning{kind, fmt.Sprintf(format, v...)})
+	}
+	var b bytes.Buffer
+	b.WriteString("<Gocal>\n")
+	for _, ev := range newICSReader().read(filename, from, "", warn) {
+		if ev.Kind == "todo" || ev.Kind == "done" || ev.Kind == "journal" {
+			continue
+		}
+		date, _ := shiftDate(fmt.Sprintf("%d/%d", int(ev.Month), ev.Day), "", from, to, weekdays)
+		b.WriteString("  <Gocaldate date=" + strconv.Quote(date) + " text=\"")
+		xml.EscapeText(&b, []byte(convertFromCP(ev.Text)))
+		b.WriteString("\"")
+		if ev.Description != "" {
+			b.WriteString(" description=\"")
+			xml.EscapeText(&b, []byte(ev.Description))
+			b.WriteString("\"")
+		}
+		b.WriteString("/>\n")
+	}
+	b.WriteString("</Gocal>\n")
+	_, err = w.Write(b.Bytes())
+	return warnings, err
+}
@@ -0,0 +1,93 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// sources.go
+//
+// Days with events of several sources: the events are in the same
+// order on every run, and prefixes tell the sources apart.
+//
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// AddSourcePrefix puts the prefix before the texts of the events of
+// the source: a configuration or ICS file or URL as given, or its
+// file name, or holidays, astronomy, liturgical, shabbat, recipient,
+// bridge or api for the events of the Add functions.
+func (g *Calendar) AddSourcePrefix(source string, prefix string) {
+	if g.OptSourcePrefixes == nil {
+		g.OptSourcePrefixes = make(map[string]string)
+	}
+	g.OptSourcePrefixes[source] = prefix
+}
+
+// priorityRank returns the rank of a priority, with 0 like 5.
+func priorityRank(p int) int {
+	if p == 0 {
+		return 5
+	}
+	return p
+}
+
+// sortEvents orders the events of a day by priority, then all-day
+// events before those at a time, by time, then by text.
+func sortEvents(eL []gDate) {
+	sort.SliceStable(eL, func(i, j int) bool {
+		a, b := eL[i], eL[j]
+		if a.Month != b.Month || a.Day != b.Day {
+			return a.Month < b.Month || a.Month == b.Month && a.Day < b.Day
+		}
+		if pa, pb := priorityRank(a.Priority), priorityRank(b.Priority); pa != pb {
+			return pa < pb
+		}
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		return strings.ToLower(a.Text) < strings.ToLower(b.Text)
+	})
+}
+
+// sourceMatches reports whether the event source is the source of a
+// prefix.
+func sourceMatches(source string, name string) bool {
+	switch {
+	case source == name:
+		return true
+	case name == "holidays":
+		return source == "holidays" || strings.Contains(source, "Holidays?")
+	}
+	return path.Base(source) == name
+}
+
+// prefixEvents puts the prefixes of OptSourcePrefixes before the
+// texts of the events.
+func (g *Calendar) prefixEvents(eL []gDate) []gDate {
+	if len(g.OptSourcePrefixes) == 0 {
+		return eL
+	}
+	names := make([]string, 0, len(g.OptSourcePrefixes))
+	for name := range g.OptSourcePrefixes {
+		names = append(names, name)
+	}
+	// The longest, most exact name wins.
+	sort.Strings(names)
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	out := make([]gDate, len(eL))
+	for i, ev := range eL {
+		for _, name := range names {
+			if sourceMatches(ev.Source, name) {
+				ev.Text = convertCP(g.OptSourcePrefixes[name]) + ev.Text
+				break
+			}
+		}
+		out[i] = ev
+	}
+	return out
+}
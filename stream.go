@@ -0,0 +1,404 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// stream.go
+//
+// Streaming of long monthly calendars: gofpdf keeps the whole
+// document in memory, so the calendar is rendered in parts of a few
+// months, and every part is joined into the output file as soon as it
+// is done. The fonts and photos that the parts share are written once.
+//
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// streamPart is the part of a streamed calendar being rendered.
+type streamPart struct {
+	first, last bool
+	// offset is the number of pages of the parts before, total that of
+	// all parts if the page numbers show it.
+	offset, total int
+	// pages is the number of pages of the part, once it is rendered.
+	pages int
+}
+
+// isFirst reports whether the part begins the calendar; a calendar
+// that isn't streamed is its only part.
+func (p *streamPart) isFirst() bool {
+	return p == nil || p.first
+}
+
+// isLast reports whether the part ends the calendar.
+func (p *streamPart) isLast() bool {
+	return p == nil || p.last
+}
+
+// SetStream renders the monthly calendar in parts of the months,
+// which are written to the output as soon as they are done, so that
+// only one part is kept in memory.
+func (g *Calendar) SetStream(months int) {
+	g.OptStream = months
+}
+
+// streamed reports whether the calendar is rendered in parts.
+func (g *Calendar) streamed() bool {
+	if g.OptStream <= 0 || g.part != nil || g.WantEndMonth-g.WantBeginMonth < g.OptStream {
+		return false
+	}
+	if g.OptPDFX != "" {
+		fmt.Printf("# PDF/X is written in one piece, not streamed\n")
+		return false
+	}
+	return true
+}
+
+// renderParts renders the parts of the calendar and passes the PDF of
+// every part to emit.
+func (g *Calendar) renderParts(total int, emit func(data []byte) error) error {
+	g.icsReader() // shared by the parts
+	offset := 0
+	for b := g.WantBeginMonth; b <= g.WantEndMonth; b += g.OptStream {
+		part := *g
+		part.WantBeginMonth = b
+		part.WantEndMonth = b + g.OptStream - 1
+		if part.WantEndMonth > g.WantEndMonth {
+			part.WantEndMonth = g.WantEndMonth
+		}
+		var buf bytes.Buffer
+		part.output = &buf
+		part.part = &streamPart{first: b == g.WantBeginMonth, last: part.WantEndMonth == g.WantEndMonth, offset: offset, total: total}
+		part.CreateCalendar("")
+		if buf.Len() == 0 {
+			return fmt.Errorf("the months %d to %d were not rendered", part.WantBeginMonth, part.WantEndMonth)
+		}
+		if err := emit(buf.Bytes()); err != nil {
+			return err
+		}
+		offset += part.part.pages
+	}
+	return nil
+}
+
+// createStreamed creates the monthly calendar in parts of OptStream
+// months.
+func (g *Calendar) createStreamed(fn string) {
+	fname := g.outputFilename(fn)
+	total := 0
+	if strings.Contains(g.OptPageNumbers, "{pages}") {
+		// The total of the page numbers takes a first pass.
+		g.renderParts(0, func(data []byte) error {
+			_, pages, err := pdfPages(data)
+			total += len(pages)
+			return err
+		})
+	}
+	w := g.output
+	if w == nil && fname == STDIN {
+		w = pdfStdout
+	} else if w == nil {
+		f, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("# Error opening output file '%s'\n", fname)
+			g.exitCode = ExitRender
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+	j := newPDFJoiner(w)
+	err := g.renderParts(total, j.add)
+	if err == nil {
+		err = j.close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "# Error writing '%s': %v\n", fname, err)
+		countError("render")
+		g.exitCode = ExitRender
+		return
+	}
+	g.getEventList() // the events of all parts for the report
+	g.recordFile(fname, len(j.kids))
+	if g.OptCompanionICS && w != pdfStdout && g.output == nil {
+		g.writeCompanionICS(fname)
+	}
+	if w != pdfStdout && g.output == nil {
+		fmt.Printf("Generated '%v'.\n", fname)
+	}
+}
+
+// pdfRef finds the references to objects.
+var pdfRef = regexp.MustCompile(`(\d+) 0 R\b`)
+
+// pdfStrings returns the start and end of the string literals like
+// (a \) b) in the dictionary, which may look like references.
+func pdfStrings(dict []byte) (spans [][2]int) {
+	for i := 0; i < len(dict); i++ {
+		if dict[i] != '(' {
+			continue
+		}
+		start, depth := i, 0
+		for ; i < len(dict); i++ {
+			switch dict[i] {
+			case '\\':
+				i++
+				continue
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		end := i + 1
+		if end > len(dict) {
+			end = len(dict) // not closed
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+// replaceRefs replaces the references of the dictionary with repl,
+// but not in its strings.
+func replaceRefs(dict []byte, repl func([]byte) []byte) []byte {
+	var out []byte
+	last := 0
+	for _, s := range pdfStrings(dict) {
+		out = append(out, pdfRef.ReplaceAllFunc(dict[last:s[0]], repl)...)
+		out = append(out, dict[s[0]:s[1]]...)
+		last = s[1]
+	}
+	return append(out, pdfRef.ReplaceAllFunc(dict[last:], repl)...)
+}
+
+// pdfRefs returns the numbers of the objects that the dictionary
+// refers to, outside of its strings.
+func pdfRefs(dict []byte) (refs []int) {
+	replaceRefs(dict, func(m []byte) []byte {
+		n, _ := strconv.Atoi(string(m[:len(m)-4]))
+		refs = append(refs, n)
+		return m
+	})
+	return refs
+}
+
+// pdfJoiner joins the PDFs of gofpdf into one while they are written:
+// the pages of all parts in one page tree, and the objects that are
+// the same in several parts, like fonts and photos, only once.
+type pdfJoiner struct {
+	w       io.Writer
+	written int
+	// offsets of the objects by number; object 1 is the page tree.
+	offsets []int
+	kids    []int
+	shared  map[[sha256.Size]byte]int
+	// version, pages, catalog and info of the first part
+	version string
+	pages   string
+	catalog string
+	info    string
+}
+
+func newPDFJoiner(w io.Writer) *pdfJoiner {
+	return &pdfJoiner{w: w, offsets: []int{0, 0}, shared: make(map[[sha256.Size]byte]int)}
+}
+
+func (j *pdfJoiner) write(b []byte) error {
+	n, err := j.w.Write(b)
+	j.written += n
+	return err
+}
+
+// alloc returns the number of a new object.
+func (j *pdfJoiner) alloc() int {
+	j.offsets = append(j.offsets, 0)
+	return len(j.offsets) - 1
+}
+
+// pdfPages returns the objects of a PDF of gofpdf and its pages.
+func pdfPages(data []byte) (bodies map[int][]byte, pages []int, err error) {
+	offsets, xref, _, _, err := pdfObjects(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var numbers []int
+	for n := range offsets {
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(a, b int) bool { return offsets[numbers[a]] < offsets[numbers[b]] })
+	bodies = make(map[int][]byte)
+	for i, n := range numbers {
+		end := xref
+		if i+1 < len(numbers) {
+			end = offsets[numbers[i+1]]
+		}
+		if offsets[n] >= end || end > len(data) {
+			return nil, nil, fmt.Errorf("object %d not found", n)
+		}
+		obj := data[offsets[n]:end]
+		start, stop := bytes.Index(obj, []byte("obj\n")), bytes.LastIndex(obj, []byte("endobj"))
+		if start < 0 || stop < start {
+			return nil, nil, fmt.Errorf("object %d not found", n)
+		}
+		bodies[n] = obj[start+4 : stop]
+	}
+	kids := regexp.MustCompile(`/Kids \[([^\]]*)\]`).FindSubmatch(bodies[1])
+	if kids == nil {
+		return nil, nil, fmt.Errorf("no page tree")
+	}
+	for _, r := range pdfRef.FindAllSubmatch(kids[1], -1) {
+		n, _ := strconv.Atoi(string(r[1]))
+		pages = append(pages, n)
+	}
+	return bodies, pages, nil
+}
+
+// dictOf returns the dictionary of the object body, without stream.
+func dictOf(body []byte) []byte {
+	if i := bytes.Index(body, []byte(">>\nstream\n")); i >= 0 {
+		return body[:i+2]
+	}
+	return body
+}
+
+// add writes the objects of the PDF of a part.
+func (j *pdfJoiner) add(data []byte) error {
+	bodies, pages, err := pdfPages(data)
+	if err != nil {
+		return err
+	}
+	_, _, root, info, _ := pdfObjects(data)
+	isPage := make(map[int]bool)
+	for _, p := range pages {
+		isPage[p] = true
+	}
+	// Every object gets a new number, or that of the same object of
+	// a part before, once the objects it refers to have theirs.
+	numbers := map[int]int{1: 1}
+	rewrite := func(body []byte) []byte {
+		dict := replaceRefs(dictOf(body), func(m []byte) []byte {
+			n, _ := strconv.Atoi(string(m[:len(m)-4]))
+			if to, ok := numbers[n]; ok {
+				return []byte(strconv.Itoa(to) + " 0 R")
+			}
+			return m
+		})
+		return append(dict, body[len(dictOf(body)):]...)
+	}
+	var todo []int
+	for n := range bodies {
+		if n != 1 && n != root && n != info {
+			todo = append(todo, n)
+		}
+	}
+	sort.Ints(todo)
+	written := make(map[int]bool)
+	for progress := true; progress; {
+		progress = false
+		for _, n := range todo {
+			if _, ok := numbers[n]; ok {
+				continue
+			}
+			ready := true
+			for _, ref := range pdfRefs(dictOf(bodies[n])) {
+				if _, ok := numbers[ref]; !ok && ref != n && bodies[ref] != nil {
+					ready = false
+				}
+			}
+			if !ready {
+				continue
+			}
+			progress = true
+			if isPage[n] {
+				numbers[n] = j.alloc()
+				continue
+			}
+			sum := sha256.Sum256(rewrite(bodies[n]))
+			if m, ok := j.shared[sum]; ok {
+				numbers[n] = m
+				continue
+			}
+			numbers[n] = j.alloc()
+			j.shared[sum] = numbers[n]
+			written[n] = true
+		}
+	}
+	for _, n := range todo {
+		if _, ok := numbers[n]; !ok {
+			numbers[n] = j.alloc()
+			written[n] = true
+		}
+		if isPage[n] {
+			written[n] = true
+		}
+	}
+
+	if j.version == "" {
+		j.version = strings.TrimSpace(string(data[:bytes.IndexByte(data, '\n')+1]))
+		j.pages = regexp.MustCompile(`/Kids \[[^\]]*\]\s*/Count \d+\s*`).ReplaceAllString(string(bodies[1]), "")
+		j.catalog = string(rewrite(bodies[root]))
+		j.info = string(bodies[info])
+		if err := j.write([]byte(j.version + "\n")); err != nil {
+			return err
+		}
+	} else if v := strings.TrimSpace(string(data[:bytes.IndexByte(data, '\n')+1])); v > j.version {
+		j.catalog = strings.Replace(j.catalog, "/Type /Catalog", "/Type /Catalog\n/Version /"+strings.TrimPrefix(v, "%PDF-"), 1)
+	}
+	for _, n := range todo {
+		if !written[n] {
+			continue
+		}
+		j.offsets[numbers[n]] = j.written
+		obj := append([]byte(fmt.Sprintf("%d 0 obj\n", numbers[n])), rewrite(bodies[n])...)
+		if err := j.write(append(obj, "endobj\n"...)); err != nil {
+			return err
+		}
+	}
+	for _, p := range pages {
+		j.kids = append(j.kids, numbers[p])
+	}
+	return nil
+}
+
+// close writes the page tree, the catalog and the information of the
+// first part, and the cross-reference table.
+func (j *pdfJoiner) close() error {
+	var kids []string
+	for _, k := range j.kids {
+		kids = append(kids, fmt.Sprintf("%d 0 R", k))
+	}
+	pages := strings.Replace(j.pages, "/Type /Pages", fmt.Sprintf("/Type /Pages\n/Kids [%s]\n/Count %d", strings.Join(kids, " "), len(j.kids)), 1)
+	catalog, info := j.alloc(), j.alloc()
+	for _, o := range []struct {
+		n    int
+		body string
+	}{{1, pages}, {catalog, j.catalog}, {info, j.info}} {
+		j.offsets[o.n] = j.written
+		if err := j.write([]byte(fmt.Sprintf("%d 0 obj\n%sendobj\n", o.n, o.body))); err != nil {
+			return err
+		}
+	}
+	xref := j.written
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(j.offsets))
+	for _, off := range j.offsets[1:] {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<<\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n>>\nstartxref\n%d\n%%%%EOF\n", len(j.offsets), catalog, info, xref)
+	return j.write(b.Bytes())
+}
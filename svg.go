@@ -0,0 +1,700 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// svg.go
+//
+// SVG images as vector graphics, so that logos and icons stay sharp
+// in print: paths and basic shapes with fill and stroke colors,
+// in groups with transformations. Gradients, text, clipping and
+// references are left out.
+//
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// svgMatrix is an affine transformation [a b c d e f] as in SVG:
+// x' = a*x + c*y + e, y' = b*x + d*y + f.
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the transformation that applies n, then m.
+func (m svgMatrix) mul(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// svgSegment is a part of a path: M and L with one point, C with
+// the two control points and the end point, Z without points.
+type svgSegment struct {
+	cmd byte
+	pts []float64
+}
+
+// svgShape is a path with its paint. The colors are CSS colors,
+// empty for none.
+type svgShape struct {
+	path        []svgSegment
+	fill        string
+	stroke      string
+	strokeWidth float64
+	evenOdd     bool
+	alpha       float64
+}
+
+// svgImage is an SVG file in the coordinates of its viewBox.
+type svgImage struct {
+	minX, minY, w, h float64
+	shapes           []svgShape
+}
+
+// svgScanner reads the numbers and flags of path data and point lists.
+type svgScanner struct {
+	s string
+	i int
+}
+
+func (p *svgScanner) skip() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n,", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// number reads a number like "-1.5e3". "1.5.5" are two numbers.
+func (p *svgScanner) number() (float64, bool) {
+	p.skip()
+	start, i := p.i, p.i
+	if i < len(p.s) && (p.s[i] == '+' || p.s[i] == '-') {
+		i++
+	}
+	digits, dot := false, false
+	for ; i < len(p.s); i++ {
+		if isDigit(p.s[i]) {
+			digits = true
+		} else if p.s[i] == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+	}
+	if !digits {
+		return 0, false
+	}
+	if i < len(p.s) && (p.s[i] == 'e' || p.s[i] == 'E') {
+		j := i + 1
+		if j < len(p.s) && (p.s[j] == '+' || p.s[j] == '-') {
+			j++
+		}
+		if j < len(p.s) && isDigit(p.s[j]) {
+			for j < len(p.s) && isDigit(p.s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	v, err := strconv.ParseFloat(p.s[start:i], 64)
+	p.i = i
+	return v, err == nil
+}
+
+// numbers reads n numbers.
+func (p *svgScanner) numbers(n int) ([]float64, bool) {
+	v := make([]float64, n)
+	for k := range v {
+		var ok bool
+		if v[k], ok = p.number(); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// flag reads an arc flag, which needs no separator: "a1 1 0 01 2 2".
+func (p *svgScanner) flag() (bool, bool) {
+	p.skip()
+	if p.i < len(p.s) && (p.s[p.i] == '0' || p.s[p.i] == '1') {
+		p.i++
+		return p.s[p.i-1] == '1', true
+	}
+	return false, false
+}
+
+// svgArc returns the elliptical arc from x1,y1 to x2,y2 as cubic
+// curves, see the implementation notes of the SVG specification.
+func svgArc(x1, y1, rx, ry, phi float64, large, sweep bool, x2, y2 float64) []svgSegment {
+	if x1 == x2 && y1 == y2 {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []svgSegment{{'L', []float64{x2, y2}}}
+	}
+	sin, cos := math.Sincos(phi * math.Pi / 180)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p, y1p := cos*dx+sin*dy, -sin*dx+cos*dy
+	if l := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := 0.0
+	if num > 0 && den > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if large == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*y1p/ry, -coef*ry*x1p/rx
+	cx, cy := cos*cxp-sin*cyp+(x1+x2)/2, sin*cxp+cos*cyp+(y1+y2)/2
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1p-cxp)/rx, (y1p-cyp)/ry)
+	dtheta := angle((x1p-cxp)/rx, (y1p-cyp)/ry, (-x1p-cxp)/rx, (-y1p-cyp)/ry)
+	if !sweep && dtheta > 0 {
+		dtheta -= 2 * math.Pi
+	} else if sweep && dtheta < 0 {
+		dtheta += 2 * math.Pi
+	}
+	n := int(math.Ceil(math.Abs(dtheta)/(math.Pi/2) - 1e-9))
+	delta := dtheta / float64(n)
+	k := 4.0 / 3.0 * math.Tan(delta/4)
+	point := func(t float64) (float64, float64) {
+		return cx + rx*math.Cos(t)*cos - ry*math.Sin(t)*sin, cy + rx*math.Cos(t)*sin + ry*math.Sin(t)*cos
+	}
+	deriv := func(t float64) (float64, float64) {
+		return -rx*math.Sin(t)*cos - ry*math.Cos(t)*sin, -rx*math.Sin(t)*sin + ry*math.Cos(t)*cos
+	}
+	var segs []svgSegment
+	for i := 0; i < n; i++ {
+		t1, t2 := theta+float64(i)*delta, theta+float64(i+1)*delta
+		px1, py1 := point(t1)
+		px2, py2 := point(t2)
+		if i == n-1 {
+			px2, py2 = x2, y2
+		}
+		d1x, d1y := deriv(t1)
+		d2x, d2y := deriv(t2)
+		segs = append(segs, svgSegment{'C', []float64{px1 + k*d1x, py1 + k*d1y, px2 - k*d2x, py2 - k*d2y, px2, py2}})
+	}
+	return segs
+}
+
+// parseSVGPath reads path data into absolute M, L, C and Z segments.
+func parseSVGPath(d string) ([]svgSegment, error) {
+	p := &svgScanner{s: d}
+	var segs []svgSegment
+	var x, y, startX, startY float64
+	var ctrlX, ctrlY float64 // last control point, for S and T
+	var cmd, last byte
+	for {
+		p.skip()
+		if p.i >= len(d) {
+			return segs, nil
+		}
+		if c := d[p.i]; c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			cmd = c
+			p.i++
+		} else if cmd == 0 {
+			return nil, fmt.Errorf("unexpected '%c' in path data", c)
+		}
+		ox, oy := 0.0, 0.0
+		if cmd >= 'a' {
+			ox, oy = x, y
+		}
+		up := cmd &^ 0x20
+		var v []float64
+		ok := true
+		switch up {
+		case 'Z':
+			segs = append(segs, svgSegment{'Z', nil})
+			x, y = startX, startY
+			cmd = 0 // no numbers may follow
+		case 'M':
+			if v, ok = p.numbers(2); ok {
+				x, y = ox+v[0], oy+v[1]
+				startX, startY = x, y
+				segs = append(segs, svgSegment{'M', []float64{x, y}})
+				cmd = 'L' | cmd&0x20 // more points are lines
+			}
+		case 'L', 'H', 'V':
+			n := 2
+			if up != 'L' {
+				n = 1
+			}
+			if v, ok = p.numbers(n); ok {
+				switch up {
+				case 'L':
+					x, y = ox+v[0], oy+v[1]
+				case 'H':
+					x = ox + v[0]
+				case 'V':
+					y = oy + v[0]
+				}
+				segs = append(segs, svgSegment{'L', []float64{x, y}})
+			}
+		case 'C', 'S':
+			n := 6
+			if up == 'S' {
+				n = 4
+			}
+			if v, ok = p.numbers(n); ok {
+				c1x, c1y := x, y
+				if up == 'S' {
+					if last == 'C' || last == 'S' {
+						c1x, c1y = 2*x-ctrlX, 2*y-ctrlY
+					}
+					v = append([]float64{c1x - ox, c1y - oy}, v...)
+				}
+				c1x, c1y = ox+v[0], oy+v[1]
+				ctrlX, ctrlY = ox+v[2], oy+v[3]
+				x, y = ox+v[4], oy+v[5]
+				segs = append(segs, svgSegment{'C', []float64{c1x, c1y, ctrlX, ctrlY, x, y}})
+			}
+		case 'Q', 'T':
+			n := 4
+			if up == 'T' {
+				n = 2
+			}
+			if v, ok = p.numbers(n); ok {
+				qx, qy := x, y
+				if up == 'T' {
+					if last == 'Q' || last == 'T' {
+						qx, qy = 2*x-ctrlX, 2*y-ctrlY
+					}
+					v = append([]float64{qx - ox, qy - oy}, v...)
+				}
+				qx, qy = ox+v[0], oy+v[1]
+				ex, ey := ox+v[2], oy+v[3]
+				segs = append(segs, svgSegment{'C', []float64{
+					x + 2.0/3.0*(qx-x), y + 2.0/3.0*(qy-y),
+					ex + 2.0/3.0*(qx-ex), ey + 2.0/3.0*(qy-ey), ex, ey}})
+				ctrlX, ctrlY = qx, qy
+				x, y = ex, ey
+			}
+		case 'A':
+			var large, sweep bool
+			v, ok = p.numbers(3)
+			if ok {
+				large, ok = p.flag()
+			}
+			if ok {
+				sweep, ok = p.flag()
+			}
+			var end []float64
+			if ok {
+				end, ok = p.numbers(2)
+			}
+			if ok {
+				ex, ey := ox+end[0], oy+end[1]
+				segs = append(segs, svgArc(x, y, v[0], v[1], v[2], large, sweep, ex, ey)...)
+				x, y = ex, ey
+			}
+		default:
+			return nil, fmt.Errorf("unknown path command '%c'", cmd)
+		}
+		if !ok {
+			return nil, fmt.Errorf("bad arguments of '%c' in path data", up)
+		}
+		last = up
+	}
+}
+
+// parseSVGTransform reads a list of transformations like
+// "translate(10,20) rotate(45)".
+func parseSVGTransform(s string) (svgMatrix, error) {
+	m := svgIdentity
+	for {
+		s = strings.TrimLeft(s, " \t\r\n,")
+		if s == "" {
+			return m, nil
+		}
+		open, end := strings.IndexByte(s, '('), strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return m, fmt.Errorf("bad transform '%s'", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		p := &svgScanner{s: s[open+1 : end]}
+		var v []float64
+		for {
+			f, ok := p.number()
+			if !ok {
+				break
+			}
+			v = append(v, f)
+		}
+		var t svgMatrix
+		switch {
+		case name == "matrix" && len(v) == 6:
+			copy(t[:], v)
+		case name == "translate" && len(v) == 1:
+			t = svgMatrix{1, 0, 0, 1, v[0], 0}
+		case name == "translate" && len(v) == 2:
+			t = svgMatrix{1, 0, 0, 1, v[0], v[1]}
+		case name == "scale" && len(v) == 1:
+			t = svgMatrix{v[0], 0, 0, v[0], 0, 0}
+		case name == "scale" && len(v) == 2:
+			t = svgMatrix{v[0], 0, 0, v[1], 0, 0}
+		case name == "rotate" && (len(v) == 1 || len(v) == 3):
+			sin, cos := math.Sincos(v[0] * math.Pi / 180)
+			t = svgMatrix{cos, sin, -sin, cos, 0, 0}
+			if len(v) == 3 {
+				t = svgMatrix{1, 0, 0, 1, v[1], v[2]}.mul(t).mul(svgMatrix{1, 0, 0, 1, -v[1], -v[2]})
+			}
+		case name == "skewX" && len(v) == 1:
+			t = svgMatrix{1, 0, math.Tan(v[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(v) == 1:
+			t = svgMatrix{1, math.Tan(v[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m, fmt.Errorf("bad transform '%s'", s[:end+1])
+		}
+		m = m.mul(t)
+		s = s[end+1:]
+	}
+}
+
+// svgPaint returns the CSS color of a fill or stroke, empty for
+// none. Gradients and patterns use their fallback color.
+func svgPaint(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "url(") {
+		if end := strings.IndexByte(s, ')'); end > 0 {
+			s = strings.TrimSpace(s[end+1:])
+		}
+	}
+	switch s {
+	case "", "none":
+		return ""
+	case "currentColor":
+		return "black"
+	}
+	if _, _, _, err := parseColor(s); err != nil {
+		return ""
+	}
+	return s
+}
+
+// svgState is the inherited paint of an element.
+type svgState struct {
+	m           svgMatrix
+	fill        string
+	stroke      string
+	strokeWidth float64
+	evenOdd     bool
+	alpha       float64
+}
+
+// svgSkipped are the elements that are not drawn, with their content.
+var svgSkipped = []string{"defs", "clipPath", "mask", "symbol", "marker", "pattern",
+	"linearGradient", "radialGradient", "text", "style", "title", "desc", "metadata", "image", "use", "foreignObject"}
+
+// svgAttrs returns the attributes of the element, the properties of
+// the style attribute override the attributes.
+func svgAttrs(se xml.StartElement) map[string]string {
+	attrs := make(map[string]string)
+	for _, a := range se.Attr {
+		attrs[a.Name.Local] = a.Value
+	}
+	for _, prop := range strings.Split(attrs["style"], ";") {
+		if kv := strings.SplitN(prop, ":", 2); len(kv) == 2 {
+			attrs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return attrs
+}
+
+// svgLength reads a length like "120", "120px" or "3cm", the unit
+// is ignored. Percentages are no length.
+func svgLength(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasSuffix(s, "%") {
+		return 0, false
+	}
+	p := &svgScanner{s: s}
+	v, ok := p.number()
+	return v, ok && v > 0
+}
+
+// svgShapePath returns the path of a shape element.
+func svgShapePath(name string, attrs map[string]string) ([]svgSegment, error) {
+	num := func(k string) float64 {
+		v, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(attrs[k]), "px"), 64)
+		return v
+	}
+	switch name {
+	case "path":
+		return parseSVGPath(attrs["d"])
+	case "rect":
+		x, y, w, h := num("x"), num("y"), num("width"), num("height")
+		rx, ry := num("rx"), num("ry")
+		if _, ok := attrs["ry"]; !ok {
+			ry = rx
+		}
+		if _, ok := attrs["rx"]; !ok {
+			rx = ry
+		}
+		rx, ry = math.Min(rx, w/2), math.Min(ry, h/2)
+		if w <= 0 || h <= 0 {
+			return nil, nil
+		}
+		if rx <= 0 || ry <= 0 {
+			return parseSVGPath(fmt.Sprintf("M%g %gh%gv%gh%gz", x, y, w, h, -w))
+		}
+		return parseSVGPath(fmt.Sprintf("M%g %gH%gA%g %g 0 0 1 %g %gV%gA%g %g 0 0 1 %g %gH%gA%g %g 0 0 1 %g %gV%gA%g %g 0 0 1 %g %gz",
+			x+rx, y, x+w-rx, rx, ry, x+w, y+ry, y+h-ry, rx, ry, x+w-rx, y+h, x+rx, rx, ry, x, y+h-ry, y+ry, rx, ry, x+rx, y))
+	case "circle", "ellipse":
+		cx, cy, rx, ry := num("cx"), num("cy"), num("rx"), num("ry")
+		if name == "circle" {
+			rx, ry = num("r"), num("r")
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil, nil
+		}
+		return parseSVGPath(fmt.Sprintf("M%g %gA%g %g 0 0 1 %g %gA%g %g 0 0 1 %g %gz",
+			cx+rx, cy, rx, ry, cx-rx, cy, rx, ry, cx+rx, cy))
+	case "line":
+		return parseSVGPath(fmt.Sprintf("M%g %gL%g %g", num("x1"), num("y1"), num("x2"), num("y2")))
+	case "polyline", "polygon":
+		p := &svgScanner{s: attrs["points"]}
+		var segs []svgSegment
+		for {
+			v, ok := p.numbers(2)
+			if !ok {
+				break
+			}
+			cmd := byte('L')
+			if segs == nil {
+				cmd = 'M'
+			}
+			segs = append(segs, svgSegment{cmd, v})
+		}
+		if name == "polygon" && segs != nil {
+			segs = append(segs, svgSegment{'Z', nil})
+		}
+		return segs, nil
+	}
+	return nil, nil
+}
+
+// parseSVG reads an SVG image.
+func parseSVG(r io.Reader) (*svgImage, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	img := &svgImage{}
+	var stack []svgState
+	skip := 0 // depth inside a skipped element
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+			} else if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.StartElement:
+			name := t.Name.Local
+			if skip > 0 || stringInSlice(name, svgSkipped) {
+				skip++
+				continue
+			}
+			attrs := svgAttrs(t)
+			var st svgState
+			if len(stack) == 0 {
+				if name != "svg" {
+					return nil, fmt.Errorf("no svg element")
+				}
+				st = svgState{svgIdentity, "black", "", 1, false, 1}
+				p := &svgScanner{s: attrs["viewBox"]}
+				if v, ok := p.numbers(4); ok && v[2] > 0 && v[3] > 0 {
+					img.minX, img.minY, img.w, img.h = v[0], v[1], v[2], v[3]
+				} else {
+					w, okw := svgLength(attrs["width"])
+					h, okh := svgLength(attrs["height"])
+					if !okw || !okh {
+						return nil, fmt.Errorf("no viewBox and no width and height")
+					}
+					img.w, img.h = w, h
+				}
+			} else {
+				st = stack[len(stack)-1]
+			}
+			if attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+				skip++
+				continue
+			}
+			if tr, ok := attrs["transform"]; ok {
+				m, err := parseSVGTransform(tr)
+				if err != nil {
+					return nil, err
+				}
+				st.m = st.m.mul(m)
+			}
+			if v, ok := attrs["fill"]; ok {
+				st.fill = svgPaint(v)
+			}
+			if v, ok := attrs["stroke"]; ok {
+				st.stroke = svgPaint(v)
+			}
+			if v, ok := svgLength(attrs["stroke-width"]); ok {
+				st.strokeWidth = v
+			}
+			if v, ok := attrs["fill-rule"]; ok {
+				st.evenOdd = v == "evenodd"
+			}
+			for _, k := range []string{"opacity", "fill-opacity"} {
+				if v, err := strconv.ParseFloat(attrs[k], 64); err == nil {
+					st.alpha *= math.Max(0, math.Min(1, v))
+				}
+			}
+			stack = append(stack, st)
+			path, err := svgShapePath(name, attrs)
+			if err != nil {
+				return nil, err
+			}
+			if len(path) == 0 {
+				continue
+			}
+			for _, seg := range path {
+				for k := 0; k+1 < len(seg.pts); k += 2 {
+					seg.pts[k], seg.pts[k+1] = st.m.apply(seg.pts[k], seg.pts[k+1])
+				}
+			}
+			fill := st.fill
+			if name == "line" {
+				fill = ""
+			}
+			scale := math.Sqrt(math.Abs(st.m[0]*st.m[3] - st.m[1]*st.m[2]))
+			img.shapes = append(img.shapes, svgShape{path, fill, st.stroke, st.strokeWidth * scale, st.evenOdd, st.alpha})
+		}
+	}
+	if img.w == 0 {
+		return nil, fmt.Errorf("no svg element")
+	}
+	return img, nil
+}
+
+// isSVG reports whether the image file is an SVG file.
+func isSVG(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".svg")
+}
+
+// svgCache keeps the parsed SVG files, nil for files with errors.
+var svgCache = make(map[string]*svgImage)
+var svgMutex sync.Mutex
+
+// loadSVG reads the SVG file once and reports errors once.
+func loadSVG(file string) *svgImage {
+	svgMutex.Lock()
+	defer svgMutex.Unlock()
+	if img, ok := svgCache[file]; ok {
+		countCache("svg", true)
+		return img
+	}
+	countCache("svg", false)
+	data, err := ioutil.ReadFile(file)
+	var img *svgImage
+	if err == nil {
+		img, err = parseSVG(bytes.NewReader(data))
+	}
+	if err != nil {
+		fmt.Printf("# Error reading SVG image %v: %v\n", file, err)
+	}
+	svgCache[file] = img
+	return img
+}
+
+// drawSVG draws the image into the area, centered and as large as
+// it fits, or with cover as small as it covers the area, which cuts
+// it at the sides or at the top and bottom.
+func drawSVG(pdf *gofpdf.Fpdf, img *svgImage, r rect, cover bool) {
+	s := math.Min(r.w/img.w, r.h/img.h)
+	if cover {
+		s = math.Max(r.w/img.w, r.h/img.h)
+		pdf.ClipRect(r.x, r.y, r.w, r.h, false)
+		defer pdf.ClipEnd()
+	}
+	ox := r.x + (r.w-img.w*s)/2 - img.minX*s
+	oy := r.y + (r.h-img.h*s)/2 - img.minY*s
+	dr, dg, db := pdf.GetDrawColor()
+	fr, fg, fb := pdf.GetFillColor()
+	lw := pdf.GetLineWidth()
+	for _, sh := range img.shapes {
+		style := ""
+		if sh.fill != "" {
+			red, green, blue, _ := parseColor(sh.fill)
+			pdf.SetFillColor(red, green, blue)
+			style = "F"
+		}
+		if sh.stroke != "" && sh.strokeWidth > 0 {
+			red, green, blue, _ := parseColor(sh.stroke)
+			pdf.SetDrawColor(red, green, blue)
+			pdf.SetLineWidth(sh.strokeWidth * s)
+			style += "D"
+		}
+		if style == "" {
+			continue
+		}
+		if sh.evenOdd && sh.fill != "" {
+			style += "*"
+		}
+		if sh.alpha < 1 {
+			pdf.SetAlpha(sh.alpha, "Normal")
+		}
+		for _, seg := range sh.path {
+			p := seg.pts
+			switch seg.cmd {
+			case 'M':
+				pdf.MoveTo(ox+p[0]*s, oy+p[1]*s)
+			case 'L':
+				pdf.LineTo(ox+p[0]*s, oy+p[1]*s)
+			case 'C':
+				pdf.CurveBezierCubicTo(ox+p[0]*s, oy+p[1]*s, ox+p[2]*s, oy+p[3]*s, ox+p[4]*s, oy+p[5]*s)
+			case 'Z':
+				pdf.ClosePath()
+			}
+		}
+		pdf.DrawPath(style)
+		if sh.alpha < 1 {
+			pdf.SetAlpha(1, "Normal")
+		}
+	}
+	pdf.SetDrawColor(dr, dg, db)
+	pdf.SetFillColor(fr, fg, fb)
+	pdf.SetLineWidth(lw)
+}
@@ -0,0 +1,72 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// trace.go
+//
+// The trace line at the bottom of every page: the generator, a hash
+// of the configuration and the date, so that a printed proof can be
+// traced back to the exact inputs.
+//
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/phpdave11/gofpdf"
+	"io/ioutil"
+	"time"
+)
+
+// TRACEFONTSIZE is the font size of the trace line.
+const TRACEFONTSIZE = 5.0
+
+// configHash returns the start of the SHA-256 hash of the options
+// and the contents of the configuration files.
+func (g *Calendar) configHash() string {
+	c := *g
+	c.Astronomy = nil // the implementation, not an input
+	h := sha256.New()
+	opts, _ := json.Marshal(c)
+	h.Write(opts)
+	for _, f := range append([]string{g.OptConfig, g.OptBrand}, g.OptConfigs...) {
+		if f == "" {
+			continue
+		}
+		if data, err := ioutil.ReadFile(f); err == nil {
+			h.Write(data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// traceLine returns the trace line, empty without the option. It is
+// taken before the pages are drawn, which change some options.
+func (g *Calendar) traceLine() string {
+	if !g.OptTrace {
+		return ""
+	}
+	generator := g.OptGenerator
+	if generator == "" {
+		generator = "Gocal"
+	}
+	return generator + " | config " + g.configHash() + " | " + time.Now().Format("2006-01-02 15:04")
+}
+
+// addTraceLine prints the trace line at the bottom left of every
+// page of the finished document.
+func (g *Calendar) addTraceLine(pdf *gofpdf.Fpdf, calFont string, trace string, PAGEHEIGHT float64) {
+	if trace == "" {
+		return
+	}
+	pdf.SetTextColor(DARKGREY, DARKGREY, DARKGREY)
+	pdf.SetFont(calFont, "", TRACEFONTSIZE)
+	for p := 1; p <= pdf.PageCount(); p++ {
+		pdf.SetPage(p)
+		pdf.Text(MARGIN, PAGEHEIGHT-2, convertCP(trace))
+	}
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+}
@@ -0,0 +1,101 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// tracker.go
+//
+// Companion page with habit tracker and budget table.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"strings"
+	"time"
+)
+
+const (
+	// TRACKERROWHEIGHT is the height of a row in the tracker tables.
+	TRACKERROWHEIGHT = 7.0
+	// TRACKERFONTSIZE is the font size in the tracker tables.
+	TRACKERFONTSIZE = 9.0
+)
+
+// splitLabels splits a comma separated list of row labels.
+func splitLabels(in string) (labels []string) {
+	for _, l := range strings.Split(in, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, convertCP(l))
+		}
+	}
+	return labels
+}
+
+// addTrackerPage adds the companion page of a month with one
+// row of checkboxes per habit, a small budget table and the notes.
+func (g *Calendar) addTrackerPage(pdf *gofpdf.Fpdf, calFont string, fontScale float64, PAGEWIDTH float64, title string, month int, year int) {
+	habits := splitLabels(g.OptHabits)
+	budget := splitLabels(g.OptBudget)
+	if len(habits) == 0 && len(budget) == 0 && g.OptNotes == "" {
+		return
+	}
+	pdf.AddPage()
+	pdf.SetTextColor(BLACK, BLACK, BLACK)
+	pdf.SetDrawColor(BLACK, BLACK, BLACK)
+	pdf.SetLineWidth(0.2)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetFont(calFont, "", HEADERFONTSIZE*fontScale*0.75)
+	pdf.CellFormat(PAGEWIDTH-MARGIN, MARGIN, title, "", 0, "C", false, 0, "")
+	pdf.Ln(-1)
+	pdf.Ln(-1)
+
+	width := PAGEWIDTH - 2*MARGIN
+	labelWidth := width * 0.2
+	days := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	if len(habits) > 0 {
+		dw := (width - labelWidth) / 31
+		pdf.SetFont(calFont, "", TRACKERFONTSIZE*fontScale)
+		pdf.CellFormat(labelWidth, TRACKERROWHEIGHT, "Habits", "", 0, "L", false, 0, "")
+		pdf.SetFillColor(LIGHTGREY, LIGHTGREY, LIGHTGREY)
+		for d := 1; d <= days; d++ {
+			wd := time.Date(year, time.Month(month), d, 0, 0, 0, 0, time.UTC).Weekday()
+			weekend := wd == time.Saturday || wd == time.Sunday
+			pdf.CellFormat(dw, TRACKERROWHEIGHT, fmt.Sprintf("%d", d), "", 0, "C", weekend, 0, "")
+		}
+		pdf.Ln(-1)
+		box := dw * 0.6
+		for _, h := range habits {
+			x, y := pdf.GetXY()
+			pdf.CellFormat(labelWidth, TRACKERROWHEIGHT, h, "B", 0, "L", false, 0, "")
+			for d := 0; d < days; d++ {
+				pdf.Rect(x+labelWidth+float64(d)*dw+(dw-box)/2, y+(TRACKERROWHEIGHT-box)/2, box, box, "D")
+			}
+			pdf.Ln(-1)
+		}
+		pdf.Ln(-1)
+	}
+
+	if len(budget) > 0 {
+		cw := (width - labelWidth) / 3
+		pdf.SetFont(calFont, "", TRACKERFONTSIZE*fontScale)
+		pdf.CellFormat(labelWidth, TRACKERROWHEIGHT, "Budget", "B", 0, "L", false, 0, "")
+		for _, h := range []string{"Planned", "Actual", "Difference"} {
+			pdf.CellFormat(cw, TRACKERROWHEIGHT, h, "B", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+		for _, b := range append(budget, "Total") {
+			pdf.CellFormat(labelWidth, TRACKERROWHEIGHT, b, "B", 0, "L", false, 0, "")
+			for i := 0; i < 3; i++ {
+				pdf.CellFormat(cw, TRACKERROWHEIGHT, "", "LB", 0, "", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+		pdf.Ln(-1)
+	}
+	g.drawNotes(pdf, calFont, fontScale, pdf.GetY())
+	g.setGridStyle(pdf)
+}
@@ -19,21 +19,20 @@ import (
 	"bytes"
 	"encoding/xml"
 	"fmt"
-	"github.com/PuloV/ics-golang"
 	"github.com/goodsign/monday"
 	"github.com/phpdave11/gofpdf"
 	"github.com/paulrosania/go-charset/charset"
 	_ "github.com/paulrosania/go-charset/data"
 	"github.com/soniakeys/meeus/v3/julian"
 	"github.com/soniakeys/meeus/v3/moonphase"
-	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -41,50 +40,248 @@ const YmdHis = "2006-01-02 15:04:05"
 
 // TelegramStore is a container to read XML event-list
 type TelegramStore struct {
-	XMLName   xml.Name `xml:"Gocal"`
-	Gocaldate []Gocaldate
+	XMLName      xml.Name `xml:"Gocal"`
+	Gocaldate    []Gocaldate
+	Gocalquote   []Gocalquote
+	Gocaltext    []Gocaltext
+	Gocalstyle   []Gocalstyle
+	Gocalclass   []Gocalclass
+	Gocalrule    []Gocalrule
+	Gocalimage   []Gocalimage
+	Gocalbrand   []Gocalbrand
+	Gocalproject []Gocalproject
 }
 
-const (
-	CLEARTEMP = true
-)
+// keepTemp keeps the temporary directories for debugging.
+var keepTemp = false
+
+// tempDirs are the temporary directories in use, to remove
+// them when the program fails or is interrupted.
+var tempDirs = make(map[string]bool)
+var tempDirsMutex sync.Mutex
+
+// SetKeepTemp keeps the temporary directories for debugging.
+func SetKeepTemp(keep bool) {
+	keepTemp = keep
+}
+
+// makeTempdir creates a temporary directory that is removed
+// by removeTempdir or Cleanup.
+func makeTempdir(pattern string) (string, error) {
+	d, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	tempDirsMutex.Lock()
+	tempDirs[d] = true
+	tempDirsMutex.Unlock()
+	return d, nil
+}
 
-// removeTempdir removes the temoprary directory,
+// removeTempdir removes the temporary directory,
 // unless we want to keep it for debugging.
 func removeTempdir(d string) {
-	if CLEARTEMP == false {
+	if d == "" {
+		return
+	}
+	tempDirsMutex.Lock()
+	delete(tempDirs, d)
+	tempDirsMutex.Unlock()
+	if keepTemp {
+		fmt.Printf("# Keeping temporary directory %v\n", d)
 		return
 	}
 	os.RemoveAll(d)
 }
 
+// Cleanup removes all temporary directories still in use,
+// e.g. when the program is interrupted.
+func Cleanup() {
+	tempDirsMutex.Lock()
+	var dirs []string
+	for d := range tempDirs {
+		dirs = append(dirs, d)
+	}
+	tempDirsMutex.Unlock()
+	for _, d := range dirs {
+		removeTempdir(d)
+	}
+}
+
+// Exit codes of fatal errors, so that scripts can tell them apart.
+const (
+	ExitOK     = 0
+	ExitError  = 1 // any other error
+	ExitConfig = 2 // configuration file, options, dates
+	ExitFetch  = 3 // downloads
+	ExitRender = 4 // PDF generation
+	ExitFont   = 5 // fonts
+)
+
+// ExitCode returns the exit code for the errors of the calendar that
+// were not fatal, e.g. a PDF that could not be written.
+func (g *Calendar) ExitCode() int {
+	return g.exitCode
+}
+
+// fatal is log.Fatal with an exit code, but cleans up first.
+func fatal(code int, v ...interface{}) {
+	exit(code, fmt.Sprint(v...))
+}
+
+// fatalf is log.Fatalf with an exit code, but cleans up first.
+func fatalf(code int, format string, v ...interface{}) {
+	exit(code, fmt.Sprintf(format, v...))
+}
+
+// fatalError is the fatal error of a job.
+type fatalError struct {
+	code int
+	msg  string
+}
+
+func (e fatalError) Error() string {
+	return e.msg
+}
+
+// exit ends the program with the message and the exit code.
+func exit(code int, msg string) {
+	Cleanup()
+	log.Print(msg)
+	os.Exit(code)
+}
+
+// Warning is a problem that was worked around in lenient mode.
+// Kind is date, field or download.
+type Warning struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// warnings collects the warnings of the calendars without a report
+// of their own, and of the files read without a calendar.
+var warnings []Warning
+var warningsMutex sync.Mutex
+
+// SetStrict makes malformed dates, unknown fields in the
+// configuration and failed downloads fatal errors of the calendar.
+func (g *Calendar) SetStrict(s bool) {
+	g.strict = s
+}
+
+// fatalf ends the program with the exit code like fatalf, or panics
+// with a fatalError for a job of the server, so that only the job
+// fails.
+func (g *Calendar) fatalf(code int, format string, v ...interface{}) {
+	if g.fatalPanics {
+		panic(fatalError{code, fmt.Sprintf(format, v...)})
+	}
+	fatalf(code, format, v...)
+}
+
+// Warnings returns the warnings collected so far.
+func Warnings() []Warning {
+	warningsMutex.Lock()
+	defer warningsMutex.Unlock()
+	return append([]Warning(nil), warnings...)
+}
+
+// warnf reports a problem of a file that is read without a calendar,
+// e.g. the recipients: it is printed and collected for the report.
+// The calendars report theirs with their warnf.
+func warnf(kind string, format string, v ...interface{}) {
+	countError(kind)
+	warningsMutex.Lock()
+	defer warningsMutex.Unlock()
+	warnings = addWarning(warnings, Warning{kind, fmt.Sprintf(format, v...)})
+}
+
+// addWarning prints the warning and adds it to the list, unless it
+// is there already.
+func addWarning(list []Warning, w Warning) []Warning {
+	for _, seen := range list {
+		if seen == w {
+			return list
+		}
+	}
+	fmt.Printf("# %s\n", w.Message)
+	return append(list, w)
+}
+
+// moonphaseCache keeps the moon phases of a year, they are
+// expensive and the same for every calendar of that year.
+var moonphaseCache = make(map[int]map[string]string)
+var moonphaseMutex sync.Mutex
+
 // computeMoonphasesJ populates a map for the entire year.
 // Keys are dates in YYYY-MM-DD format,
 // Values are strings from the list Full, New, First, Last.
 func computeMoonphasesJ(moonJ map[string]string, yr int) {
-	daysInYear := 365
-	if julian.LeapYearGregorian(yr) {
-		daysInYear = 366
+	moonphaseMutex.Lock()
+	defer moonphaseMutex.Unlock()
+	phases, ok := moonphaseCache[yr]
+	if !ok {
+		phases = make(map[string]string)
+		computeMoonphasesYear(phases, yr)
+		moonphaseCache[yr] = phases
+	}
+	for k, v := range phases {
+		moonJ[k] = v
 	}
+}
+
+// lunationsPerYear is the number of lunations in a year,
+// (49.2) in Meeus, Astronomical Algorithms.
+const lunationsPerYear = 12.3685
+
+// moonPhases are the phases with their fraction of a lunation.
+var moonPhases = []struct {
+	name    string
+	quarter float64
+	jde     func(float64) float64
+}{
+	{"New", 0, moonphase.New},
+	{"First", 0.25, moonphase.First},
+	{"Full", 0.5, moonphase.Full},
+	{"Last", 0.75, moonphase.Last},
+}
 
-	moon_funcs := map[string]func(float64) float64{
-		"Full":  moonphase.Full,
-		"New":   moonphase.New,
-		"First": moonphase.First,
-		"Last":  moonphase.Last,
+// computeMoonphasesYear does the work of computeMoonphasesJ. It
+// iterates over the lunations of the year: lunation k starts with
+// the new moon at 2000 + k/12.3685, so each phase is computed once.
+func computeMoonphasesYear(moonJ map[string]string, yr int) {
+	first := int(math.Floor((float64(yr)-2000)*lunationsPerYear)) - 1
+	last := int(math.Ceil((float64(yr+1)-2000)*lunationsPerYear)) + 1
+	for k := first; k <= last; k++ {
+		for _, p := range moonPhases {
+			jd := p.jde(2000 + (float64(k)+p.quarter)/lunationsPerYear)
+			y, m, d := julian.JDToCalendar(jd)
+			if y != yr {
+				continue
+			}
+			moonString := fmt.Sprintf("%04d-%02d-%02d", y, m, int(d))
+			moonJ[moonString] = p.name
+		}
 	}
+}
 
-	// For each days of the year we compute the
-	// nearest Full/New/First/Last moonphase.
-	// The dates for each are crammed into the result map.
-	for i := 0; i < daysInYear; i++ {
-		decimalYear := float64(yr) +
-			float64(i-1)/float64(daysInYear)
-		for moonkey, _ := range moon_funcs {
-			jd := moon_funcs[moonkey](decimalYear)
+// computeMoonphasesRange fills a map with the moon phases from the day
+// from to the day to, computing only the lunations of these days.
+func computeMoonphasesRange(moonJ map[string]string, from, to time.Time) {
+	decimalYear := func(t time.Time) float64 {
+		return float64(t.Year()) + float64(t.YearDay()-1)/365.25
+	}
+	first := int(math.Floor((decimalYear(from)-2000)*lunationsPerYear)) - 1
+	last := int(math.Ceil((decimalYear(to)-2000)*lunationsPerYear)) + 1
+	begin, end := from.Format("2006-01-02"), to.Format("2006-01-02")
+	for k := first; k <= last; k++ {
+		for _, p := range moonPhases {
+			jd := p.jde(2000 + (float64(k)+p.quarter)/lunationsPerYear)
 			y, m, d := julian.JDToCalendar(jd)
 			moonString := fmt.Sprintf("%04d-%02d-%02d", y, m, int(d))
-			moonJ[moonString] = moonkey
+			if moonString >= begin && moonString <= end {
+				moonJ[moonString] = p.name
+			}
 		}
 	}
 }
@@ -136,72 +333,150 @@ var freemonobold []byte
 //go:embed fonts/FreeSerifBold.ttf
 var freeserifbold []byte
 
+// fontLoader loads the definitions of the builtin fonts from memory
+// where there are no temporary files, e.g. in the browser.
+var fontLoader gofpdf.FontLoader
+
+// builtinFonts are the names of the definitions of the builtin fonts.
+var builtinFonts = map[string]string{
+	"sans":  "freesansbold",
+	"mono":  "freemonobold",
+	"serif": "freeserifbold",
+}
+
 // processFont creates a font usable from a TTF.
 // It also sets up the temporary directory to store the
 // intermediate files.
-func processFont(fontFile string) (fontName, tempDirname string) {
-	var err error
-	tempDirname, err = ioutil.TempDir("", "")
+func (g *Calendar) processFont(fontFile string) (fontName, tempDirname string) {
+	if fontLoader != nil {
+		return g.convertFont(fontFile, ""), ""
+	}
+	tempDirname, err := makeTempdir("gocal-")
+	if err != nil {
+		g.fatalf(ExitError, "%v", err)
+	}
+	fontName = g.convertFont(fontFile, tempDirname)
+	return fontName, tempDirname
+}
+
+// convertFont converts the font into the directory, unless the
+// Generator of the calendar has converted it already.
+func (g *Calendar) convertFont(fontFile string, tempDirname string) (fontName string) {
+	if name, ok := g.generator.font(fontFile); ok {
+		return name
+	}
+	if fontLoader != nil {
+		if name, ok := builtinFonts[fontFile]; ok {
+			return name
+		}
+		g.warnf("font", "Only the fonts sans, serif and mono are available here, using serif for '%s'", fontFile)
+		return builtinFonts["serif"]
+	}
+	fontName, err := processFontInto(fontFile, tempDirname)
 	if err != nil {
-		log.Fatal(err)
+		g.fatalf(ExitFont, "%v", err)
 	}
+	return fontName
+}
 
+// processFontInto creates a font usable from a TTF in an
+// existing temporary directory, e.g. for a second font.
+func processFontInto(fontFile string, tempDirname string) (fontName string, err error) {
 	if fontFile == "mono" {
-		fontFile = tempDirname + string(os.PathSeparator) + "freemonobold.ttf"
+		fontFile = filepath.Join(tempDirname, "freemonobold.ttf")
 		ioutil.WriteFile(fontFile, freemonobold, 0700)
 	} else if fontFile == "serif" {
-		fontFile = tempDirname + string(os.PathSeparator) + "freeserifbold.ttf"
+		fontFile = filepath.Join(tempDirname, "freeserifbold.ttf")
 		ioutil.WriteFile(fontFile, freeserifbold, 0700)
 	} else if fontFile == "sans" {
-		fontFile = tempDirname + string(os.PathSeparator) + "freesansbold.ttf"
+		fontFile = filepath.Join(tempDirname, "freesansbold.ttf")
 		ioutil.WriteFile(fontFile, freesansbold, 0700)
 	}
-	err = ioutil.WriteFile(tempDirname+string(os.PathSeparator)+"cp1252.map", []byte(codepageCP1252), 0700)
+	fontName = filepath.Base(fontFile)
+	fontName = strings.TrimSuffix(fontName, filepath.Ext(fontName))
+
+	cacheKey := fontCacheKey(fontFile)
+	if cacheKey != "" && loadCachedFont(cacheKey, fontName, tempDirname) == nil {
+		countCache("font", true)
+		return fontName, nil
+	}
+	if cacheKey != "" {
+		countCache("font", false)
+	}
+
+	mapFile := filepath.Join(tempDirname, "cp1252.map")
+	err = ioutil.WriteFile(mapFile, []byte(codepageCP1252), 0700)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	err = gofpdf.MakeFont(fontFile, tempDirname+string(os.PathSeparator)+"cp1252.map", tempDirname, nil, true)
+	err = gofpdf.MakeFont(fontFile, mapFile, tempDirname, nil, true)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+	if cacheKey != "" {
+		storeCachedFont(cacheKey, fontName, tempDirname)
 	}
-	fontName = filepath.Base(fontFile)
-	fontName = strings.TrimSuffix(fontName, filepath.Ext(fontName))
 	// fmt.Printf("Using external font: %v\n", fontName)
-	return fontName, tempDirname
+	return fontName, nil
 }
 
-// downloadFile loads a file via http into the tempDir
+// downloadFile loads an image via http into the tempDir
 // and returns the fullpath filename.
-func downloadFile(in string, tempDir string) (fileName string) {
+func (g *Calendar) downloadFile(in string, tempDir string) (fileName string) {
 	extension := filepath.Ext(in)
 
 	// The filename from the URL might contain colons that are
 	// not valid characters in a filename in Windows. THerefore
 	// we simply call out image 'image'.
 	fileName = "image" + extension
-	fileName = tempDir + string(os.PathSeparator) + fileName
+	fileName = filepath.Join(tempDir, fileName)
 
-	output, err := os.Create(fileName)
+	data, err := fetchURL(in, "image/*")
 	if err != nil {
-		fmt.Printf("# Error creating %v\n", output)
+		g.warnf("download", "Error downloading %v: %v", in, err)
 		return
 	}
-	defer output.Close()
 
-	retrieve, err := http.Get(in)
+	err = ioutil.WriteFile(fileName, data, 0600)
 	if err != nil {
-		fmt.Printf("# Error downloading %v\n", in)
+		fmt.Printf("# Error creating %v\n", fileName)
 		return
 	}
-	defer retrieve.Body.Close()
 
-	_, err = io.Copy(output, retrieve.Body)
+	return fileName
+}
+
+// gregorianToJulian converts a date of the Gregorian calendar into
+// the Julian calendar (old style) via the Julian day number.
+func gregorianToJulian(t time.Time) (year int, month time.Month, day int) {
+	// Julian day number of the Gregorian date
+	a := (14 - int(t.Month())) / 12
+	y := t.Year() + 4800 - a
+	m := int(t.Month()) + 12*a - 3
+	jdn := t.Day() + (153*m+2)/5 + 365*y + y/4 - y/100 + y/400 - 32045
+
+	// Julian calendar date of the day number
+	c := jdn + 32082
+	d := (4*c + 3) / 1461
+	e := c - 1461*d/4
+	m = (5*e + 2) / 153
+	day = e - (153*m+2)/5 + 1
+	month = time.Month(m + 3 - 12*(m/10))
+	year = d - 4800 + m/10
+	return year, month, day
+}
+
+// convertFromCP converts a string of the codepage back into UTF-8.
+func convertFromCP(in string) string {
+	r, err := charset.NewReader("windows-1252", strings.NewReader(in))
 	if err != nil {
-		fmt.Printf("# Error copying %v\n", in)
-		return
+		return in
 	}
-
-	return fileName
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		return in
+	}
+	return string(out)
 }
 
 // This function converts a string into the required
@@ -210,7 +485,7 @@ func convertCP(in string) (out string) {
 	buf := new(bytes.Buffer)
 	w, err := charset.NewWriter("windows-1252", buf)
 	if err != nil {
-		log.Fatal(err)
+		fatal(ExitError, err)
 	}
 	fmt.Fprintf(w, in)
 	w.Close()
@@ -219,120 +494,474 @@ func convertCP(in string) (out string) {
 	return out
 }
 
-// This function reads the events XML file and returns a
-// list of gDate objects.
-func readICSfile(filename string, targetyear int) (eL []gDate) {
+// STDIN is the filename that refers to standard input/output.
+const STDIN = "-"
 
-	/* There is an ugly hack lurking here. The events in ICS
-	contain years, but we wanted the configuration to be
-	agnostic of years.*/
-	parser := ics.New()
+// stdinData keeps standard input, because it can be read only once,
+// but the configuration is read several times.
+var stdinData []byte
+var stdinOnce sync.Once
 
-	ics.FilePath = "tmp/new/"
+// AddFile makes data readable as the configuration or text file
+// name of the calendar, for programs without files like the browser.
+func (g *Calendar) AddFile(name string, data []byte) {
+	if g.files == nil {
+		g.files = make(map[string][]byte)
+	}
+	g.files[name] = data
+}
 
-	ics.DeleteTempFiles = true
+// readFile reads the file added with AddFile, or else the file or
+// standard input.
+func (g *Calendar) readFile(filename string) ([]byte, error) {
+	if data, ok := g.files[filename]; ok {
+		return data, nil
+	}
+	return readInputFile(filename)
+}
 
-	inputChan := parser.GetInputChan()
+// readInputFile reads the file, or standard input for "-".
+func readInputFile(filename string) ([]byte, error) {
+	if filename != STDIN {
+		return ioutil.ReadFile(filename)
+	}
+	var err error
+	stdinOnce.Do(func() {
+		stdinData, err = ioutil.ReadAll(os.Stdin)
+	})
+	return stdinData, err
+}
 
-	outputChan := parser.GetOutputChan()
+// loadConfigurationfile reads and unmarshals the XML file.
+func (g *Calendar) loadConfigurationfile(filename string) (v TelegramStore) {
+	data, err := g.readFile(filename)
+	if err != nil {
+		return
+	}
 
-	inputChan <- filename
+	data = validUTF8(data, filename, g.warnf)
+	v, err2 := parseConfiguration(data, filename)
+	if err2 != nil {
+		g.fatalf(ExitConfig, "# ERROR: when trying to unmarshal the XML configuration file: %v", err2)
+		return
+	}
+	g.checkConfigurationFields(data, filename)
+	return v
+}
 
-	go func() {
-		for event := range outputChan {
-			eventText := convertCP(event.GetSummary())
-			year := event.GetStart().Format("2006")
-			mon := event.GetStart().Format("01")
-			day := event.GetStart().Format("02")
+// configurationFields are the elements of the XML file and their
+// attributes.
+var configurationFields = map[string][]string{
+	"Gocaldate":    {"date", "text", "image", "color", "class", "category", "description", "priority"},
+	"Gocalquote":   {"month", "text"},
+	"Gocaltext":    {"text", "x", "y", "angle", "size"},
+	"Gocalstyle":   {"month", "date", "color", "fill", "class"},
+	"Gocalclass":   {"name", "color", "fill"},
+	"Gocalrule":    {"if", "then"},
+	"Gocalimage":   {"file", "crop", "focus", "enhance", "caption", "credit"},
+	"Gocalbrand":   {"name", "logo", "primary", "secondary", "font", "footer", "tagline"},
+	"Gocalproject": {"name", "start", "end", "color"},
+}
+
+// classStyle returns color and fill of an entry with the class of
+// the configuration v, the attributes of the entry override those of
+// the class.
+func (g *Calendar) classStyle(v TelegramStore, class string, color string, fill string) (string, string) {
+	if class == "" {
+		return color, fill
+	}
+	for _, c := range v.Gocalclass {
+		if c.Name != class {
+			continue
+		}
+		if color == "" {
+			color = c.Color
+		}
+		if fill == "" {
+			fill = c.Fill
+		}
+		return color, fill
+	}
+	g.warnf("field", "Unknown style class '%s'", class)
+	return color, fill
+}
 
-			yr, _ := strconv.ParseInt(year, 10, 32)
-			mo, _ := strconv.ParseInt(mon, 10, 32)
-			d, _ := strconv.ParseInt(day, 10, 32)
-			if int(targetyear) == int(yr) {
-				gcd := gDate{time.Month(mo), int(d), eventText, "", ""}
-				eL = append(eL, gcd)
+// checkConfigurationFields warns about elements and attributes
+// of the XML file that gocal doesn't know, e.g. misspelled ones.
+func (g *Calendar) checkConfigurationFields(data []byte, filename string) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 2 {
+				continue
+			}
+			attrs, ok := configurationFields[t.Name.Local]
+			if !ok {
+				g.warnf("field", "Unknown element <%s> in %v", t.Name.Local, filename)
+				continue
+			}
+			for _, a := range t.Attr {
+				if !stringInSlice(a.Name.Local, attrs) {
+					g.warnf("field", "Unknown attribute %s of <%s> in %v", a.Name.Local, t.Name.Local, filename)
+				}
 			}
+		case xml.EndElement:
+			depth--
 		}
-	}()
-	parser.Wait()
+	}
+}
 
-	return eL
+// stringInSlice reports whether s is in list.
+func stringInSlice(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
 }
 
 // This function reads the events XML file and returns a
-// list of gDate objects.
-func readConfigurationfile(filename string) (eL []gDate) {
+// list of gDate objects. Weekday names may be in lang, relative
+// dates are computed for year.
+func (g *Calendar) readConfigurationfile(filename string, lang string, year int) (eL []gDate) {
 
-	var v TelegramStore
+	v := g.loadConfigurationfile(filename)
 
-	f, err := os.Open(filename)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	data, err := ioutil.ReadAll(f)
-	if err != nil {
-		return
+	for _, m := range v.Gocaldate {
+		days, ok := parseConfigDate(m.Date, lang, year)
+		if !ok {
+			g.warnf("date", "Ignoring event '%s', unknown date '%s'", m.Text, m.Date)
+			continue
+		}
+		eventText := convertCP(m.Text)
+		image := g.rootedFiles(m.Image)
+		color, _ := g.classStyle(v, m.Class, m.Color, "")
+		category := m.Category
+		if category == "" {
+			category = m.Class
+		}
+		for _, d := range days {
+			eL = append(eL, gDate{d.Month, d.Day, eventText, d.Weekday, image, filename, color, category, m.Desc, "", m.Priority, ""})
+		}
 	}
 
-	v = TelegramStore{}
-	err2 := xml.Unmarshal([]byte(data), &v)
-	if err2 != nil {
-		log.Fatalf("# ERROR: when trying to unmarshal the XML configuration file: %v", err2)
-		return
+	return eL
+}
+
+// parseConfigDate returns the days of a date of the XML file:
+// Month/Day with * for every month, a range, a weekday or a
+// relative date. The days have no text. 2/29 is valid in every
+// year, the leap day policy moves it.
+func parseConfigDate(date string, lang string, year int) (days []gDate, ok bool) {
+	if strings.Index(date, "/") != -1 && strings.Index(date, "-") != -1 { // Is this a range?
+
+		dl, ok := parseDateRange(date, year)
+		if !ok {
+			return nil, false
+		}
+		for _, d := range dl {
+			days = append(days, gDate{d.Month(), d.Day(), "", "", "", "", "", "", "", "", 0, ""})
+		}
+	} else if strings.Index(date, "/") != -1 { // Is this Month/Day ?
+
+		textArray := strings.Split(date, "/")
+		if len(textArray) != 2 {
+			return nil, false
+		}
+		d, err := strconv.Atoi(textArray[1])
+		if err != nil || d < 1 || d > 31 {
+			return nil, false
+		}
+
+		if textArray[0] == "*" {
+			for j := time.January; j <= time.December; j++ {
+				if d <= daysInMonth(j, year) {
+					days = append(days, gDate{j, d, "", "", "", "", "", "", "", "", 0, ""})
+				}
+			}
+		} else {
+			mo, err := strconv.Atoi(textArray[0])
+			if err != nil || mo < 1 || mo > 12 || d > daysInMonth(time.Month(mo), year) && !(mo == 2 && d == 29) {
+				return nil, false
+			}
+			days = append(days, gDate{time.Month(mo), d, "", "", "", "", "", "", "", "", 0, ""})
+		}
+	} else if wd, ok := parseWeekdaySpec(date, lang); ok { // weekday
+
+		days = append(days, gDate{time.Month(0), int(0), "", wd, "", "", "", "", "", "", 0, ""})
+	} else if d, ok := parseDateExpr(date, lang, year); ok { // relative date
+
+		days = append(days, gDate{d.Month(), d.Day(), "", "", "", "", "", "", "", "", 0, ""})
+	} else {
+		return nil, false
 	}
+	return days, true
+}
 
-	for _, m := range v.Gocaldate {
+// daysInMonth returns the number of days of the month of the year.
+func daysInMonth(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
 
-		if strings.Index(m.Date, "/") != -1 { // Is this Month/Day ?
+// readConfigurationStyles returns the styles of the XML file: without
+// month and date for the calendar, with month for a month and with
+// date for the days of the date. The fill of the class of an event
+// styles the days of the event.
+func (g *Calendar) readConfigurationStyles(filename string, lang string, year int) (sL []gStyle) {
+	v := g.loadConfigurationfile(filename)
+	for _, st := range v.Gocalstyle {
+		st.Color, st.Fill = g.classStyle(v, st.Class, st.Color, st.Fill)
+		switch {
+		case st.Date != "":
+			days, ok := parseConfigDate(st.Date, lang, year)
+			if !ok {
+				g.warnf("date", "Ignoring style, unknown date '%s'", st.Date)
+				continue
+			}
+			for _, d := range days {
+				sL = append(sL, gStyle{STYLEDAY, d, st.Color, st.Fill})
+			}
+		case st.Month != 0:
+			if st.Month < 1 || st.Month > 12 {
+				g.warnf("date", "Ignoring style for invalid month %d", st.Month)
+				continue
+			}
+			sL = append(sL, gStyle{STYLEMONTH, gDate{time.Month(st.Month), 0, "", "", "", "", "", "", "", "", 0, ""}, st.Color, st.Fill})
+		default:
+			sL = append(sL, gStyle{STYLECALENDAR, gDate{}, st.Color, st.Fill})
+		}
+	}
+	for _, m := range v.Gocaldate {
+		if m.Class == "" {
+			continue
+		}
+		_, fill := g.classStyle(v, m.Class, "", "")
+		days, ok := parseConfigDate(m.Date, lang, year)
+		if fill == "" || !ok {
+			continue
+		}
+		for _, d := range days {
+			sL = append(sL, gStyle{STYLEDAY, d, "", fill})
+		}
+	}
+	return sL
+}
 
-			textArray := strings.Split(m.Date, "/")
+// weekdayOrdinals are the qualifiers of a weekday, -1 is the last
+// one of the month.
+var weekdayOrdinals = map[string]int{
+	"first": 1, "1st": 1,
+	"second": 2, "2nd": 2,
+	"third": 3, "3rd": 3,
+	"fourth": 4, "4th": 4,
+	"fifth": 5, "5th": 5,
+	"last": -1,
+}
 
-			eventText := convertCP(m.Text)
+// parseWeekdaySpec turns "Monday", "lundi", "Mo." or "last Friday"
+// into the English form used by weekdayMatches, e.g. "last Friday".
+// Names are case-insensitive and may be in lang or any supported
+// language, lang and English are tried first.
+// "last Friday of *" is the same as "last Friday".
+func parseWeekdaySpec(spec string, lang string) (string, bool) {
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 4 && fields[2] == "of" && fields[3] == "*" {
+		fields = fields[:2]
+	}
+	qualifier := ""
+	switch len(fields) {
+	case 1:
+	case 2:
+		if _, ok := weekdayOrdinals[fields[0]]; !ok {
+			return "", false
+		}
+		qualifier = fields[0] + " "
+	default:
+		return "", false
+	}
+	wd, ok := lookupWeekday(strings.TrimSuffix(fields[len(fields)-1], "."), lang)
+	if !ok {
+		return "", false
+	}
+	return qualifier + wd.String(), true
+}
 
-			if textArray[0] == "*" {
-				d, _ := strconv.ParseInt(textArray[1], 10, 32)
-				for j := 1; j < 13; j++ {
-					gcd := gDate{time.Month(j), int(d), eventText, "", m.Image}
-					eL = append(eL, gcd)
+// lookupWeekday finds a lower case weekday name or an abbreviation
+// of at least two letters that is unique in its language.
+func lookupWeekday(name string, lang string) (time.Weekday, bool) {
+	langs := append([]string{lang, "en_US"}, SupportedLanguages()...)
+	for _, abbrev := range []bool{false, true} {
+		for _, l := range langs {
+			found, n := time.Sunday, 0
+			for wd := time.Sunday; wd <= time.Saturday; wd++ {
+				// 2017-01-01 is a Sunday
+				t := time.Date(2017, 1, 1+int(wd), 0, 0, 0, 0, time.UTC)
+				full := strings.ToLower(monday.Format(t, "Monday", monday.Locale(l)))
+				if full == name || (abbrev && len([]rune(name)) >= 2 && strings.HasPrefix(full, name)) {
+					found, n = wd, n+1
 				}
-			} else {
-				mo, _ := strconv.ParseInt(textArray[0], 10, 32)
-				d, _ := strconv.ParseInt(textArray[1], 10, 32)
-
-				gcd := gDate{time.Month(mo), int(d), eventText, "", m.Image}
-				eL = append(eL, gcd)
 			}
-		} else { // There is no slash, assume weekday
+			if n == 1 {
+				return found, true
+			}
+		}
+	}
+	return time.Sunday, false
+}
 
-			eventText := convertCP(m.Text)
-			gcd := gDate{time.Month(0), int(0), eventText, string(m.Date), m.Image}
-			eL = append(eL, gcd)
+// weekdayMatches reports whether d is the weekday of a spec made by
+// parseWeekdaySpec, like "Monday" or "first Monday".
+func weekdayMatches(spec string, d time.Time) bool {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || d.Weekday().String() != fields[len(fields)-1] {
+		return false
+	}
+	if len(fields) == 1 {
+		return true
+	}
+	n := weekdayOrdinals[fields[0]]
+	if n == -1 {
+		return d.AddDate(0, 0, 7).Month() != d.Month()
+	}
+	return (d.Day()-1)/7+1 == n
+}
+
+// readConfigurationQuotes returns the quotes of the XML file,
+// indexed by month.
+func (g *Calendar) readConfigurationQuotes(filename string) (quotes [13]string) {
+	v := g.loadConfigurationfile(filename)
+	for _, q := range v.Gocalquote {
+		if q.Month < 1 || q.Month > 12 {
+			g.warnf("date", "Ignoring quote for invalid month %d", q.Month)
+			continue
 		}
+		quotes[q.Month] = convertCP(q.Text)
 	}
+	return quotes
+}
 
-	return eL
+// readConfigurationTexts returns the decorative texts of the XML file.
+func (g *Calendar) readConfigurationTexts(filename string) (tL []gText) {
+	v := g.loadConfigurationfile(filename)
+	for _, t := range v.Gocaltext {
+		tL = append(tL, gText{convertCP(t.Text), t.X, t.Y, t.Angle, t.Size})
+	}
+	return tL
+}
+
+// readQuotesfile reads a plain text file with one quote per
+// line, starting with January. Empty lines leave the month
+// without quote.
+func readQuotesfile(filename string) (quotes [13]string) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("# Error reading quotes file %v\n", filename)
+		return
+	}
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	for i := 0; i < 12 && i < len(lines); i++ {
+		quotes[i+1] = convertCP(strings.TrimSpace(lines[i]))
+	}
+	return quotes
 }
 
+// localizedNames keeps the month and weekday names by kind and
+// locale, because batch and server modes need them thousands of
+// times and formatting them is slow.
+var localizedNames sync.Map
+
 // / This function returns an array of Monthnames already in the
 // right locale.
 func getLocalizedMonthNames(locale string) (monthnames [13]string) {
+	if names, ok := localizedNames.Load("month " + locale); ok {
+		return names.([13]string)
+	}
 
 	for page := 1; page < 13; page++ {
 		t := time.Date(2013, time.Month(page), 1, 0, 0, 0, 0, time.UTC)
 		monthnames[page] = convertCP(fmt.Sprintf("%s", monday.Format(t, "January", monday.Locale(locale))))
 	}
 
+	localizedNames.Store("month "+locale, monthnames)
+	return monthnames
+}
+
+// readNamesfile reads custom names from lines "January = Xaneiro"
+// or "Monday = Luns", keyed by the English name.
+func readNamesfile(filename string) (names map[string]string) {
+	names = make(map[string]string)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("# Error reading names file %v\n", filename)
+		return
+	}
+	for n, line := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			fmt.Printf("# Ignoring line %d of %v, expected name = custom name\n", n+1, filename)
+			continue
+		}
+		names[strings.TrimSpace(kv[0])] = convertCP(strings.TrimSpace(kv[1]))
+	}
+	return names
+}
+
+// genitiveMonthNames are the genitive month names of locales
+// that monday doesn't know.
+var genitiveMonthNames = map[string][13]string{
+	"pl_PL": {"", "stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca",
+		"lipca", "sierpnia", "września", "października", "listopada", "grudnia"},
+}
+
+// getLocalizedMonthNamesGenitive returns the month names in the
+// form used in dates like "1 January". Some languages, e.g. Russian
+// or Polish, use the genitive there instead of the nominative of titles.
+func getLocalizedMonthNamesGenitive(locale string) (monthnames [13]string) {
+	if names, ok := localizedNames.Load("genitive " + locale); ok {
+		return names.([13]string)
+	}
+	defer func() { localizedNames.Store("genitive "+locale, monthnames) }()
+	if names, ok := genitiveMonthNames[locale]; ok {
+		for i, n := range names {
+			monthnames[i] = convertCP(n)
+		}
+		return monthnames
+	}
+	for page := 1; page < 13; page++ {
+		t := time.Date(2013, time.Month(page), 1, 0, 0, 0, 0, time.UTC)
+		// monday picks the genitive after a day number.
+		name := strings.TrimPrefix(monday.Format(t, "2 January", monday.Locale(locale)), "1 ")
+		monthnames[page] = convertCP(name)
+	}
 	return monthnames
 }
 
 // / This function returns an array of weekday names already in the
 // right locale.
 func getLocalizedWeekdayNames(locale string, cutoff int) (wdnames [8]string) {
+	if names, ok := localizedNames.Load("weekday " + locale); ok {
+		wdnames = names.([8]string)
+	} else {
+		for i := 0; i <= 6; i++ {
+			// Some arbitrary date, that allows us to pickup Weekday-Strings.
+			t := time.Date(2013, 1, 5+i, 0, 0, 0, 0, time.UTC)
+			wdnames[i] = convertCP(monday.Format(t, "Monday", monday.Locale(locale)))
+		}
+		localizedNames.Store("weekday "+locale, wdnames)
+	}
 	for i := 0; i <= 6; i++ {
-		// Some arbitrary date, that allows us to pickup Weekday-Strings.
-		t := time.Date(2013, 1, 5+i, 0, 0, 0, 0, time.UTC)
-		wdnames[i] = convertCP(monday.Format(t, "Monday", monday.Locale(locale)))
 		if cutoff > 0 {
 			wdnames[i] = wdnames[i][0:cutoff]
 		}
@@ -0,0 +1,2800 @@
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package gocal
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_gregorianToJulian(t *testing.T) {
+	tests := []struct {
+		gregorian time.Time
+		y         int
+		m         time.Month
+		d         int
+	}{
+		{time.Date(1582, 10, 15, 0, 0, 0, 0, time.UTC), 1582, time.October, 5},
+		{time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), 2023, time.December, 25},
+		{time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC), 2024, time.January, 1},
+		{time.Date(2100, 3, 14, 0, 0, 0, 0, time.UTC), 2100, time.February, 29},
+		{time.Date(2100, 3, 15, 0, 0, 0, 0, time.UTC), 2100, time.March, 1},
+	}
+	for _, tt := range tests {
+		y, m, d := gregorianToJulian(tt.gregorian)
+		if y != tt.y || m != tt.m || d != tt.d {
+			t.Errorf("gregorianToJulian(%v) = %d-%d-%d, want %d-%d-%d", tt.gregorian.Format("2006-01-02"), y, m, d, tt.y, tt.m, tt.d)
+		}
+	}
+}
+
+func Test_easterSunday(t *testing.T) {
+	tests := map[int]string{
+		2000: "2000-04-23",
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2038: "2038-04-25",
+	}
+	for year, want := range tests {
+		if got := easterSunday(year).Format("2006-01-02"); got != want {
+			t.Errorf("easterSunday(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func Test_adventSunday(t *testing.T) {
+	tests := map[int]string{
+		2023: "2023-12-03",
+		2024: "2024-12-01",
+		2025: "2025-11-30",
+		2022: "2022-11-27",
+	}
+	for year, want := range tests {
+		if got := adventSunday(year).Format("2006-01-02"); got != want {
+			t.Errorf("adventSunday(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func Test_sunTimes(t *testing.T) {
+	// Jerusalem, 2024-06-21: sunrise 05:33, sunset 19:47 local time (UTC+3)
+	rise, set, ok := sunTimes(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 31.778, 35.235)
+	if !ok {
+		t.Fatal("sunTimes: no sunrise in Jerusalem")
+	}
+	loc := time.FixedZone("IDT", 3*3600)
+	if d := rise.In(loc).Sub(time.Date(2024, 6, 21, 5, 33, 0, 0, loc)); d < -3*time.Minute || d > 3*time.Minute {
+		t.Errorf("sunrise = %v", rise.In(loc))
+	}
+	if d := set.In(loc).Sub(time.Date(2024, 6, 21, 19, 47, 0, 0, loc)); d < -3*time.Minute || d > 3*time.Minute {
+		t.Errorf("sunset = %v", set.In(loc))
+	}
+	// New York at the March equinox, when the right ascension of the
+	// sun wraps: sunrise 06:59, sunset 19:09 local time (UTC-4)
+	rise, set, _ = sunTimes(time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), 40.713, -74.006)
+	loc = time.FixedZone("EDT", -4*3600)
+	if d := rise.In(loc).Sub(time.Date(2024, 3, 20, 6, 59, 0, 0, loc)); d < -3*time.Minute || d > 3*time.Minute {
+		t.Errorf("sunrise = %v", rise.In(loc))
+	}
+	if d := set.In(loc).Sub(time.Date(2024, 3, 20, 19, 9, 0, 0, loc)); d < -3*time.Minute || d > 3*time.Minute {
+		t.Errorf("sunset = %v", set.In(loc))
+	}
+	// Polar night in Tromsø
+	if _, _, ok := sunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 69.65, 18.96); ok {
+		t.Error("sunTimes: sunrise during polar night")
+	}
+}
+
+func Test_prayerTimes(t *testing.T) {
+	// Makkah, 2024-01-15, Umm al-Qura: Fajr 05:38, Dhuhr 12:28, Asr 15:36, Maghrib 17:59, Isha 19:29
+	loc := time.FixedZone("AST", 3*3600)
+	times := prayerTimes(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), 21.4225, 39.8262, prayerMethods["Makkah"], false)
+	want := []string{"05:38", "12:28", "15:36", "17:59", "19:29"}
+	for i, w := range want {
+		wt, _ := time.ParseInLocation("2006-01-02 15:04", "2024-01-15 "+w, loc)
+		if d := times[i].Sub(wt); d < -3*time.Minute || d > 3*time.Minute {
+			t.Errorf("prayer %s = %v, want %s", prayerNames[i], times[i].In(loc).Format("15:04"), w)
+		}
+	}
+}
+
+func Test_parseConfigDate(t *testing.T) {
+	tests := []struct {
+		date string
+		ok   bool
+		days int
+	}{
+		{"3/14", true, 1},
+		{"2/29", true, 1},
+		{"*/31", true, 7},
+		{"*/30", true, 11},
+		{"13/40", false, 0},
+		{"13/1", false, 0},
+		{"0/1", false, 0},
+		{"4/31", false, 0},
+		{"2/30", false, 0},
+		{"2/x", false, 0},
+		{"x/3", false, 0},
+		{"*/0", false, 0},
+		{"*/32", false, 0},
+		{"1/2/3", false, 0},
+	}
+	for _, tt := range tests {
+		days, ok := parseConfigDate(tt.date, "en", 2025)
+		if ok != tt.ok || len(days) != tt.days {
+			t.Errorf("parseConfigDate(%q) = %v, %v", tt.date, days, ok)
+		}
+	}
+}
+
+func Test_shortenText(t *testing.T) {
+	tests := []struct {
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"First man on the moon", 60, "First man on the moon"},
+		{"First man on the moon", 16, "First man on..."},
+		{"Mozart", 3, "Moz"},
+	}
+	for _, tt := range tests {
+		if got := shortenText(tt.in, tt.maxLen); got != tt.want {
+			t.Errorf("shortenText(%q, %d) = %q, want %q", tt.in, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func Test_newSudoku(t *testing.T) {
+	puzzle, solution := newSudoku(rand.New(rand.NewSource(202501)))
+	for i := 0; i < 9; i++ {
+		var row, col, box [10]bool
+		for j := 0; j < 9; j++ {
+			row[solution[i][j]] = true
+			col[solution[j][i]] = true
+			box[solution[i/3*3+j/3][i%3*3+j%3]] = true
+		}
+		for v := 1; v <= 9; v++ {
+			if !row[v] || !col[v] || !box[v] {
+				t.Fatalf("invalid solution %v", solution)
+			}
+		}
+	}
+	for r := 0; r < 9; r++ {
+		for c := 0; c < 9; c++ {
+			if puzzle[r][c] != 0 && puzzle[r][c] != solution[r][c] {
+				t.Fatalf("puzzle does not match solution")
+			}
+		}
+	}
+	if n := puzzle.countSolutions(2); n != 1 {
+		t.Errorf("puzzle has %d solutions, want 1", n)
+	}
+}
+
+func Test_outputFilename(t *testing.T) {
+	g := New(1, 12, 2025)
+	g.SetLocale("de_DE")
+	if got := g.outputFilename("cal-{year}-{month}-{locale}.pdf"); got != "cal-2025-01-12-de_DE.pdf" {
+		t.Errorf("outputFilename = %q", got)
+	}
+	if got := monthFilename("cal-{year}-{month}.pdf", 3); got != "cal-{year}-03.pdf" {
+		t.Errorf("monthFilename = %q", got)
+	}
+	if got := monthFilename("out/cal.pdf", 11); got != "out/cal-11.pdf" {
+		t.Errorf("monthFilename = %q", got)
+	}
+}
+
+func Test_readConfigurationfileStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(`<Gocal><Gocaldate date="3/14" text="Pi day"/></Gocal>`)
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	for i := 0; i < 2; i++ { // stdin is read only once
+		eL := New(1, 12, 2025).readConfigurationfile(STDIN, "en_US", 2025)
+		if len(eL) != 1 || eL[0].Text != "Pi day" || eL[0].Month != time.March {
+			t.Errorf("readConfigurationfile(-) = %v", eL)
+		}
+	}
+}
+
+func Test_Cleanup(t *testing.T) {
+	d, err := makeTempdir("gocal-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Cleanup()
+	if _, err := os.Stat(d); !os.IsNotExist(err) {
+		t.Errorf("temporary directory %v not removed", d)
+	}
+}
+
+func Test_fetchURL(t *testing.T) {
+	httpBackoff = time.Millisecond
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("User-Agent") != USERAGENT {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		switch {
+		case r.URL.Path == "/big":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case calls < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	data, err := fetchURL(srv.URL+"/flaky", "")
+	if err != nil || string(data) != "ok" || calls != 3 {
+		t.Errorf("fetchURL = %q, %v after %d calls", data, err, calls)
+	}
+
+	calls = 0
+	if _, err := fetchURL(srv.URL+"/missing", ""); err == nil || calls != 1 {
+		t.Errorf("fetchURL of missing page: %v after %d calls", err, calls)
+	}
+
+	maxSize := httpMaxSize
+	httpMaxSize = 10
+	defer func() { httpMaxSize = maxSize }()
+	if _, err := fetchURL(srv.URL+"/big", ""); err == nil {
+		t.Errorf("fetchURL ignored the maximum size")
+	}
+}
+
+func Test_fetchURLTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	retries := httpRetries
+	httpRetries = 0
+	defer func() { httpRetries = retries; SetTLSOptions("", false) }()
+
+	if _, err := fetchURL(srv.URL, ""); err == nil {
+		t.Errorf("fetchURL accepted an unknown certificate")
+	}
+
+	ca, err := os.CreateTemp("", "gocal-ca-*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(ca.Name())
+	pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	ca.Close()
+	SetTLSOptions(ca.Name(), false)
+	if data, err := fetchURL(srv.URL, ""); err != nil || string(data) != "ok" {
+		t.Errorf("fetchURL with CA file = %q, %v", data, err)
+	}
+
+	SetTLSOptions("", true)
+	if _, err := fetchURL(srv.URL, ""); err != nil {
+		t.Errorf("fetchURL insecure: %v", err)
+	}
+}
+
+func Test_RemotePolicy(t *testing.T) {
+	calls := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)+"/ok", http.StatusFound)
+			return
+		}
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+	defer SetRemotePolicy(RemotePolicy{})
+
+	SetRemotePolicy(RemotePolicy{Deny: true})
+	if _, err := fetchURL(srv.URL, ""); err == nil || calls != 0 {
+		t.Errorf("download denied: %v after %d calls", err, calls)
+	}
+	SetRemotePolicy(RemotePolicy{Hosts: []string{"example.com", "*.example.org"}})
+	if _, err := fetchURL(srv.URL, ""); err == nil || calls != 0 {
+		t.Errorf("download of a host not allowed: %v after %d calls", err, calls)
+	}
+	for _, u := range []string{"https://example.com/a.ics", "https://www.EXAMPLE.org/a.jpg", "http://example.com:8080/"} {
+		if err := remotePolicy.check(u); err != nil {
+			t.Errorf("%s refused: %v", u, err)
+		}
+	}
+	for _, u := range []string{"https://example.com.evil.net/", "https://example.org/", "ftp://example.com/", "file:///etc/passwd"} {
+		if err := remotePolicy.check(u); err == nil {
+			t.Errorf("%s allowed", u)
+		}
+	}
+
+	SetRemotePolicy(RemotePolicy{Hosts: []string{"127.0.0.1"}, MaxImageSize: 10})
+	if data, err := fetchURL(srv.URL+"/a.ics", "text/calendar"); err != nil || len(data) != 100 {
+		t.Errorf("fetchURL of the allowed host = %d bytes, %v", len(data), err)
+	}
+	if _, err := fetchURL(srv.URL+"/a.jpg", "image/*"); err == nil {
+		t.Errorf("fetchURL ignored the maximum size of images")
+	}
+	calls = 0
+	if _, err := fetchURL(srv.URL+"/away", ""); err == nil || calls != 1 {
+		t.Errorf("redirect to a host not allowed: %v after %d calls", err, calls)
+	}
+}
+
+func Test_fontCache(t *testing.T) {
+	root, err := os.MkdirTemp("", "gocal-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	fontCacheRoot = root
+	defer func() { fontCacheRoot = "" }()
+
+	for i := 0; i < 2; i++ { // convert, then from the cache
+		name, dir := New(1, 1, 2026).processFont("sans")
+		for _, ext := range []string{".json", ".z"} {
+			if _, err := os.Stat(filepath.Join(dir, name+ext)); err != nil {
+				t.Errorf("run %d: %v", i, err)
+			}
+		}
+		removeTempdir(dir)
+	}
+	// The same font under another name is in the cache too.
+	other := filepath.Join(root, "other.ttf")
+	if err := ioutil.WriteFile(other, freesansbold, 0600); err != nil {
+		t.Fatal(err)
+	}
+	metricsMutex.Lock()
+	hits := cacheCounts["font"][1]
+	metricsMutex.Unlock()
+	name, dir := New(1, 1, 2026).processFont(other)
+	defer removeTempdir(dir)
+	metricsMutex.Lock()
+	if cacheCounts["font"][1] != hits+1 {
+		t.Errorf("processFont(%q) missed the cache", other)
+	}
+	metricsMutex.Unlock()
+	def, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil || name != "other" || !strings.Contains(string(def), `"File":"other.z"`) {
+		t.Errorf("processFont(%q) = %q, %s, %v", other, name, def, err)
+	}
+	entries, _ := ioutil.ReadDir(root)
+	if len(entries) != 3 { // the font's .json and .z, other.ttf
+		t.Errorf("%d entries in the font cache, want 3", len(entries))
+	}
+}
+
+func Test_paintFillErrors(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	for _, spec := range []string{"#ffffff", "gradient:#fff:#00f", "vgradient:1,2,3:#000", "stripes:#ccc", "dots:#ccc", "checks:#ccc"} {
+		if err := paintFill(pdf, spec, 10, 10, 20, 20, 0); err != nil {
+			t.Errorf("paintFill(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"gradient:#fff", "stripes", "waves:#fff", "#ggg"} {
+		if err := paintFill(pdf, spec, 10, 10, 20, 20, 0); err == nil {
+			t.Errorf("paintFill(%q) accepted", spec)
+		}
+	}
+}
+
+func Test_parseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		r, g, b int
+	}{
+		{"#4682b4", 70, 130, 180},
+		{"#FFF", 255, 255, 255},
+		{"10, 20,30", 10, 20, 30},
+		{"rgb(70, 130, 180)", 70, 130, 180},
+		{"rgb(100% 0% 50%)", 255, 0, 128},
+		{"hsl(207, 44%, 49%)", 70, 130, 180},
+		{"hsl(0deg 100% 50%)", 255, 0, 0},
+		{"HSL(120, 100%, 25%)", 0, 128, 0},
+		{"SteelBlue", 70, 130, 180},
+		{"rebeccapurple", 102, 51, 153},
+	}
+	for _, tt := range tests {
+		r, g, b, err := parseColor(tt.in)
+		if err != nil || r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("parseColor(%q) = %d,%d,%d, %v; want %d,%d,%d", tt.in, r, g, b, err, tt.r, tt.g, tt.b)
+		}
+	}
+	for _, in := range []string{"", "#12", "rgb(1,2)", "rgba(1,2,3,0.5)", "rgb(300,0,0)", "hsl(10, 50, 50)", "blurple", "1,2,256"} {
+		if _, _, _, err := parseColor(in); err == nil {
+			t.Errorf("parseColor(%q) accepted", in)
+		}
+	}
+}
+
+func Test_localizeDigits(t *testing.T) {
+	tests := []struct{ system, want string }{
+		{"latn", "29"},
+		{"arab", "٢٩"},
+		{"arabext", "۲۹"},
+		{"deva", "२९"},
+		{numeralSystem("auto", "fa_IR"), "۲۹"},
+		{numeralSystem("auto", "de_DE"), "29"},
+	}
+	for _, tt := range tests {
+		if got := localizeDigits("29", tt.system); got != tt.want {
+			t.Errorf("localizeDigits(29, %s) = %q, want %q", tt.system, got, tt.want)
+		}
+	}
+}
+
+func Test_getLocalizedMonthNamesGenitive(t *testing.T) {
+	tests := []struct {
+		locale     string
+		nominative string
+		genitive   string
+	}{
+		{"en_US", "May", "May"},
+		{"de_DE", "Mai", "Mai"},
+		{"ru_RU", convertCP("Май"), convertCP("мая")},
+		{"pl_PL", convertCP("Maj"), convertCP("maja")},
+	}
+	for _, tt := range tests {
+		if got := getLocalizedMonthNames(tt.locale)[5]; got != tt.nominative {
+			t.Errorf("%s nominative = %q, want %q", tt.locale, got, tt.nominative)
+		}
+		if got := getLocalizedMonthNamesGenitive(tt.locale)[5]; got != tt.genitive {
+			t.Errorf("%s genitive = %q, want %q", tt.locale, got, tt.genitive)
+		}
+	}
+}
+
+func Test_normalizeLanguage(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"de_DE", "de_DE"},
+		{"de_DE.UTF-8", "de_DE"},
+		{"de-DE", "de_DE"},
+		{"sr_RS@latin", "sr_RS"},
+	}
+	for _, tt := range tests {
+		if got := normalizeLanguage(tt.in); got != tt.want {
+			t.Errorf("normalizeLanguage(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if !IsSupportedLanguage("fr_FR.UTF-8") || IsSupportedLanguage("gl_ES") {
+		t.Errorf("IsSupportedLanguage is wrong")
+	}
+}
+
+func Test_customNames(t *testing.T) {
+	g := New(1, 1, 2025)
+	g.SetNames("test-names.txt")
+	if got := g.monthNames("en_US", false)[6]; got != convertCP("Xuño") {
+		t.Errorf("June = %q, want Xuño", got)
+	}
+	wd := g.weekdayNames("en_US", 2)
+	if wd[0] != "S\xe1" || wd[2] != "Lu" {
+		t.Errorf("weekdays = %q", wd)
+	}
+}
+
+func Test_dedupEvents(t *testing.T) {
+	eL := []gDate{
+		{time.May, 1, "Labour Day", "", "", "", "", "", "", "", 0, ""},
+		{time.May, 1, "labour  day.", "", "flag.png", "", "", "", "", "", 0, ""},
+		{time.May, 1, "May Day", "", "", "", "", "", "", "", 0, ""},
+		{time.May, 2, "Labour Day", "", "", "", "", "", "", "", 0, ""},
+	}
+	tests := []struct {
+		strategy string
+		n        int
+		image    string
+	}{
+		{"keep-all", 4, ""},
+		{"keep-first", 3, ""},
+		{"merge", 3, "flag.png"},
+	}
+	for _, tt := range tests {
+		got := dedupEvents(eL, tt.strategy)
+		if len(got) != tt.n || got[0].Image != tt.image {
+			t.Errorf("dedupEvents(%s) = %v", tt.strategy, got)
+		}
+	}
+}
+
+func Test_dedupEventsMerge(t *testing.T) {
+	ics := gDate{time.May, 1, "Labour Day", "", "", "work.ics", "", "holiday", "", "", 0, "09:00"}
+	web := gDate{time.May, 1, "labour day", "", "flag.png", "holidays", "#ff0000", "public", "International Workers' Day", "", 2, ""}
+	got := dedupEvents([]gDate{ics, web}, "merge")
+	want := gDate{time.May, 1, "Labour Day", "", "flag.png", "work.ics", "#ff0000", "holiday", "International Workers' Day", "", 2, "09:00"}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("dedupEvents(merge) = %+v, want %+v", got, want)
+	}
+}
+
+func Test_parseWeekdaySpec(t *testing.T) {
+	tests := []struct{ spec, lang, want string }{
+		{"Monday", "en_US", "Monday"},
+		{"monday", "en_US", "Monday"},
+		{"Mon.", "en_US", "Monday"},
+		{"lundi", "en_US", "Monday"},
+		{"Mi", "de_DE", "Wednesday"},
+		{"last Friday", "en_US", "last Friday"},
+		{"First Freitag", "de_DE", "first Friday"},
+	}
+	for _, tt := range tests {
+		if got, ok := parseWeekdaySpec(tt.spec, tt.lang); !ok || got != tt.want {
+			t.Errorf("parseWeekdaySpec(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"Funday", "S", "sixth Monday", "every other Monday"} {
+		if got, ok := parseWeekdaySpec(spec, "en_US"); ok {
+			t.Errorf("parseWeekdaySpec(%q) = %q, want error", spec, got)
+		}
+	}
+}
+
+func Test_weekdayMatches(t *testing.T) {
+	tests := []struct {
+		spec string
+		day  int
+		want bool
+	}{
+		{"Friday", 30, true},
+		{"last Friday", 30, true},
+		{"last Friday", 23, false},
+		{"first Friday", 2, true},
+		{"second Friday", 9, true},
+		{"second Friday", 2, false},
+		{"Monday", 30, false},
+	}
+	for _, tt := range tests {
+		d := time.Date(2026, time.January, tt.day, 0, 0, 0, 0, time.UTC)
+		if got := weekdayMatches(tt.spec, d); got != tt.want {
+			t.Errorf("weekdayMatches(%q, Jan %d) = %v, want %v", tt.spec, tt.day, got, tt.want)
+		}
+	}
+}
+
+func Test_parseDateExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"easter", "2025-04-20"},
+		{"easter-2", "2025-04-18"},
+		{"Easter + 49", "2025-06-08"},
+		{"3rd Sunday of 6", "2025-06-15"},
+		{"second Sunday of May", "2025-05-11"},
+		{"last Monday of 5", "2025-05-26"},
+		{"last weekday of 12", "2025-12-31"},
+		{"first weekday of 3", "2025-03-03"},
+		{"4th Donnerstag of 11", "2025-11-27"},
+	}
+	for _, tt := range tests {
+		d, ok := parseDateExpr(tt.expr, "de_DE", 2025)
+		if got := d.Format("2006-01-02"); !ok || got != tt.want {
+			t.Errorf("parseDateExpr(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+	for _, expr := range []string{"", "easter2", "easter+x", "fifth Friday of 2", "3rd Sunday of 13", "3rd Sunday in 6"} {
+		if d, ok := parseDateExpr(expr, "en_US", 2025); ok {
+			t.Errorf("parseDateExpr(%q) = %v, want error", expr, d)
+		}
+	}
+}
+
+func Test_parseDateRange(t *testing.T) {
+	tests := []struct {
+		expr        string
+		n           int
+		first, last string
+	}{
+		{"7/15-7/30", 16, "07-15", "07-30"},
+		{"12/30-1/2", 4, "12-30", "01-02"},
+		{"*/1-*/3", 36, "01-01", "12-03"},
+		{"*/29-*/31", 29, "01-29", "12-31"},
+		{"12/31-2/29", 61, "12-31", "02-29"}, // 2024 is a leap year
+	}
+	for _, tt := range tests {
+		year := 2025
+		if strings.HasSuffix(tt.expr, "2/29") {
+			year = 2023
+		}
+		days, ok := parseDateRange(tt.expr, year)
+		if !ok || len(days) != tt.n || days[0].Format("01-02") != tt.first || days[len(days)-1].Format("01-02") != tt.last {
+			t.Errorf("parseDateRange(%q) = %d days %v, want %d", tt.expr, len(days), ok, tt.n)
+		}
+	}
+	for _, expr := range []string{"7/15", "7/30-7/15-7/1", "*/3-*/1", "*/1-7/3", "13/1-13/2", "7/x-7/3", "2/30-3/5", "3/1-4/31", "2/29-3/1"} {
+		if _, ok := parseDateRange(expr, 2025); ok {
+			t.Errorf("parseDateRange(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func Test_moveLeapDayEvents(t *testing.T) {
+	eL := []gDate{
+		{time.February, 29, "Leap", "", "", "", "", "", "", "", 0, ""},
+		{time.March, 5, "Other", "", "", "", "", "", "", "", 0, ""},
+	}
+	tests := []struct {
+		policy string
+		year   int
+		n      int
+		month  time.Month
+		day    int
+	}{
+		{"skip", 2025, 1, time.March, 5},
+		{"feb28", 2025, 2, time.February, 28},
+		{"mar1", 2025, 2, time.March, 1},
+		{"mar1", 2024, 2, time.February, 29},
+	}
+	for _, tt := range tests {
+		got := moveLeapDayEvents(eL, tt.policy, tt.year)
+		if len(got) != tt.n || got[0].Month != tt.month || got[0].Day != tt.day {
+			t.Errorf("moveLeapDayEvents(%s, %d) = %v", tt.policy, tt.year, got)
+		}
+	}
+}
+
+func Test_checkConfigurationFields(t *testing.T) {
+	g := New(1, 1, 2026)
+	var r Report
+	g.SetReport(&r)
+	g.checkConfigurationFields([]byte(`<Gocal>
+	<Gocaldate date="1/1" text="New Year" />
+	<Gocaldate dat="1/2" text="Typo" />
+	<Gocalevent date="1/3" text="Unknown" />
+</Gocal>`), "test.xml")
+	got := r.Warnings
+	if len(got) != 2 || got[0].Kind != "field" || !strings.Contains(got[0].Message, "dat") || !strings.Contains(got[1].Message, "Gocalevent") {
+		t.Errorf("checkConfigurationFields warnings = %v", got)
+	}
+}
+
+func Test_recordEvents(t *testing.T) {
+	all := []gDate{
+		{time.May, 1, "Labour Day", "", "", "a.xml", "", "", "", "", 0, ""},
+		{time.May, 1, "Labour Day", "", "", "b.ics", "", "", "", "", 0, ""},
+		{time.June, 1, "June", "", "", "a.xml", "", "", "", "", 0, ""},
+		{0, 0, "Run", "Monday", "", "a.xml", "", "", "", "", 0, ""},
+	}
+	recordFile("test.pdf", 1, countEvents(all, dedupEvents(all, "keep-first"), 5, 5))
+	files := GetReport().Files
+	got := files[len(files)-1]
+	want := map[string]EventCount{"a.xml": {2, 1}, "b.ics": {0, 1}}
+	if got.File != "test.pdf" || got.Pages != 1 || len(got.Events) != 2 || got.Events["a.xml"] != want["a.xml"] || got.Events["b.ics"] != want["b.ics"] {
+		t.Errorf("report = %+v, want %v", got, want)
+	}
+}
+
+func Test_recordEventsConcurrent(t *testing.T) {
+	dir, err := os.MkdirTemp("", "gocal-report-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var wg sync.WaitGroup
+	for n := 1; n <= 4; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			g := New(5, 5, 2026)
+			for d := 1; d <= n; d++ {
+				g.AddEvent(d, 5, "Event", "")
+			}
+			g.CreateCalendar(filepath.Join(dir, fmt.Sprintf("%d.pdf", n)))
+		}(n)
+	}
+	wg.Wait()
+	for _, f := range GetReport().Files {
+		if filepath.Dir(f.File) != dir {
+			continue
+		}
+		var n int
+		fmt.Sscanf(filepath.Base(f.File), "%d.pdf", &n)
+		placed := 0
+		for _, c := range f.Events {
+			placed += c.Placed
+		}
+		if placed != n {
+			t.Errorf("%s has %d events, want %d", filepath.Base(f.File), placed, n)
+		}
+	}
+}
+
+func Test_ExitCodeRender(t *testing.T) {
+	g := New(1, 1, 2025)
+	g.CreateCalendar(filepath.Join(os.TempDir(), "gocal-no-such-dir", "x.pdf"))
+	if g.ExitCode() != ExitRender {
+		t.Errorf("ExitCode() = %d, want %d", g.ExitCode(), ExitRender)
+	}
+	if New(1, 1, 2025).ExitCode() != ExitOK {
+		t.Errorf("the exit code of a calendar is shared")
+	}
+}
+
+func Test_computeMoonphasesJ(t *testing.T) {
+	want := make(map[string]string)
+	computeMoonphasesYear(want, 2031)
+	for i := 0; i < 2; i++ {
+		got := make(map[string]string)
+		computeMoonphasesJ(got, 2031)
+		if len(want) < 40 || len(got) != len(want) {
+			t.Errorf("computeMoonphasesJ = %d phases, want %d", len(got), len(want))
+		}
+	}
+	if _, ok := moonphaseCache[2031]; !ok {
+		t.Errorf("moon phases of 2031 are not cached")
+	}
+}
+
+func Test_computeMoonphasesYear(t *testing.T) {
+	for _, yr := range []int{1999, 2024, 2025, 2100} {
+		moon := make(map[string]string)
+		computeMoonphasesYear(moon, yr)
+		days := make(map[string][]time.Time)
+		for k, phase := range moon {
+			d, err := time.Parse("2006-01-02", k)
+			if err != nil || d.Year() != yr {
+				t.Errorf("%d: phase %s on %s", yr, phase, k)
+			}
+			days[phase] = append(days[phase], d)
+		}
+		for phase, dl := range days {
+			// 12 or 13 of each phase, one lunation apart, also
+			// at the start and the end of the year.
+			if len(dl) < 12 || len(dl) > 13 {
+				t.Errorf("%d: %d times %s", yr, len(dl), phase)
+			}
+			sort.Slice(dl, func(i, j int) bool { return dl[i].Before(dl[j]) })
+			if dl[0].YearDay() > 31 || dl[len(dl)-1].YearDay() < 335 {
+				t.Errorf("%d: %s from %v to %v", yr, phase, dl[0], dl[len(dl)-1])
+			}
+			for i := 1; i < len(dl); i++ {
+				if gap := dl[i].Sub(dl[i-1]).Hours() / 24; gap < 28 || gap > 31 {
+					t.Errorf("%d: %s %v after %v", yr, phase, dl[i], dl[i-1])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkMoonphasesYear(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		computeMoonphasesYear(make(map[string]string), 2025)
+	}
+}
+
+// fakeAstronomy is an Astronomy with fixed data.
+type fakeAstronomy struct{}
+
+func (fakeAstronomy) MoonPhases(year int) map[string]string {
+	return map[string]string{fmt.Sprintf("%d-01-01", year): "Full"}
+}
+
+func (fakeAstronomy) SunTimes(day time.Time, lat, lon float64) (rise, set time.Time, ok bool) {
+	return day.Add(6 * time.Hour), day.Add(18 * time.Hour), true
+}
+
+func (fakeAstronomy) Seasons(year int) [4]time.Time {
+	var s [4]time.Time
+	for i := range s {
+		s[i] = time.Date(year, time.Month(3*i+3), 21, 12, 0, 0, 0, time.UTC)
+	}
+	return s
+}
+
+func (fakeAstronomy) Eclipses(year int) []Eclipse {
+	return []Eclipse{{time.Date(year, 4, 8, 18, 0, 0, 0, time.UTC), true, "total"}}
+}
+
+func (fakeAstronomy) Longitudes(t time.Time) (sun, moon float64) {
+	return 0, 100
+}
+
+func Test_fakeAstronomy(t *testing.T) {
+	eL := shabbatEvents(fakeAstronomy{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), 0, 0, CANDLELIGHTING, HAVDALAH, time.UTC)
+	if len(eL) != 104 || eL[0].Text != "Candles 17:42" || eL[1].Text != "Havdalah 18:42" {
+		t.Errorf("shabbatEvents = %d events, first %v %v", len(eL), eL[0], eL[1])
+	}
+	eL = shabbatEvents(fakeAstronomy{}, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC), 0, 0, 40, 72, time.UTC)
+	if len(eL) != 2 || eL[0].Text != "Candles 17:20" || eL[1].Text != "Havdalah 19:12" {
+		t.Errorf("shabbatEvents = %v", eL)
+	}
+	eL = astroEvents(fakeAstronomy{}, 2025, time.UTC)
+	if len(eL) != 5 || eL[1].Text != "June solstice" || eL[1].Month != time.June || eL[4].Text != "Solar eclipse (total)" {
+		t.Errorf("astroEvents = %v", eL)
+	}
+	g := New(1, 1, 2025)
+	g.SetAstronomy(fakeAstronomy{})
+	if m := g.astronomy().MoonPhases(2025); m["2025-01-01"] != "Full" {
+		t.Errorf("MoonPhases = %v", m)
+	}
+}
+
+func Test_meeusEclipses(t *testing.T) {
+	want := []struct {
+		month time.Month
+		solar bool
+		typ   string
+	}{
+		{time.March, false, "penumbral"},
+		{time.April, true, "total"},
+		{time.September, false, "partial"},
+		{time.October, true, "annular"},
+	}
+	got := meeusAstronomy{}.Eclipses(2024)
+	if len(got) != len(want) {
+		t.Fatalf("Eclipses(2024) = %v", got)
+	}
+	for i, w := range want {
+		if got[i].Time.Month() != w.month || got[i].Solar != w.solar || got[i].Type != w.typ {
+			t.Errorf("eclipse %d = %v, want %v", i, got[i], w)
+		}
+	}
+	seasons := meeusAstronomy{}.Seasons(2024)
+	if d := seasons[0]; d.Month() != time.March || d.Day() != 20 {
+		t.Errorf("March equinox 2024 = %v", d)
+	}
+}
+
+func Test_panchang(t *testing.T) {
+	// New moon on 2024-01-11 11:57 UTC, full moon on 2024-01-25 17:54 UTC
+	a := meeusAstronomy{}
+	for _, tc := range []struct {
+		t     time.Time
+		tithi int
+	}{
+		{time.Date(2024, 1, 11, 12, 30, 0, 0, time.UTC), 0},
+		{time.Date(2024, 1, 11, 11, 30, 0, 0, time.UTC), 29},
+		{time.Date(2024, 1, 25, 17, 0, 0, 0, time.UTC), 14},
+		{time.Date(2024, 1, 25, 19, 0, 0, 0, time.UTC), 15},
+	} {
+		sun, moon := a.Longitudes(tc.t)
+		if tithi, _ := panchangAt(sun, moon, lahiriAyanamsa(tc.t)); tithi != tc.tithi {
+			t.Errorf("tithi at %v = %d, want %d", tc.t, tithi, tc.tithi)
+		}
+	}
+	if tithi, nakshatra := panchangAt(350, 10, 24); tithi != 1 || nakshatra != 25 {
+		t.Errorf("panchangAt = %d %d", tithi, nakshatra)
+	}
+	tithi, nakshatra := panchang(fakeAstronomy{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 0, 0, time.UTC)
+	if got := formatPanchang(tithi, nakshatra); got != [2]string{"S Navami", "Ardra"} {
+		t.Errorf("formatPanchang = %v", got)
+	}
+	if got := formatPanchang(29, 0); got != [2]string{"Amavasya", "Ashwini"} {
+		t.Errorf("formatPanchang = %v", got)
+	}
+	if got := formatPanchang(17, 0)[0]; got != "K Tritiya" {
+		t.Errorf("formatPanchang = %v", got)
+	}
+}
+
+func Test_cellLayout(t *testing.T) {
+	l := newCellLayout(10, 20, 30, 20)
+	l.reserve(rect{10, 20, 10, 8}) // day number
+	if l.free(rect{12, 22, 5, 2}) || !l.free(rect{25, 22, 5, 2}) || l.free(rect{35, 22, 10, 2}) {
+		t.Errorf("free is wrong")
+	}
+	r, ok := l.place(1, 0, 20, 4)
+	if !ok || r.y != 28 {
+		t.Errorf("place = %v %v, want below the day number", r, ok)
+	}
+	// 20 mm cell, 8 mm day number and 4 mm line leave two lines.
+	if _, ok := l.placeLines(1, 0, []float64{20, 20, 20}, 4); ok {
+		t.Errorf("placeLines placed 3 lines into the room of 2")
+	}
+	if len(l.used) != 2 {
+		t.Errorf("placeLines didn't roll back: %v", l.used)
+	}
+	rs, ok := l.placeLines(1, 0, []float64{20, 20}, 4)
+	if !ok || rs[0].y != 32 || rs[1].y != 36 {
+		t.Errorf("placeLines = %v %v", rs, ok)
+	}
+	if _, ok := l.place(1, 0, 20, 4); ok {
+		t.Errorf("place in a full cell")
+	}
+}
+
+func Test_dayStyle(t *testing.T) {
+	sL := New(1, 12, 2025).readConfigurationStyles("test-styles.xml", "en_US", 2025)
+	if len(sL) != 6 {
+		t.Fatalf("readConfigurationStyles = %v", sL)
+	}
+	tests := []struct {
+		day   time.Time
+		color string
+		fill  string
+	}{
+		{time.Date(2025, 9, 10, 0, 0, 0, 0, time.UTC), "darkslategray", ""},
+		{time.Date(2025, 10, 10, 0, 0, 0, 0, time.UTC), "orange", "#fff3e0"},
+		{time.Date(2025, 10, 12, 0, 0, 0, 0, time.UTC), "firebrick", "#fff3e0"},
+		{time.Date(2025, 12, 25, 0, 0, 0, 0, time.UTC), "red", "mistyrose"},
+	}
+	for _, tt := range tests {
+		color, fill := dayStyle(sL, tt.day)
+		if color != tt.color || fill != tt.fill {
+			t.Errorf("dayStyle(%v) = %q %q, want %q %q", tt.day, color, fill, tt.color, tt.fill)
+		}
+	}
+}
+
+func Test_classStyle(t *testing.T) {
+	g := New(1, 12, 2025)
+	eL := g.readConfigurationfile("test-styles.xml", "en_US", 2025)
+	colors := make(map[string]string)
+	for _, ev := range eL {
+		colors[ev.Text] = ev.Color
+	}
+	if colors["Alice"] != "purple" || colors["Bob"] != "navy" {
+		t.Errorf("event colors = %v", colors)
+	}
+	sL := g.readConfigurationStyles("test-styles.xml", "en_US", 2025)
+	_, fill := dayStyle(sL, time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC))
+	if fill != "lavender" {
+		t.Errorf("fill of a birthday = %q, want lavender", fill)
+	}
+}
+
+func Test_applyRules(t *testing.T) {
+	rL := New(1, 12, 2025).readConfigurationRules("test-styles.xml", "en_US")
+	if len(rL) != 3 {
+		t.Fatalf("readConfigurationRules = %v", rL)
+	}
+	events := []gDate{
+		{time.June, 13, "Standup", "", "", "", "", "work", "", "", 0, ""},
+		{time.June, 13, "Lunch", "", "", "", "", "", "", "", 0, ""},
+	}
+	f, out := applyRules(rL, time.Date(2025, 6, 13, 0, 0, 0, 0, time.UTC), events)
+	if f.Icon != "\u2020" || f.Fill != "#eeeeee" || f.Color != "" || f.Scale != 0 {
+		t.Errorf("format of Friday 13 = %+v", f)
+	}
+	if out[0].Color != "gray" || out[1].Color != "" || events[0].Color != "" {
+		t.Errorf("event colors = %q %q", out[0].Color, out[1].Color)
+	}
+	f, _ = applyRules(rL, time.Date(2025, 6, 14, 0, 0, 0, 0, time.UTC), append(events, events...))
+	if f.Icon != "" || f.Scale != 0.8 {
+		t.Errorf("format of a busy day = %+v", f)
+	}
+	for _, bad := range []string{"colour == red", "count > many", "weekday < Friday", "day is someday"} {
+		if _, ok := parseRule(bad, "color red", "en_US"); ok {
+			t.Errorf("parseRule(%q) accepted", bad)
+		}
+	}
+	if _, ok := parseRule("month == 5", "shrink 2", "en_US"); ok {
+		t.Errorf("parseRule accepted shrink 2")
+	}
+}
+
+func Test_datePatterns(t *testing.T) {
+	day := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		match DatePattern
+		day   time.Time
+		want  bool
+	}{
+		{FridayThe13th, day(2026, 2, 13), true},
+		{FridayThe13th, day(2026, 4, 13), false},
+		{PalindromeDate, day(2021, 12, 2), true},
+		{PalindromeDate, day(2030, 3, 2), true},
+		{PalindromeDate, day(2030, 3, 3), false},
+		{RepdigitDate, day(2026, 11, 11), true},
+		{RepdigitDate, day(2026, 2, 22), true},
+		{RepdigitDate, day(2026, 1, 1), true},
+		{RepdigitDate, day(2026, 1, 12), false},
+	} {
+		if got := tc.match(tc.day); got != tc.want {
+			t.Errorf("pattern on %v = %v", tc.day, got)
+		}
+	}
+
+	g := New(1, 12, 2026)
+	g.SetDatePatterns("friday13, palindrome=*, nosuch")
+	g.AddDatePattern("Payday", func(d time.Time) bool { return d.Day() == 13 })
+	if got := g.patternLabels(day(2026, 3, 13)); len(got) != 2 || got[0] != "Friday 13th" || got[1] != "Payday" {
+		t.Errorf("patternLabels = %v", got)
+	}
+	if got := g.patternLabels(day(2030, 3, 2)); len(got) != 1 || got[0] != "*" || !isIconLabel(got[0]) || isIconLabel("Payday") {
+		t.Errorf("patternLabels = %v", got)
+	}
+
+	r, ok := parseRule("day is palindrome", "fill yellow", "en_US")
+	if !ok {
+		t.Fatalf("parseRule did not accept a pattern")
+	}
+	if f, _ := applyRules([]gRule{r}, day(2030, 3, 2), nil); f.Fill != "yellow" {
+		t.Errorf("format of a palindrome = %+v", f)
+	}
+	if f, _ := applyRules([]gRule{r}, day(2030, 3, 3), nil); f.Fill != "" {
+		t.Errorf("format of another day = %+v", f)
+	}
+}
+
+func Test_parseMarkup(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []textSpan
+	}{
+		{"plain", []textSpan{{"plain", false, false}}},
+		{"*Exam* at _noon_", []textSpan{{"Exam", true, false}, {" at ", false, false}, {"noon", false, true}}},
+		{"*_both_*", []textSpan{{"both", true, true}}},
+		{"snake_case_name", []textSpan{{"snake_case_name", false, false}}},
+		{"2 * 3 = 6", []textSpan{{"2 * 3 = 6", false, false}}},
+		{"\\*not bold\\*", []textSpan{{"*not bold*", false, false}}},
+		{"*open", []textSpan{{"*open", false, false}}},
+	}
+	for _, tt := range tests {
+		got := parseMarkup(tt.in)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("parseMarkup(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func Test_grayPDF(t *testing.T) {
+	if l := grayLevel(1, 1, 1, GRAYCONTRAST); l != 1 {
+		t.Errorf("white = %v", l)
+	}
+	if l := grayLevel(0.5, 0.5, 0.5, 2); math.Abs(l-0.5) > 1e-9 {
+		t.Errorf("middle gray = %v", l)
+	}
+	content := "1.000 0.000 0.000 rg 0.500 G\nBT (x) Tj ET 0.000 0.000 1.000 RG"
+	in := fmt.Sprintf("<</Length %d>>\nstream\n%s\nendstream /C0 [1.000 1.000 0.000]", len(content), content)
+	out := string(grayPDF([]byte(in), 1))
+	if len(out) != len(in) {
+		t.Fatalf("grayPDF changed the length: %q", out)
+	}
+	for _, want := range []string{"0.299 g ", "0.500 G\n", "0.114 G", "/C0 [0.886 0.886 0.886]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("grayPDF = %q, want %q", out, want)
+		}
+	}
+}
+
+func Test_brailleNumber(t *testing.T) {
+	if s := brailleString(brailleNumber(2025)); s != "⠼⠃⠚⠃⠑" {
+		t.Errorf("brailleNumber(2025) = %q", s)
+	}
+	if w, h := brailleSize(brailleNumber(31)); w != 16 || h != 6.5 {
+		t.Errorf("brailleSize = %v %v", w, h)
+	}
+}
+
+func Test_diffEvents(t *testing.T) {
+	g := New(1, 12, 2025)
+	oldList := g.readConfigurationfile("test-diff-old.xml", "en_US", 2025)
+	newList := g.readConfigurationfile("test-diff-new.xml", "en_US", 2025)
+	changes, marked := diffEvents(oldList, newList)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"> 3/8 -> 3/15 Spring fair",
+		"~ 5/1 Picnic -> Picnic in the park",
+		"- 9/20 Choir concert",
+		"+ 11/2 Lantern walk",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diffEvents = %q, want %q", got, want)
+	}
+	if len(marked) != 6 || marked[5].Text != "- Choir concert" || marked[5].Color != "red" || marked[0].Color != "" {
+		t.Errorf("marked = %v", marked)
+	}
+}
+
+func Test_shiftDate(t *testing.T) {
+	tests := []struct {
+		date     string
+		weekdays bool
+		want     string
+	}{
+		{"3/11", false, "3/11"},
+		{"3/11", true, "3/10"}, // 2nd Tuesday
+		{"3/31", true, "3/30"}, // 5th, the last Monday
+		{"2/29", true, "2/29"}, // not in 2025
+		{"7/15-7/30", true, "7/15-7/30"},
+		{"3rd Sunday of 6", true, "3rd Sunday of 6"},
+		{"last Friday", true, "last Friday"},
+	}
+	for _, tt := range tests {
+		if got, ok := shiftDate(tt.date, "en_US", 2025, 2026, tt.weekdays); !ok || got != tt.want {
+			t.Errorf("shiftDate(%q) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func Test_ShiftConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "gocal-shift-*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`<Gocal><Gocaldate date="3/11" text="A"/><Gocaldate date="sometime" text="B"/></Gocal>`)
+	f.Close()
+	var buf bytes.Buffer
+	warnings, err := ShiftConfig(&buf, f.Name(), "en_US", 2025, 2026, true)
+	if err != nil || !strings.Contains(buf.String(), `date="3/10"`) {
+		t.Fatalf("ShiftConfig = %s, %v", buf.String(), err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "sometime") || strings.Contains(buf.String(), "Keeping") {
+		t.Errorf("ShiftConfig warnings = %v", warnings)
+	}
+}
+
+func Test_WriteICS(t *testing.T) {
+	g := New(3, 3, 2025)
+	g.AddEvent(14, 3, "*Pi* day, with cake; maybe", "")
+	g.EventList = append(g.EventList, gDate{0, 0, "Choir", "last Monday", "", "", "", "", "", "", 0, ""})
+	var b bytes.Buffer
+	if err := g.WriteICS(&b); err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART;VALUE=DATE:20250314\r\nDTEND;VALUE=DATE:20250315\r\nSUMMARY:Pi day\\, with cake\\; maybe\r\n",
+		"DTSTART;VALUE=DATE:20250331\r\n",
+		"SUMMARY:Full moon\r\nCATEGORIES:moon\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("WriteICS lacks %q in\n%s", want, s)
+		}
+	}
+	if n := strings.Count(s, "BEGIN:VEVENT"); n != 6 { // with 4 moon phases
+		t.Errorf("WriteICS wrote %d events, want 6", n)
+	}
+	g.SetHideMoon()
+	b.Reset()
+	g.WriteICS(&b)
+	if n := strings.Count(b.String(), "BEGIN:VEVENT"); n != 2 {
+		t.Errorf("WriteICS wrote %d events without the moon, want 2", n)
+	}
+}
+
+func Test_WriteCSV(t *testing.T) {
+	g := New(3, 3, 2025)
+	g.AddEvent(14, 3, "*Pi* day, with cake", "")
+	var b bytes.Buffer
+	if err := g.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "date,text,category,description,color,image,source\n2025-03-14,\"Pi day, with cake\",,,,,api\n"
+	if b.String() != want {
+		t.Errorf("WriteCSV = %q, want %q", b.String(), want)
+	}
+	b.Reset()
+	if err := g.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"date": "2025-03-14",`) || !strings.Contains(b.String(), `"month": 3,`) {
+		t.Errorf("WriteJSON = %s", b.String())
+	}
+}
+
+func Test_collageTiles(t *testing.T) {
+	cell := rect{10, 20, 40, 20}
+	tests := []struct {
+		n    int
+		cell rect
+		want []rect
+	}{
+		{1, cell, []rect{cell}},
+		{2, cell, []rect{{10, 20, 19.75, 20}, {30.25, 20, 19.75, 20}}},
+		{2, rect{0, 0, 20, 40}, []rect{{0, 0, 20, 19.75}, {0, 20.25, 20, 19.75}}},
+		{3, cell, []rect{{10, 20, 19.75, 20}, {30.25, 20, 19.75, 9.75}, {30.25, 30.25, 19.75, 9.75}}},
+		{5, cell, []rect{{10, 20, 13, 9.75}, {23.5, 20, 13, 9.75}, {37, 20, 13, 9.75}, {10, 30.25, 19.75, 9.75}, {30.25, 30.25, 19.75, 9.75}}},
+	}
+	for _, tt := range tests {
+		if got := collageTiles(tt.n, tt.cell, 0.5); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("collageTiles(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+	if got := dayImages([]gDate{{Image: "a.png, b.png"}, {Image: "a.png"}, {}}); fmt.Sprint(got) != "[a.png b.png]" {
+		t.Errorf("dayImages = %v", got)
+	}
+}
+
+func Test_parseSVG(t *testing.T) {
+	f, err := os.Open("test-logo.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := parseSVG(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.w != 120 || img.h != 80 || len(img.shapes) != 5 {
+		t.Fatalf("parseSVG = %v x %v with %d shapes", img.w, img.h, len(img.shapes))
+	}
+	if s := img.shapes[0]; s.fill != "#cde6ff" || s.stroke != "" {
+		t.Errorf("rect paint = %q %q", s.fill, s.stroke)
+	}
+	circle := img.shapes[1]
+	if circle.fill != "#ffcc33" || circle.stroke != "#204080" || circle.strokeWidth != 3 {
+		t.Errorf("circle paint = %q %q %v", circle.fill, circle.stroke, circle.strokeWidth)
+	}
+	if p := circle.path[0].pts; p[0] != 84 || p[1] != 40 {
+		t.Errorf("circle starts at %v, want 84 40", p)
+	}
+	end := circle.path[len(circle.path)-2].pts
+	if math.Abs(end[4]-84) > 1e-9 || math.Abs(end[5]-40) > 1e-9 {
+		t.Errorf("circle ends at %v, want 84 40", end[4:])
+	}
+	if s := img.shapes[3]; s.fill != "" || s.path[1].cmd != 'C' {
+		t.Errorf("smile = %q %v", s.fill, s.path)
+	}
+	if _, err := parseSVGPath("M0 0L10"); err == nil {
+		t.Errorf("parseSVGPath accepted a missing coordinate")
+	}
+	if m, _ := parseSVGTransform("translate(10 20) scale(2)"); m != (svgMatrix{2, 0, 0, 2, 10, 20}) {
+		t.Errorf("parseSVGTransform = %v", m)
+	}
+}
+
+func Test_decodeImage(t *testing.T) {
+	// 1x1 lossy WebP
+	data, _ := base64.StdEncoding.DecodeString("UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA")
+	file := filepath.Join(t.TempDir(), "pixel.webp")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	img, err := decodeImage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+		t.Errorf("decodeImage = %v", b)
+	}
+	if !needsConversion("IMG_0042.HEIC") || needsConversion("photo.jpg") {
+		t.Errorf("needsConversion")
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	registerConverted(pdf, file)
+	if info := pdf.GetImageInfo(file); info == nil || !pdf.Ok() {
+		t.Errorf("registerConverted: %v", pdf.Error())
+	}
+}
+
+func Test_placeImage(t *testing.T) {
+	// A 200x100 image into a 50x50 area, the face at the left.
+	fr, ok := parseImageFrame("", "20%,50%")
+	if !ok {
+		t.Fatal("parseImageFrame failed")
+	}
+	if got := placeImage(200, 100, fr, rect{10, 10, 50, 50}, true); got != (rect{10, 10, 100, 50}) {
+		t.Errorf("placeImage(focus left) = %v", got)
+	}
+	if got := placeImage(200, 100, fullFrame, rect{10, 10, 50, 50}, true); got != (rect{-15, 10, 100, 50}) {
+		t.Errorf("placeImage(center) = %v", got)
+	}
+	// The right half, stretched.
+	fr, _ = parseImageFrame("0.5,0,0.5,1", "")
+	if got := placeImage(200, 100, fr, rect{0, 0, 50, 50}, false); got != (rect{-50, 0, 100, 50}) {
+		t.Errorf("placeImage(crop) = %v", got)
+	}
+	for _, bad := range [][2]string{{"0.5,0,0.6,1", ""}, {"0,0,1", ""}, {"", "120%,0"}} {
+		if _, ok := parseImageFrame(bad[0], bad[1]); ok {
+			t.Errorf("parseImageFrame(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+}
+
+func Test_enhanceImage(t *testing.T) {
+	// A dull photo with a blue cast: red from 100 to 150, blue higher.
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			v := uint8(100 + 5*x)
+			img.Set(x, y, color.NRGBA{v, v, v + 50, 255})
+		}
+	}
+	out := enhanceImage(img)
+	for _, x := range []int{0, 9} {
+		c := out.NRGBAAt(x, 5)
+		want := uint8(0)
+		if x == 9 {
+			want = 255
+		}
+		if c.R != want || c.G != want || c.B != want {
+			t.Errorf("enhanceImage at %d = %v, want gray %d", x, c, want)
+		}
+	}
+	// Flat channels stay.
+	flat := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.NRGBA{80, 80, 80, 255}), image.Point{}, draw.Src)
+	if c := enhanceImage(flat).NRGBAAt(1, 1); c != (color.NRGBA{80, 80, 80, 255}) {
+		t.Errorf("enhanceImage(flat) = %v", c)
+	}
+}
+
+func Test_captionText(t *testing.T) {
+	data := captionData{"Mai", 5, 2025, "lake.jpg", "Anna"}
+	got, err := captionText("{{.Month}} {{.Year}} ({{.MonthNumber}}), photo: {{.Credit}}", data)
+	if err != nil || got != "Mai 2025 (5), photo: Anna" {
+		t.Errorf("captionText = %q, %v", got, err)
+	}
+	if _, err := captionText("{{.Photographer}}", data); err == nil {
+		t.Errorf("captionText accepted an unknown variable")
+	}
+	x, y, align := captionPlace("bottom", rect{0, 100, 200, 100}, 50, 10)
+	if x != 75 || y != 187 || align != 0.5 {
+		t.Errorf("captionPlace(bottom) = %v, %v, %v", x, y, align)
+	}
+	if x, y, _ := captionPlace("nowhere", rect{0, 0, 200, 100}, 50, 10); x != 147 || y != 87 {
+		t.Errorf("captionPlace(unknown) = %v, %v", x, y)
+	}
+}
+
+func Test_assignPhotos(t *testing.T) {
+	files := []string{"p/01-snow.jpg", "p/07_beach.jpg", "p/7-lake.jpg", "p/cat.jpg"}
+	got := assignPhotos(files, "pattern", 0, filenameMonth)
+	if got[0] != "p/01-snow.jpg" || got[6] != "p/07_beach.jpg" || got[1] != "p/7-lake.jpg" || got[2] != "p/cat.jpg" || got[3] != "p/7-lake.jpg" {
+		t.Errorf("assignPhotos(pattern) = %v", got)
+	}
+	if got := assignPhotos(files, "name", 0, nil); got[4] != "p/01-snow.jpg" || got[11] != "p/cat.jpg" {
+		t.Errorf("assignPhotos(name) = %v", got)
+	}
+	if a, b := assignPhotos(files, "random", 42, nil), assignPhotos(files, "random", 42, nil); a != b {
+		t.Errorf("assignPhotos(random) differs with the same seed: %v %v", a, b)
+	}
+}
+
+func Test_exifDate(t *testing.T) {
+	// TIFF with IFD0 pointing to an EXIF IFD with DateTimeOriginal.
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = append(tiff, 1, 0, 0x69, 0x87, 4, 0, 1, 0, 0, 0, 26, 0, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, 1, 0, 0x03, 0x90, 2, 0, 20, 0, 0, 0, 44, 0, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "2024:07:14 10:00:00\x00"...)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)}
+	jpeg = append(append(jpeg, app1...), 0xff, 0xd9)
+	file := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := ioutil.WriteFile(file, jpeg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := exifMonth(file); !ok || m != time.July {
+		t.Errorf("exifMonth = %v, %v", m, ok)
+	}
+	if _, ok := exifDate([]byte{0xff, 0xd8, 0xff, 0xd9}); ok {
+		t.Errorf("exifDate found a date without EXIF")
+	}
+}
+
+func Test_parseOrnaments(t *testing.T) {
+	grey := [3]int{200, 200, 200}
+	o, err := parseOrnaments("seasonal, 12=stars:#ff0000", grey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o[1] != (ornament{"snowflakes", grey}) || o[7].motif != "suns" || o[12] != (ornament{"stars", [3]int{255, 0, 0}}) {
+		t.Errorf("parseOrnaments = %v", o)
+	}
+	if o, _ := parseOrnaments("seasonal", grey, true); o[1].motif != "suns" || o[7].motif != "snowflakes" {
+		t.Errorf("parseOrnaments(south) = %v", o)
+	}
+	if o, _ := parseOrnaments("", grey, false); o[5].motif != "" {
+		t.Errorf("parseOrnaments(\"\") = %v", o)
+	}
+	for _, bad := range []string{"clouds", "13=stars", "stars:nocolor"} {
+		if _, err := parseOrnaments(bad, grey, false); err == nil {
+			t.Errorf("parseOrnaments(%q) accepted", bad)
+		}
+	}
+}
+
+func Test_seasonOf(t *testing.T) {
+	for _, c := range []struct {
+		mo    time.Month
+		south bool
+		want  int
+	}{
+		{time.December, false, 0}, {time.February, false, 0}, {time.March, false, 1},
+		{time.August, false, 2}, {time.November, false, 3},
+		{time.December, true, 2}, {time.July, true, 0}, {time.October, true, 1}, {time.April, true, 3},
+	} {
+		if got := seasonOf(c.mo, c.south); got != c.want {
+			t.Errorf("seasonOf(%v, %v) = %d, want %d", c.mo, c.south, got, c.want)
+		}
+	}
+	g := New(1, 12, 2025)
+	g.SetSeasonTheme("auto")
+	g.SetLocation("-33.87,151.21")
+	if !g.southern() {
+		t.Errorf("southern() false for Sydney")
+	}
+}
+
+func Test_brand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "brand.xml")
+	data := `<Gocal><Gocalbrand name="ACME" primary="#003366" secondary="nocolor" footer="acme.example" /></Gocal>`
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := New(1, 12, 2026)
+	if g.brand().hasBrand() || g.footer() != "" {
+		t.Errorf("brand without brand file = %+v", g.brand())
+	}
+	g.SetBrand(file)
+	if b := g.brand(); b.Name != "ACME" || b.Primary != "#003366" || b.Secondary != "" {
+		t.Errorf("brand() = %+v", b)
+	}
+	if g.footer() != "acme.example" {
+		t.Errorf("footer() = %q", g.footer())
+	}
+	g.SetFooter("Printed in 2026")
+	if g.footer() != "Printed in 2026" {
+		t.Errorf("footer() = %q, want the footer of the user", g.footer())
+	}
+}
+
+func Test_ReadRecipients(t *testing.T) {
+	recipients, err := ReadRecipients("test-recipients.csv")
+	if err != nil || len(recipients) != 2 {
+		t.Fatalf("ReadRecipients = %v, %v", recipients, err)
+	}
+	anna := recipients[0]
+	if anna["photo"] != "golang-gopher.png" || anna.Expand("calendar-{name}.pdf") != "calendar-Anna.pdf" {
+		t.Errorf("recipient = %v", anna)
+	}
+	g := New(1, 12, 2026)
+	g.SetRecipient(anna)
+	events := g.recipientEvents()
+	if len(events) != 2 || events[0].Month != time.March || events[0].Day != 14 || events[0].Text != "Birthday of Anna" {
+		t.Errorf("recipientEvents = %v", events)
+	}
+	if g.OptPhoto != "golang-gopher.png" || g.outputFilename("{name}-{year}.pdf") != "Anna-2026.pdf" {
+		t.Errorf("SetRecipient: photo %q, filename %q", g.OptPhoto, g.outputFilename("{name}-{year}.pdf"))
+	}
+}
+
+func Test_parseAddressWindow(t *testing.T) {
+	if r, err := parseAddressWindow("din"); err != nil || r != (rect{20, 45, 85, 45}) {
+		t.Errorf("parseAddressWindow(din) = %v, %v", r, err)
+	}
+	if r, err := parseAddressWindow("25, 50"); err != nil || r != (rect{25, 50, 85, 45}) {
+		t.Errorf("parseAddressWindow(x,y) = %v, %v", r, err)
+	}
+	for _, bad := range []string{"c4", "1,2,3", "a,b"} {
+		if _, err := parseAddressWindow(bad); err == nil {
+			t.Errorf("parseAddressWindow(%q) accepted", bad)
+		}
+	}
+	recipients, _ := ReadRecipients("test-recipients.csv")
+	g := New(1, 12, 2026)
+	g.SetRecipient(recipients[1])
+	if got := g.addressLines(); fmt.Sprint(got) != "[Ben 1 Main Street Springfield IL 62701]" {
+		t.Errorf("addressLines = %q", got)
+	}
+}
+
+func Test_pdfx(t *testing.T) {
+	components, description, err := iccInfo(srgbProfile())
+	if err != nil || components != 3 || description != SRGBDESCRIPTION {
+		t.Errorf("iccInfo(sRGB) = %d, %q, %v", components, description, err)
+	}
+	if _, _, err := iccInfo([]byte("no profile")); err == nil {
+		t.Errorf("iccInfo accepted no profile")
+	}
+	g := New(1, 1, 2026)
+	g.SetPDFX("srgb")
+	pdf := g.newPDF("")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Text(10, 10, "Gocal")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := g.pdfxIntent().apply(buf.Bytes(), g.warnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/TrimBox [0.00 0.00 841.89 595.28]", "/S /GTS_PDFX", "/OutputConditionIdentifier (sRGB IEC61966-2.1)", "/Trapped /False", "/DefaultRGB", "<pdfxid:GTS_PDFXVersion>PDF/X-4<"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("PDF/X misses %q", want)
+		}
+	}
+	if !bytes.HasPrefix(out, buf.Bytes()[:100]) {
+		t.Errorf("the update changed the beginning of the PDF")
+	}
+	// The update is readable like the original, every object where
+	// the cross-reference table says.
+	offsets, _, root, _, err := pdfObjects(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, off := range offsets {
+		if !bytes.HasPrefix(out[off:], []byte(fmt.Sprintf("%d 0 obj", n))) {
+			t.Errorf("object %d not at %d", n, off)
+		}
+	}
+	if catalog, _ := pdfDict(out, offsets, root); !strings.Contains(catalog, "/OutputIntents") {
+		t.Errorf("the catalog has no output intent: %s", catalog)
+	}
+}
+
+func Test_traceLine(t *testing.T) {
+	g := New(1, 12, 2026)
+	if g.traceLine() != "" {
+		t.Errorf("traceLine without the option = %q", g.traceLine())
+	}
+	g.SetTrace("Gocal 1.0")
+	h := g.configHash()
+	if len(h) != 12 || h != g.configHash() {
+		t.Errorf("configHash = %q, %q", h, g.configHash())
+	}
+	if !strings.HasPrefix(g.traceLine(), "Gocal 1.0 | config "+h+" | ") {
+		t.Errorf("traceLine = %q", g.traceLine())
+	}
+	g.SetFooter("Proof")
+	if g.configHash() == h {
+		t.Errorf("configHash ignores the options")
+	}
+	h = g.configHash()
+	g.AddConfig("test-brand.xml")
+	if g.configHash() == h {
+		t.Errorf("configHash ignores the configuration files")
+	}
+}
+
+func Test_JobServer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gocal-jobs-")
+	defer os.RemoveAll(dir)
+	js, err := NewJobServer(dir, 1, 1<<30, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(js)
+	defer srv.Close()
+
+	config := `<Gocal><Gocaldate date="3/14" text="Pi day" /></Gocal>`
+	resp, err := http.Post(srv.URL+"/jobs?year=2026&begin=3&end=3", "application/xml", strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || job.ID == "" || resp.Header.Get("Location") != "/jobs/"+job.ID {
+		t.Fatalf("POST /jobs = %d, %+v", resp.StatusCode, job)
+	}
+	for start := time.Now(); job.Status != JobDone && job.Status != JobFailed; time.Sleep(50 * time.Millisecond) {
+		if time.Since(start) > time.Minute {
+			t.Fatalf("job %s still %s", job.ID, job.Status)
+		}
+		resp, err := http.Get(srv.URL + "/jobs/" + job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+	}
+	if job.Status != JobDone {
+		t.Fatalf("job failed: %s", job.Error)
+	}
+	resp, err = http.Get(srv.URL + job.PDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		t.Errorf("GET %s is no PDF: %d bytes", job.PDF, len(data))
+	}
+
+	for _, bad := range []struct{ query, config string }{
+		{"?layout=poster", ""},
+		{"?begin=5&end=4", ""},
+		{"?year=0", ""},
+		{"?year=10000", ""},
+		{"", "<Gocal><Gocaldate"},
+	} {
+		resp, err := http.Post(srv.URL+"/jobs"+bad.query, "application/xml", strings.NewReader(bad.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /jobs%s %q = %d", bad.query, bad.config, resp.StatusCode)
+		}
+	}
+	if names := LayoutNames(); names != "monthly, perpetual, strip, year, yearinverse" {
+		t.Errorf("LayoutNames = %s", names)
+	}
+
+	resp, err = http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		"gocal_render_duration_seconds_count 1\n",
+		"gocal_pages_total 1\n",
+		"gocal_jobs_total{status=\"done\"} 1\n",
+		"gocal_jobs{status=\"queued\"} 0\n",
+		"# TYPE gocal_cache_lookups_total counter\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("/metrics misses %q:\n%s", want, data)
+		}
+	}
+
+	// The finished job makes room for the next one.
+	js.quota = job.Bytes
+	if !js.reserve(10) || len(js.jobs) != 0 {
+		t.Errorf("reserve kept the finished job")
+	}
+	if js.reserve(job.Bytes + 1) {
+		t.Errorf("reserve exceeded the quota")
+	}
+
+	// A job reserves room for its calendar.
+	js.quota = JOBOUTPUT
+	resp, err = http.Post(srv.URL+"/jobs", "application/xml", strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("POST /jobs over the quota = %d", resp.StatusCode)
+	}
+
+	// Files outside of the job are refused, fatal errors fail the job
+	// but not the server, and the reports are the jobs' own. In strict
+	// mode the warnings fail the job.
+	js.quota = 1 << 30
+	files := len(GetReport().Files)
+	for _, tc := range []struct {
+		font            string
+		strict          bool
+		status, warning string
+	}{
+		{"/etc/hostname", false, JobDone, "outside of the file root"},
+		{"config.xml", false, JobFailed, ""},
+		{"/etc/hostname", true, JobFailed, ""},
+	} {
+		js.SetStrict(tc.strict)
+		resp, err := http.Post(srv.URL+"/jobs?begin=1&end=1", "application/xml", strings.NewReader(`<Gocal><Gocalbrand name="Shop" font="`+tc.font+`"/></Gocal>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		job = Job{}
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		for start := time.Now(); job.Status != JobDone && job.Status != JobFailed; time.Sleep(50 * time.Millisecond) {
+			if time.Since(start) > time.Minute {
+				t.Fatalf("job %s still %s", job.ID, job.Status)
+			}
+			resp, err := http.Get(srv.URL + "/jobs/" + job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			json.NewDecoder(resp.Body).Decode(&job)
+			resp.Body.Close()
+		}
+		if job.Status != tc.status || tc.warning != "" && (len(job.Warnings) != 1 || !strings.Contains(job.Warnings[0].Message, tc.warning)) {
+			t.Errorf("job with font %s = %s %q %v", tc.font, job.Status, job.Error, job.Warnings)
+		}
+		if tc.strict && !strings.Contains(job.Error, "outside of the file root") {
+			t.Errorf("strict job with font %s failed with %q", tc.font, job.Error)
+		}
+	}
+	if n := len(GetReport().Files); n != files {
+		t.Errorf("the jobs added %d files to the report", n-files)
+	}
+}
+
+func Test_histogram(t *testing.T) {
+	var h histogram
+	for _, v := range []float64{0.2, 1.5, 200} {
+		h.observe(v)
+	}
+	if h.count != 3 || h.sum != 201.7 || h.buckets[0] != 1 || h.buckets[2] != 2 || h.buckets[len(renderBuckets)-1] != 2 {
+		t.Errorf("histogram = %+v", h)
+	}
+}
+
+// dirFontLoader loads the font definitions of a directory, like the
+// browser loads them from memory.
+type dirFontLoader string
+
+func (d dirFontLoader) Open(name string) (io.Reader, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+func Test_SetWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocal-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteFontDefinitions(dir); err != nil {
+		t.Fatal(err)
+	}
+	fontLoader = dirFontLoader(dir)
+	defer func() { fontLoader = nil }()
+	g := New(1, 1, 2026)
+	g.AddFile("memory-config.xml", []byte(`<Gocal><Gocaldate date="1/26" text="In memory"/></Gocal>`))
+	g.SetFont("sans")
+	g.SetConfig("memory-config.xml")
+	var buf bytes.Buffer
+	g.SetWriter(&buf)
+	g.CreateCalendar(filepath.Join(dir, "calendar.pdf"))
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) || !bytes.Contains(buf.Bytes(), []byte("/BaseFont /FreeSansBold")) {
+		t.Errorf("SetWriter got no PDF with the font from the loader")
+	}
+	if v := g.loadConfigurationfile("memory-config.xml"); len(v.Gocaldate) != 1 || v.Gocaldate[0].Text != "In memory" {
+		t.Errorf("loadConfigurationfile(AddFile) = %+v", v.Gocaldate)
+	}
+	if v := New(1, 1, 2026).loadConfigurationfile("memory-config.xml"); len(v.Gocaldate) != 0 {
+		t.Errorf("another calendar read the file of AddFile: %+v", v.Gocaldate)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "calendar.pdf")); err == nil {
+		t.Errorf("SetWriter wrote the output file")
+	}
+}
+
+func Test_icsReader(t *testing.T) {
+	cal := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:1\nDTSTART;VALUE=DATE:20260501\nDTEND;VALUE=DATE:20260502\nSUMMARY:Labour Day\nDESCRIPTION:Parade\\, then picnic\nEND:VEVENT\nBEGIN:VEVENT\nUID:2\nDTSTART;VALUE=DATE:20270101\nDTEND;VALUE=DATE:20270102\nSUMMARY:New Year\nEND:VEVENT\nEND:VCALENDAR\n"
+	g := New(1, 12, 2026)
+	g.AddFile("memory.ics", []byte(cal))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := newICSReader()
+			r.fetch = func(url, accept string) ([]byte, error) { return []byte(cal), nil }
+			r.open = g.readFile
+			for _, f := range []string{"memory.ics", "https://example.org/a.ics"} {
+				eL := r.read(f, 2026, "", warnf)
+				if len(eL) != 1 || eL[0].Month != time.May || eL[0].Day != 1 || eL[0].Text != "Labour Day" || eL[0].Description != "Parade, then picnic" || eL[0].Source != f {
+					t.Errorf("read(%s) = %+v", f, eL)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_Generator(t *testing.T) {
+	gen, err := NewGenerator(nil, []string{"de"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGenerator([]string{"no-such-font.ttf"}, nil, "", nil); err == nil {
+		t.Errorf("NewGenerator accepted a missing font file")
+	}
+	g := gen.New(1, 12, 2026)
+	if n := g.monthNames("de_DE", false); n[3] != convertCP("März") {
+		t.Errorf("monthNames(de_DE) = %v", n)
+	}
+	if n := g.weekdayNames("de_DE", 2); n[2] != "Mo" {
+		t.Errorf("weekdayNames(de_DE, 2) = %v", n)
+	}
+	name, dir := g.processFont("sans")
+	defer removeTempdir(dir)
+	if _, err := os.Stat(filepath.Join(dir, name+".json")); err == nil {
+		t.Errorf("the calendar of the generator converted the font again")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g := gen.New(1, 2, 2026+i)
+			g.SetFont([]string{"sans", "mono", "serif", "sans"}[i])
+			var buf bytes.Buffer
+			g.SetWriter(&buf)
+			g.CreateCalendar("")
+			if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+				t.Errorf("calendar %d of the generator has no PDF", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if g := gen.New(1, 12, 2026); g.OptHolidayCountry != "FR" {
+		t.Errorf("holiday country of the generator = %q, want FR", g.OptHolidayCountry)
+	}
+}
+
+func Test_downloadCache(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	c := &downloadCache{ttl: time.Hour, size: 2, entries: make(map[string]download)}
+	c.fetch = func(url, accept string) ([]byte, error) {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		fetched[url]++
+		return []byte(url), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, err := c.get("a", ""); err != nil || string(data) != "a" {
+				t.Errorf("get(a) = %q, %v", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetched["a"] != 1 {
+		t.Errorf("a was downloaded %d times at the same time, want once", fetched["a"])
+	}
+	c.get("b", "")
+	c.get("c", "")
+	if len(c.entries) != 2 {
+		t.Errorf("the cache keeps %d downloads, want at most 2", len(c.entries))
+	}
+	if c.get("a", ""); fetched["a"] != 2 {
+		t.Errorf("the oldest download was not dropped")
+	}
+	c.ttl = 0
+	if c.get("c", ""); fetched["c"] != 2 {
+		t.Errorf("an expired download was not downloaded again")
+	}
+}
+
+func Test_localizedNames(t *testing.T) {
+	if n := getLocalizedWeekdayNames("fr_FR", 2); n[2] != "lu" {
+		t.Errorf("getLocalizedWeekdayNames(fr_FR, 2) = %v", n)
+	}
+	if n := getLocalizedWeekdayNames("fr_FR", 0); n[2] != "lundi" {
+		t.Errorf("the cutoff changed the kept names: %v", n)
+	}
+	first := getLocalizedMonthNames("fr_FR")
+	if _, ok := localizedNames.Load("month fr_FR"); !ok {
+		t.Errorf("the month names of fr_FR were not kept")
+	}
+	if getLocalizedMonthNames("fr_FR") != first || first[2] != convertCP("février") {
+		t.Errorf("getLocalizedMonthNames(fr_FR) = %v", first)
+	}
+}
+
+func Test_replaceRefs(t *testing.T) {
+	dict := []byte(`<< /P 3 0 R /Contents (see 3 0 R \) (4 0 R)) /T (3 0 R) /Parent 4 0 R >>`)
+	got := replaceRefs(dict, func(m []byte) []byte { return []byte("9 0 R") })
+	want := `<< /P 9 0 R /Contents (see 3 0 R \) (4 0 R)) /T (3 0 R) /Parent 9 0 R >>`
+	if string(got) != want {
+		t.Errorf("replaceRefs = %s, want %s", got, want)
+	}
+	if refs := pdfRefs(dict); fmt.Sprint(refs) != "[3 4]" {
+		t.Errorf("pdfRefs = %v", refs)
+	}
+	if refs := pdfRefs([]byte(`<< /T (open 3 0 R`)); refs != nil {
+		t.Errorf("pdfRefs of an open string = %v", refs)
+	}
+}
+
+func Test_SetStream(t *testing.T) {
+	render := func(stream int) []byte {
+		g := New(1, 5, 2026)
+		g.SetStream(stream)
+		g.SetPageNumbers("{page}/{pages}")
+		var buf bytes.Buffer
+		g.SetWriter(&buf)
+		g.CreateCalendar("")
+		return buf.Bytes()
+	}
+	whole, streamed := render(0), render(2)
+	bodies, pages, err := pdfPages(streamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 5 {
+		t.Errorf("%d pages streamed, want 5", len(pages))
+	}
+	for n, body := range bodies {
+		for _, r := range pdfRef.FindAllSubmatch(dictOf(body), -1) {
+			if ref, _ := strconv.Atoi(string(r[1])); bodies[ref] == nil {
+				t.Errorf("object %d refers to the missing object %d", n, ref)
+			}
+		}
+	}
+	if len(streamed) > len(whole)+len(whole)/10 {
+		t.Errorf("the parts don't share the font: %d bytes streamed, %d whole", len(streamed), len(whole))
+	}
+	contents := regexp.MustCompile(`/Contents (\d+) 0 R`)
+	for i, p := range pages {
+		c, _ := strconv.Atoi(string(contents.FindSubmatch(bodies[p])[1]))
+		stream := bodies[c][bytes.Index(bodies[c], []byte("stream\n"))+7:]
+		r, err := zlib.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := ioutil.ReadAll(r)
+		if want := fmt.Sprintf("(%d/5) Tj", i+1); !bytes.Contains(text, []byte(want)) {
+			t.Errorf("page %d has no page number %s", i+1, want)
+		}
+	}
+}
+
+func Test_addPageNumbers(t *testing.T) {
+	g := New(1, 3, 2026)
+	g.SetPageNumbers("{page}")
+	var buf bytes.Buffer
+	g.SetWriter(&buf)
+	g.CreateCalendar("")
+	bodies, pages, err := pdfPages(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := regexp.MustCompile(`/Contents (\d+) 0 R`)
+	fontSize := regexp.MustCompile(`([\d.]+) Tf`)
+	want := fmt.Sprintf("%.2f", FOOTERFONTSIZE*0.8)
+	for i, p := range pages {
+		c, _ := strconv.Atoi(string(contents.FindSubmatch(bodies[p])[1]))
+		stream := bodies[c][bytes.Index(bodies[c], []byte("stream\n"))+7:]
+		r, err := zlib.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := ioutil.ReadAll(r)
+		n := bytes.Index(text, []byte(fmt.Sprintf("(%d) Tj", i+1)))
+		if n < 0 {
+			t.Fatalf("page %d has no page number", i+1)
+		}
+		sizes := fontSize.FindAllSubmatch(text[:n], -1)
+		if len(sizes) == 0 || string(sizes[len(sizes)-1][1]) != want {
+			t.Errorf("page number of page %d is not in the font size %s", i+1, want)
+		}
+	}
+}
+
+func Test_gridDashAndToday(t *testing.T) {
+	g := New(1, 1, 2025)
+	var r Report
+	g.SetReport(&r)
+	g.SetGridDash("2, 1")
+	g.SetGridDash("2,x")
+	if dashes, err := parseDashes(g.OptGridDash); err != nil || len(dashes) != 2 || dashes[0] != 2 || dashes[1] != 1 {
+		t.Errorf("grid dash = %q, %v %v", g.OptGridDash, dashes, err)
+	}
+	g.SetHighlightToday("fill")
+	g.SetHighlightToday("blink")
+	if g.OptHighlightToday != "fill" || len(r.Warnings) != 2 {
+		t.Errorf("highlight = %q, warnings %v", g.OptHighlightToday, r.Warnings)
+	}
+}
+
+func Test_parseConfiguration(t *testing.T) {
+	v, err := parseConfiguration([]byte("<Gocal><Gocaldate date=\"12/24\" text=\"Eve\xff\"/></Gocal>"), "test.xml")
+	if err != nil || len(v.Gocaldate) != 1 || v.Gocaldate[0].Text != "Eve\uFFFD" {
+		t.Errorf("parseConfiguration = %+v, %v", v.Gocaldate, err)
+	}
+	nested := func(depth int) []byte {
+		return []byte("<Gocal>" + strings.Repeat("<a>", depth-1) + strings.Repeat("</a>", depth-1) + "</Gocal>")
+	}
+	if _, err := parseConfiguration(nested(MAXXMLDEPTH), "test.xml"); err != nil {
+		t.Errorf("error for elements nested %d deep: %v", MAXXMLDEPTH, err)
+	}
+	if _, err := parseConfiguration(nested(MAXXMLDEPTH+1), "test.xml"); err == nil {
+		t.Errorf("no error for elements nested %d deep", MAXXMLDEPTH+1)
+	}
+	if _, err := parseConfiguration(make([]byte, MAXINPUTSIZE+1), "test.xml"); err == nil {
+		t.Errorf("no error for a configuration of %d bytes", MAXINPUTSIZE+1)
+	}
+	if err := CheckConfig([]byte("<Gocal><Gocaldate date=")); err == nil {
+		t.Errorf("CheckConfig accepted a broken configuration")
+	}
+}
+
+func Test_rootedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gocal-root-")
+	defer os.RemoveAll(dir)
+	os.Symlink("/etc/hostname", filepath.Join(dir, "link.ttf"))
+	g := New(1, 1, 2026)
+	var r Report
+	g.SetReport(&r)
+	if f, ok := g.rootedFile("/etc/hostname"); !ok || f != "/etc/hostname" {
+		t.Errorf("rootedFile without a root = %s %v", f, ok)
+	}
+	g.SetFileRoot(dir)
+	for _, tt := range []struct {
+		file string
+		ok   bool
+	}{
+		{"logo.png", true},
+		{"sub/logo.png", true},
+		{"https://example.org/logo.png", true},
+		{"/etc/hostname", false},
+		{"../logo.png", false},
+		{"link.ttf", false},
+	} {
+		if _, ok := g.rootedFile(tt.file); ok != tt.ok {
+			t.Errorf("rootedFile(%s) = %v", tt.file, ok)
+		}
+	}
+	if f, _ := g.rootedFile("logo.png"); f != filepath.Join(dir, "logo.png") {
+		t.Errorf("rootedFile(logo.png) = %s", f)
+	}
+	if s := g.rootedFiles("a.jpg, /etc/passwd,b.jpg"); s != filepath.Join(dir, "a.jpg")+","+filepath.Join(dir, "b.jpg") {
+		t.Errorf("rootedFiles = %s", s)
+	}
+	g.SetNumerals("arab")
+	g.SetNumeralFont("/etc/hosts")
+	if system := g.loadNumeralFont(gofpdf.New("P", "mm", "A4", "")); system != "latn" {
+		t.Errorf("loadNumeralFont outside of the root = %s", system)
+	}
+	if len(r.Warnings) != 5 {
+		t.Errorf("warnings = %v", r.Warnings)
+	}
+}
+
+func Test_readRecipientsLimits(t *testing.T) {
+	recipients, err := readRecipients(strings.NewReader("Name,City\nAnn,K\xf6ln\n"), "test.csv")
+	if err != nil || len(recipients) != 1 || recipients[0]["city"] != "K\uFFFDln" {
+		t.Errorf("readRecipients = %v, %v", recipients, err)
+	}
+	many := "name\n" + strings.Repeat("x\n", MAXRECIPIENTS+1)
+	if _, err := readRecipients(strings.NewReader(many), "test.csv"); err == nil {
+		t.Errorf("no error for %d recipients", MAXRECIPIENTS+1)
+	}
+	if _, err := readRecipients(strings.NewReader(""), "test.csv"); err == nil {
+		t.Errorf("no error for a file without header row")
+	}
+}
+
+func Test_sanitizeText(t *testing.T) {
+	all := map[string]bool{"controls": true, "urls": true, "templates": true}
+	for _, tc := range []struct {
+		in       string
+		cleanups map[string]bool
+		maxLen   int
+		want     string
+	}{
+		{"Party\x07 at \u202eenoh", all, 0, "Party at enoh"},
+		{"Meet https://evil.example/x?y=1 or www.example.com now", all, 0, "Meet or now"},
+		{"Hi {{.Month}} {name} {not a var}", all, 0, "Hi ((.Month)) (name) {not a var}"},
+		{"Hi {name}\x07", nil, 0, "Hi {name}\x07"},
+		{"Summer party at the lake house", nil, 12, "Summer..."},
+		{"line one\nline two", map[string]bool{"controls": true}, 0, "line one\nline two"},
+	} {
+		if got := sanitizeText(tc.in, tc.cleanups, tc.maxLen); got != tc.want {
+			t.Errorf("sanitizeText(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	g := New(1, 12, 2026)
+	g.SetSanitize("all")
+	g.SetEventLength(12)
+	eL := g.sanitizeEvents([]gDate{{Month: 7, Day: 4, Text: "Party {year} http://x.org", Description: "See www.x.org"}})
+	if eL[0].Text != "Party (year)" || eL[0].Description != "See" {
+		t.Errorf("sanitizeEvents = %q, %q", eL[0].Text, eL[0].Description)
+	}
+}
+
+func Test_icsComponents(t *testing.T) {
+	cal := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nDUE;VALUE=DATE:20260310\r\nSUMMARY:File\r\n  taxes\r\nBEGIN:VALARM\r\nSUMMARY:Alarm\r\nEND:VALARM\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nDTSTART:20260316T090000\r\nDUE:20260317T120000Z\r\nstatus:completed\r\nSUMMARY:Passport\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nSUMMARY:Someday\r\nEND:VTODO\r\n" +
+		"BEGIN:VJOURNAL\r\nDTSTART;VALUE=DATE:20260320\r\nDUE:20260101\r\nSUMMARY:Spring\\, finally\r\nEND:VJOURNAL\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART:20260321\r\nSUMMARY:Event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	comps := icsComponents(cal, "VTODO", "VJOURNAL")
+	if len(comps) != 4 || comps[0].props["SUMMARY"] != "File taxes" || comps[3].name != "VJOURNAL" {
+		t.Errorf("icsComponents = %+v", comps)
+	}
+
+	g := New(3, 3, 2026)
+	g.AddFile("todos.ics", []byte(cal))
+	eL := g.icsReader().read("todos.ics", 2026, "", g.warnf)
+	var got []string
+	for _, ev := range eL {
+		got = append(got, fmt.Sprintf("%d/%d %s %s", ev.Month, ev.Day, ev.Kind, ev.Text))
+	}
+	if want := "[3/10 todo File taxes 3/17 done Passport 3/20 journal Spring, finally]"; fmt.Sprint(got) != want {
+		t.Errorf("read = %v, want %v", got, want)
+	}
+
+	if n := len(g.icsEvents(eL)); n != 0 {
+		t.Errorf("%d to-dos and journal entries without SetICSInclude", n)
+	}
+	g.SetICSInclude("todo")
+	g.SetICSColors("red", "gray")
+	if eL := g.icsEvents(eL); len(eL) != 2 || eL[0].Color != "red" || eL[1].Kind != "done" {
+		t.Errorf("icsEvents = %+v", eL)
+	}
+}
+
+func Test_icsStatus(t *testing.T) {
+	event := func(uid, summary, extra string) string {
+		return "BEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART;VALUE=DATE:20260601\r\nDTEND;VALUE=DATE:20260602\r\nSUMMARY:" + summary + "\r\n" + extra + "END:VEVENT\r\n"
+	}
+	g := New(6, 6, 2026)
+	g.AddFile("status.ics", []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"+
+		event("1", "Review", "STATUS:CONFIRMED\r\n")+
+		event("2", "Offsite", "STATUS:CANCELLED\r\n")+
+		event("3", "Lunch", "STATUS:TENTATIVE\r\n")+
+		event("4", "Declined", "TRANSP:TRANSPARENT\r\nBEGIN:VALARM\r\nSTATUS:CANCELLED\r\nEND:VALARM\r\n")+
+		"END:VCALENDAR\r\n"))
+	texts := func(eL []gDate) string {
+		var s []string
+		for _, ev := range eL {
+			s = append(s, ev.Text+" "+ev.Kind)
+		}
+		sort.Strings(s)
+		return strings.Join(s, ",")
+	}
+	r := g.icsReader()
+	for _, tc := range []struct{ transp, want string }{
+		{"", "Declined ,Lunch tentative,Review "},
+		{"busy", "Lunch tentative,Review "},
+		{"free", "Declined "},
+	} {
+		if got := texts(r.read("status.ics", 2026, tc.transp, g.warnf)); got != tc.want {
+			t.Errorf("read with transp '%s' = %s, want %s", tc.transp, got, tc.want)
+		}
+	}
+
+	eL := r.read("status.ics", 2026, "", g.warnf)
+	for _, tc := range []struct{ policy, want string }{
+		{"", "Declined ,Lunch ,Review "},
+		{"dim", "Declined ,Lunch tentative,Review "},
+		{"skip", "Declined ,Review "},
+	} {
+		g.SetICSTentative(tc.policy)
+		if got := texts(g.icsEvents(eL)); got != tc.want {
+			t.Errorf("icsEvents with tentative '%s' = %s, want %s", tc.policy, got, tc.want)
+		}
+	}
+}
+
+func Test_icsSource(t *testing.T) {
+	g := New(6, 6, 2026)
+	g.AddFile("work.ics", []byte("BEGIN:VCALENDAR\r\nX-WR-CALNAME:Work\\, Berlin\r\nX-APPLE-CALENDAR-COLOR:#1BADF8FF\r\n"+
+		"BEGIN:VEVENT\r\nUID:1\r\nDTSTART;VALUE=DATE:20260601\r\nDTEND;VALUE=DATE:20260602\r\nSUMMARY:Review\r\nX-WR-CALNAME:Event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	g.AddFile("plain.ics", []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:2\r\nDTSTART;VALUE=DATE:20260602\r\nDTEND;VALUE=DATE:20260603\r\nSUMMARY:Plain\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	g.AddFile("home.ics", []byte("BEGIN:VCALENDAR\r\nX-APPLE-CALENDAR-COLOR:#ff0000\r\nEND:VCALENDAR\r\n"))
+	g.AddICS("work.ics")
+	g.AddICS("plain.ics")
+	g.AddICS("https://example.org/cal/home.ics")
+	g.icsReader().fetch = func(url, accept string) ([]byte, error) { return g.readFile("home.ics") }
+	eL := g.getEventList()
+	if len(eL) != 2 || eL[0].Color != "#1BADF8" || eL[1].Color != "" {
+		t.Errorf("events = %+v", eL)
+	}
+	if got := fmt.Sprint(g.legendEntries()); got != "[{Work, Berlin #1BADF8} {home.ics #ff0000}]" {
+		t.Errorf("legendEntries = %s", got)
+	}
+}
+
+func Test_sortEvents(t *testing.T) {
+	eL := []gDate{
+		{6, 2, "Late", "", "", "b.ics", "", "", "", "", 0, ""},
+		{6, 1, "zoo", "", "", "b.ics", "", "", "", "", 0, "14:00"},
+		{6, 1, "Meeting", "", "", "a.ics", "", "", "", "", 0, "09:30"},
+		{6, 1, "apple", "", "", "https://example.org/b.ics", "", "", "", "", 0, ""},
+		{6, 1, "Deadline", "", "", "gocal.xml", "", "", "", "", 1, "17:00"},
+		{6, 1, "Someday", "", "", "https://openholidaysapi.org/PublicHolidays?countryIsoCode=DE", "", "", "", "", 9, ""},
+	}
+	sortEvents(eL)
+	var got []string
+	for _, ev := range eL {
+		got = append(got, ev.Text)
+	}
+	if s := strings.Join(got, ","); s != "Deadline,apple,Meeting,zoo,Someday,Late" {
+		t.Errorf("sortEvents = %s", s)
+	}
+
+	g := New(6, 6, 2026)
+	g.AddSourcePrefix("b.ics", "B:")
+	g.AddSourcePrefix("https://example.org/b.ics", "W:")
+	g.AddSourcePrefix("holidays", "*")
+	got = nil
+	for _, ev := range g.prefixEvents(eL) {
+		got = append(got, ev.Text)
+	}
+	if s := strings.Join(got, ","); s != "Deadline,W:apple,Meeting,B:zoo,*Someday,B:Late" {
+		t.Errorf("prefixEvents = %s", s)
+	}
+	if eL[1].Text != "apple" {
+		t.Errorf("prefixEvents changed the events")
+	}
+}
+
+func Test_quarters(t *testing.T) {
+	day := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		day        time.Time
+		firstMonth int
+		quarter    int
+		doq        int
+	}{
+		{day(2026, 1, 1), 1, 1, 1},
+		{day(2026, 3, 31), 1, 1, 90},
+		{day(2026, 4, 1), 1, 2, 1},
+		{day(2026, 12, 31), 1, 4, 92},
+		{day(2026, 3, 31), 4, 4, 90},
+		{day(2026, 1, 10), 11, 1, 71},
+		{day(2026, 2, 10), 11, 2, 10},
+	} {
+		q, _ := quarterOf(tc.day, tc.firstMonth)
+		if doq := dayOfQuarter(tc.day, tc.firstMonth); q != tc.quarter || doq != tc.doq {
+			t.Errorf("quarter of %v from %d = Q%d day %d, want Q%d day %d", tc.day, tc.firstMonth, q, doq, tc.quarter, tc.doq)
+		}
+	}
+
+	g := New(1, 12, 2026)
+	if g.dayNumber(day(2026, 4, 10)) != "100" || g.quarterStart(day(2026, 4, 1)) {
+		t.Errorf("day number without quarters")
+	}
+	g.SetQuarters(4)
+	if g.dayNumber(day(2026, 4, 10)) != "10" || !g.quarterStart(day(2026, 7, 1)) || g.quarterStart(day(2026, 8, 1)) {
+		t.Errorf("day number with quarters from April")
+	}
+
+	if _, _, ok := g.sprintOf(day(2026, 1, 5)); ok {
+		t.Errorf("sprint without sprints")
+	}
+	g.SetSprints("2026-01-05", 14)
+	for _, tc := range []struct {
+		day   time.Time
+		n     int
+		first bool
+		ok    bool
+	}{
+		{day(2026, 1, 4), 0, false, false},
+		{day(2026, 1, 5), 1, true, true},
+		{day(2026, 1, 18), 1, false, true},
+		{day(2026, 1, 19), 2, true, true},
+		{day(2026, 6, 22), 13, true, true},
+	} {
+		if n, first, ok := g.sprintOf(tc.day); n != tc.n || first != tc.first || ok != tc.ok {
+			t.Errorf("sprintOf(%v) = %d %v %v", tc.day, n, first, ok)
+		}
+	}
+}
+
+func Test_projects(t *testing.T) {
+	var pL []project
+	for _, p := range [][4]string{
+		{"B", "2026-03-10", "2026-03-20", ""},
+		{"A", "2026-03-01", "2026-03-10", "teal"},
+		{"C", "2026-03-11", "2026-03-31", ""},
+		{"D", "2026-03-15", "2026-03-16", ""},
+		{"E", "2026-03-15", "2026-03-16", ""},
+		{"F", "2026-03-16", "2026-03-16", ""},
+	} {
+		pr, err := parseProject(p[0], p[1], p[2], p[3])
+		if err != nil {
+			t.Fatalf("parseProject(%v) = %v", p, err)
+		}
+		pL = append(pL, pr)
+	}
+	var got []string
+	var warned []string
+	warn := func(kind string, format string, v ...interface{}) { warned = append(warned, fmt.Sprintf(format, v...)) }
+	for _, p := range assignLanes(pL, warn) {
+		got = append(got, fmt.Sprintf("%s%d", p.name, p.lane))
+	}
+	if s := strings.Join(got, ","); s != "A0,B1,C0,D2,E3" {
+		t.Errorf("assignLanes = %s", s)
+	}
+	if len(warned) != 1 || !strings.Contains(warned[0], "'F'") {
+		t.Errorf("assignLanes warnings = %v", warned)
+	}
+	if pL[0].color != "teal" || pL[1].color != PROJECTCOLOR {
+		t.Errorf("colors = %s %s", pL[0].color, pL[1].color)
+	}
+	for _, bad := range [][4]string{
+		{"X", "3/1", "2026-03-10", ""},
+		{"X", "2026-03-10", "2026-03-01", ""},
+		{"X", "2026-03-01", "2026-03-10", "nocolor"},
+	} {
+		if _, err := parseProject(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Errorf("parseProject(%v) accepted", bad)
+		}
+	}
+}
+
+func Test_bridgeDays(t *testing.T) {
+	eL := []gDate{
+		{time.May, 14, "Ascension Day", "", "", "", "", "holiday", "", "", 0, ""},      // Thursday
+		{time.December, 24, "Christmas Eve", "", "", "", "", "holiday", "", "", 0, ""}, // Thursday
+		{time.December, 25, "Christmas", "", "", "", "", "Holiday", "", "", 0, ""},     // Friday
+		{time.October, 2, "Party", "", "", "", "", "", "", "", 0, ""},                  // Friday, no holiday
+		{time.October, 1, "Autumn break", "", "", "https://openholidaysapi.org/SchoolHolidays?x", "", "", "", "", 0, ""},
+		{time.June, 3, "Day off", "", "", "https://openholidaysapi.org/PublicHolidays?x", "", "", "", "", 0, ""}, // Wednesday
+		{time.June, 5, "Day off", "", "", "https://openholidaysapi.org/PublicHolidays?x", "", "", "", "", 0, ""}, // Friday
+	}
+	var got []string
+	for _, d := range bridgeDays(eL, 2026) {
+		got = append(got, d.Format("01-02"))
+	}
+	if s := strings.Join(got, ","); s != "05-15,06-04" {
+		t.Errorf("bridgeDays = %s", s)
+	}
+
+	g := New(1, 12, 2026)
+	if len(g.bridgeEvents(eL)) != 0 {
+		t.Errorf("bridgeEvents without the option")
+	}
+	g.SetBridgeDays("Bridge day")
+	bL := g.bridgeEvents(eL)
+	if len(bL) != 2 || bL[0].Text != "Bridge day" || !hasBridgeDay(bL[1:]) || hasBridgeDay(eL) {
+		t.Errorf("bridgeEvents = %v", bL)
+	}
+}
+
+func Test_vacationDays(t *testing.T) {
+	var eL []gDate
+	for d := 20; d <= 31; d++ { // Monday, July 20 to Friday, July 31
+		eL = append(eL, gDate{time.July, d, "Vacation", "", "", "", "", "vacation", "", "", 0, ""})
+	}
+	eL = append(eL,
+		gDate{time.July, 24, "Local holiday", "", "", "", "", "holiday", "", "", 0, ""},
+		gDate{time.March, 3, "Day off", "", "", "", "", "Vacation", "", "", 0, ""},
+		gDate{time.March, 4, "Meeting", "", "", "", "", "", "", "", 0, ""},
+	)
+	used := vacationDays(eL, 2026)
+	if used[2] != 0 || used[3] != 1 || used[6] != 1 || used[7] != 10 || used[12] != 10 {
+		t.Errorf("vacationDays = %v", used)
+	}
+
+	g := New(1, 12, 2026)
+	g.SetVacation(30)
+	if got := g.vacationText(12); got != "12/30 days used" {
+		t.Errorf("vacationText = %s", got)
+	}
+	g.SetVacationText("{left} left")
+	if got := g.vacationText(12); got != "18 left" {
+		t.Errorf("vacationText = %s", got)
+	}
+}
+
+func Test_parseRuling(t *testing.T) {
+	tests := []struct {
+		spec string
+		want ruling
+	}{
+		{"blank", ruling{"blank", 0, 0}},
+		{"ruled", ruling{"ruled", RULEDLINESPACING, RULINGWEIGHT}},
+		{"Dots:4", ruling{"dots", 4, DOTWEIGHT}},
+		{"squared:5:0.1", ruling{"squared", 5, 0.1}},
+		{"squared::0.3", ruling{"squared", SQUARESPACING, 0.3}},
+	}
+	for _, test := range tests {
+		got, err := parseRuling(test.spec)
+		if err != nil || got != test.want {
+			t.Errorf("parseRuling(%s) = %v, %v, want %v", test.spec, got, err, test.want)
+		}
+	}
+	for _, spec := range []string{"lines", "ruled:x", "dots:-1", "squared:5:0.1:2", "sudoku"} {
+		if _, err := parseRuling(spec); err == nil {
+			t.Errorf("parseRuling(%s) expected an error", spec)
+		}
+	}
+}
+
+func Test_applyWritable(t *testing.T) {
+	g := New(1, 12, 2026)
+	g.SetDayNumberPos("C")
+	g.applyWritable()
+	if !g.OptHideMoon || !g.OptHideDOY || g.OptDayNumberSize != WRITABLEDAYSIZE || g.OptDayNumberPos != "TL" || g.OptGridWidth != WRITABLEGRIDWIDTH {
+		t.Errorf("applyWritable = %v %v %v %s %v", g.OptHideMoon, g.OptHideDOY, g.OptDayNumberSize, g.OptDayNumberPos, g.OptGridWidth)
+	}
+
+	g = New(1, 12, 2026)
+	g.SetDayNumberPos("TR")
+	g.SetDayNumberSize(14)
+	g.SetGridWidth(0.3)
+	g.applyWritable()
+	if g.OptDayNumberSize != 14 || g.OptDayNumberPos != "TR" || g.OptGridWidth != 0.3 {
+		t.Errorf("applyWritable overrides %v %s %v", g.OptDayNumberSize, g.OptDayNumberPos, g.OptGridWidth)
+	}
+}
+
+func Test_perpetualEvents(t *testing.T) {
+	if perpetualDays(time.February) != 29 || perpetualDays(time.April) != 30 || perpetualDays(time.December) != 31 {
+		t.Errorf("perpetualDays")
+	}
+	g := New(1, 12, 2027)
+	g.AddEvent(29, 2, "Leap day", "")
+	g.AddEvent(14, 2, "Anniversary", "")
+	g.EventList = append(g.EventList, gDate{0, 0, "Weekly", "Monday", "", "", "", "", "", "", 0, ""})
+	eL := g.perpetualEvents()
+	if len(eL) != 2 || eL[0].Text != "Anniversary" || eL[1].Text != "Leap day" {
+		t.Errorf("perpetualEvents = %v", eL)
+	}
+}
+
+func Test_checkGrid(t *testing.T) {
+	for year := 1900; year <= 2100; year++ {
+		for month := 1; month <= 12; month++ {
+			m, err := monthGrid(year, month)
+			if err != nil {
+				t.Fatalf("monthGrid(%d, %d): %v", year, month, err)
+			}
+			first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			if columnWeekday(m.FirstColumn) != first.Weekday() {
+				t.Errorf("%s in column %d", first.Format("2006-01"), m.FirstColumn)
+			}
+		}
+	}
+	m, _ := monthGrid(2021, 2)
+	if m.FirstColumn != 0 || m.Rows != 4 || m.Weekdays[time.Monday] != 4 {
+		t.Errorf("monthGrid(2021, 2) = %+v", m)
+	}
+	m, _ = monthGrid(2026, 3)
+	if m.FirstColumn != 6 || m.Rows != 6 || m.Weekdays[time.Sunday] != 5 || m.Weekdays[time.Thursday] != 4 {
+		t.Errorf("monthGrid(2026, 3) = %+v", m)
+	}
+
+	g := New(1, 12, 2026)
+	var buf bytes.Buffer
+	if err := g.WriteGridReport(&buf); err != nil {
+		t.Errorf("WriteGridReport: %v", err)
+	}
+	if !strings.Contains(buf.String(), "  2026-01  Thu    4     5   4  4  4  5  5  5  4\n") {
+		t.Errorf("WriteGridReport = %s", buf.String())
+	}
+}
+
+func Test_moonPhaseLabels(t *testing.T) {
+	g := New(1, 12, 2026)
+	if l := g.moonPhaseLabels("de_DE"); l["Full"] != "Vollmond" || l["First"] != "Erstes Viertel" {
+		t.Errorf("moonPhaseLabels(de_DE) = %v", l)
+	}
+	if l := g.moonPhaseLabels("ja_JP"); l["New"] != "New moon" || l["Last"] != "Last quarter" {
+		t.Errorf("moonPhaseLabels(ja_JP) = %v", l)
+	}
+	if l := g.moonPhaseLabels("da_DK"); l["New"] != convertCP("Nymåne") {
+		t.Errorf("moonPhaseLabels(da_DK) = %v", l)
+	}
+
+	f, err := ioutil.TempFile("", "names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("Full moon = FM\nJanuary = Xaneiro\n")
+	f.Close()
+	g.SetNames(f.Name())
+	if l := g.moonPhaseLabels("fr_FR"); l["Full"] != "FM" || l["New"] != "Nouvelle lune" {
+		t.Errorf("moonPhaseLabels with names = %v", l)
+	}
+
+	g.SetMoonLabels("names")
+	if !g.moonSymbols() || !g.moonNames() {
+		t.Errorf("SetMoonLabels(names)")
+	}
+	g.SetMoonLabels("text")
+	g.SetMoonLabels("bogus")
+	if g.moonSymbols() || !g.moonNames() {
+		t.Errorf("SetMoonLabels(text)")
+	}
+}
+
+func Test_parseEphemeris(t *testing.T) {
+	csvData := `date,kind,text,time
+# comment
+2026-08-12,meteor,Perseids maximum
+2026-03-05, ISS ,ISS pass,19:42
+2025-12-14,meteor,Geminids
+2026-13-01,comet,Bad date
+2026-05-01,comet,Bad time,25:00
+2026-06-01,planet
+`
+	var warned []string
+	warn := func(kind string, format string, v ...interface{}) { warned = append(warned, kind) }
+	eL, err := parseEphemeris([]byte(csvData), "sky.csv", 2026, warn)
+	if err != nil || len(eL) != 2 {
+		t.Fatalf("parseEphemeris csv = %v, %v", eL, err)
+	}
+	if eL[0].kind != "meteor" || eL[0].at != "" || eL[1].kind != "iss" || eL[1].at != "19:42" || eL[1].day.Day() != 5 {
+		t.Errorf("parseEphemeris csv = %v", eL)
+	}
+	if strings.Join(warned, ",") != "field,date,date" {
+		t.Errorf("parseEphemeris csv warnings = %v", warned)
+	}
+
+	jsonData := `[{"date": "2026-01-10", "kind": "opposition", "text": "Jupiter at opposition"},
+	{"date": "2027-01-10", "kind": "opposition", "text": "Next year"}]`
+	eL, err = parseEphemeris([]byte(jsonData), "sky.JSON", 2026, warn)
+	if err != nil || len(eL) != 1 || eL[0].text != "Jupiter at opposition" {
+		t.Errorf("parseEphemeris json = %v, %v", eL, err)
+	}
+	if _, err := parseEphemeris([]byte("{"), "sky.json", 2026, warn); err == nil {
+		t.Errorf("parseEphemeris expected an error")
+	}
+
+	g := New(1, 12, 2026)
+	g.AddFile("test-sky.csv", []byte(csvData))
+	g.AddEphemeris("test-sky.csv")
+	g.SetAstroIcons("iss=S, meteor=meteor.png")
+	out := g.ephemerisEvents()
+	if len(out) != 2 || out[1].Text != "ISS pass 19:42" || out[1].Category != "astronomy" || out[1].Time != "19:42" {
+		t.Errorf("ephemerisEvents = %v", out)
+	}
+	kinds := g.ephemerisKinds(time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC))
+	if len(kinds) != 1 || kinds[0] != "iss" || g.astroIcon("iss") != "S" || g.astroIcon("meteor") != "meteor.png" || g.astroIcon("comet") != "" {
+		t.Errorf("ephemerisKinds = %v", kinds)
+	}
+}
+
+func Test_moonPlace(t *testing.T) {
+	for _, tt := range []struct {
+		in       string
+		v        float64
+		relative bool
+		ok       bool
+	}{
+		{"", 0, false, true},
+		{"5", 5, false, true},
+		{"6.5mm", 6.5, false, true},
+		{"20%", 20, true, true},
+		{"0", 0, false, false},
+		{"150%", 0, false, false},
+		{"big", 0, false, false},
+	} {
+		v, relative, err := parseMoonSize(tt.in)
+		if v != tt.v || relative != tt.relative || (err == nil) != tt.ok {
+			t.Errorf("parseMoonSize(%s) = %v, %v, %v", tt.in, v, relative, err)
+		}
+	}
+
+	g := New(1, 12, 2026)
+	cell := rect{10, 20, 40, 30}
+	if x, y, r := g.moonPlace(cell, moonAnchors["TR"], MOONSIZE); x != 10+40*0.82 || y != 20+30*0.2 || r != MOONSIZE {
+		t.Errorf("moonPlace default = %v %v %v", x, y, r)
+	}
+	g.SetMoonAnchor("c")
+	g.SetMoonSize("20%")
+	if x, y, r := g.moonPlace(cell, moonAnchors["TR"], MOONSIZE); x != 30 || y != 35 || r != 3 {
+		t.Errorf("moonPlace C 20%% = %v %v %v", x, y, r)
+	}
+	g.SetMoonAnchor("middle")
+	g.SetMoonSize("5mm")
+	g.SetMoonOpacity(1.5)
+	if x, _, r := g.moonPlace(cell, moonAnchors["TR"], MOONSIZE); x != 30 || r != 2.5 || g.OptMoonOpacity != 1 {
+		t.Errorf("moonPlace 5mm = %v %v", x, r)
+	}
+}
+
+func Test_moonPhasesBetween(t *testing.T) {
+	year := make(map[string]string)
+	computeMoonphasesYear(year, 2026)
+	g := New(3, 3, 2026)
+	from, to := g.astroRange()
+	if from.Format("2006-01-02") != "2026-02-23" || to.Format("2006-01-02") != "2026-04-05" {
+		t.Errorf("astroRange = %v, %v", from, to)
+	}
+	got := moonPhasesBetween(meeusAstronomy{}, from, to)
+	want := 0
+	for k, v := range year {
+		if k >= "2026-02-23" && k <= "2026-04-05" {
+			want++
+			if got[k] != v {
+				t.Errorf("moonPhasesBetween[%s] = %q, want %q", k, got[k], v)
+			}
+		}
+	}
+	if want < 5 || len(got) != want {
+		t.Errorf("moonPhasesBetween = %d phases, want %d", len(got), want)
+	}
+	g = New(12, 12, 2026)
+	if from, to = g.astroRange(); to.Format("2006-01-02") != "2026-12-31" {
+		t.Errorf("astroRange = %v, %v", from, to)
+	}
+
+	// An Astronomy without ranges is cut to the days.
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if m := moonPhasesBetween(fakeAstronomy{}, jan, jan.AddDate(0, 1, 0)); len(m) != 1 || m["2025-01-01"] != "Full" {
+		t.Errorf("moonPhasesBetween = %v", m)
+	}
+	if m := moonPhasesBetween(fakeAstronomy{}, jan.AddDate(0, 0, 1), jan.AddDate(0, 1, 0)); len(m) != 0 {
+		t.Errorf("moonPhasesBetween = %v", m)
+	}
+	if eL := shabbatEvents(fakeAstronomy{}, from, to, 0, 0, CANDLELIGHTING, HAVDALAH, time.UTC); len(eL) != 8 {
+		t.Errorf("shabbatEvents = %d events", len(eL))
+	}
+}
+
+func BenchmarkMoonphasesMonth(b *testing.B) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < b.N; i++ {
+		computeMoonphasesRange(make(map[string]string), from, from.AddDate(0, 1, 0))
+	}
+}
+
+func Test_companionICS(t *testing.T) {
+	if got := companionFilename("out/cal-2025.pdf"); got != "out/cal-2025.ics" {
+		t.Errorf("companionFilename = %q", got)
+	}
+	g := New(3, 3, 2025)
+	g.SetAstronomy(fakeAstronomy{})
+	g.AddEvent(14, 3, "Pi day", "")
+	g.EventList[len(g.EventList)-1].Image = "pi day.png"
+	g.SetArtworkURL("https://example.org/cal/")
+	eL := g.companionEvents()
+	if len(eL) != 2 || eL[0].Text != "Pi day" || eL[1].Text != "March equinox" {
+		t.Errorf("companionEvents = %v", eL)
+	}
+	g.SetAstroEvents() // the seasons only once
+	if eL = g.companionEvents(); len(eL) != 2 {
+		t.Errorf("companionEvents = %v", eL)
+	}
+	g.OptAstroEvents = false
+	g.SetAstronomy(nil)
+	eL = g.companionEvents()
+	var b bytes.Buffer
+	g.writeICS(&b, eL, "out/cal-2025.pdf")
+	s := b.String()
+	for _, want := range []string{
+		"X-WR-CALNAME:cal-2025\r\n",
+		"SUMMARY:Full moon\r\nCATEGORIES:moon\r\nURL:https://example.org/cal/cal-2025.pdf\r\n",
+		"SUMMARY:Pi day\r\nURL:https://example.org/cal/cal-2025.pdf\r\nIMAGE;VALUE=URI;DISPLAY=BADGE:https://example.org/cal/pi%20day.png\r\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("writeICS lacks %q in\n%s", want, s)
+		}
+	}
+	if n := strings.Count(s, "BEGIN:VEVENT"); n != 6 {
+		t.Errorf("writeICS wrote %d events, want 6", n)
+	}
+	g.SetArtworkURL("")
+	if link := g.artworkLink("https://example.org/x.png"); link != "https://example.org/x.png" {
+		t.Errorf("artworkLink = %q", link)
+	}
+	if link := g.artworkLink("x.png"); !strings.HasPrefix(link, "file://") || !strings.HasSuffix(link, "/x.png") {
+		t.Errorf("artworkLink = %q", link)
+	}
+	g.SetHideMoon()
+	if eL := g.moonEvents(); eL != nil {
+		t.Errorf("moonEvents = %v", eL)
+	}
+	g.writeCompanionICS(STDIN)
+	if _, err := os.Stat("-.ics"); err == nil {
+		os.Remove("-.ics")
+		t.Error("writeCompanionICS wrote -.ics for standard output")
+	}
+	if got := icsEscaper.Replace("a\r\nb\rc;d"); got != `a\nb\nc\;d` {
+		t.Errorf("icsEscaper = %q", got)
+	}
+}
+
+// testVariableFont builds a variable font with a weight axis from
+// 100 to 900, the instances Regular and Bold and a square glyph whose
+// top right corner and advance grow with the weight.
+func testVariableFont() []byte {
+	be := func(v ...int) (b []byte) {
+		for _, x := range v {
+			b = append(b, byte(x>>8), byte(x))
+		}
+		return b
+	}
+	square := (&glyph{endPts: []int{3}, flags: []byte{1, 1, 1, 1}, x: []int{0, 0, 100, 100}, y: []int{0, 100, 100, 0}, bbox: [4]int{0, 0, 100, 100}}).encode()
+	if len(square)%2 != 0 {
+		square = append(square, 0)
+	}
+	head := make([]byte, 54)
+	copy(head, be(1, 0, 1, 0))
+	copy(head[18:], be(1000))
+	hhea := make([]byte, 36)
+	copy(hhea, be(1, 0))
+	copy(hhea[34:], be(2))
+	name := be(0, 2, 30, 3, 1, 0x409, 257, 14, 0, 3, 1, 0x409, 258, 8, 14)
+	for _, r := range "RegularBold" {
+		name = append(name, be(int(r))...)
+	}
+	fvar := append(be(1, 0, 16, 2, 1, 20, 2, 8), []byte("wght")...)
+	fvar = append(fvar, be(100, 0, 400, 0, 900, 0, 0, 256)...)
+	fvar = append(fvar, be(257, 0, 400, 0, 258, 0, 700, 0)...)
+	// One tuple at wght=1 for the points 0, 2 and 5 (the advance)
+	tuple := []byte{3, 2, 0, 2, 3, 2, 0, 50, 100, 0x82}
+	gvar := append(be(1, 0, 1, 0, 0, 26, 2, 0, 0, 26, 0, 0, 10), be(1, 10, len(tuple), 0xA000, 16384)...)
+	gvar = append(gvar, tuple...)
+	font := &sfnt{0x00010000, map[string][]byte{
+		"head": head, "hhea": hhea, "maxp": be(0, 0x5000, 2),
+		"hmtx": be(500, 0, 200, 0), "loca": be(0, 0, len(square)/2),
+		"glyf": square, "name": name, "fvar": fvar, "gvar": gvar,
+	}}
+	return font.bytes()
+}
+
+func Test_fontInstance(t *testing.T) {
+	dir, err := os.MkdirTemp("", "gocal-fonts-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "Test[wght].ttf")
+	ioutil.WriteFile(file, testVariableFont(), 0600)
+	g := New(1, 1, 2025)
+	if _, ok := g.fontInstance(file, "italic", dir); ok {
+		t.Error("fontInstance: italic instance")
+	}
+	bold, ok := g.fontInstance(file, "bold", dir)
+	if !ok || filepath.Base(bold) != "Testwght-Bold.ttf" {
+		t.Fatalf("fontInstance = %q, %v", bold, ok)
+	}
+	data, _ := ioutil.ReadFile(bold)
+	font, err := parseSfnt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := font.tables["gvar"]; ok || isVariableFont(bold) {
+		t.Error("fontInstance: variation tables kept")
+	}
+	// At wght 700 the tuple counts 0.6; the untouched point 3 follows
+	// point 2, point 1 stays with point 0.
+	loca := font.tables["loca"]
+	glyphData := font.tables["glyf"][binary.BigEndian.Uint32(loca[4:]):binary.BigEndian.Uint32(loca[8:])]
+	gl, err := decodeGlyph(glyphData)
+	if err != nil || fmt.Sprint(gl.x, gl.y, gl.bbox) != "[0 0 130 130] [0 100 100 0] [0 0 130 100]" {
+		t.Errorf("glyph = %v %v %v, %v", gl.x, gl.y, gl.bbox, err)
+	}
+	if advance := binary.BigEndian.Uint16(font.tables["hmtx"][4:]); advance != 260 {
+		t.Errorf("advance = %d", advance)
+	}
+	if format := binary.BigEndian.Uint16(font.tables["head"][50:]); format != 1 {
+		t.Errorf("indexToLocFormat = %d", format)
+	}
+}
+
+func Test_styleFontFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "gocal-fonts-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"Roboto-Bold.ttf", "Roboto-Italic.ttf", "Plain.ttf", "Plain-BoldItalic.ttf"} {
+		ioutil.WriteFile(filepath.Join(dir, f), nil, 0600)
+	}
+	tests := []struct {
+		file, style, want string
+	}{
+		{"Roboto-Bold.ttf", "italic", "Roboto-Italic.ttf"},
+		{"Roboto-Italic.ttf", "bold", "Roboto-Bold.ttf"},
+		{"Plain.ttf", "bolditalic", "Plain-BoldItalic.ttf"},
+		{"Roboto-Bold.ttf", "regular", ""},
+	}
+	for _, tt := range tests {
+		got, ok := styleFontFile(filepath.Join(dir, tt.file), tt.style)
+		if tt.want == "" && ok || tt.want != "" && got != filepath.Join(dir, tt.want) {
+			t.Errorf("styleFontFile(%q, %q) = %q, %v", tt.file, tt.style, got, ok)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// vacation.go
+//
+// The vacation budget: the planned vacation days of the year, counted
+// up to the end of each month against the allowance, in the footer.
+//
+
+import (
+	"fmt"
+	"github.com/phpdave11/gofpdf"
+	"strings"
+	"time"
+)
+
+// VACATIONTEXT is the default text of the vacation budget.
+const VACATIONTEXT = "{used}/{allowance} days used"
+
+// SetVacation counts the vacation days, the days of events of the
+// category vacation, against the allowance in days and prints the
+// total up to the end of each month in its footer.
+func (g *Calendar) SetVacation(allowance int) {
+	g.OptVacation = allowance
+}
+
+// SetVacationText sets the text of the vacation budget, with the
+// variables {used}, {allowance} and {left}.
+func (g *Calendar) SetVacationText(text string) {
+	g.OptVacationText = text
+}
+
+// vacationDays returns the number of vacation days of the year up to
+// the end of each month. Weekends and public holidays don't count.
+func vacationDays(eL []gDate, year int) (used [13]int) {
+	var vacation, holidays []gDate
+	for _, ev := range eL {
+		switch {
+		case strings.EqualFold(ev.Category, "vacation"):
+			vacation = append(vacation, ev)
+		case isPublicHoliday(ev):
+			holidays = append(holidays, ev)
+		}
+	}
+	matches := func(eL []gDate, d time.Time) bool {
+		for _, ev := range eL {
+			if dateMatches(ev, d) {
+				return true
+			}
+		}
+		return false
+	}
+	n := 0
+	for d := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday &&
+			matches(vacation, d) && !matches(holidays, d) {
+			n++
+		}
+		used[d.Month()] = n
+	}
+	return used
+}
+
+// vacationText returns the text of the vacation budget with used days.
+func (g *Calendar) vacationText(used int) string {
+	text := g.OptVacationText
+	if text == "" {
+		text = VACATIONTEXT
+	}
+	return convertCP(strings.NewReplacer(
+		"{used}", fmt.Sprintf("%d", used),
+		"{allowance}", fmt.Sprintf("%d", g.OptVacation),
+		"{left}", fmt.Sprintf("%d", g.OptVacation-used),
+	).Replace(text))
+}
+
+// drawVacation prints the vacation budget right aligned at x on the
+// line of the footer, in red if the allowance is exceeded.
+func (g *Calendar) drawVacation(pdf *gofpdf.Fpdf, used int, x float64, y float64) {
+	if g.OptVacation <= 0 {
+		return
+	}
+	text := g.vacationText(used)
+	if used > g.OptVacation && !g.OptNocolor {
+		r, gr, b := pdf.GetTextColor()
+		defer pdf.SetTextColor(r, gr, b)
+		pdf.SetTextColor(255, 0, 0) // RED
+	}
+	pdf.Text(x-pdf.GetStringWidth(text), y, text)
+}
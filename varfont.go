@@ -0,0 +1,955 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// varfont.go
+//
+// Named instances of variable TrueType fonts, e.g. the Bold of
+// Roboto[wght].ttf, written as static fonts for gofpdf.
+//
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// errFontData is returned for truncated or inconsistent font tables.
+var errFontData = errors.New("invalid font data")
+
+// droppedTables are the variation tables and the device metrics
+// that an instance doesn't keep.
+var droppedTables = []string{"fvar", "gvar", "avar", "cvar", "HVAR", "VVAR", "MVAR", "STAT", "hdmx", "LTSH", "VDMX"}
+
+// fontData reads big-endian values and remembers the first error.
+type fontData struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *fontData) next(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.b) {
+		r.err = errFontData
+		return make([]byte, n)
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *fontData) u8() uint8   { return r.next(1)[0] }
+func (r *fontData) u16() uint16 { return binary.BigEndian.Uint16(r.next(2)) }
+func (r *fontData) i16() int16  { return int16(r.u16()) }
+func (r *fontData) u32() uint32 { return binary.BigEndian.Uint32(r.next(4)) }
+
+// f2dot14 reads a signed 2.14 fixed point number.
+func (r *fontData) f2dot14() float64 { return float64(r.i16()) / 16384 }
+
+// fixed reads a signed 16.16 fixed point number.
+func (r *fontData) fixed() float64 { return float64(int32(r.u32())) / 65536 }
+
+// sfnt is a TrueType font as its tables.
+type sfnt struct {
+	version uint32
+	tables  map[string][]byte
+}
+
+// parseSfnt splits a TrueType font into its tables.
+func parseSfnt(data []byte) (*sfnt, error) {
+	r := &fontData{b: data}
+	f := &sfnt{version: r.u32(), tables: make(map[string][]byte)}
+	n := int(r.u16())
+	r.next(6)
+	for i := 0; i < n && r.err == nil; i++ {
+		tag := string(r.next(4))
+		r.u32()
+		offset, length := int(r.u32()), int(r.u32())
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errFontData
+		}
+		f.tables[tag] = data[offset : offset+length]
+	}
+	return f, r.err
+}
+
+// bytes returns the font file with the tables sorted by tag.
+func (f *sfnt) bytes() []byte {
+	tags := make([]string, 0, len(f.tables))
+	for tag := range f.tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	out := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(out[0:], f.version)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*n-searchRange))
+	headAt := -1
+	for i, tag := range tags {
+		data := f.tables[tag]
+		if tag == "head" && len(data) >= 12 {
+			data = append([]byte(nil), data...)
+			binary.BigEndian.PutUint32(data[8:], 0)
+			headAt = len(out)
+		}
+		record := out[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], tableChecksum(data))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(data)))
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	if headAt >= 0 {
+		binary.BigEndian.PutUint32(out[headAt+8:], 0xB1B0AFBA-tableChecksum(out))
+	}
+	return out
+}
+
+// tableChecksum is the sum of the table as 32-bit words.
+func tableChecksum(data []byte) (sum uint32) {
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// nameString returns the name with the ID from the name table,
+// preferring the Windows names.
+func (f *sfnt) nameString(id uint16) string {
+	data := f.tables["name"]
+	r := &fontData{b: data}
+	r.u16()
+	count := int(r.u16())
+	storage := int(r.u16())
+	var mac string
+	for i := 0; i < count && r.err == nil; i++ {
+		platform, _, _, nameID := r.u16(), r.u16(), r.u16(), r.u16()
+		length, offset := int(r.u16()), int(r.u16())
+		if nameID != id || storage+offset+length > len(data) {
+			continue
+		}
+		raw := data[storage+offset : storage+offset+length]
+		switch platform {
+		case 0, 3:
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[2*j:])
+			}
+			return string(utf16.Decode(units))
+		case 1:
+			if mac == "" {
+				runes := make([]rune, len(raw))
+				for j, c := range raw {
+					runes[j] = rune(c)
+				}
+				mac = string(runes)
+			}
+		}
+	}
+	return mac
+}
+
+type fontAxis struct {
+	tag           string
+	min, def, max float64
+}
+
+type fontInstance struct {
+	name   string
+	coords []float64
+}
+
+// variations returns the axes and the named instances of the fvar table.
+func (f *sfnt) variations() (axes []fontAxis, instances []fontInstance, err error) {
+	r := &fontData{b: f.tables["fvar"]}
+	r.u32()
+	axesOffset := int(r.u16())
+	r.u16()
+	axisCount, axisSize := int(r.u16()), int(r.u16())
+	instanceCount, instanceSize := int(r.u16()), int(r.u16())
+	if r.err != nil || axisSize < 20 || instanceSize < 4+4*axisCount {
+		return nil, nil, errFontData
+	}
+	for i := 0; i < axisCount; i++ {
+		r.pos = axesOffset + i*axisSize
+		axes = append(axes, fontAxis{string(r.next(4)), r.fixed(), r.fixed(), r.fixed()})
+	}
+	for i := 0; i < instanceCount; i++ {
+		r.pos = axesOffset + axisCount*axisSize + i*instanceSize
+		nameID := r.u16()
+		r.u16()
+		in := fontInstance{f.nameString(nameID), make([]float64, axisCount)}
+		for j := range in.coords {
+			in.coords[j] = r.fixed()
+		}
+		instances = append(instances, in)
+	}
+	return axes, instances, r.err
+}
+
+// normalizeCoords maps the user coordinates of the axes to -1..1,
+// through the avar table if there is one.
+func (f *sfnt) normalizeCoords(axes []fontAxis, coords []float64) []float64 {
+	norm := make([]float64, len(axes))
+	for i, a := range axes {
+		v := math.Max(a.min, math.Min(a.max, coords[i]))
+		switch {
+		case v < a.def && a.def > a.min:
+			norm[i] = (v - a.def) / (a.def - a.min)
+		case v > a.def && a.max > a.def:
+			norm[i] = (v - a.def) / (a.max - a.def)
+		}
+	}
+	avar, ok := f.tables["avar"]
+	if !ok {
+		return norm
+	}
+	r := &fontData{b: avar}
+	r.next(6)
+	count := int(r.u16())
+	for i := 0; i < count && i < len(norm) && r.err == nil; i++ {
+		maps := make([][2]float64, r.u16())
+		for j := range maps {
+			maps[j] = [2]float64{r.f2dot14(), r.f2dot14()}
+		}
+		for j := 1; j < len(maps); j++ {
+			from, to := maps[j-1], maps[j]
+			if norm[i] <= to[0] && to[0] > from[0] {
+				norm[i] = from[1] + (norm[i]-from[0])*(to[1]-from[1])/(to[0]-from[0])
+				break
+			}
+		}
+	}
+	return norm
+}
+
+// glyph is a simple glyph or, with components, a composite one.
+type glyph struct {
+	endPts       []int
+	flags        []byte
+	x, y         []int
+	components   []glyphComponent
+	instructions []byte
+	composite    bool
+	hasInstr     bool
+	bbox         [4]int
+}
+
+type glyphComponent struct {
+	flags     uint16
+	index     uint16
+	arg1      int
+	arg2      int
+	transform []float64
+}
+
+const (
+	argWords      = 0x0001
+	argsAreXY     = 0x0002
+	haveScale     = 0x0008
+	moreComps     = 0x0020
+	haveXYScale   = 0x0040
+	haveTwoByTwo  = 0x0080
+	haveInstrs    = 0x0100
+	onCurve       = 0x01
+	xShort        = 0x02
+	yShort        = 0x04
+	repeatFlag    = 0x08
+	xSameOrPos    = 0x10
+	ySameOrPos    = 0x20
+	keptFlagsMask = 0x41
+)
+
+// decodeGlyph decodes a glyph of the glyf table.
+func decodeGlyph(data []byte) (*glyph, error) {
+	g := &glyph{}
+	if len(data) == 0 {
+		return g, nil
+	}
+	r := &fontData{b: data}
+	contours := int(r.i16())
+	for i := range g.bbox {
+		g.bbox[i] = int(r.i16())
+	}
+	if contours < 0 {
+		g.composite = true
+		for more := true; more && r.err == nil; {
+			c := glyphComponent{flags: r.u16(), index: r.u16()}
+			switch {
+			case c.flags&argWords != 0 && c.flags&argsAreXY != 0:
+				c.arg1, c.arg2 = int(r.i16()), int(r.i16())
+			case c.flags&argWords != 0:
+				c.arg1, c.arg2 = int(r.u16()), int(r.u16())
+			case c.flags&argsAreXY != 0:
+				c.arg1, c.arg2 = int(int8(r.u8())), int(int8(r.u8()))
+			default:
+				c.arg1, c.arg2 = int(r.u8()), int(r.u8())
+			}
+			switch {
+			case c.flags&haveScale != 0:
+				s := r.f2dot14()
+				c.transform = []float64{s, 0, 0, s}
+			case c.flags&haveXYScale != 0:
+				c.transform = []float64{r.f2dot14(), 0, 0, r.f2dot14()}
+			case c.flags&haveTwoByTwo != 0:
+				c.transform = []float64{r.f2dot14(), r.f2dot14(), r.f2dot14(), r.f2dot14()}
+			}
+			g.hasInstr = g.hasInstr || c.flags&haveInstrs != 0
+			more = c.flags&moreComps != 0
+			g.components = append(g.components, c)
+		}
+		if g.hasInstr {
+			g.instructions = r.next(int(r.u16()))
+		}
+		return g, r.err
+	}
+
+	g.endPts = make([]int, contours)
+	for i := range g.endPts {
+		g.endPts[i] = int(r.u16())
+	}
+	g.instructions = r.next(int(r.u16()))
+	points := 0
+	if contours > 0 {
+		points = g.endPts[contours-1] + 1
+	}
+	if points > len(data) {
+		return nil, errFontData
+	}
+	g.flags = make([]byte, 0, points)
+	for len(g.flags) < points && r.err == nil {
+		flag := r.u8()
+		g.flags = append(g.flags, flag)
+		if flag&repeatFlag != 0 {
+			for n := r.u8(); n > 0 && len(g.flags) < points; n-- {
+				g.flags = append(g.flags, flag)
+			}
+		}
+	}
+	g.x = readCoords(r, g.flags, xShort, xSameOrPos)
+	g.y = readCoords(r, g.flags, yShort, ySameOrPos)
+	return g, r.err
+}
+
+// readCoords reads the x or y coordinates of a simple glyph.
+func readCoords(r *fontData, flags []byte, short, sameOrPos byte) []int {
+	coords := make([]int, len(flags))
+	v := 0
+	for i, flag := range flags {
+		switch {
+		case flag&short != 0 && flag&sameOrPos != 0:
+			v += int(r.u8())
+		case flag&short != 0:
+			v -= int(r.u8())
+		case flag&sameOrPos == 0:
+			v += int(r.i16())
+		}
+		coords[i] = v
+	}
+	return coords
+}
+
+// encode returns the glyph for the glyf table.
+func (g *glyph) encode() []byte {
+	var out []byte
+	put16 := func(v int) { out = append(out, byte(uint16(v)>>8), byte(v)) }
+	if g.composite {
+		put16(-1)
+	} else if len(g.endPts) == 0 {
+		return nil
+	} else {
+		put16(len(g.endPts))
+	}
+	for _, v := range g.bbox {
+		put16(v)
+	}
+	if g.composite {
+		for i, c := range g.components {
+			flags := c.flags &^ (argWords | moreComps | haveInstrs)
+			if i < len(g.components)-1 {
+				flags |= moreComps
+			} else if g.hasInstr {
+				flags |= haveInstrs
+			}
+			words := c.arg1 < -128 || c.arg1 > 127 || c.arg2 < -128 || c.arg2 > 127
+			if c.flags&argsAreXY == 0 {
+				words = c.arg1 > 255 || c.arg2 > 255
+			}
+			if words {
+				flags |= argWords
+			}
+			put16(int(flags))
+			put16(int(c.index))
+			if words {
+				put16(c.arg1)
+				put16(c.arg2)
+			} else {
+				out = append(out, byte(c.arg1), byte(c.arg2))
+			}
+			var transform []float64
+			switch {
+			case c.flags&haveScale != 0:
+				transform = c.transform[:1]
+			case c.flags&haveXYScale != 0:
+				transform = []float64{c.transform[0], c.transform[3]}
+			case c.flags&haveTwoByTwo != 0:
+				transform = c.transform
+			}
+			for _, t := range transform {
+				put16(int(math.Round(t * 16384)))
+			}
+		}
+		if g.hasInstr {
+			put16(len(g.instructions))
+			out = append(out, g.instructions...)
+		}
+		return out
+	}
+
+	for _, e := range g.endPts {
+		put16(e)
+	}
+	put16(len(g.instructions))
+	out = append(out, g.instructions...)
+	var xs, ys []byte
+	px, py := 0, 0
+	for i := range g.flags {
+		flag := g.flags[i] & keptFlagsMask
+		flag, xs = appendCoord(xs, g.x[i]-px, flag, xShort, xSameOrPos)
+		flag, ys = appendCoord(ys, g.y[i]-py, flag, yShort, ySameOrPos)
+		out = append(out, flag)
+		px, py = g.x[i], g.y[i]
+	}
+	out = append(out, xs...)
+	return append(out, ys...)
+}
+
+// appendCoord appends the delta of a coordinate in its shortest form.
+func appendCoord(b []byte, d int, flag byte, short, sameOrPos byte) (byte, []byte) {
+	switch {
+	case d == 0:
+		return flag | sameOrPos, b
+	case d > 0 && d < 256:
+		return flag | short | sameOrPos, append(b, byte(d))
+	case d < 0 && d > -256:
+		return flag | short, append(b, byte(-d))
+	}
+	return flag, append(b, byte(uint16(d)>>8), byte(d))
+}
+
+// tupleScalar is the weight of a variation tuple at the coordinates.
+func tupleScalar(peak, start, end, coords []float64) float64 {
+	scalar := 1.0
+	for i, p := range peak {
+		c := coords[i]
+		if p == 0 || c == p {
+			continue
+		}
+		lo, hi := math.Min(0, p), math.Max(0, p)
+		if start != nil {
+			lo, hi = start[i], end[i]
+		}
+		if c == 0 || c < lo || c > hi {
+			return 0
+		}
+		if c < p {
+			scalar *= (c - lo) / (p - lo)
+		} else {
+			scalar *= (hi - c) / (hi - p)
+		}
+	}
+	return scalar
+}
+
+// readPoints reads packed point numbers, nil for all points.
+func readPoints(r *fontData) []int {
+	count := int(r.u8())
+	if count&0x80 != 0 {
+		count = (count&0x7F)<<8 | int(r.u8())
+	}
+	if count == 0 {
+		return nil
+	}
+	points := make([]int, 0, count)
+	last := 0
+	for len(points) < count && r.err == nil {
+		control := r.u8()
+		for n := int(control&0x7F) + 1; n > 0 && len(points) < count; n-- {
+			if control&0x80 != 0 {
+				last += int(r.u16())
+			} else {
+				last += int(r.u8())
+			}
+			points = append(points, last)
+		}
+	}
+	return points
+}
+
+// readDeltas reads count packed deltas.
+func readDeltas(r *fontData, count int) []float64 {
+	deltas := make([]float64, 0, count)
+	for len(deltas) < count && r.err == nil {
+		control := r.u8()
+		for n := int(control&0x3F) + 1; n > 0 && len(deltas) < count; n-- {
+			switch {
+			case control&0x80 != 0:
+				deltas = append(deltas, 0)
+			case control&0x40 != 0:
+				deltas = append(deltas, float64(r.i16()))
+			default:
+				deltas = append(deltas, float64(int8(r.u8())))
+			}
+		}
+	}
+	return deltas
+}
+
+// glyphVariations computes the deltas of the points of a glyph from
+// its gvar data. Points without deltas in a tuple are interpolated
+// for the outline of a simple glyph.
+func glyphVariations(data []byte, shared [][]float64, coords []float64, g *glyph, points int) (dx, dy []float64, err error) {
+	dx, dy = make([]float64, points), make([]float64, points)
+	if len(data) == 0 {
+		return dx, dy, nil
+	}
+	axes := len(coords)
+	r := &fontData{b: data}
+	count := r.u16()
+	serial := &fontData{b: data, pos: int(r.u16())}
+	var sharedPoints []int
+	if count&0x8000 != 0 {
+		sharedPoints = readPoints(serial)
+	}
+	readTuple := func() []float64 {
+		t := make([]float64, axes)
+		for i := range t {
+			t[i] = r.f2dot14()
+		}
+		return t
+	}
+	for i := 0; i < int(count&0x0FFF) && r.err == nil && serial.err == nil; i++ {
+		size, index := int(r.u16()), r.u16()
+		var peak, start, end []float64
+		if index&0x8000 != 0 {
+			peak = readTuple()
+		} else if int(index&0x0FFF) < len(shared) {
+			peak = shared[index&0x0FFF]
+		} else {
+			return nil, nil, errFontData
+		}
+		if index&0x4000 != 0 {
+			start, end = readTuple(), readTuple()
+		}
+		tuple := &fontData{b: serial.next(size)}
+		scalar := tupleScalar(peak, start, end, coords)
+		if scalar == 0 {
+			continue
+		}
+		pts := sharedPoints
+		if index&0x2000 != 0 {
+			pts = readPoints(tuple)
+		}
+		n := points
+		if pts != nil {
+			n = len(pts)
+		}
+		tx, ty := readDeltas(tuple, n), readDeltas(tuple, n)
+		if tuple.err != nil {
+			return nil, nil, tuple.err
+		}
+		if pts == nil {
+			for p := range tx {
+				dx[p] += scalar * tx[p]
+				dy[p] += scalar * ty[p]
+			}
+			continue
+		}
+		touched := make([]bool, points)
+		ux, uy := make([]float64, points), make([]float64, points)
+		for j, p := range pts {
+			if p < points {
+				touched[p] = true
+				ux[p], uy[p] = tx[j], ty[j]
+			}
+		}
+		if !g.composite {
+			interpolateUntouched(g, touched, ux, uy)
+		}
+		for p := range ux {
+			dx[p] += scalar * ux[p]
+			dy[p] += scalar * uy[p]
+		}
+	}
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return dx, dy, serial.err
+}
+
+// interpolateUntouched infers the deltas of the points of the
+// contours without deltas from their touched neighbours.
+func interpolateUntouched(g *glyph, touched []bool, dx, dy []float64) {
+	start := 0
+	for _, end := range g.endPts {
+		var known []int
+		for p := start; p <= end && p < len(touched); p++ {
+			if touched[p] {
+				known = append(known, p)
+			}
+		}
+		switch len(known) {
+		case 0:
+		case 1:
+			for p := start; p <= end; p++ {
+				dx[p], dy[p] = dx[known[0]], dy[known[0]]
+			}
+		default:
+			for k, p1 := range known {
+				p2 := known[(k+1)%len(known)]
+				for p := p1 + 1; ; p++ {
+					if p > end {
+						p = start
+					}
+					if p == p2 {
+						break
+					}
+					dx[p] = interpolateDelta(g.x[p], g.x[p1], g.x[p2], dx[p1], dx[p2])
+					dy[p] = interpolateDelta(g.y[p], g.y[p1], g.y[p2], dy[p1], dy[p2])
+				}
+			}
+		}
+		start = end + 1
+	}
+}
+
+// interpolateDelta interpolates the delta of the coordinate c
+// between the touched coordinates c1 and c2 with the deltas d1 and d2.
+func interpolateDelta(c, c1, c2 int, d1, d2 float64) float64 {
+	if c1 == c2 {
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	}
+	if c1 > c2 {
+		c1, c2, d1, d2 = c2, c1, d2, d1
+	}
+	switch {
+	case c <= c1:
+		return d1
+	case c >= c2:
+		return d2
+	}
+	return d1 + float64(c-c1)*(d2-d1)/float64(c2-c1)
+}
+
+// instantiate applies the glyph variations at the user coordinates
+// to the outlines and advance widths and drops the variation tables.
+func (f *sfnt) instantiate(axes []fontAxis, user []float64) error {
+	head, maxp, hhea := f.tables["head"], f.tables["maxp"], f.tables["hhea"]
+	glyf, loca, gvar := f.tables["glyf"], f.tables["loca"], f.tables["gvar"]
+	if glyf == nil || loca == nil {
+		return errors.New("only fonts with TrueType outlines are supported")
+	}
+	if len(head) < 54 || len(maxp) < 6 || len(hhea) < 36 {
+		return errFontData
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	coords := f.normalizeCoords(axes, user)
+
+	offsets := make([]int, numGlyphs+1)
+	lr := &fontData{b: loca}
+	for i := range offsets {
+		if binary.BigEndian.Uint16(head[50:]) == 0 {
+			offsets[i] = 2 * int(lr.u16())
+		} else {
+			offsets[i] = int(lr.u32())
+		}
+		if i > 0 && (offsets[i] < offsets[i-1] || offsets[i] > len(glyf)) {
+			return errFontData
+		}
+	}
+	if lr.err != nil {
+		return lr.err
+	}
+
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hr := &fontData{b: f.tables["hmtx"]}
+	advances, lsbs := make([]int, numGlyphs), make([]int, numGlyphs)
+	for i := range advances {
+		if i < numMetrics {
+			advances[i] = int(hr.u16())
+		} else if i > 0 {
+			advances[i] = advances[i-1]
+		}
+		lsbs[i] = int(hr.i16())
+	}
+	if hr.err != nil || numMetrics == 0 {
+		return errFontData
+	}
+
+	// The gvar header and its shared tuples
+	gr := &fontData{b: gvar}
+	var shared [][]float64
+	var dataOffsets []int
+	var dataStart int
+	if gvar != nil {
+		gr.u32()
+		axisCount, sharedCount := int(gr.u16()), int(gr.u16())
+		sharedOffset := int(gr.u32())
+		glyphCount, flags := int(gr.u16()), gr.u16()
+		dataStart = int(gr.u32())
+		if axisCount != len(axes) || glyphCount != numGlyphs {
+			return errFontData
+		}
+		dataOffsets = make([]int, glyphCount+1)
+		for i := range dataOffsets {
+			if flags&1 == 0 {
+				dataOffsets[i] = 2 * int(gr.u16())
+			} else {
+				dataOffsets[i] = int(gr.u32())
+			}
+		}
+		gr.pos = sharedOffset
+		for i := 0; i < sharedCount; i++ {
+			t := make([]float64, axisCount)
+			for j := range t {
+				t[j] = gr.f2dot14()
+			}
+			shared = append(shared, t)
+		}
+		if gr.err != nil {
+			return gr.err
+		}
+	}
+
+	glyphs := make([]*glyph, numGlyphs)
+	for i := range glyphs {
+		g, err := decodeGlyph(glyf[offsets[i]:offsets[i+1]])
+		if err != nil {
+			return fmt.Errorf("glyph %d: %v", i, err)
+		}
+		glyphs[i] = g
+		if dataOffsets == nil {
+			continue
+		}
+		from, to := dataStart+dataOffsets[i], dataStart+dataOffsets[i+1]
+		if from > to || to > len(gvar) {
+			return errFontData
+		}
+		points := len(g.x)
+		if g.composite {
+			points = len(g.components)
+		}
+		dx, dy, err := glyphVariations(gvar[from:to], shared, coords, g, points+4)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %v", i, err)
+		}
+		// The phantom points give the origin and the advance.
+		origin := int(math.Round(dx[points]))
+		advances[i] += int(math.Round(dx[points+1])) - origin
+		for p := 0; p < points; p++ {
+			if g.composite {
+				if g.components[p].flags&argsAreXY != 0 {
+					g.components[p].arg1 += int(math.Round(dx[p])) - origin
+					g.components[p].arg2 += int(math.Round(dy[p]))
+				}
+				continue
+			}
+			g.x[p] = int(math.Round(float64(g.x[p])+dx[p])) - origin
+			g.y[p] = int(math.Round(float64(g.y[p]) + dy[p]))
+		}
+	}
+
+	// The boxes of the composites need those of their components.
+	done := make([]bool, numGlyphs)
+	var box func(i, depth int) [4]int
+	box = func(i, depth int) [4]int {
+		g := glyphs[i]
+		if done[i] || depth > 16 {
+			return g.bbox
+		}
+		done[i] = true
+		if !g.composite {
+			if len(g.x) > 0 {
+				g.bbox = [4]int{g.x[0], g.y[0], g.x[0], g.y[0]}
+			}
+			for p := range g.x {
+				g.bbox = [4]int{minInt(g.bbox[0], g.x[p]), minInt(g.bbox[1], g.y[p]), maxInt(g.bbox[2], g.x[p]), maxInt(g.bbox[3], g.y[p])}
+			}
+			return g.bbox
+		}
+		first := true
+		for _, c := range g.components {
+			if int(c.index) >= numGlyphs {
+				continue
+			}
+			b := box(int(c.index), depth+1)
+			t := c.transform
+			if t == nil {
+				t = []float64{1, 0, 0, 1}
+			}
+			dx, dy := 0, 0
+			if c.flags&argsAreXY != 0 {
+				dx, dy = c.arg1, c.arg2
+			}
+			for _, corner := range [][2]int{{b[0], b[1]}, {b[0], b[3]}, {b[2], b[1]}, {b[2], b[3]}} {
+				x := int(math.Round(float64(corner[0])*t[0]+float64(corner[1])*t[2])) + dx
+				y := int(math.Round(float64(corner[0])*t[1]+float64(corner[1])*t[3])) + dy
+				if first {
+					g.bbox = [4]int{x, y, x, y}
+					first = false
+				}
+				g.bbox = [4]int{minInt(g.bbox[0], x), minInt(g.bbox[1], y), maxInt(g.bbox[2], x), maxInt(g.bbox[3], y)}
+			}
+		}
+		return g.bbox
+	}
+
+	var newGlyf, newLoca, newHmtx []byte
+	fontBox := [4]int{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}
+	maxAdvance := 0
+	for i, g := range glyphs {
+		b := box(i, 0)
+		data := g.encode()
+		if len(data) > 0 {
+			lsbs[i] = b[0]
+			fontBox = [4]int{minInt(fontBox[0], b[0]), minInt(fontBox[1], b[1]), maxInt(fontBox[2], b[2]), maxInt(fontBox[3], b[3])}
+		}
+		newLoca = appendUint32(newLoca, uint32(len(newGlyf)))
+		newGlyf = append(newGlyf, data...)
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+		advances[i] = maxInt(advances[i], 0)
+		maxAdvance = maxInt(maxAdvance, advances[i])
+		newHmtx = append(newHmtx, byte(advances[i]>>8), byte(advances[i]), byte(lsbs[i]>>8), byte(lsbs[i]))
+	}
+	newLoca = appendUint32(newLoca, uint32(len(newGlyf)))
+
+	head = append([]byte(nil), head...)
+	if fontBox[0] <= fontBox[2] {
+		for i, v := range fontBox {
+			binary.BigEndian.PutUint16(head[36+2*i:], uint16(int16(v)))
+		}
+	}
+	binary.BigEndian.PutUint16(head[50:], 1)
+	hhea = append([]byte(nil), hhea...)
+	binary.BigEndian.PutUint16(hhea[10:], uint16(maxAdvance))
+	binary.BigEndian.PutUint16(hhea[34:], uint16(numGlyphs))
+	f.tables["head"], f.tables["hhea"] = head, hhea
+	f.tables["glyf"], f.tables["loca"], f.tables["hmtx"] = newGlyf, newLoca, newHmtx
+
+	// The weight of the instance for the font descriptor
+	if os2 := f.tables["OS/2"]; len(os2) >= 6 {
+		for i, a := range axes {
+			if a.tag == "wght" {
+				os2 = append([]byte(nil), os2...)
+				binary.BigEndian.PutUint16(os2[4:], uint16(math.Max(1, math.Min(1000, math.Round(user[i])))))
+				f.tables["OS/2"] = os2
+			}
+		}
+	}
+	for _, tag := range droppedTables {
+		delete(f.tables, tag)
+	}
+	return nil
+}
+
+// appendUint32 appends the big-endian value.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// minInt returns the smaller of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// styleName simplifies a style or instance name for comparison,
+// e.g. "Bold Italic" to "bolditalic".
+func styleName(name string) string {
+	return strings.ToLower(strings.Replace(name, " ", "", -1))
+}
+
+// fontInstance writes the named instance of the style of a variable
+// font into the directory, e.g. its Bold instance for "bold". ok is
+// false if the font has no such instance.
+func (g *Calendar) fontInstance(fontFile, style, dir string) (string, bool) {
+	if !isVariableFont(fontFile) {
+		return "", false
+	}
+	data, err := ioutil.ReadFile(fontFile)
+	if err != nil {
+		g.warnf("font", "Error reading %s: %v", fontFile, err)
+		return "", false
+	}
+	font, err := parseSfnt(data)
+	if err != nil {
+		g.warnf("font", "Error reading %s: %v", fontFile, err)
+		return "", false
+	}
+	axes, instances, err := font.variations()
+	if err != nil {
+		g.warnf("font", "Error reading the instances of %s: %v", fontFile, err)
+		return "", false
+	}
+	for _, in := range instances {
+		if styleName(in.name) != styleName(style) {
+			continue
+		}
+		if err := font.instantiate(axes, in.coords); err != nil {
+			g.warnf("font", "Cannot use the %s instance of %s: %v", in.name, fontFile, err)
+			return "", false
+		}
+		// Brackets as in Roboto[wght].ttf are no PDF name.
+		base := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+				return r
+			}
+			return -1
+		}, strings.TrimSuffix(filepath.Base(fontFile), filepath.Ext(fontFile)))
+		file := filepath.Join(dir, base+"-"+strings.Replace(in.name, " ", "", -1)+".ttf")
+		if err := ioutil.WriteFile(file, font.bytes(), 0600); err != nil {
+			g.warnf("font", "Error writing %s: %v", file, err)
+			return "", false
+		}
+		return file, true
+	}
+	return "", false
+}
@@ -0,0 +1,50 @@
+package gocal
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-03-10
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// wallbound.go
+//
+// Print preparation of wall calendars bound at the top with wire-O:
+// the photo of a month is on the back of the sheet before, upside
+// down, so that it hangs above the grid when that sheet is turned
+// up. The hanger hole is marked for punching.
+//
+
+import (
+	"github.com/phpdave11/gofpdf"
+)
+
+// HANGERHOLERADIUS is the radius of the hanger hole in mm.
+const HANGERHOLERADIUS = 3.0
+
+// HANGERHOLEY is the distance of the center of the hanger hole from
+// the top edge of the sheet.
+const HANGERHOLEY = 5.0
+
+// drawHangerMark marks the hanger hole at the top center of the page
+// with a dashed circle and a cross.
+func drawHangerMark(pdf *gofpdf.Fpdf, width float64) {
+	dr, dg, db := pdf.GetDrawColor()
+	lw := pdf.GetLineWidth()
+	pdf.SetDrawColor(DARKGREY, DARKGREY, DARKGREY)
+	pdf.SetLineWidth(0.2)
+	x := width / 2
+	pdf.SetDashPattern([]float64{0.8, 0.6}, 0)
+	pdf.Circle(x, HANGERHOLEY, HANGERHOLERADIUS, "D")
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.Line(x-HANGERHOLERADIUS/2, HANGERHOLEY, x+HANGERHOLERADIUS/2, HANGERHOLEY)
+	pdf.Line(x, HANGERHOLEY-HANGERHOLERADIUS/2, x, HANGERHOLEY+HANGERHOLERADIUS/2)
+	pdf.SetDrawColor(dr, dg, db)
+	pdf.SetLineWidth(lw)
+}
+
+// upsideDown draws the content of the page turned by 180 degrees.
+func upsideDown(pdf *gofpdf.Fpdf, width, height float64, draw func()) {
+	pdf.TransformBegin()
+	pdf.TransformRotate(180, width/2, height/2)
+	draw()
+	pdf.TransformEnd()
+}
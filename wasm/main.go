@@ -0,0 +1,77 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-04-13
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+// The WebAssembly build of Gocal for static web pages: it defines
+// the JavaScript function gocalGenerate, which returns the PDF of
+// the calendar as Uint8Array. See index.html.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/StefanSchroeder/Gocal"
+	"syscall/js"
+	"time"
+)
+
+// option returns the option of the JavaScript object, or def.
+func option(o js.Value, name string, def string) string {
+	if v := o.Get(name); v.Type() == js.TypeString && v.String() != "" {
+		return v.String()
+	}
+	return def
+}
+
+// number returns the number option of the JavaScript object, or def.
+func number(o js.Value, name string, def int) int {
+	if v := o.Get(name); v.Type() == js.TypeNumber {
+		return v.Int()
+	}
+	return def
+}
+
+// generate creates the calendar of the options {year, begin, end,
+// layout, lang, font, paper, orientation, config}, where config is
+// the text of a configuration file. It returns {pdf} or {error}.
+func generate(this js.Value, args []js.Value) interface{} {
+	o := js.Global().Get("Object").New()
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		o = args[0]
+	}
+	layout := option(o, "layout", "monthly")
+	create, ok := gocal.Layouts[layout]
+	if !ok {
+		return map[string]interface{}{"error": fmt.Sprintf("unknown layout '%s', use %s", layout, gocal.LayoutNames())}
+	}
+	g := gocal.New(number(o, "begin", 1), number(o, "end", 12), number(o, "year", time.Now().Year()+1))
+	g.SetLocale(option(o, "lang", "en_US"))
+	g.SetFont(option(o, "font", "serif"))
+	g.SetPaperformat(option(o, "paper", "A4"))
+	g.SetOrientation(option(o, "orientation", "L"))
+	if config := option(o, "config", ""); config != "" {
+		if err := gocal.CheckConfig([]byte(config)); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("invalid config: %v", err)}
+		}
+		g.AddFile("config.xml", []byte(config))
+		g.SetConfig("config.xml")
+	}
+	var buf bytes.Buffer
+	g.SetWriter(&buf)
+	create(g, "calendar.pdf")
+	if buf.Len() == 0 {
+		return map[string]interface{}{"error": "no PDF was generated"}
+	}
+	pdf := js.Global().Get("Uint8Array").New(buf.Len())
+	js.CopyBytesToJS(pdf, buf.Bytes())
+	return map[string]interface{}{"pdf": pdf}
+}
+
+func main() {
+	js.Global().Set("gocalGenerate", js.FuncOf(generate))
+	select {}
+}
@@ -0,0 +1,22 @@
+//go:build ignore
+// +build ignore
+
+// Copyright (c) 2014 Stefan Schroeder, NY, 2014-04-13
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+// mkfonts converts the builtin fonts for the WebAssembly build,
+// which embeds them: go run wasm/mkfonts.go
+package main
+
+import (
+	"github.com/StefanSchroeder/Gocal"
+	"log"
+)
+
+func main() {
+	if err := gocal.WriteFontDefinitions("fonts/wasm"); err != nil {
+		log.Fatal(err)
+	}
+}